@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mbsof31/go-quiz/internals"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	attempts "github.com/mbsof31/go-quiz/views/attempts"
+)
+
+func RegisterAttemptRoutes(r chi.Router) {
+	r.Get("/{attemptID}", attemptHandler)
+	r.Post("/{attemptID}", attemptSubmitHandler)
+	r.Post("/{attemptID}/questions/{questionID}/check", answerCheckHandler)
+	r.Get("/{attemptID}/questions/{questionID}/recording", attemptRecordingHandler)
+	r.Get("/{attemptID}/questions/{questionID}/captions", captionsHandler)
+}
+
+func attemptStartHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	quizID, err := urlID(r, "quizID", "quiz")
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	a, err := ctx.Store.StartAttempt(quizID, ctx.UserID)
+	if errors.Is(err, quiz.ErrAttemptLimitReached) || errors.Is(err, quiz.ErrAttemptCooldown) || errors.Is(err, quiz.ErrQuizClosed) {
+		renderError(w, r, http.StatusForbidden, err)
+		return
+	}
+	if errors.Is(err, quiz.ErrAttemptInProgress) {
+		open, err := ctx.Store.ListAttempts(quizID, ctx.UserID)
+		if err != nil || len(open) == 0 {
+			renderError(w, r, http.StatusInternalServerError, errors.New("cannot find the attempt in progress"))
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/attempts/%d", open[len(open)-1].ID), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		renderLookupError(w, r, notFound(err, "quiz", quizID))
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/attempts/%d", a.ID), http.StatusSeeOther)
+}
+
+// findOwnAttempt loads the attempt named in the URL if it belongs to the
+// current user.
+func findOwnAttempt(r *http.Request) (*quiz.Attempt, error) {
+	ctx := internals.GetAppContext(r)
+	ID, err := urlID(r, "attemptID", "attempt")
+	if err != nil {
+		return nil, err
+	}
+	a, err := ctx.Store.FindAttemptByID(ID)
+	if err != nil {
+		return nil, notFound(err, "attempt", ID)
+	}
+	if a.UserID != ctx.UserID {
+		return nil, notFoundError{"attempt", ID}
+	}
+	return a, nil
+}
+
+func attemptHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	a, err := findOwnAttempt(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	q, err := ctx.Store.FindQuizByID(a.QuizID)
+	if err != nil {
+		renderLookupError(w, r, notFound(err, "quiz", a.QuizID))
+		return
+	}
+
+	if !a.Submitted() {
+		render(w, r, attempts.TakeQuizPage(q, a, q.RevealFeedback(a, time.Now())))
+		return
+	}
+	summary, err := attemptSummary(ctx, q, a)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	render(w, r, attempts.AttemptResultPage(q, a, summary))
+}
+
+func attemptSummary(ctx *internals.AppContext, q *quiz.Quiz, a *quiz.Attempt) (attempts.AttemptSummary, error) {
+	prior, err := ctx.Store.ListAttempts(q.ID, ctx.UserID)
+	if err != nil {
+		return attempts.AttemptSummary{}, err
+	}
+	now := time.Now()
+	summary := attempts.AttemptSummary{AttemptsUsed: len(prior), Reveal: q.RevealFeedback(a, now)}
+	summary.Official, summary.OfficialMax, _ = quiz.OfficialScore(q.GradingPolicy, prior)
+	summary.Provisional = quiz.Provisional(q.GradingPolicy, prior)
+	if err := quiz.CanStartAttempt(q, prior, now); err != nil {
+		summary.RetakeMessage = err.Error()
+	} else {
+		summary.CanRetake = true
+	}
+	return summary, nil
+}
+
+func attemptSubmitHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	a, err := findOwnAttempt(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	q, err := ctx.Store.FindQuizByID(a.QuizID)
+	if err != nil {
+		renderLookupError(w, r, notFound(err, "quiz", a.QuizID))
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	answers, err := parseAnswers(q, r.PostForm)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := quiz.RunCode(r.Context(), ctx.Runner, q, answers); err != nil {
+		renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	_, err = ctx.Store.SubmitAttempt(a.ID, answers)
+	if errors.Is(err, quiz.ErrQuizClosed) {
+		renderError(w, r, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusConflict, err)
+		return
+	}
+	if q.Survey {
+		// Survey responses are detached from the user on submit, so there is
+		// no result page to redirect to.
+		render(w, r, attempts.SurveyThanksPage(q))
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/attempts/%d", a.ID), http.StatusSeeOther)
+}
+
+// answerCheckHandler grades the answer to one question of an attempt at a
+// quiz that reveals feedback immediately, and answers with its feedback. The
+// checked answer can no longer be changed.
+func answerCheckHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	a, err := findOwnAttempt(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	q, err := ctx.Store.FindQuizByID(a.QuizID)
+	if err != nil {
+		renderLookupError(w, r, notFound(err, "quiz", a.QuizID))
+		return
+	}
+	var question *quiz.Question
+	for i := range q.Questions {
+		if fmt.Sprint(q.Questions[i].ID) == chi.URLParam(r, "questionID") {
+			question = &q.Questions[i]
+		}
+	}
+	if question == nil {
+		renderError(w, r, http.StatusNotFound, fmt.Errorf("cannot find the question with the id of: %s", chi.URLParam(r, "questionID")))
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	answers, err := parseAnswers(&quiz.Quiz{Questions: []quiz.Question{*question}}, r.PostForm)
+	if err != nil {
+		renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	answer := quiz.Answer{QuestionID: question.ID}
+	if len(answers) > 0 {
+		answer = answers[0]
+	}
+	checked, err := ctx.Store.CheckAnswer(a.ID, answer)
+	if errors.Is(err, quiz.ErrQuizClosed) {
+		renderError(w, r, http.StatusForbidden, err)
+		return
+	}
+	if errors.Is(err, quiz.ErrAttemptSubmitted) || errors.Is(err, quiz.ErrAnswerChecked) {
+		renderError(w, r, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	render(w, r, attempts.CheckedAnswer(*question, checked))
+}
+
+// parseAnswers reads the "q-<questionID>" fields posted by the take page.
+func parseAnswers(q *quiz.Quiz, form url.Values) ([]quiz.Answer, error) {
+	var answers []quiz.Answer
+	for _, question := range q.Questions {
+		values, found := form[fmt.Sprintf("q-%d", question.ID)]
+		if !found {
+			continue
+		}
+		answer := quiz.Answer{QuestionID: question.ID}
+		switch {
+		case question.Type == quiz.TypeOrdering || question.Type == quiz.TypeMatching:
+			items, err := parseItems(values)
+			if err != nil {
+				return nil, fmt.Errorf("invalid items for question %d: %w", question.ID, err)
+			}
+			answer.Items = items
+		case question.Type == quiz.TypeCloze:
+			answer.Blanks = values
+		case question.Type == quiz.TypeLikert:
+			rating, err := strconv.Atoi(values[0])
+			if err != nil || rating < 1 || rating > len(question.ScaleLabels()) {
+				return nil, fmt.Errorf("invalid rating %q for question %d", values[0], question.ID)
+			}
+			answer.Rating = rating
+		case question.Type == quiz.TypeCode:
+			answer.Text = values[0]
+		case !question.HasChoices():
+			answer.Text = strings.TrimSpace(values[0])
+		default:
+			for _, v := range values {
+				choiceID, err := strconv.Atoi(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid choice %q for question %d", v, question.ID)
+				}
+				answer.ChoiceIDs = append(answer.ChoiceIDs, uint(choiceID))
+			}
+		}
+		answers = append(answers, answer)
+	}
+	return answers, nil
+}
+
+// parseItems reads item indexes, where a blank value means no pick.
+func parseItems(values []string) (quiz.IntList, error) {
+	items := make(quiz.IntList, 0, len(values))
+	for _, v := range values {
+		if v == "" {
+			items = append(items, -1)
+			continue
+		}
+		item, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
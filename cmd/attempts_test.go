@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func post(handler http.Handler, target string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json, text/html")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// visitor returns the cookie of a new anonymous visitor.
+func visitor(t *testing.T, router http.Handler) *http.Cookie {
+	t.Helper()
+	cookies := get(router, "/", "").Result().Cookies()
+	require.Len(t, cookies, 1)
+	return cookies[0]
+}
+
+func TestAnswerCheckHandler(t *testing.T) {
+	router, store := newTestRouter(t)
+	require.NoError(t, store.Store(quiz.Quiz{Name: "Geography", RevealPolicy: quiz.RevealImmediately, Questions: []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "Capital of France?", Explanation: "Paris has been the capital since 987.",
+			Choices: []quiz.Choice{{Content: "Paris", IsCorrect: true, Feedback: "Well done"}, {Content: "Lyon", Feedback: "Lyon is the third city"}}},
+		{Type: quiz.TypeShortText, Content: "Capital of Italy?", Text: &quiz.TextSpec{Accepted: []string{"Rome"}}},
+	}}))
+	learner := visitor(t, router)
+
+	rec := post(router, "/quizzes/1/attempts", nil, learner)
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	require.Equal(t, "/attempts/1", rec.Header().Get("Location"))
+	rec = get(router, "/attempts/1", "", learner)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "/attempts/1/questions/1/check")
+	for _, secret := range []string{"Well done", "Lyon is the third city", "capital since 987"} {
+		assert.NotContains(t, rec.Body.String(), secret, "nothing is revealed before an answer is checked")
+	}
+
+	rec = post(router, "/attempts/1/questions/1/check", url.Values{"q-1": {"2"}}, learner)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Checked: 0 / 1")
+	assert.Contains(t, rec.Body.String(), "Lyon is the third city")
+	assert.NotContains(t, rec.Body.String(), "Well done", "only the feedback of the picked choice")
+	assert.Contains(t, rec.Body.String(), "capital since 987")
+
+	rec = post(router, "/attempts/1/questions/1/check", url.Values{"q-1": {"1"}}, learner)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.JSONEq(t, `{"status": 409, "error": "answer has already been checked"}`, rec.Body.String())
+	rec = post(router, "/attempts/1/questions/2/check", nil, learner)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, http.StatusNotFound, post(router, "/attempts/1/questions/1/check", url.Values{"q-1": {"1"}}, visitor(t, router)).Code)
+
+	rec = get(router, "/attempts/1", "", learner)
+	assert.Contains(t, rec.Body.String(), "<fieldset disabled>")
+	assert.Contains(t, rec.Body.String(), "Lyon is the third city")
+
+	rec = post(router, "/attempts/1", url.Values{"q-1": {"1"}, "q-2": {"Rome"}}, learner)
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	a, err := store.FindAttemptByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, a.Score, "the checked answer stands")
+}
+
+func TestAttemptSubmitHandler_UntouchedOrdering(t *testing.T) {
+	router, store := newTestRouter(t)
+	require.NoError(t, store.Store(quiz.Quiz{Name: "History", Questions: []quiz.Question{
+		{Type: quiz.TypeOrdering, Content: "Order the kings", PenaltyFraction: 1,
+			Ordering: &quiz.OrderingSpec{Items: []string{"Clovis", "Charlemagne", "Hugh Capet"}}},
+		{Type: quiz.TypeSingleChoice, Content: "First king of the Franks?",
+			Choices: []quiz.Choice{{Content: "Clovis", IsCorrect: true}, {Content: "Pepin"}}},
+	}}))
+	learner := visitor(t, router)
+
+	require.Equal(t, http.StatusSeeOther, post(router, "/quizzes/1/attempts", nil, learner).Code)
+	rec := get(router, "/attempts/1", "", learner)
+	assert.Contains(t, rec.Body.String(), `:disabled="!answered"`, "the shuffled order is not posted until the learner answers")
+	assert.Contains(t, rec.Body.String(), "Keep this order")
+
+	// What the browser posts when the ordering question was not touched.
+	rec = post(router, "/attempts/1", url.Values{"q-2": {"1"}}, learner)
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	a, err := store.FindAttemptByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, a.Score, "no penalty for the unanswered question")
+	require.Len(t, a.Answers, 1)
+	assert.Equal(t, uint(2), a.Answers[0].QuestionID)
+}
+
+func TestAttemptSubmitHandler_Closed(t *testing.T) {
+	router, store := newTestRouter(t)
+	require.NoError(t, store.Store(quiz.Quiz{Name: "Timed", Questions: []quiz.Question{
+		{Type: quiz.TypeShortText, Content: "Capital of Italy?", Text: &quiz.TextSpec{Accepted: []string{"Rome"}}},
+	}}))
+	learner := visitor(t, router)
+	require.Equal(t, http.StatusSeeOther, post(router, "/quizzes/1/attempts", nil, learner).Code)
+
+	require.NoError(t, store.DB.Model(&quiz.Quiz{}).Where("id = ?", 1).Update("closes_at", time.Now().Add(-time.Minute)).Error)
+	rec := post(router, "/attempts/1", url.Values{"q-1": {"Rome"}}, learner)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), quiz.ErrQuizClosed.Error())
+}
+
+func TestAttemptHandler_CodeOutput(t *testing.T) {
+	router, store := newTestRouter(t)
+	require.NoError(t, store.Store(quiz.Quiz{Name: "Go", RevealPolicy: quiz.RevealNever, Questions: []quiz.Question{
+		{Type: quiz.TypeCode, Content: "Write Sum", Explanation: "Add the operands.",
+			Code: &quiz.CodeSpec{Tests: "package sum\n\nimport \"testing\"\n\nfunc TestSum(t *testing.T) {}\n"}},
+	}}))
+	learner := visitor(t, router)
+	require.Equal(t, http.StatusSeeOther, post(router, "/quizzes/1/attempts", nil, learner).Code)
+	_, err := store.SubmitAttempt(1, []quiz.Answer{{QuestionID: 1, Text: "package sum", TestsPassed: 0, TestsTotal: 1,
+		Output: "solution_test.go:9: Sum(-1, -2) should be -3"}})
+	require.NoError(t, err)
+
+	rec := get(router, "/attempts/1", "", learner)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Tests passed: 0 of 1")
+	assert.NotContains(t, rec.Body.String(), "should be -3", "the output quotes the hidden tests")
+	assert.NotContains(t, rec.Body.String(), "Add the operands.")
+
+	require.NoError(t, store.DB.Model(&quiz.Quiz{}).Where("id = ?", 1).Update("reveal_policy", quiz.RevealAfterSubmitted).Error)
+	rec = get(router, "/attempts/1", "", learner)
+	assert.Contains(t, rec.Body.String(), "should be -3")
+	assert.Contains(t, rec.Body.String(), "Add the operands.")
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// runBackup copies the database, with attempts, print runs and users, and
+// writes a JSON export of the quizzes with their media next to it:
+//
+//	quiz backup [-o dir]
+//
+// The copy restores everything but the media; the export can be imported
+// into any installation with quiz import.
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dir := fs.String("o", "backups", "directory to write the backup to")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: quiz backup [-o dir]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+	name := filepath.Join(*dir, "quiz-"+time.Now().Format("20060102-150405"))
+	if err := store.Backup(name + ".db"); err != nil {
+		return err
+	}
+	e, err := store.Export(context.Background())
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := quiz.WriteExport(&buf, e); err != nil {
+		return err
+	}
+	if err := os.WriteFile(name+".json", buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("%s.db: copied the database\n", name)
+	fmt.Printf("%s.json: exported %d quizzes with %d media\n", name, len(e.Quizzes), len(e.Media))
+	return nil
+}
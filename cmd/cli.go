@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/mbsof31/go-quiz/internals/config"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"gorm.io/gorm/logger"
+)
+
+// cfg is the configuration shared by every command. Its flags come before
+// the name of the command:
+//
+//	quiz [-config file] [-db file] [-addr :4000] ... command [arguments]
+var cfg *config.Config
+
+// command is a subcommand of the quiz tool. Its failure completes "Error
+// ..." when run returns an error.
+type command struct {
+	name    string
+	summary string
+	failure string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"serve", "start the web server", "serving", runServe},
+	{"config", "print the effective configuration", "printing the configuration", runConfig},
+	{"migrate", "create or update the database tables and move old media to storage", "migrating the database", runMigrate},
+	{"seed", "add sample quizzes to an empty database", "seeding the database", runSeed},
+	{"import", "import quiz files or JSON exports", "importing quizzes", runImport},
+	{"export", "export quizzes to a file", "exporting quiz", runExport},
+	{"validate", "check quiz files, or the stored quizzes, without importing", "validating quizzes", runValidate},
+	{"user", "manage users: user create", "managing users", runUser},
+	{"backup", "copy the database and a JSON export of the quizzes", "backing up", runBackup},
+	{"print", "print a quiz, its answer key or bubble sheet as a PDF", "printing quiz", runPrint},
+	{"scan", "grade scanned bubble sheets", "grading sheets", runScan},
+}
+
+func main() {
+	fs := flag.NewFlagSet("quiz", flag.ExitOnError)
+	loader := config.NewLoader(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: quiz [-config file] [flags] command [arguments]")
+		fmt.Fprintln(fs.Output(), "\ncommands:")
+		for _, c := range commands {
+			fmt.Fprintf(fs.Output(), "  %-9s %s\n", c.name, c.summary)
+		}
+		fmt.Fprintln(fs.Output(), "\nWithout a command the web server is started. Flags override the environment")
+		fmt.Fprintln(fs.Output(), "variables named with them, which override the config file:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(os.Args[1:])
+
+	name, args := "serve", fs.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		fs.SetOutput(os.Stdout)
+		fs.Usage()
+		return
+	}
+	var err error
+	if cfg, err = loader.Load(); err != nil {
+		log.Fatalf("Error loading configuration: %s", err.Error())
+	}
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(args); err != nil {
+				log.Fatalf("Error %s: %s", c.failure, err.Error())
+			}
+			return
+		}
+	}
+	fmt.Fprintf(fs.Output(), "quiz: unknown command %q\n", name)
+	fs.Usage()
+	os.Exit(2)
+}
+
+// runConfig prints the effective configuration and where each setting
+// comes from:
+//
+//	quiz config
+func runConfig(args []string) error {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: quiz config")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	cfg.Print(os.Stdout)
+	return nil
+}
+
+// openStore opens the configured database with its media storage.
+func openStore() (*quiz.SQLiteStore, error) {
+	store, err := quiz.NewSQLiteStore(cfg.DB.DSN)
+	if err != nil {
+		return nil, err
+	}
+	store.DB.Logger = store.DB.Logger.LogMode(sqlLogLevel())
+	store.Blobs = blobStore()
+	return store, nil
+}
+
+// sqlLogLevel returns what the database logs at the configured level: every
+// statement when debugging, errors and slow statements otherwise.
+func sqlLogLevel() logger.LogLevel {
+	switch cfg.LogLevel {
+	case "debug":
+		return logger.Info
+	case "error":
+		return logger.Error
+	}
+	return logger.Warn
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	quizzes "github.com/mbsof31/go-quiz/views/quizzes"
+)
+
+// quizNewSaveHandler creates a quiz, owned by the current user, from the
+// quiz form.
+func quizNewSaveHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	saveQuiz(w, r, &quiz.Quiz{OwnerID: ctx.UserID})
+}
+
+// quizEditSaveHandler saves the quiz form of a quiz the current user may
+// edit.
+func quizEditSaveHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := findOwnQuiz(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	saveQuiz(w, r, q)
+}
+
+// saveQuiz applies the posted quiz form to q and stores it. When the form
+// does not parse or the quiz is invalid, the form is shown again with the
+// problems and what the author typed.
+func saveQuiz(w http.ResponseWriter, r *http.Request, q *quiz.Quiz) {
+	ctx := internals.GetAppContext(r)
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	err := parseQuizForm(r.PostForm, q)
+	if err == nil {
+		err = ctx.Store.SaveQuiz(q)
+	}
+	if err != nil {
+		renderStatus(w, r, http.StatusUnprocessableEntity, quizzes.QuizFormPage(*q, err.Error(), quizzes.PastedQuestions{}))
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/quizzes/%d", q.ID), http.StatusSeeOther)
+}
+
+var gradingPolicies = map[quiz.GradingPolicy]bool{quiz.GradeHighest: true, quiz.GradeLatest: true, quiz.GradeAverage: true, quiz.GradeFirst: true}
+
+var revealPolicies = map[quiz.RevealPolicy]bool{quiz.RevealAfterSubmitted: true, quiz.RevealImmediately: true, quiz.RevealAfterClose: true, quiz.RevealNever: true}
+
+// parseQuizForm applies the fields of the quiz form to q. Questions and
+// choices are matched to the stored ones by ID, so fields the form does not
+// show are kept; those left without content are removed. It returns all the
+// problems found at once.
+func parseQuizForm(form url.Values, q *quiz.Quiz) error {
+	var errs []error
+	q.Name = strings.TrimSpace(form.Get("name"))
+	q.Description = textareaValue(form.Get("description"))
+	q.Survey = form.Get("survey") != ""
+	q.MaxAttempts = 0
+	if v := strings.TrimSpace(form.Get("max_attempts")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			errs = append(errs, fmt.Errorf("max attempts %q is not a whole number of zero or more", v))
+		}
+		q.MaxAttempts = n
+	}
+	q.AttemptCooldown = 0
+	if v := strings.TrimSpace(form.Get("attempt_cooldown")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("cooldown %q is not a duration such as 30m or 2h", v))
+		}
+		q.AttemptCooldown = d
+	}
+	q.SubmitGrace = 0
+	if v := strings.TrimSpace(form.Get("submit_grace")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("late submission period %q is not a duration such as 5m", v))
+		}
+		q.SubmitGrace = d
+	}
+	q.GradingPolicy = quiz.GradingPolicy(form.Get("grading_policy"))
+	if q.GradingPolicy != "" && !gradingPolicies[q.GradingPolicy] {
+		errs = append(errs, fmt.Errorf("unknown grading policy %q", q.GradingPolicy))
+	}
+	q.RevealPolicy = quiz.RevealPolicy(form.Get("reveal_policy"))
+	if q.RevealPolicy != "" && !revealPolicies[q.RevealPolicy] {
+		errs = append(errs, fmt.Errorf("unknown reveal policy %q", q.RevealPolicy))
+	}
+	q.ClosesAt = nil
+	if v := strings.TrimSpace(form.Get("closes_at")); v != "" {
+		t, err := time.ParseInLocation("2006-01-02T15:04", v, time.Local)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("closing time %q is not a date and time", v))
+		} else {
+			q.ClosesAt = &t
+		}
+	}
+
+	stored := make(map[uint]quiz.Question, len(q.Questions))
+	for _, question := range q.Questions {
+		stored[question.ID] = question
+	}
+	var questions []quiz.Question
+	for i := 0; form.Has(fmt.Sprintf("questions.%d.type", i)); i++ {
+		prefix := fmt.Sprintf("questions.%d.", i)
+		if strings.TrimSpace(form.Get(prefix+"content")) == "" {
+			continue
+		}
+		var question quiz.Question
+		if id, err := strconv.ParseUint(form.Get(prefix+"id"), 10, 0); err == nil && id != 0 {
+			question = stored[uint(id)]
+		}
+		if err := parseQuestionForm(form, prefix, &question); err != nil {
+			errs = append(errs, fmt.Errorf("question %d: %w", len(questions)+1, err))
+		}
+		questions = append(questions, question)
+	}
+	q.Questions = questions
+	return errors.Join(errs...)
+}
+
+// parseQuestionForm applies the fields of one question editor, named with
+// prefix, to question. Only the answers of the chosen type are kept.
+func parseQuestionForm(form url.Values, prefix string, question *quiz.Question) error {
+	var errs []error
+	question.Content = strings.TrimSpace(form.Get(prefix + "content"))
+	question.Type = form.Get(prefix + "type")
+	question.Explanation = strings.TrimSpace(textareaValue(form.Get(prefix + "explanation")))
+	question.Points = 0
+	if v := strings.TrimSpace(form.Get(prefix + "points")); v != "" {
+		points, err := strconv.ParseFloat(v, 64)
+		if err != nil || points < 0 {
+			errs = append(errs, fmt.Errorf("points %q is not a number of zero or more", v))
+		}
+		question.Points = points
+	}
+	var err error
+	if question.Penalty, err = parseFloatField(form, prefix, "penalty", "penalty"); err != nil {
+		errs = append(errs, err)
+	}
+	if question.PenaltyFraction, err = parseFloatField(form, prefix, "penalty_fraction", "penalty fraction"); err != nil {
+		errs = append(errs, err)
+	}
+
+	text, numeric, ordering, matching := question.Text, question.Numeric, question.Ordering, question.Matching
+	essay, likert, code := question.Essay, question.Likert, question.Code
+	question.Text, question.Numeric, question.Ordering, question.Matching = nil, nil, nil, nil
+	question.Essay, question.Likert, question.Code = nil, nil, nil
+	choices := question.Choices
+	question.Choices = nil
+	switch question.Type {
+	case quiz.TypeSingleChoice, quiz.TypeMultiChoice:
+		question.Choices = parseChoices(form, prefix, choices)
+	case quiz.TypeShortText:
+		if text == nil {
+			text = &quiz.TextSpec{}
+		}
+		text.Accepted = lines(form.Get(prefix + "text.accepted"))
+		text.Patterns = lines(form.Get(prefix + "text.patterns"))
+		question.Text = text
+	case quiz.TypeNumeric:
+		if numeric == nil {
+			numeric = &quiz.NumericSpec{}
+		}
+		if numeric.Answer, err = parseFloatField(form, prefix, "numeric.answer", "answer"); err != nil {
+			errs = append(errs, err)
+		}
+		if numeric.Tolerance, err = parseFloatField(form, prefix, "numeric.tolerance", "tolerance"); err != nil {
+			errs = append(errs, err)
+		}
+		if numeric.RelativeTolerance, err = parseFloatField(form, prefix, "numeric.relative_tolerance", "relative tolerance"); err != nil {
+			errs = append(errs, err)
+		}
+		numeric.Units = nil
+		for _, line := range lines(form.Get(prefix + "numeric.units")) {
+			unit, multiplier, found := strings.Cut(line, "=")
+			m, err := strconv.ParseFloat(strings.TrimSpace(multiplier), 64)
+			if !found || err != nil {
+				errs = append(errs, fmt.Errorf("unit %q is not written as unit=multiplier", line))
+				continue
+			}
+			numeric.Units = append(numeric.Units, quiz.NumericUnit{Unit: strings.TrimSpace(unit), Multiplier: m})
+		}
+		question.Numeric = numeric
+	case quiz.TypeOrdering:
+		if ordering == nil {
+			ordering = &quiz.OrderingSpec{}
+		}
+		ordering.Items = lines(form.Get(prefix + "ordering.items"))
+		question.Ordering = ordering
+	case quiz.TypeMatching:
+		if matching == nil {
+			matching = &quiz.MatchingSpec{}
+		}
+		matching.Pairs = nil
+		for _, line := range lines(form.Get(prefix + "matching.pairs")) {
+			prompt, answer, found := strings.Cut(line, "=")
+			if !found {
+				errs = append(errs, fmt.Errorf("pair %q is not written as prompt = answer", line))
+				continue
+			}
+			matching.Pairs = append(matching.Pairs, quiz.MatchPair{Prompt: strings.TrimSpace(prompt), Answer: strings.TrimSpace(answer)})
+		}
+		matching.Distractors = lines(form.Get(prefix + "matching.distractors"))
+		question.Matching = matching
+	case quiz.TypeEssay:
+		descriptions := make(map[string]string)
+		if essay != nil {
+			for _, c := range essay.Rubric {
+				descriptions[c.Name] = c.Description
+			}
+		}
+		var rubric []quiz.RubricCriterion
+		for _, line := range lines(form.Get(prefix + "essay.rubric")) {
+			name, points, found := strings.Cut(line, "=")
+			p, err := strconv.ParseFloat(strings.TrimSpace(points), 64)
+			if !found || err != nil {
+				errs = append(errs, fmt.Errorf("rubric criterion %q is not written as name = points", line))
+				continue
+			}
+			name = strings.TrimSpace(name)
+			rubric = append(rubric, quiz.RubricCriterion{Name: name, Description: descriptions[name], Points: p})
+		}
+		if len(rubric) > 0 {
+			question.Essay = &quiz.EssaySpec{Rubric: rubric}
+		}
+	case quiz.TypeLikert:
+		if labels := lines(form.Get(prefix + "likert.labels")); len(labels) > 0 {
+			question.Likert = &quiz.LikertSpec{Labels: labels}
+		} else {
+			question.Likert = likert
+		}
+	case quiz.TypeCode:
+		if code == nil {
+			code = &quiz.CodeSpec{}
+		}
+		code.Starter = textareaValue(form.Get(prefix + "code.starter"))
+		code.Tests = textareaValue(form.Get(prefix + "code.tests"))
+		code.TimeoutSeconds = 0
+		if v := strings.TrimSpace(form.Get(prefix + "code.timeout_seconds")); v != "" {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds < 0 {
+				errs = append(errs, fmt.Errorf("timeout %q is not a whole number of seconds", v))
+			}
+			code.TimeoutSeconds = seconds
+		}
+		question.Code = code
+	case quiz.TypeCloze:
+	default:
+		errs = append(errs, fmt.Errorf("unknown question type %q", question.Type))
+	}
+	return errors.Join(errs...)
+}
+
+// parseChoices reads the choice rows of a question editor, keeping the
+// stored choices they name by ID. Rows without content are dropped.
+func parseChoices(form url.Values, prefix string, stored []quiz.Choice) []quiz.Choice {
+	byID := make(map[uint]quiz.Choice, len(stored))
+	for _, choice := range stored {
+		byID[choice.ID] = choice
+	}
+	var choices []quiz.Choice
+	for j := 0; form.Has(fmt.Sprintf("%schoices.%d.content", prefix, j)); j++ {
+		field := fmt.Sprintf("%schoices.%d.", prefix, j)
+		content := strings.TrimSpace(form.Get(field + "content"))
+		if content == "" {
+			continue
+		}
+		var choice quiz.Choice
+		if id, err := strconv.ParseUint(form.Get(field+"id"), 10, 0); err == nil && id != 0 {
+			choice = byID[uint(id)]
+		}
+		choice.Content = content
+		choice.IsCorrect = form.Get(field+"is_correct") != ""
+		choice.Feedback = strings.TrimSpace(form.Get(field + "feedback"))
+		choices = append(choices, choice)
+	}
+	return choices
+}
+
+// parseFloatField reads an optional number field; a blank field is zero.
+func parseFloatField(form url.Values, prefix, field, label string) (float64, error) {
+	v := strings.TrimSpace(form.Get(prefix + field))
+	if v == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s %q is not a number", label, v)
+	}
+	return f, nil
+}
+
+// lines returns the non-blank lines of a textarea, trimmed.
+func lines(v string) []string {
+	var result []string
+	for _, line := range strings.Split(v, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+// textareaValue undoes the CRLF line breaks browsers post textareas with.
+func textareaValue(v string) string {
+	return strings.ReplaceAll(v, "\r\n", "\n")
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuizSaveHandler(t *testing.T) {
+	router, store := newTestRouter(t)
+	author := visitor(t, router)
+
+	rec := get(router, "/quizzes/new", "", author)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `action="/quizzes/new"`)
+	assert.Contains(t, rec.Body.String(), `name="questions.0.choices.1.content"`, "a blank question with blank choices")
+
+	rec = post(router, "/quizzes/new", url.Values{
+		"name":             {"Geography"},
+		"description":      {"Capitals\r\nand rivers"},
+		"max_attempts":     {"3"},
+		"attempt_cooldown": {"30m"},
+		"grading_policy":   {string(quiz.GradeLatest)},
+		"reveal_policy":    {string(quiz.RevealImmediately)},
+		"closes_at":        {"2030-06-01T18:30"},
+
+		"questions.0.type":                 {quiz.TypeSingleChoice},
+		"questions.0.content":              {"Capital of France?"},
+		"questions.0.points":               {"2"},
+		"questions.0.choices.0.content":    {"Paris"},
+		"questions.0.choices.0.is_correct": {"on"},
+		"questions.0.choices.1.content":    {"Lyon"},
+		"questions.0.choices.1.feedback":   {"Lyon is the third city"},
+		"questions.0.choices.2.content":    {""},
+		"questions.0.explanation":          {"Paris has been the capital since 987.\r\n"},
+
+		"questions.1.type":                       {quiz.TypeNumeric},
+		"questions.1.content":                    {"Length of the Seine?"},
+		"questions.1.numeric.answer":             {"777"},
+		"questions.1.numeric.tolerance":          {"5"},
+		"questions.1.numeric.relative_tolerance": {""},
+		"questions.1.numeric.units":              {"km=1\r\nm=0.001"},
+		"questions.1.choices.0.content":          {"ignored"},
+
+		"questions.2.type":          {quiz.TypeShortText},
+		"questions.2.content":       {"Capital of Italy?"},
+		"questions.2.text.accepted": {"Rome\r\n\r\nRoma"},
+
+		"questions.3.type":    {quiz.TypeSingleChoice},
+		"questions.3.content": {""},
+	}, author)
+	require.Equal(t, http.StatusSeeOther, rec.Code, rec.Body.String())
+	assert.Equal(t, "/quizzes/1", rec.Header().Get("Location"))
+
+	q, err := store.FindQuizByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "Geography", q.Name)
+	assert.Equal(t, "Capitals\nand rivers", q.Description)
+	assert.Equal(t, 3, q.MaxAttempts)
+	assert.Equal(t, 30*time.Minute, q.AttemptCooldown)
+	assert.Equal(t, quiz.GradeLatest, q.GradingPolicy)
+	assert.Equal(t, quiz.RevealImmediately, q.RevealPolicy)
+	require.NotNil(t, q.ClosesAt)
+	assert.Equal(t, time.Date(2030, 6, 1, 18, 30, 0, 0, time.Local), q.ClosesAt.Local())
+	assert.NotZero(t, q.OwnerID)
+	require.Len(t, q.Questions, 3)
+	assert.Equal(t, 2.0, q.Questions[0].Points)
+	require.Len(t, q.Questions[0].Choices, 2)
+	assert.True(t, q.Questions[0].Choices[0].IsCorrect)
+	assert.False(t, q.Questions[0].Choices[1].IsCorrect)
+	assert.Equal(t, "Lyon is the third city", q.Questions[0].Choices[1].Feedback)
+	assert.Equal(t, "Paris has been the capital since 987.", q.Questions[0].Explanation)
+	assert.Equal(t, &quiz.NumericSpec{Answer: 777, Tolerance: 5, Units: []quiz.NumericUnit{{Unit: "km", Multiplier: 1}, {Unit: "m", Multiplier: 0.001}}}, q.Questions[1].Numeric)
+	assert.Empty(t, q.Questions[1].Choices)
+	assert.Equal(t, []string{"Rome", "Roma"}, q.Questions[2].Text.Accepted)
+
+	rec = get(router, "/quizzes/1/edit", "", author)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `action="/quizzes/1/edit"`)
+	assert.Contains(t, rec.Body.String(), `name="questions.3.content"`, "a blank question to add")
+	assert.Contains(t, rec.Body.String(), `value="Lyon is the third city"`)
+	assert.Contains(t, rec.Body.String(), "capital since 987.</textarea>")
+
+	paris, lyon := q.Questions[0].Choices[0], q.Questions[0].Choices[1]
+	rec = post(router, "/quizzes/1/edit", url.Values{
+		"name":           {"Capitals"},
+		"grading_policy": {string(quiz.GradeHighest)},
+		"reveal_policy":  {string(quiz.RevealAfterSubmitted)},
+
+		"questions.0.id":                   {"1"},
+		"questions.0.type":                 {quiz.TypeMultiChoice},
+		"questions.0.content":              {"Cities of France?"},
+		"questions.0.choices.0.id":         {fmt.Sprint(paris.ID)},
+		"questions.0.choices.0.content":    {"Paris"},
+		"questions.0.choices.0.is_correct": {"on"},
+		"questions.0.choices.1.id":         {fmt.Sprint(lyon.ID)},
+		"questions.0.choices.1.content":    {"Lyon"},
+		"questions.0.choices.1.is_correct": {"on"},
+		"questions.0.choices.2.content":    {"Milan"},
+
+		"questions.1.id":      {"2"},
+		"questions.1.type":    {quiz.TypeNumeric},
+		"questions.1.content": {""},
+
+		"questions.2.id":            {"3"},
+		"questions.2.type":          {quiz.TypeShortText},
+		"questions.2.content":       {"Capital of Italy?"},
+		"questions.2.text.accepted": {"Rome"},
+
+		"questions.3.type":    {quiz.TypeCloze},
+		"questions.3.content": {"The capital of Spain is {{Madrid}}."},
+	}, author)
+	require.Equal(t, http.StatusSeeOther, rec.Code, rec.Body.String())
+
+	q, err = store.FindQuizByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "Capitals", q.Name)
+	assert.Zero(t, q.MaxAttempts)
+	assert.Nil(t, q.ClosesAt)
+	require.Len(t, q.Questions, 3, "the cleared question is removed")
+	assert.Equal(t, []uint{1, 3}, []uint{q.Questions[0].ID, q.Questions[1].ID}, "kept questions keep their IDs")
+	require.Len(t, q.Questions[0].Choices, 3)
+	assert.Equal(t, paris.ID, q.Questions[0].Choices[0].ID)
+	assert.True(t, q.Questions[0].Choices[1].IsCorrect)
+	assert.Equal(t, "Milan", q.Questions[0].Choices[2].Content)
+	assert.Equal(t, quiz.TypeCloze, q.Questions[2].Type)
+
+	assert.Equal(t, http.StatusNotFound, post(router, "/quizzes/1/edit", url.Values{"name": {"Mine"}}, visitor(t, router)).Code)
+}
+
+func TestQuizSaveHandler_OrderingAndMatching(t *testing.T) {
+	router, store := newTestRouter(t)
+	author := visitor(t, router)
+
+	rec := post(router, "/quizzes/new", url.Values{
+		"name":                             {"History"},
+		"questions.0.type":                 {quiz.TypeOrdering},
+		"questions.0.content":              {"Order the kings"},
+		"questions.0.ordering.items":       {"Clovis\r\nCharlemagne\r\nHugh Capet\r\n"},
+		"questions.1.type":                 {quiz.TypeMatching},
+		"questions.1.content":              {"Match the kings to their dynasty"},
+		"questions.1.matching.pairs":       {"Clovis = Merovingian\r\nCharlemagne = Carolingian"},
+		"questions.1.matching.distractors": {"Bourbon"},
+	}, author)
+	require.Equal(t, http.StatusSeeOther, rec.Code, rec.Body.String())
+
+	q, err := store.FindQuizByID(1)
+	require.NoError(t, err)
+	require.Len(t, q.Questions, 2)
+	assert.Equal(t, []string{"Clovis", "Charlemagne", "Hugh Capet"}, q.Questions[0].Ordering.Items)
+	assert.Equal(t, []quiz.MatchPair{{Prompt: "Clovis", Answer: "Merovingian"}, {Prompt: "Charlemagne", Answer: "Carolingian"}}, q.Questions[1].Matching.Pairs)
+	assert.Equal(t, []string{"Bourbon"}, q.Questions[1].Matching.Distractors)
+
+	rec = get(router, "/quizzes/1/edit", "", author)
+	assert.Contains(t, rec.Body.String(), "Clovis = Merovingian\nCharlemagne = Carolingian</textarea>")
+
+	rec = post(router, "/quizzes/1/edit", url.Values{
+		"name":                       {"History"},
+		"questions.0.id":             {"1"},
+		"questions.0.type":           {quiz.TypeOrdering},
+		"questions.0.content":        {"Order the kings"},
+		"questions.0.ordering.items": {"Clovis\r\nHugh Capet"},
+		"questions.1.id":             {"2"},
+		"questions.1.type":           {quiz.TypeMatching},
+		"questions.1.content":        {"Match the kings to their dynasty"},
+		"questions.1.matching.pairs": {"Clovis: Merovingian"},
+	}, author)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "question 2: pair &#34;Clovis: Merovingian&#34; is not written as prompt = answer")
+	q, err = store.FindQuizByID(1)
+	require.NoError(t, err)
+	assert.Len(t, q.Questions[0].Ordering.Items, 3, "nothing is saved")
+}
+
+func TestQuizSaveHandler_Invalid(t *testing.T) {
+	router, store := newTestRouter(t)
+	author := visitor(t, router)
+
+	rec := post(router, "/quizzes/new", url.Values{
+		"name":             {"Geography"},
+		"max_attempts":     {"-1"},
+		"attempt_cooldown": {"soon"},
+		"grading_policy":   {"best"},
+
+		"questions.0.type":           {quiz.TypeNumeric},
+		"questions.0.content":        {"Length of the Seine?"},
+		"questions.0.numeric.answer": {"long"},
+	}, author)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	for _, problem := range []string{
+		"max attempts &#34;-1&#34; is not a whole number of zero or more",
+		"cooldown &#34;soon&#34; is not a duration",
+		"unknown grading policy &#34;best&#34;",
+		"question 1: answer &#34;long&#34; is not a number",
+		"Length of the Seine?",
+	} {
+		assert.Contains(t, rec.Body.String(), problem)
+	}
+
+	rec = post(router, "/quizzes/new", url.Values{
+		"name":                {"Geography"},
+		"questions.0.type":    {quiz.TypeShortText},
+		"questions.0.content": {"Capital of Italy?"},
+	}, author)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "question 1: ")
+	_, err := store.FindQuizByID(1)
+	assert.Error(t, err, "nothing is stored")
+}
+
+func TestParseQuizForm(t *testing.T) {
+	tests := `package solution
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	if Add(1, 2) != 3 {
+		t.Fail()
+	}
+}
+`
+	q := &quiz.Quiz{Questions: []quiz.Question{
+		{ID: 7, Type: quiz.TypeEssay, Content: "Why?", Essay: &quiz.EssaySpec{Rubric: []quiz.RubricCriterion{{Name: "Clarity", Description: "Easy to follow", Points: 1}}}},
+	}}
+	require.NoError(t, parseQuizForm(url.Values{
+		"name":                             {"Mixed"},
+		"survey":                           {"on"},
+		"questions.0.id":                   {"7"},
+		"questions.0.type":                 {quiz.TypeEssay},
+		"questions.0.content":              {"Why?"},
+		"questions.0.essay.rubric":         {"Clarity = 2\r\nEvidence = 3"},
+		"questions.1.type":                 {quiz.TypeLikert},
+		"questions.1.content":              {"How was it?"},
+		"questions.1.likert.labels":        {"Bad\nGood"},
+		"questions.2.type":                 {quiz.TypeCode},
+		"questions.2.content":              {"Write Add"},
+		"questions.2.code.starter":         {"package solution\r\n"},
+		"questions.2.code.tests":           {tests},
+		"questions.2.code.timeout_seconds": {"5"},
+	}, q))
+	assert.True(t, q.Survey)
+	require.Len(t, q.Questions, 3)
+	assert.Equal(t, uint(7), q.Questions[0].ID)
+	assert.Equal(t, []quiz.RubricCriterion{{Name: "Clarity", Description: "Easy to follow", Points: 2}, {Name: "Evidence", Points: 3}}, q.Questions[0].Essay.Rubric)
+	assert.Equal(t, []string{"Bad", "Good"}, q.Questions[1].Likert.Labels)
+	assert.Equal(t, &quiz.CodeSpec{Starter: "package solution\n", Tests: tests, TimeoutSeconds: 5}, q.Questions[2].Code)
+
+	assert.EqualError(t, parseQuizForm(url.Values{
+		"name":                     {"Mixed"},
+		"questions.0.type":         {quiz.TypeEssay},
+		"questions.0.content":      {"Why?"},
+		"questions.0.essay.rubric": {"Clarity"},
+		"questions.1.type":         {"poem"},
+		"questions.1.content":      {"Roses?"},
+	}, &quiz.Quiz{}), "question 1: rubric criterion \"Clarity\" is not written as name = points\nquestion 2: unknown question type \"poem\"")
+}
+
+func TestQuizSaveHandler_Penalty(t *testing.T) {
+	router, store := newTestRouter(t)
+	author := visitor(t, router)
+
+	rec := post(router, "/quizzes/new", url.Values{
+		"name":                             {"Geography"},
+		"questions.0.type":                 {quiz.TypeSingleChoice},
+		"questions.0.content":              {"Capital of France?"},
+		"questions.0.points":               {"2"},
+		"questions.0.penalty":              {"0.5"},
+		"questions.0.choices.0.content":    {"Paris"},
+		"questions.0.choices.0.is_correct": {"on"},
+		"questions.0.choices.1.content":    {"Lyon"},
+		"questions.1.type":                 {quiz.TypeShortText},
+		"questions.1.content":              {"Capital of Italy?"},
+		"questions.1.penalty_fraction":     {"0.25"},
+		"questions.1.text.accepted":        {"Rome"},
+	}, author)
+	require.Equal(t, http.StatusSeeOther, rec.Code, rec.Body.String())
+	q, err := store.FindQuizByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, q.Questions[0].Penalty)
+	assert.Equal(t, 0.25, q.Questions[1].PenaltyFraction)
+
+	rec = get(router, "/quizzes/1/edit", "", author)
+	assert.Contains(t, rec.Body.String(), `name="questions.0.penalty" value="0.5"`)
+	assert.Contains(t, rec.Body.String(), `name="questions.1.penalty_fraction" value="0.25"`)
+
+	// A learner sees the weight and penalty of each question with the result
+	learner := visitor(t, router)
+	require.Equal(t, http.StatusSeeOther, post(router, "/quizzes/1/attempts", nil, learner).Code)
+	rec = post(router, "/attempts/1", url.Values{"q-1": {fmt.Sprint(q.Questions[0].Choices[1].ID)}, "q-2": {"Rome"}}, learner)
+	require.Equal(t, http.StatusSeeOther, rec.Code, rec.Body.String())
+	rec = get(router, "/attempts/1", "", learner)
+	assert.Contains(t, rec.Body.String(), "2 point(s), −0.5 for a wrong answer")
+	assert.Contains(t, rec.Body.String(), "1 point(s), −0.25 for a wrong answer")
+	assert.Contains(t, rec.Body.String(), "-0.5 / 2")
+
+	rec = post(router, "/quizzes/1/edit", url.Values{
+		"name":                          {"Geography"},
+		"questions.0.id":                {fmt.Sprint(q.Questions[0].ID)},
+		"questions.0.type":              {quiz.TypeSingleChoice},
+		"questions.0.content":           {"Capital of France?"},
+		"questions.0.penalty":           {"lots"},
+		"questions.0.penalty_fraction":  {"2"},
+		"questions.0.choices.0.content": {"Paris"},
+	}, author)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "question 1: penalty &#34;lots&#34; is not a number")
+
+	rec = post(router, "/quizzes/1/edit", url.Values{
+		"name":                             {"Geography"},
+		"questions.0.id":                   {fmt.Sprint(q.Questions[0].ID)},
+		"questions.0.type":                 {quiz.TypeSingleChoice},
+		"questions.0.content":              {"Capital of France?"},
+		"questions.0.penalty_fraction":     {"2"},
+		"questions.0.choices.0.content":    {"Paris"},
+		"questions.0.choices.0.is_correct": {"on"},
+	}, author)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "penalty fraction must be between 0 and 1")
+}
+
+func TestQuestionPasteHandler(t *testing.T) {
+	router, store := newTestRouter(t)
+	author := visitor(t, router)
+	storeQuiz(t, store, "Geography", 0)
+
+	text := "Capital of France?\nA. Paris\nB. Lyon\nANSWER: A\n\nCapital of Italy?\nA. Rome\nB. Milan\nANSWER: A\n"
+	rec := post(router, "/quizzes/1/questions/paste", url.Values{"text": {text}, "action": {"preview"}}, author)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "2 question(s) to add")
+
+	rec = post(router, "/quizzes/1/questions/paste", url.Values{"text": {text}, "action": {"add"}}, author)
+	require.Equal(t, http.StatusSeeOther, rec.Code, rec.Body.String())
+	q, err := store.FindQuizByID(1)
+	require.NoError(t, err)
+	require.Len(t, q.Questions, 3)
+	assert.Equal(t, "Capital of Italy?", q.Questions[2].Content)
+	assert.True(t, q.Questions[2].Choices[0].IsCorrect)
+}
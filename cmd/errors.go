@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/mbsof31/go-quiz/views"
+	"gorm.io/gorm"
+)
+
+// errorMessage replaces the message of server errors, whose details are only
+// logged.
+const errorMessage = "something went wrong on our side, please try again later"
+
+// errInvalidID is wrapped by the errors of IDs in the URL that are not
+// numbers.
+var errInvalidID = errors.New("invalid id")
+
+// notFoundError tells that there is no record with the ID, or none the
+// current user may see. It matches gorm.ErrRecordNotFound.
+type notFoundError struct {
+	what string
+	id   interface{}
+}
+
+func (e notFoundError) Error() string {
+	return fmt.Sprintf("cannot find the %s with the id of: %v", e.what, e.id)
+}
+
+func (e notFoundError) Is(target error) bool {
+	return target == gorm.ErrRecordNotFound
+}
+
+// urlID reads the ID of the named URL parameter, the what of the errors.
+func urlID(r *http.Request, param, what string) (uint, error) {
+	value := chi.URLParam(r, param)
+	ID, err := strconv.ParseUint(value, 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf("%w: the %s id %q is not a number", errInvalidID, what, value)
+	}
+	return uint(ID), nil
+}
+
+// notFound is err as a notFoundError when the store has no record with the
+// ID, and err itself otherwise.
+func notFound(err error, what string, id interface{}) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return notFoundError{what, id}
+	}
+	return err
+}
+
+// renderLookupError answers a request whose records could not be loaded:
+// 400 for an invalid ID, 404 for a missing record and 500, logged, for
+// anything else.
+func renderLookupError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errInvalidID):
+		renderError(w, r, http.StatusBadRequest, err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		renderError(w, r, http.StatusNotFound, err)
+	default:
+		renderError(w, r, http.StatusInternalServerError, err)
+	}
+}
+
+// renderError answers the request with the status and the message of err:
+// a JSON object for clients that ask for JSON, an error page otherwise.
+func renderError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	message := err.Error()
+	if status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %s", r.Method, r.URL.Path, message)
+		message = errorMessage
+	}
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "error": message})
+		return
+	}
+	var buf bytes.Buffer
+	if err := views.ErrorPage(status, message).Render(r.Context(), &buf); err != nil {
+		http.Error(w, message, status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// wantsJSON tells whether the client prefers JSON to HTML.
+func wantsJSON(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return true
+		case "text/html", "application/xhtml+xml":
+			return false
+		}
+	}
+	return false
+}
+
+// render writes the page, or the error page when it cannot be rendered. The
+// page is rendered in full first, so a failure leaves no half page behind.
+func render(w http.ResponseWriter, r *http.Request, page templ.Component) {
+	renderStatus(w, r, http.StatusOK, page)
+}
+
+// renderStatus is render answering with the status.
+func renderStatus(w http.ResponseWriter, r *http.Request, status int, page templ.Component) {
+	var buf bytes.Buffer
+	if err := page.Render(r.Context(), &buf); err != nil {
+		renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// recoverer answers requests whose handler panics with a server error and
+// logs the panic with its stack.
+func recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				log.Printf("panic: %v\n%s", rec, debug.Stack())
+				renderError(w, r, http.StatusInternalServerError, fmt.Errorf("panic: %v", rec))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	renderError(w, r, http.StatusNotFound, errors.New("there is nothing at this address"))
+}
+
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	renderError(w, r, http.StatusMethodNotAllowed, fmt.Errorf("this address does not take %s requests", r.Method))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/quizzes", nil)
+	rec := httptest.NewRecorder()
+	renderError(rec, req, http.StatusInternalServerError, errors.New("disk full at /var/lib/quiz"))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Internal Server Error")
+	assert.Contains(t, rec.Body.String(), errorMessage)
+	assert.NotContains(t, rec.Body.String(), "disk full", "server errors keep their details to the log")
+
+	req.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	renderError(rec, req, http.StatusConflict, errors.New("the attempt is already submitted"))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.JSONEq(t, `{"status": 409, "error": "the attempt is already submitted"}`, rec.Body.String())
+}
+
+func TestWantsJSON(t *testing.T) {
+	for accept, want := range map[string]bool{
+		"":                                  false,
+		"*/*":                               false,
+		"application/json":                  true,
+		"application/json; charset=utf-8":   true,
+		"text/html,application/xhtml+xml":   false,
+		"text/html, application/json;q=0.9": false,
+		"text/plain, application/json":      true,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", accept)
+		assert.Equal(t, want, wantsJSON(req), "Accept: %s", accept)
+	}
+}
+
+func TestRecoverer(t *testing.T) {
+	handler := recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q *struct{ Name string }
+		w.Write([]byte(q.Name))
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/quizzes/1", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), errorMessage)
+	assert.NotContains(t, rec.Body.String(), "nil pointer")
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mbsof31/go-quiz/internals"
+	"github.com/mbsof31/go-quiz/internals/formats"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// runExport exports a quiz from the command line, or with -format json any
+// number of quizzes, all of them when none are named:
+//
+//	quiz export [-format qti|csv|xlsx|markdown|aiken] [-version 2.1|3.0] -o file quiz-id
+//	quiz export -format json -o file [quiz-id...]
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "qti", "file format: qti, csv, xlsx, markdown, aiken or json")
+	version := fs.String("version", formats.QTI21, "QTI version: 2.1 or 3.0")
+	out := fs.String("o", "", "file to write")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: quiz export [-format qti|csv|xlsx|markdown|aiken] [-version 2.1|3.0] -o file quiz-id")
+		fmt.Fprintln(fs.Output(), "       quiz export -format json -o file [quiz-id...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	var ids []uint
+	for _, arg := range fs.Args() {
+		ID, err := strconv.Atoi(arg)
+		if err != nil {
+			fs.Usage()
+			os.Exit(2)
+		}
+		ids = append(ids, uint(ID))
+	}
+	if *out == "" || *format != "json" && len(ids) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		e, err := store.Export(context.Background(), ids...)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := quiz.WriteExport(&buf, e); err != nil {
+			return err
+		}
+		fmt.Printf("%s: exported %d quizzes with %d media\n", *out, len(e.Quizzes), len(e.Media))
+		return os.WriteFile(*out, buf.Bytes(), 0o644)
+	}
+	q, err := store.FindQuizByID(ids[0])
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	problems, err := exportQuiz(context.Background(), store, q, *format, *version, &buf)
+	for _, p := range problems {
+		fmt.Printf("%s: %s\n", *out, p)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*out, buf.Bytes(), 0o644)
+}
+
+// exportQuiz writes the quiz in the format, QTI packages in the version.
+func exportQuiz(ctx context.Context, store *quiz.SQLiteStore, q *quiz.Quiz, format, version string, buf *bytes.Buffer) ([]formats.Problem, error) {
+	switch format {
+	case "qti":
+		return formats.ExportQTI(buf, q, version, func(key string) ([]byte, error) {
+			return store.Media(ctx, key)
+		})
+	case "csv":
+		return formats.ExportCSV(buf, q)
+	case "xlsx":
+		return formats.ExportXLSX(buf, q)
+	case "markdown":
+		return formats.ExportMarkdown(buf, q)
+	case "aiken":
+		return formats.ExportAiken(buf, q)
+	case "json":
+		e, err := store.Export(ctx, q.ID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, quiz.WriteExport(buf, e)
+	}
+	return nil, fmt.Errorf("unknown export format %q, use qti, csv, xlsx, markdown, aiken or json", format)
+}
+
+// quizExportHandler downloads the quiz as a QTI package, a spreadsheet,
+// Markdown, Aiken text or a JSON export with its media.
+// What the format cannot carry is listed by the export command.
+func quizExportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	q, err := findOwnQuiz(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	format := chi.URLParam(r, "format")
+	version := r.URL.Query().Get("version")
+	if version == "" {
+		version = formats.QTI21
+	}
+	var buf bytes.Buffer
+	if _, err := exportQuiz(r.Context(), ctx.Store, q, format, version, &buf); err != nil {
+		renderError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	filename := fileSlug(q.Name) + "." + format
+	contentType := "text/csv; charset=utf-8"
+	switch format {
+	case "qti":
+		filename = fmt.Sprintf("%s-qti%s.zip", fileSlug(q.Name), strings.ReplaceAll(version, ".", ""))
+		contentType = "application/zip"
+	case "xlsx":
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case "markdown":
+		filename = fileSlug(q.Name) + ".md"
+		contentType = "text/markdown; charset=utf-8"
+	case "aiken":
+		filename = fileSlug(q.Name) + ".txt"
+		contentType = "text/plain; charset=utf-8"
+	case "json":
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Write(buf.Bytes())
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// fileSlug turns a quiz name into a safe file name.
+func fileSlug(name string) string {
+	if slug := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(name), "-"), "-"); slug != "" {
+		return slug
+	}
+	return "quiz"
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mbsof31/go-quiz/internals"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	grading "github.com/mbsof31/go-quiz/views/grading"
+)
+
+func RegisterGradingRoutes(r chi.Router) {
+	r.Get("/", gradingQueueHandler)
+	r.Get("/{answerID}", reviewFormHandler)
+	r.Post("/{answerID}", reviewSubmitHandler)
+}
+
+func gradingQueueHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	items, err := ctx.Store.ListPendingReviews(ctx.UserID)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	render(w, r, grading.GradingQueuePage(items))
+}
+
+// findReviewItem loads the answer named in the URL if the current user may
+// grade it: the owner of its quiz, or anyone when the quiz has no owner.
+func findReviewItem(r *http.Request) (*quiz.ReviewItem, error) {
+	ctx := internals.GetAppContext(r)
+	ID, err := urlID(r, "answerID", "answer")
+	if err != nil {
+		return nil, err
+	}
+	item, err := ctx.Store.FindReviewItem(ID)
+	if err != nil {
+		return nil, notFound(err, "answer", ID)
+	}
+	q, err := ctx.Store.FindQuizByID(item.QuizID)
+	if err != nil {
+		return nil, notFound(err, "quiz", item.QuizID)
+	}
+	if !item.Question.ManuallyGraded() || (q.OwnerID != 0 && q.OwnerID != ctx.UserID) {
+		return nil, notFoundError{"answer", ID}
+	}
+	return item, nil
+}
+
+func reviewFormHandler(w http.ResponseWriter, r *http.Request) {
+	item, err := findReviewItem(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	if !item.Answer.Pending {
+		renderError(w, r, http.StatusConflict, quiz.ErrAnswerReviewed)
+		return
+	}
+	render(w, r, grading.ReviewFormPage(item, ""))
+}
+
+func reviewSubmitHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	item, err := findReviewItem(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	review := quiz.Review{GraderID: ctx.UserID, Comment: r.PostForm.Get("comment")}
+	for _, v := range r.PostForm["score"] {
+		score, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			renderError(w, r, http.StatusBadRequest, fmt.Errorf("invalid score %q", v))
+			return
+		}
+		review.Scores = append(review.Scores, score)
+	}
+
+	_, err = ctx.Store.ReviewAnswer(item.Answer.ID, review)
+	if errors.Is(err, quiz.ErrAnswerReviewed) {
+		renderError(w, r, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		renderStatus(w, r, http.StatusUnprocessableEntity, grading.ReviewFormPage(item, err.Error()))
+		return
+	}
+	http.Redirect(w, r, "/grading", http.StatusSeeOther)
+}
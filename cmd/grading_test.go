@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReviewSubmitHandler(t *testing.T) {
+	router, store := newTestRouter(t)
+	owner, err := store.CreateUser("Ada", "")
+	require.NoError(t, err)
+	for _, ownerID := range []uint{owner.ID, 0} {
+		require.NoError(t, store.Store(quiz.Quiz{Name: "Essays", OwnerID: ownerID, Questions: []quiz.Question{
+			{Type: quiz.TypeEssay, Content: "Discuss", Points: 10},
+		}}))
+	}
+	learner := visitor(t, router)
+	for _, id := range []string{"1", "2"} {
+		// Each quiz has one question, and the learner one attempt at it.
+		require.Equal(t, http.StatusSeeOther, post(router, "/quizzes/"+id+"/attempts", nil, learner).Code)
+		require.Equal(t, http.StatusSeeOther, post(router, "/attempts/"+id, url.Values{"q-" + id: {"My essay"}}, learner).Code)
+	}
+	cookies := get(router, "/signin/"+owner.Token, "").Result().Cookies()
+	signedIn := cookies[len(cookies)-1]
+
+	assert.Equal(t, http.StatusNotFound, get(router, "/grading/1", "", learner).Code, "only the owner grades")
+	assert.Equal(t, http.StatusNotFound, post(router, "/grading/1", url.Values{"score": {"10"}}, learner).Code)
+	rec := get(router, "/grading", "", learner)
+	assert.Contains(t, rec.Body.String(), "/grading/2", "anyone grades a quiz without an owner")
+	assert.NotContains(t, rec.Body.String(), "/grading/1")
+	rec = post(router, "/grading/2", url.Values{"score": {"6"}}, signedIn)
+	require.Equal(t, http.StatusSeeOther, rec.Code, rec.Body.String())
+	ownerless, err := store.FindAttemptByID(2)
+	require.NoError(t, err)
+	assert.False(t, ownerless.PendingReview, "the attempt leaves review once graded")
+	assert.Equal(t, 6.0, ownerless.Score)
+
+	assert.Equal(t, http.StatusOK, get(router, "/grading/1", "", signedIn).Code)
+	rec = post(router, "/grading/1", url.Values{"score": {"8"}, "comment": {"Nice"}}, signedIn)
+	require.Equal(t, http.StatusSeeOther, rec.Code, rec.Body.String())
+	rec = post(router, "/grading/1", url.Values{"score": {"2"}}, signedIn)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.JSONEq(t, `{"status": 409, "error": "answer has already been graded"}`, rec.Body.String())
+	assert.Equal(t, http.StatusConflict, get(router, "/grading/1", "", signedIn).Code)
+
+	a, err := store.FindAttemptByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, 8.0, a.Score)
+}
+
+func TestMarkdownFeedback(t *testing.T) {
+	router, store := newTestRouter(t)
+	owner, err := store.CreateUser("Ada", "")
+	require.NoError(t, err)
+	require.NoError(t, store.Store(quiz.Quiz{Name: "Geography", OwnerID: owner.ID, Questions: []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "Capital of `France`?", Explanation: "Because of *Clovis*.",
+			Choices: []quiz.Choice{{Content: "Paris", IsCorrect: true, Feedback: "**Right**"}, {Content: "Lyon"}}},
+		{Type: quiz.TypeEssay, Content: "Describe the *Seine*"},
+	}}))
+	cookies := get(router, "/signin/"+owner.Token, "").Result().Cookies()
+	signedIn := cookies[len(cookies)-1]
+
+	require.Equal(t, http.StatusSeeOther, post(router, "/quizzes/1/attempts", nil, signedIn).Code)
+	require.Equal(t, http.StatusSeeOther, post(router, "/attempts/1", url.Values{"q-1": {"1"}, "q-2": {"Long"}}, signedIn).Code)
+
+	body := get(router, "/attempts/1", "", signedIn).Body.String()
+	assert.Contains(t, body, "<em>Clovis</em>")
+	assert.Contains(t, body, "<strong>Right</strong>")
+	assert.Contains(t, get(router, "/quizzes/1/report", "", signedIn).Body.String(), "<code>France</code>")
+	assert.Contains(t, get(router, "/grading", "", signedIn).Body.String(), "<em>Seine</em>")
+	assert.Contains(t, get(router, "/grading/2", "", signedIn).Body.String(), "<em>Seine</em>")
+}
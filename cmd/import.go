@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mbsof31/go-quiz/internals"
+	"github.com/mbsof31/go-quiz/internals/formats"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	quizzes "github.com/mbsof31/go-quiz/views/quizzes"
+)
+
+// maxImportSize bounds uploaded quiz files, in bytes.
+const maxImportSize = 128 << 20
+
+// runImport imports quiz files from the command line. JSON exports keep
+// their quiz IDs, and -mode decides what happens to the quizzes whose ID is
+// taken:
+//
+//	quiz import [-format moodle|gift|qti|csv|xlsx|markdown|aiken] [-owner id] file...
+//	quiz import [-format json] [-mode skip|overwrite|merge] [-dry-run] [-owner id] file...
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "auto", "file format: auto, moodle, gift, qti, csv, xlsx, markdown, aiken or json")
+	owner := fs.Uint("owner", 0, "user ID that owns the imported quizzes")
+	mode := fs.String("mode", string(quiz.ImportSkip), "JSON exports: skip, overwrite or merge the quizzes whose ID is taken")
+	dryRun := fs.Bool("dry-run", false, "JSON exports: report what would be imported without storing it")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: quiz import [-format moodle|gift|qti|csv|xlsx|markdown|aiken] [-owner id] file...")
+		fmt.Fprintln(fs.Output(), "       quiz import [-format json] [-mode skip|overwrite|merge] [-dry-run] [-owner id] file...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	for _, filename := range fs.Args() {
+		if *format == "json" || *format == "auto" && strings.EqualFold(filepath.Ext(filename), ".json") {
+			opts := quiz.ImportOptions{Mode: quiz.ImportMode(*mode), DryRun: *dryRun}
+			if err := importExport(context.Background(), store, filename, opts, uint(*owner)); err != nil {
+				return fmt.Errorf("%s: %w", filename, err)
+			}
+			continue
+		}
+		res, err := importFile(filename, *format)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		if err := storeImport(context.Background(), store, res, uint(*owner)); err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		for _, q := range res.Quizzes {
+			fmt.Printf("%s: imported %q with %d questions\n", filename, q.Name, len(q.Questions))
+		}
+		for _, p := range res.Problems {
+			fmt.Printf("%s: %s\n", filename, p)
+		}
+	}
+	return nil
+}
+
+// storeImport stores the quizzes of an import that pass validation, with
+// their media, for the owner.
+func storeImport(ctx context.Context, store *quiz.SQLiteStore, res *formats.Result, owner uint) error {
+	res.Check(store.ValidateQuestion)
+	if err := store.PutMedia(ctx, res.Media); err != nil {
+		return fmt.Errorf("storing media: %w", err)
+	}
+	for _, q := range res.Quizzes {
+		q.OwnerID = owner
+		if err := store.Store(*q); err != nil {
+			return fmt.Errorf("storing %q: %w", q.Name, err)
+		}
+	}
+	return nil
+}
+
+// importExport imports a JSON export, giving its quizzes to owner unless
+// that is zero, and prints what was done with each quiz.
+func importExport(ctx context.Context, store *quiz.SQLiteStore, filename string, opts quiz.ImportOptions, owner uint) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	e, err := quiz.ReadExport(f)
+	if err != nil {
+		return err
+	}
+	if owner != 0 {
+		for _, q := range e.Quizzes {
+			q.OwnerID = owner
+		}
+	}
+	report, err := store.Import(ctx, e, opts)
+	if err != nil {
+		return err
+	}
+	for _, q := range report.Quizzes {
+		fmt.Printf("%s: %s quiz %d %q\n", filename, q.Action, q.ID, q.Name)
+	}
+	fmt.Printf("%s: %s\n", filename, report)
+	return nil
+}
+
+func importFile(filename, format string) (*formats.Result, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return formats.Import(f, filename, format)
+}
+
+func quizImportFormHandler(w http.ResponseWriter, r *http.Request) {
+	render(w, r, quizzes.QuizImportPage(""))
+}
+
+// quizImportHandler imports the quizzes of an uploaded file for the current
+// user and reports what could not be imported.
+func quizImportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	res, err := importUpload(w, r)
+	if err == nil {
+		err = storeImport(r.Context(), ctx.Store, res, ctx.UserID)
+	}
+	if err != nil {
+		renderStatus(w, r, http.StatusUnprocessableEntity, quizzes.QuizImportPage(err.Error()))
+		return
+	}
+	render(w, r, quizzes.QuizImportReportPage(res))
+}
+
+func importUpload(w http.ResponseWriter, r *http.Request) (*formats.Result, error) {
+	if err := parseUpload(w, r, maxImportSize); err != nil {
+		return nil, err
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, errors.New("choose a file to import")
+	}
+	defer file.Close()
+	return formats.Import(file, header.Filename, r.PostForm.Get("format"))
+}
+
+// questionPasteHandler reads the Aiken questions pasted into the quiz form.
+// It previews them, and the lines it could not read, until the author adds
+// them to the quiz.
+func questionPasteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	q, err := findOwnQuiz(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	paste := quizzes.PastedQuestions{Text: r.PostForm.Get("text")}
+	res, err := formats.ImportAiken(strings.NewReader(paste.Text), q.Name)
+	if err != nil {
+		paste.Message = err.Error()
+	} else {
+		res.Check(ctx.Store.ValidateQuestion)
+		paste.Result = res
+	}
+	if r.PostForm.Get("action") == "add" && res != nil && len(res.Quizzes) > 0 {
+		err := ctx.Store.AddQuestions(q.ID, res.Quizzes[0].Questions)
+		if errors.Is(err, quiz.ErrInvalidQuestion) {
+			paste.Message = err.Error()
+			renderStatus(w, r, http.StatusUnprocessableEntity, quizzes.QuizFormPage(*q, "", paste))
+			return
+		}
+		if err != nil {
+			renderError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/quizzes/%d", q.ID), http.StatusSeeOther)
+		return
+	}
+	render(w, r, quizzes.QuizFormPage(*q, "", paste))
+}
@@ -1,128 +1,269 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/mbsof31/go-quiz/internals"
 	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/mbsof31/go-quiz/internals/sandbox"
+	"github.com/mbsof31/go-quiz/internals/server"
 	home "github.com/mbsof31/go-quiz/views/home"
 	quizzes "github.com/mbsof31/go-quiz/views/quizzes"
 	"gorm.io/gorm"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/a-h/templ"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-func main() {
-
-	store, err := quiz.NewSQLiteStore("database/quiz.db")
+// runServe starts the web server on the configured address:
+//
+//	quiz serve [-seed]
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	seed := fs.Bool("seed", false, "add sample quizzes when the database has none")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: quiz serve [-seed]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	logConfig()
+	store, err := openStore()
 	if err != nil {
-		log.Fatalf("Error creating db store: %s", err.Error())
+		return fmt.Errorf("creating db store: %w", err)
+	}
+	if cfg.SessionSecret == "" {
+		if cfg.SessionSecret, err = store.SessionSecret(); err != nil {
+			return fmt.Errorf("generating the session secret: %w", err)
+		}
+	}
+	if err := store.MoveMediaToBlobs(context.Background()); err != nil {
+		return fmt.Errorf("moving media to blob storage: %w", err)
+	}
+	if *seed {
+		seedDB(store.DB)
 	}
 
-	seedDB(store.DB)
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           newRouter(store),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+	}
+	scheme := "http"
+	switch {
+	case cfg.TLS.CertFile != "":
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return fmt.Errorf("loading the TLS certificate: %w", err)
+		}
+		srv.TLSConfig, scheme = server.TLSConfig(cert), "https"
+	case cfg.TLS.DevCert:
+		cert, err := server.DevCertificate()
+		if err != nil {
+			return fmt.Errorf("generating a development certificate: %w", err)
+		}
+		srv.TLSConfig, scheme = server.TLSConfig(cert), "https"
+		log.Println("Serving HTTPS with a self-signed certificate for localhost, for development only")
+	}
+
+	// Interrupts and SIGTERM stop the server once the requests in flight are
+	// done, and then the database is closed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Printf("Starting server on %s (%s)", cfg.Addr, scheme)
+	err = server.Run(ctx, srv, time.Duration(cfg.Server.ShutdownTimeout))
+	if err == nil {
+		log.Println("Server stopped")
+	}
+	if closeErr := store.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
 
+// logConfig logs the effective configuration, with secrets hidden, at the
+// info and debug log levels. `quiz config` prints it on demand.
+func logConfig() {
+	if cfg.LogLevel != "debug" && cfg.LogLevel != "info" {
+		return
+	}
+	var b strings.Builder
+	cfg.Print(&b)
+	log.Printf("Configuration:\n%s", b.String())
+}
+
+// newRouter routes the requests of the web application to its handlers,
+// which use the store.
+func newRouter(store *quiz.SQLiteStore) http.Handler {
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
+	if cfg.LogLevel == "debug" || cfg.LogLevel == "info" {
+		r.Use(middleware.Logger)
+	}
+	r.Use(recoverer)
 	r.Use(internals.StoreMiddleware(store)) // Use the middleware
+	if cfg.Features.CodeRunner {
+		r.Use(internals.RunnerMiddleware(sandbox.NewGoRunner()))
+	}
+	r.Use(internals.UserMiddleware(cfg.SessionSecret))
+	r.NotFound(notFoundHandler)
+	r.MethodNotAllowed(methodNotAllowedHandler)
 
 	// Home route
 	r.Handle("/", templ.Handler(home.Home()))
 
 	// Quiz routes
 	r.Route("/quizzes", RegisterQuizRoutes)
+	r.Route("/attempts", RegisterAttemptRoutes)
+	r.Route("/grading", RegisterGradingRoutes)
+	r.Route("/media", RegisterMediaRoutes)
+	r.Get("/signin/{token}", signInHandler)
 
 	// Serve static files from the "public" directory
 	fileServer(r, "/public", http.Dir("./public"))
+	return r
+}
+
+// runMigrate creates or updates the database tables and moves the media
+// older versions kept in the database to the media storage:
+//
+//	quiz migrate
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: quiz migrate")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
 
-	log.Println("Starting server on :4000")
-	err = http.ListenAndServe(":4000", r)
+	store, err := openStore()
 	if err != nil {
-		return
+		return err
 	}
+	if err := store.MoveMediaToBlobs(context.Background()); err != nil {
+		return fmt.Errorf("moving media to blob storage: %w", err)
+	}
+	fmt.Printf("%s: up to date\n", cfg.DB.DSN)
+	return nil
+}
+
+// runSeed adds sample quizzes to a database without quizzes:
+//
+//	quiz seed
+func runSeed(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: quiz seed")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	seedDB(store.DB)
+	return nil
 }
 
 func RegisterQuizRoutes(r chi.Router) {
 	r.Get("/", quizListHandler)
 	r.Get("/{quizID}", quizDetailsHandler)
 	r.Get("/new", quizCreateHandler)
+	r.Post("/new", quizNewSaveHandler)
+	r.Get("/import", quizImportFormHandler)
+	r.Post("/import", quizImportHandler)
 	r.Get("/{quizID}/edit", quizEditHandler)
+	r.Post("/{quizID}/edit", quizEditSaveHandler)
+	r.Post("/{quizID}/questions/paste", questionPasteHandler)
+	r.Post("/{quizID}/attempts", attemptStartHandler)
+	r.Get("/{quizID}/report", quizReportHandler)
+	r.Get("/{quizID}/export/{format}", quizExportHandler)
+	if cfg.Features.PaperExams {
+		r.Get("/{quizID}/print", quizPrintHandler)
+		r.Post("/{quizID}/print", quizPrintHandler)
+		r.Get("/{quizID}/scans", quizScansHandler)
+		r.Post("/{quizID}/scans", quizScanUploadHandler)
+	}
+	r.Get("/{quizID}/media", quizMediaHandler)
+	r.Post("/{quizID}/media/cover", coverUploadHandler)
+	r.Post("/{quizID}/media/questions/{questionID}", questionImageUploadHandler)
+	r.Post("/{quizID}/media/choices/{choiceID}", choiceThumbUploadHandler)
+	r.Post("/{quizID}/media/questions/{questionID}/recording", recordingUploadHandler)
+	r.Get("/{quizID}/media/questions/{questionID}/recording", recordingPreviewHandler)
+	r.Get("/{quizID}/media/questions/{questionID}/captions", captionsPreviewHandler)
 }
 
 func quizListHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := internals.GetAppContext(r)
-	store := ctx.Store
-
-	all, _ := store.ListAllQuizzes()
-	err := quizzes.QuizListPage(all).Render(r.Context(), w)
+	all, err := ctx.Store.ListAllQuizzes()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		renderError(w, r, http.StatusInternalServerError, err)
+		return
 	}
+	render(w, r, quizzes.QuizListPage(all))
 }
 
 func quizCreateHandler(w http.ResponseWriter, r *http.Request) {
-	err := quizzes.QuizFormPage(quiz.Quiz{}).Render(r.Context(), w)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
+	render(w, r, quizzes.QuizFormPage(quiz.Quiz{}, "", quizzes.PastedQuestions{}))
 }
 
 func quizDetailsHandler(w http.ResponseWriter, r *http.Request) {
-	quizID := chi.URLParam(r, "quizID")
-	// Here you'd normally fetch the q by ID from the database
-	// This is just a sample q for demonstration
-	var store, err = quiz.NewSQLiteStore("database/quiz.db")
+	q, err := findQuiz(r)
 	if err != nil {
-		log.Fatalf("Error creating db store: %s", err.Error())
+		renderLookupError(w, r, err)
+		return
 	}
-	ID, err := strconv.Atoi(quizID)
+	render(w, r, quizzes.QuizDetailsPage(q, cfg.Features.PaperExams))
+}
+
+func quizReportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	ID, err := urlID(r, "quizID", "quiz")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		renderLookupError(w, r, err)
+		return
 	}
-	q, err := store.FindQuizByID(uint(ID))
+	report, err := ctx.Store.QuizReport(ID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		renderLookupError(w, r, notFound(err, "quiz", ID))
+		return
 	}
-
-	err = quizzes.QuizDetailsPage(q).Render(r.Context(), w)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if report.Quiz.OwnerID != 0 && report.Quiz.OwnerID != ctx.UserID {
+		renderError(w, r, http.StatusForbidden, errors.New("only the quiz owner can see its report"))
+		return
 	}
+	render(w, r, quizzes.QuizReportPage(report))
 }
 
 func quizEditHandler(w http.ResponseWriter, r *http.Request) {
-	//quizID := chi.URLParam(r, "quizID")
-	// Here you'd normally fetch the q by ID from the database
-	// This is just a sample q for demonstration
-	q := quiz.Quiz{
-		ID:          1,
-		Name:        "Sample Quiz",
-		Description: "This is a detailed description of the sample",
-		Questions: []quiz.Question{
-			{Content: "What is the capital of France?", Choices: []quiz.Choice{
-				{Content: "Paris"},
-				{Content: "London"},
-				{Content: "Berlin"},
-				{Content: "Madrid"},
-			}},
-			{Content: "What is the capital of UK?", Choices: []quiz.Choice{
-				{Content: "Paris"},
-				{Content: "London"},
-				{Content: "Berlin"},
-				{Content: "Madrid"},
-			}},
-			// Add more sample questions here
-		},
-	}
-
-	err := quizzes.QuizFormPage(q).Render(r.Context(), w)
+	q, err := findOwnQuiz(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		renderLookupError(w, r, err)
+		return
 	}
+	render(w, r, quizzes.QuizFormPage(*q, "", quizzes.PastedQuestions{}))
 }
 
 // fileServer conveniently sets up a http.FileServer handler to serve static files from a http.FileSystem.
@@ -155,11 +296,17 @@ func seedDB(db *gorm.DB) {
 
 	for i := range qs {
 		for j := 0; j < 10; j++ {
-			questionType := "single-choice"
+			if j%2 != 0 && j%3 == 0 {
+				qs[i].Questions = append(qs[i].Questions, quiz.Question{
+					Type:    quiz.TypeLikert,
+					Content: "Question " + strconv.Itoa(j+1),
+					Likert:  &quiz.LikertSpec{Labels: quiz.DefaultLikertLabels},
+				})
+				continue
+			}
+			questionType := quiz.TypeSingleChoice
 			if j%2 == 0 {
-				questionType = "multi-choice"
-			} else if j%3 == 0 {
-				questionType = "liquert-scale"
+				questionType = quiz.TypeMultiChoice
 			}
 			question := quiz.Question{
 				Type:    questionType,
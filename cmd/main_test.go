@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/config"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRouter routes requests to the handlers with a fresh database, and
+// returns the store to fill it.
+func newTestRouter(t *testing.T) (http.Handler, *quiz.SQLiteStore) {
+	t.Helper()
+	cfg = config.Default()
+	cfg.LogLevel = "error"
+	cfg.Features.CodeRunner = false
+	cfg.Media.Dir = t.TempDir()
+	cfg.SessionSecret = "0123456789abcdef0123456789abcdef"
+	store, err := quiz.NewSQLiteStore(filepath.Join(t.TempDir(), "quiz.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	store.Blobs = blobStore()
+	return newRouter(store), store
+}
+
+func get(handler http.Handler, target, accept string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func storeQuiz(t *testing.T, store *quiz.SQLiteStore, name string, owner uint) {
+	t.Helper()
+	require.NoError(t, store.Store(quiz.Quiz{Name: name, OwnerID: owner, Questions: []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "Capital of France?",
+			Choices: []quiz.Choice{{Content: "Paris", IsCorrect: true}, {Content: "Lyon"}}},
+	}}))
+}
+
+func TestQuizDetailsHandler(t *testing.T) {
+	router, store := newTestRouter(t)
+	storeQuiz(t, store, "Geography", 0)
+
+	rec := get(router, "/quizzes/1", "text/html")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Geography")
+	assert.Contains(t, rec.Body.String(), "Print for paper")
+
+	rec = get(router, "/quizzes/42", "text/html")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "Not Found")
+	assert.Contains(t, rec.Body.String(), "cannot find the quiz with the id of: 42")
+	assert.Equal(t, 1, strings.Count(rec.Body.String(), "<html"), "a single page")
+
+	rec = get(router, "/quizzes/abc", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "the quiz id &#34;abc&#34; is not a number")
+
+	rec = get(router, "/quizzes/42", "application/json")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var body struct {
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, http.StatusNotFound, body.Status)
+	assert.Equal(t, "cannot find the quiz with the id of: 42", body.Error)
+
+	cfg.Features.PaperExams = false
+	router = newRouter(store)
+	rec = get(router, "/quizzes/1", "")
+	assert.NotContains(t, rec.Body.String(), "Print for paper")
+	assert.Equal(t, http.StatusNotFound, get(router, "/quizzes/1/print", "").Code)
+}
+
+func TestRouter_Errors(t *testing.T) {
+	router, store := newTestRouter(t)
+	storeQuiz(t, store, "Private", 99)
+
+	rec := get(router, "/nowhere", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "there is nothing at this address")
+
+	req := httptest.NewRequest(http.MethodDelete, "/quizzes/", nil)
+	req.Header.Set("Accept", "application/json, text/plain")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.JSONEq(t, `{"status": 405, "error": "this address does not take DELETE requests"}`, rec.Body.String())
+
+	rec = get(router, "/quizzes/1/report", "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "only the quiz owner can see its report")
+	rec = get(router, "/quizzes/1/edit", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "cannot find the quiz with the id of: 1")
+
+	// Store failures are logged, and their details hidden.
+	require.NoError(t, store.DB.Migrator().DropTable(&quiz.Choice{}))
+	rec = get(router, "/quizzes/1", "application/json")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"status": 500, "error": "`+errorMessage+`"}`, rec.Body.String())
+}
+
+func TestSignInHandler(t *testing.T) {
+	router, store := newTestRouter(t)
+	user, err := store.CreateUser("Ada", "")
+	require.NoError(t, err)
+	storeQuiz(t, store, "Ada's quiz", user.ID)
+
+	assert.Equal(t, http.StatusNotFound, get(router, "/signin/nope", "").Code)
+	rec := get(router, "/signin/"+user.Token, "")
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/quizzes", rec.Header().Get("Location"))
+	cookies := rec.Result().Cookies()
+	require.NotEmpty(t, cookies)
+	signedIn := cookies[len(cookies)-1]
+	assert.True(t, strings.HasPrefix(signedIn.Value, "1."), "signed with the secret")
+
+	assert.Equal(t, http.StatusOK, get(router, "/quizzes/1/edit", "", signedIn).Code)
+	forged := &http.Cookie{Name: signedIn.Name, Value: "1"}
+	rec = get(router, "/quizzes/1/edit", "", forged)
+	assert.Equal(t, http.StatusNotFound, rec.Code, "unsigned cookies are replaced")
+	require.Len(t, rec.Result().Cookies(), 1)
+	visitor, _, _ := strings.Cut(rec.Result().Cookies()[0].Value, ".")
+	id, err := strconv.ParseUint(visitor, 10, 32)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, id, uint64(quiz.AnonymousIDs), "visitors never get the ID of an account")
+
+	cfg.SessionSecret = "another secret of at least 32 characters"
+	rec = get(newRouter(store), "/quizzes/1/edit", "", signedIn)
+	assert.Equal(t, http.StatusNotFound, rec.Code, "cookies signed with another secret are replaced")
+}
+
+func TestLogConfig(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	cfg = config.Default()
+	cfg.SessionSecret = "0123456789abcdef0123456789abcdef"
+	logConfig()
+	assert.Contains(t, out.String(), "addr")
+	assert.Contains(t, out.String(), "session_secret")
+	assert.NotContains(t, out.String(), cfg.SessionSecret)
+
+	out.Reset()
+	cfg.LogLevel = "warn"
+	logConfig()
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mbsof31/go-quiz/internals"
+	"github.com/mbsof31/go-quiz/internals/blob"
+	"github.com/mbsof31/go-quiz/internals/media"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	quizzes "github.com/mbsof31/go-quiz/views/quizzes"
+)
+
+func RegisterMediaRoutes(r chi.Router) {
+	r.Get("/*", mediaHandler)
+}
+
+// blobStore keeps media in the configured S3-compatible bucket or
+// directory.
+func blobStore() blob.Store {
+	if s3 := cfg.Media.S3; cfg.Media.Store == "s3" {
+		return blob.NewS3Store(s3.Endpoint, s3.Bucket, s3.Region, s3.AccessKey, s3.SecretKey)
+	}
+	return blob.NewFSStore(cfg.Media.Dir)
+}
+
+func mediaHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	key := chi.URLParam(r, "*")
+	if err := blob.CheckKey(key); err != nil {
+		renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	// Recordings are only streamed through their question, which enforces
+	// play limits.
+	if !strings.HasPrefix(key, "images/") {
+		notFoundHandler(w, r)
+		return
+	}
+	data, err := ctx.Store.Media(r.Context(), key)
+	if errors.Is(err, blob.ErrNotFound) {
+		notFoundHandler(w, r)
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	media.Serve(w, r, data)
+}
+
+// findOwnQuiz loads the quiz named in the URL if the current user may
+// manage it.
+func findOwnQuiz(r *http.Request) (*quiz.Quiz, error) {
+	ctx := internals.GetAppContext(r)
+	q, err := findQuiz(r)
+	if err != nil {
+		return nil, err
+	}
+	if q.OwnerID != 0 && q.OwnerID != ctx.UserID {
+		return nil, notFoundError{"quiz", q.ID}
+	}
+	return q, nil
+}
+
+// findQuiz loads the quiz named in the URL.
+func findQuiz(r *http.Request) (*quiz.Quiz, error) {
+	ctx := internals.GetAppContext(r)
+	ID, err := urlID(r, "quizID", "quiz")
+	if err != nil {
+		return nil, err
+	}
+	q, err := ctx.Store.FindQuizByID(ID)
+	if err != nil {
+		return nil, notFound(err, "quiz", ID)
+	}
+	return q, nil
+}
+
+func quizMediaHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := findOwnQuiz(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	render(w, r, quizzes.QuizMediaPage(q, ""))
+}
+
+func coverUploadHandler(w http.ResponseWriter, r *http.Request) {
+	mediaUpload(w, r, func(store *quiz.SQLiteStore, q *quiz.Quiz) error {
+		data, err := readImage(w, r, media.CoverSize)
+		if err != nil {
+			return err
+		}
+		return store.SetQuizCover(r.Context(), q.ID, data)
+	})
+}
+
+func questionImageUploadHandler(w http.ResponseWriter, r *http.Request) {
+	mediaUpload(w, r, func(store *quiz.SQLiteStore, q *quiz.Quiz) error {
+		ID, err := strconv.Atoi(chi.URLParam(r, "questionID"))
+		if err != nil {
+			return err
+		}
+		data, err := readImage(w, r, media.QuestionSize)
+		if err != nil {
+			return err
+		}
+		return store.SetQuestionImage(r.Context(), q.ID, uint(ID), data, r.PostForm.Get("alt"))
+	})
+}
+
+func choiceThumbUploadHandler(w http.ResponseWriter, r *http.Request) {
+	mediaUpload(w, r, func(store *quiz.SQLiteStore, q *quiz.Quiz) error {
+		ID, err := strconv.Atoi(chi.URLParam(r, "choiceID"))
+		if err != nil {
+			return err
+		}
+		data, err := readImage(w, r, media.ThumbSize)
+		if err != nil {
+			return err
+		}
+		return store.SetChoiceThumb(r.Context(), q.ID, uint(ID), data)
+	})
+}
+
+// recordingUploadHandler replaces or removes the recording of a question and
+// saves its play limit, captions and transcript.
+func recordingUploadHandler(w http.ResponseWriter, r *http.Request) {
+	mediaUpload(w, r, func(store *quiz.SQLiteStore, q *quiz.Quiz) error {
+		ID, err := strconv.Atoi(chi.URLParam(r, "questionID"))
+		if err != nil {
+			return err
+		}
+		if err := parseUpload(w, r, media.MaxAttachmentSize); err != nil {
+			return err
+		}
+		if r.PostForm.Get("remove") != "" {
+			return store.SetQuestionMedia(r.Context(), q.ID, uint(ID), nil, "")
+		}
+
+		file, header, err := r.FormFile("recording")
+		switch {
+		case err == nil:
+			defer file.Close()
+			data, contentType, err := media.Attachment(file, header.Filename)
+			if err != nil {
+				return err
+			}
+			if err := store.SetQuestionMedia(r.Context(), q.ID, uint(ID), data, contentType); err != nil {
+				return err
+			}
+		case !errors.Is(err, http.ErrMissingFile):
+			return err
+		}
+
+		maxPlays := 0
+		if value := r.PostForm.Get("max_plays"); value != "" {
+			if maxPlays, err = strconv.Atoi(value); err != nil {
+				return fmt.Errorf("invalid play limit %q", value)
+			}
+		}
+		return store.UpdateMediaSettings(q.ID, uint(ID), maxPlays, r.PostForm.Get("captions"), r.PostForm.Get("transcript"))
+	})
+}
+
+// mediaUpload runs save for the quiz named in the URL, then redirects back
+// to the media page, or renders it again with the error.
+func mediaUpload(w http.ResponseWriter, r *http.Request, save func(*quiz.SQLiteStore, *quiz.Quiz) error) {
+	ctx := internals.GetAppContext(r)
+	q, err := findOwnQuiz(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+
+	if err := save(ctx.Store, q); err != nil {
+		renderStatus(w, r, http.StatusUnprocessableEntity, quizzes.QuizMediaPage(q, err.Error()))
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/quizzes/%d/media", q.ID), http.StatusSeeOther)
+}
+
+// parseUpload parses a multipart form carrying a file of at most limit bytes.
+func parseUpload(w http.ResponseWriter, r *http.Request, limit int64) error {
+	r.Body = http.MaxBytesReader(w, r.Body, limit+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return fmt.Errorf("%w: the limit is %d MB", media.ErrTooLarge, limit>>20)
+		}
+		return err
+	}
+	return nil
+}
+
+// readImage returns the processed image of the form, or nil when the form
+// asks to remove the image.
+func readImage(w http.ResponseWriter, r *http.Request, size media.Size) ([]byte, error) {
+	if err := parseUpload(w, r, media.MaxUploadSize); err != nil {
+		return nil, err
+	}
+	if r.PostForm.Get("remove") != "" {
+		return nil, nil
+	}
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		return nil, errors.New("choose an image to upload")
+	}
+	defer file.Close()
+	return media.Process(file, size)
+}
+
+// findQuestion returns the question of the quiz with the ID named in the URL.
+func findQuestion(r *http.Request, q *quiz.Quiz) (*quiz.Question, error) {
+	ID, err := urlID(r, "questionID", "question")
+	if err != nil {
+		return nil, err
+	}
+	for i := range q.Questions {
+		if q.Questions[i].ID == ID {
+			return &q.Questions[i], nil
+		}
+	}
+	return nil, notFoundError{"question", ID}
+}
+
+// recordingPreviewHandler lets the quiz owner play a recording without
+// limits.
+func recordingPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := findOwnQuiz(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	question, err := findQuestion(r, q)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	serveRecording(w, r, question)
+}
+
+// attemptRecordingHandler streams a question recording to the learner
+// taking the attempt, counting plays against the question play limit.
+func attemptRecordingHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	a, question, err := findAttemptQuestion(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	if question.MaxPlays > 0 {
+		// Limited recordings must be fetched again for every play.
+		w.Header().Set("Cache-Control", "no-store")
+		if a.Submitted() {
+			renderError(w, r, http.StatusForbidden, errors.New("the attempt is submitted"))
+			return
+		}
+		if startsPlay(r) {
+			err := ctx.Store.StartPlay(a.ID, question, time.Now())
+			if errors.Is(err, quiz.ErrPlayLimit) {
+				renderError(w, r, http.StatusForbidden, err)
+				return
+			}
+			if err != nil {
+				renderError(w, r, http.StatusInternalServerError, err)
+				return
+			}
+		} else if !a.HasPlayed(question) {
+			renderError(w, r, http.StatusForbidden, errors.New("the recording was not started"))
+			return
+		}
+	}
+	serveRecording(w, r, question)
+}
+
+func captionsHandler(w http.ResponseWriter, r *http.Request) {
+	_, question, err := findAttemptQuestion(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	serveCaptions(w, r, question)
+}
+
+func captionsPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := findOwnQuiz(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	question, err := findQuestion(r, q)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	serveCaptions(w, r, question)
+}
+
+// findAttemptQuestion loads the attempt and the question named in the URL.
+func findAttemptQuestion(r *http.Request) (*quiz.Attempt, *quiz.Question, error) {
+	ctx := internals.GetAppContext(r)
+	a, err := findOwnAttempt(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	q, err := ctx.Store.FindQuizByID(a.QuizID)
+	if err != nil {
+		return nil, nil, notFound(err, "quiz", a.QuizID)
+	}
+	question, err := findQuestion(r, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, question, nil
+}
+
+// startsPlay tells whether the request is for the start of a recording, as
+// players send when playback begins. Later requests continue that play.
+func startsPlay(r *http.Request) bool {
+	byteRange := r.Header.Get("Range")
+	return byteRange == "" || strings.HasPrefix(byteRange, "bytes=0-")
+}
+
+// serveRecording streams the question recording from the blob store,
+// answering range requests so players can seek.
+func serveRecording(w http.ResponseWriter, r *http.Request, question *quiz.Question) {
+	ctx := internals.GetAppContext(r)
+	if !question.HasMedia() {
+		notFoundHandler(w, r)
+		return
+	}
+	f, err := ctx.Store.OpenMedia(r.Context(), question.MediaKey)
+	if errors.Is(err, blob.ErrNotFound) {
+		notFoundHandler(w, r)
+		return
+	}
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", question.MediaType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+path.Base(question.MediaKey)+`"`)
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+	}
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+func serveCaptions(w http.ResponseWriter, r *http.Request, question *quiz.Question) {
+	if question.Captions == "" {
+		notFoundHandler(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.WriteString(w, question.Captions)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/mbsof31/go-quiz/internals"
+	"github.com/mbsof31/go-quiz/internals/paper"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// runPrint prints a quiz for an exam on paper from the command line:
+//
+//	quiz print [-kind questions|key|sheet] [-variant A|B|C] -o file.pdf quiz-id
+func runPrint(args []string) error {
+	fs := flag.NewFlagSet("print", flag.ExitOnError)
+	kind := fs.String("kind", "questions", "what to print: questions, key or sheet")
+	variant := fs.String("variant", "", "variant to print: A, B or C; empty keeps the authored order")
+	out := fs.String("o", "", "PDF file to write")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: quiz print [-kind questions|key|sheet] [-variant A|B|C] -o file.pdf quiz-id")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *out == "" || fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	ID, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	q, err := store.FindQuizByID(uint(ID))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := printQuiz(context.Background(), store, q, *kind, *variant, &buf); err != nil {
+		return err
+	}
+	return os.WriteFile(*out, buf.Bytes(), 0o644)
+}
+
+// printQuiz writes the PDF of the kind for the quiz in the variant.
+func printQuiz(ctx context.Context, store *quiz.SQLiteStore, q *quiz.Quiz, kind, variant string, w io.Writer) error {
+	exam, err := paper.NewExam(q, variant)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case "questions":
+		return exam.WriteQuestions(w, func(key string) ([]byte, error) {
+			return store.Media(ctx, key)
+		})
+	case "key":
+		return exam.WriteKey(w)
+	case "sheet":
+		// Sheets carry their print run, so scans are graded against the
+		// quiz as printed.
+		if _, err := exam.SheetItems(); err != nil {
+			return err
+		}
+		printing, err := store.RecordPrinting(q)
+		if err != nil {
+			return err
+		}
+		exam.Printing = printing.ID
+		return exam.WriteSheet(w)
+	}
+	return fmt.Errorf("unknown kind %q, print questions, key or sheet", kind)
+}
+
+// quizPrintHandler downloads the exam paper, answer key or bubble sheet of
+// the quiz as a PDF, in the variant of the form. Sheets record a print run,
+// so they are only printed by a POST request.
+func quizPrintHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	q, err := findOwnQuiz(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	kind := r.FormValue("kind")
+	if kind == "" {
+		kind = "questions"
+	}
+	if kind == "sheet" && r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		renderError(w, r, http.StatusMethodNotAllowed, errors.New("bubble sheets record a print run, so they are printed with a POST request"))
+		return
+	}
+	variant := r.FormValue("variant")
+	var buf bytes.Buffer
+	if err := printQuiz(r.Context(), ctx.Store, q, kind, variant, &buf); err != nil {
+		renderError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+	filename := fileSlug(q.Name)
+	if kind != "questions" {
+		filename += "-" + kind
+	}
+	if variant != "" {
+		filename += "-" + variant
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, filename))
+	w.Write(buf.Bytes())
+}
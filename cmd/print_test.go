@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuizPrintHandler(t *testing.T) {
+	router, store := newTestRouter(t)
+	storeQuiz(t, store, "Geography", 0)
+	printings := func() int64 {
+		var n int64
+		require.NoError(t, store.DB.Model(&quiz.Printing{}).Count(&n).Error)
+		return n
+	}
+
+	assert.Contains(t, get(router, "/quizzes/1", "").Body.String(), `action="/quizzes/1/print" method="POST"`)
+	rec := get(router, "/quizzes/1/print?kind=questions", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+
+	rec = get(router, "/quizzes/1/print?kind=sheet", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, http.MethodPost, rec.Header().Get("Allow"))
+	assert.Zero(t, printings(), "a GET records no print run")
+
+	rec = post(router, "/quizzes/1/print", url.Values{"kind": {"sheet"}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `attachment; filename="geography-sheet.pdf"`, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, int64(1), printings())
+}
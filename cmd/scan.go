@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/mbsof31/go-quiz/internals"
+	"github.com/mbsof31/go-quiz/internals/paper"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	quizzes "github.com/mbsof31/go-quiz/views/quizzes"
+	"gorm.io/gorm"
+)
+
+// maxScanSize bounds the uploaded sheet images together, in bytes.
+const maxScanSize = 256 << 20
+
+// runScan grades scanned bubble sheets from the command line:
+//
+//	quiz scan quiz-id image...
+func runScan(args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: quiz scan quiz-id image...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	ID, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	q, err := store.FindQuizByID(uint(ID))
+	if err != nil {
+		return err
+	}
+	for _, filename := range fs.Args()[1:] {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		sheet := gradeSheet(context.Background(), store, q, filename, f)
+		f.Close()
+		if sheet.Error != "" {
+			fmt.Printf("%s: %s\n", filename, sheet.Error)
+			continue
+		}
+		a := sheet.Attempt
+		fmt.Printf("%s: student %s, %g of %g points\n", filename, a.StudentNumber, a.Score, a.MaxScore)
+		for _, note := range a.ScanReview {
+			fmt.Printf("%s: check %s\n", filename, note)
+		}
+	}
+	return nil
+}
+
+// gradeSheet reads a scanned bubble sheet of the quiz and records it as an
+// attempt, graded against the quiz as the sheet was printed.
+func gradeSheet(ctx context.Context, store *quiz.SQLiteStore, q *quiz.Quiz, filename string, r io.Reader) quizzes.ScannedSheet {
+	sheet := quizzes.ScannedSheet{File: filename}
+	fail := func(err error) quizzes.ScannedSheet {
+		sheet.Error = err.Error()
+		return sheet
+	}
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return fail(fmt.Errorf("not a PNG or JPEG image: %w", err))
+	}
+	scan, err := paper.ScanSheet(img)
+	if err != nil {
+		return fail(err)
+	}
+	printing, err := store.FindPrinting(scan.Printing)
+	if errors.Is(err, gorm.ErrRecordNotFound) || err == nil && printing.QuizID != q.ID {
+		return fail(fmt.Errorf("the sheet was not printed for this quiz"))
+	}
+	if err != nil {
+		return fail(err)
+	}
+	printed := quiz.Quiz(printing.Quiz)
+	exam, err := paper.NewExam(&printed, scan.Variant)
+	if err != nil {
+		return fail(err)
+	}
+	answers, review, err := exam.SheetAnswers(scan)
+	if err != nil {
+		return fail(err)
+	}
+	sheet.Variant = scan.Variant
+	sheet.Attempt, err = store.RecordPaperAttempt(printing, scan.StudentNumber, answers, append(scan.Review, review...))
+	if err != nil {
+		return fail(err)
+	}
+	return sheet
+}
+
+// quizScansHandler shows the bubble sheets graded for the quiz, with the
+// marks flagged for review.
+func quizScansHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := findOwnQuiz(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	renderScans(w, r, http.StatusOK, q, nil)
+}
+
+// quizScanUploadHandler grades uploaded bubble sheet images.
+func quizScanUploadHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	q, err := findOwnQuiz(r)
+	if err != nil {
+		renderLookupError(w, r, err)
+		return
+	}
+	if err := parseUpload(w, r, maxScanSize); err != nil {
+		renderScans(w, r, http.StatusUnprocessableEntity, q, []quizzes.ScannedSheet{{File: "Upload", Error: err.Error()}})
+		return
+	}
+	var sheets []quizzes.ScannedSheet
+	for _, header := range r.MultipartForm.File["sheets"] {
+		f, err := header.Open()
+		if err != nil {
+			sheets = append(sheets, quizzes.ScannedSheet{File: header.Filename, Error: err.Error()})
+			continue
+		}
+		sheets = append(sheets, gradeSheet(r.Context(), ctx.Store, q, header.Filename, f))
+		f.Close()
+	}
+	if len(sheets) == 0 {
+		sheets = append(sheets, quizzes.ScannedSheet{File: "Upload", Error: "choose the sheet images to grade"})
+	}
+	renderScans(w, r, http.StatusOK, q, sheets)
+}
+
+func renderScans(w http.ResponseWriter, r *http.Request, status int, q *quiz.Quiz, sheets []quizzes.ScannedSheet) {
+	ctx := internals.GetAppContext(r)
+	recorded, err := ctx.Store.ListPaperAttempts(q.ID)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	renderStatus(w, r, status, quizzes.QuizScansPage(q, sheets, recorded))
+}
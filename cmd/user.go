@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mbsof31/go-quiz/internals"
+)
+
+// runUser manages users from the command line:
+//
+//	quiz user create -name name [-email address]
+func runUser(args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ExitOnError)
+	name := fs.String("name", "", "name of the user")
+	email := fs.String("email", "", "email address of the user")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: quiz user create -name name [-email address]")
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 || args[0] != "create" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	fs.Parse(args[1:])
+	if *name == "" || fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	user, err := store.CreateUser(*name, *email)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created user %d %q\n", user.ID, user.Name)
+	fmt.Printf("sign in at /signin/%s\n", user.Token)
+	return nil
+}
+
+// signInHandler makes the browser the user of the sign-in token.
+func signInHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := internals.GetAppContext(r)
+	user, err := ctx.Store.FindUserByToken(chi.URLParam(r, "token"))
+	if err != nil {
+		renderError(w, r, http.StatusNotFound, errors.New("unknown sign-in link"))
+		return
+	}
+	internals.SetUser(w, r, user.ID)
+	http.Redirect(w, r, "/quizzes", http.StatusSeeOther)
+}
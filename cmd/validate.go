@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// runValidate checks quiz files as import would, or the stored quizzes when
+// no file is named, and fails when anything is wrong:
+//
+//	quiz validate [-format auto|moodle|gift|qti|csv|xlsx|markdown|aiken|json] [file...]
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	format := fs.String("format", "auto", "file format: auto, moodle, gift, qti, csv, xlsx, markdown, aiken or json")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: quiz validate [-format auto|moodle|gift|qti|csv|xlsx|markdown|aiken|json] [file...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	// Validation needs no database, which files are checked without.
+	store := &quiz.SQLiteStore{}
+	found := 0
+	report := func(where string, problems []string) {
+		for _, p := range problems {
+			fmt.Printf("%s: %s\n", where, p)
+		}
+		found += len(problems)
+	}
+	if fs.NArg() == 0 {
+		store, err := openStore()
+		if err != nil {
+			return err
+		}
+		all, err := store.ListAllQuizzes()
+		if err != nil {
+			return err
+		}
+		for _, listed := range all {
+			q, err := store.FindQuizByID(listed.ID)
+			if err != nil {
+				return err
+			}
+			report(fmt.Sprintf("quiz %d %q", q.ID, q.Name), quizProblems(store, q))
+		}
+		fmt.Printf("checked %d quizzes\n", len(all))
+	}
+	for _, filename := range fs.Args() {
+		if *format == "json" || *format == "auto" && strings.EqualFold(filepath.Ext(filename), ".json") {
+			f, err := os.Open(filename)
+			if err != nil {
+				return err
+			}
+			e, err := quiz.ReadExport(f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", filename, err)
+			}
+			for _, q := range e.Quizzes {
+				report(fmt.Sprintf("%s: quiz %q", filename, q.Name), quizProblems(store, q))
+			}
+			fmt.Printf("%s: checked %d quizzes\n", filename, len(e.Quizzes))
+			continue
+		}
+		res, err := importFile(filename, *format)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		res.Check(store.ValidateQuestion)
+		var problems []string
+		for _, p := range res.Problems {
+			problems = append(problems, p.String())
+		}
+		report(filename, problems)
+		fmt.Printf("%s: checked %d quizzes\n", filename, len(res.Quizzes))
+	}
+	if found > 0 {
+		return fmt.Errorf("found %d problems", found)
+	}
+	return nil
+}
+
+// quizProblems lists everything the store would refuse in the quiz, where
+// storing it stops at the first.
+func quizProblems(store *quiz.SQLiteStore, q *quiz.Quiz) []string {
+	var problems []string
+	if err := store.ValidateQuiz(q); err != nil {
+		problems = append(problems, err.Error())
+	}
+	for i := range q.Questions {
+		question := &q.Questions[i]
+		if err := store.ValidateQuestion(question); err != nil {
+			problems = append(problems, fmt.Sprintf("question %d: %s", i+1, err))
+		}
+		for j := range question.Choices {
+			if err := store.ValidateChoice(&question.Choices[j]); err != nil {
+				problems = append(problems, fmt.Sprintf("question %d, choice %d: %s", i+1, j+1, err))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,56 @@
+// Package blob stores the binary files of quizzes, such as images, outside
+// the database. Rows keep only the key of their blob.
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs by key. Keys are slash-separated paths such as
+// "choices/3f2a….jpg".
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns ErrNotFound when there is no blob with the key.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Open is like Get but reads the blob on demand, so large files can be
+	// streamed in ranges without loading them whole.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete succeeds when there is no blob with the key.
+	Delete(ctx context.Context, key string) error
+}
+
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// ContentKey names a blob after its content, so a key never changes meaning
+// and identical files share a blob.
+func ContentKey(prefix string, data []byte) string {
+	sum := sha256.Sum256(data)
+	return prefix + "/" + hex.EncodeToString(sum[:]) + extensions[http.DetectContentType(data)]
+}
+
+// CheckKey rejects keys that could escape the storage root.
+func CheckKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.ContainsAny(key, "\\\x00") {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	return nil
+}
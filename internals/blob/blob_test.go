@@ -0,0 +1,114 @@
+package blob_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/blob"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T, store blob.Store) {
+	ctx := context.Background()
+	key := blob.ContentKey("choices", []byte("\x89PNG\r\n\x1a\n"))
+	assert.True(t, strings.HasPrefix(key, "choices/"))
+	assert.True(t, strings.HasSuffix(key, ".png"))
+
+	_, err := store.Get(ctx, key)
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+
+	assert.NoError(t, store.Put(ctx, key, []byte("first")))
+	assert.NoError(t, store.Put(ctx, key, []byte("second")))
+	data, err := store.Get(ctx, key)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("second"), data)
+
+	f, err := store.Open(ctx, key)
+	assert.NoError(t, err)
+	size, err := f.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), size)
+	_, err = f.Seek(2, io.SeekStart)
+	assert.NoError(t, err)
+	data, err = io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("cond"), data)
+	assert.NoError(t, f.Close())
+
+	assert.NoError(t, store.Delete(ctx, key))
+	assert.NoError(t, store.Delete(ctx, key))
+	_, err = store.Get(ctx, key)
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+	_, err = store.Open(ctx, key)
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+
+	for _, bad := range []string{"", "/abs", "a/../b", "a//b", "..", `a\b`} {
+		assert.Error(t, store.Put(ctx, bad, nil), bad)
+	}
+}
+
+func TestFSStore(t *testing.T) {
+	testStore(t, blob.NewFSStore(t.TempDir()))
+}
+
+// fakeS3 is a local stand-in for an S3-compatible service holding a single
+// bucket. It checks that requests are signed and that the signed payload
+// hash matches the body.
+type fakeS3 struct {
+	bucket string
+	mu     sync.Mutex
+	blobs  map[string][]byte
+}
+
+func (fs *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") ||
+		r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+	key, found := strings.CutPrefix(r.URL.Path, "/"+fs.bucket+"/")
+	if !found {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		fs.blobs[key] = body
+	case http.MethodGet, http.MethodHead:
+		data, found := fs.blobs[key]
+		if !found {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	case http.MethodDelete:
+		delete(fs.blobs, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{bucket: "media", blobs: make(map[string][]byte)})
+	defer server.Close()
+
+	testStore(t, blob.NewS3Store(server.URL, "media", "", "access", "secret"))
+
+	err := blob.NewS3Store(server.URL, "media", "", "intruder", "secret").Put(context.Background(), "a/b", []byte("x"))
+	assert.ErrorContains(t, err, "403")
+}
@@ -0,0 +1,87 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FSStore keeps blobs as files under Dir.
+type FSStore struct {
+	Dir string
+}
+
+func NewFSStore(dir string) *FSStore {
+	return &FSStore{Dir: dir}
+}
+
+var _ Store = (*FSStore)(nil)
+
+func (fss *FSStore) Put(ctx context.Context, key string, data []byte) error {
+	path, err := fss.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see half a blob.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (fss *FSStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := fss.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (fss *FSStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	path, err := fss.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (fss *FSStore) Delete(ctx context.Context, key string) error {
+	path, err := fss.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (fss *FSStore) path(key string) (string, error) {
+	if err := CheckKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(fss.Dir, filepath.FromSlash(key)), nil
+}
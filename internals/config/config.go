@@ -0,0 +1,383 @@
+// Package config loads the settings of the quiz application. Every setting
+// has a default, which a JSON config file overrides, then an environment
+// variable and then a command-line flag.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the effective configuration.
+type Config struct {
+	Addr          string   `json:"addr"` // Address the web server listens on
+	Server        Server   `json:"server"`
+	TLS           TLS      `json:"tls"`
+	DB            DB       `json:"db"`
+	Media         Media    `json:"media"`
+	SessionSecret string   `json:"session_secret"` // Signs the user cookie; generated when unset
+	LogLevel      string   `json:"log_level"`      // debug, info, warn or error
+	Features      Features `json:"features"`
+
+	sources map[string]string
+}
+
+// Server bounds the time the web server gives requests and connections.
+type Server struct {
+	ReadTimeout     Duration `json:"read_timeout"`     // To read a whole request, uploads included
+	WriteTimeout    Duration `json:"write_timeout"`    // To handle a request and write the response
+	IdleTimeout     Duration `json:"idle_timeout"`     // Before closing an idle keep-alive connection
+	ShutdownTimeout Duration `json:"shutdown_timeout"` // For requests in flight to finish on shutdown
+}
+
+// TLS serves HTTPS, with the certificate files or a generated development
+// certificate.
+type TLS struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	DevCert  bool   `json:"dev_cert"` // A self-signed certificate for localhost
+}
+
+// Duration is a time.Duration written like "30s" or "2m".
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for Duration
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("write durations as strings such as \"30s\"")
+	}
+	return setDuration(d, s)
+}
+
+func setDuration(d *Duration, s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration such as 30s", s)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// DB selects the database.
+type DB struct {
+	Driver string `json:"driver"` // Only sqlite is supported
+	DSN    string `json:"dsn"`    // For sqlite, the database file
+}
+
+// Media selects where uploaded media is stored.
+type Media struct {
+	Store string `json:"store"` // fs or s3
+	Dir   string `json:"dir"`   // Directory of the fs store
+	S3    S3     `json:"s3"`
+}
+
+// S3 addresses an S3-compatible bucket.
+type S3 struct {
+	Endpoint  string `json:"endpoint"`
+	Bucket    string `json:"bucket"`
+	Region    string `json:"region"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+}
+
+// Features turns parts of the application on and off.
+type Features struct {
+	CodeRunner bool `json:"code_runner"` // Run the tests of code questions on submission; off by default
+	PaperExams bool `json:"paper_exams"` // Print exams and grade scanned bubble sheets
+}
+
+// LogLevels are the valid values of LogLevel, from the most verbose.
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// Default returns the configuration used when nothing is set.
+func Default() *Config {
+	return &Config{
+		Addr: ":4000",
+		Server: Server{
+			ReadTimeout:     Duration(2 * time.Minute),
+			WriteTimeout:    Duration(2 * time.Minute),
+			IdleTimeout:     Duration(2 * time.Minute),
+			ShutdownTimeout: Duration(30 * time.Second),
+		},
+		DB:       DB{Driver: "sqlite", DSN: "database/quiz.db"},
+		Media:    Media{Store: "fs", Dir: "database/media", S3: S3{Region: "us-east-1"}},
+		LogLevel: "info",
+		Features: Features{PaperExams: true},
+	}
+}
+
+// setting is a value of the configuration with the names it is set by.
+type setting struct {
+	name   string // In the config file, dotted, and in the printed configuration
+	env    string
+	flag   string // Empty for secrets, which would show in the process list
+	usage  string
+	secret bool
+	value  func(c *Config) interface{} // A *string, *bool or *Duration in the configuration
+}
+
+var settings = []setting{
+	{"addr", "QUIZ_ADDR", "addr", "address to listen on", false, func(c *Config) interface{} { return &c.Addr }},
+	{"server.read_timeout", "QUIZ_READ_TIMEOUT", "read-timeout", "time to read a request, uploads included", false, func(c *Config) interface{} { return &c.Server.ReadTimeout }},
+	{"server.write_timeout", "QUIZ_WRITE_TIMEOUT", "write-timeout", "time to handle a request and write the response", false, func(c *Config) interface{} { return &c.Server.WriteTimeout }},
+	{"server.idle_timeout", "QUIZ_IDLE_TIMEOUT", "idle-timeout", "time before closing idle connections", false, func(c *Config) interface{} { return &c.Server.IdleTimeout }},
+	{"server.shutdown_timeout", "QUIZ_SHUTDOWN_TIMEOUT", "shutdown-timeout", "time for requests in flight to finish on shutdown", false, func(c *Config) interface{} { return &c.Server.ShutdownTimeout }},
+	{"tls.cert_file", "QUIZ_TLS_CERT_FILE", "tls-cert", "certificate file to serve HTTPS with", false, func(c *Config) interface{} { return &c.TLS.CertFile }},
+	{"tls.key_file", "QUIZ_TLS_KEY_FILE", "tls-key", "private key file of the certificate", false, func(c *Config) interface{} { return &c.TLS.KeyFile }},
+	{"tls.dev_cert", "QUIZ_TLS_DEV_CERT", "tls-dev-cert", "serve HTTPS with a self-signed certificate for localhost", false, func(c *Config) interface{} { return &c.TLS.DevCert }},
+	{"db.driver", "QUIZ_DB_DRIVER", "db-driver", "database driver: sqlite", false, func(c *Config) interface{} { return &c.DB.Driver }},
+	{"db.dsn", "QUIZ_DB_DSN", "db", "database to open, the file for sqlite", false, func(c *Config) interface{} { return &c.DB.DSN }},
+	{"media.store", "QUIZ_MEDIA_STORE", "media-store", "where uploaded media is stored: fs or s3", false, func(c *Config) interface{} { return &c.Media.Store }},
+	{"media.dir", "QUIZ_MEDIA_DIR", "media", "directory of uploaded media in the fs store", false, func(c *Config) interface{} { return &c.Media.Dir }},
+	{"media.s3.endpoint", "QUIZ_S3_ENDPOINT", "", "", false, func(c *Config) interface{} { return &c.Media.S3.Endpoint }},
+	{"media.s3.bucket", "QUIZ_S3_BUCKET", "", "", false, func(c *Config) interface{} { return &c.Media.S3.Bucket }},
+	{"media.s3.region", "QUIZ_S3_REGION", "", "", false, func(c *Config) interface{} { return &c.Media.S3.Region }},
+	{"media.s3.access_key", "QUIZ_S3_ACCESS_KEY", "", "", false, func(c *Config) interface{} { return &c.Media.S3.AccessKey }},
+	{"media.s3.secret_key", "QUIZ_S3_SECRET_KEY", "", "", true, func(c *Config) interface{} { return &c.Media.S3.SecretKey }},
+	{"session_secret", "QUIZ_SESSION_SECRET", "", "", true, func(c *Config) interface{} { return &c.SessionSecret }},
+	{"log_level", "QUIZ_LOG_LEVEL", "log-level", "log level: debug, info, warn or error", false, func(c *Config) interface{} { return &c.LogLevel }},
+	{"features.code_runner", "QUIZ_FEATURE_CODE_RUNNER", "code-runner", "run the tests of code questions", false, func(c *Config) interface{} { return &c.Features.CodeRunner }},
+	{"features.paper_exams", "QUIZ_FEATURE_PAPER_EXAMS", "paper-exams", "print exams and grade scanned bubble sheets", false, func(c *Config) interface{} { return &c.Features.PaperExams }},
+}
+
+// Loader reads the configuration from a file, the environment and the
+// flags it registers.
+type Loader struct {
+	// Getenv looks up environment variables, os.Getenv unless set.
+	Getenv func(string) string
+
+	file  string
+	flags map[string]string
+}
+
+// NewLoader registers -config and the flags of the settings on fs. The flags
+// take effect in Load, once fs is parsed.
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{Getenv: os.Getenv, flags: make(map[string]string)}
+	fs.StringVar(&l.file, "config", "", "JSON config file, also set by QUIZ_CONFIG")
+	defaults := Default()
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		s := s
+		usage := fmt.Sprintf("%s (%s, default %s)", s.usage, s.env, format(s.value(defaults)))
+		record := func(v string) error {
+			l.flags[s.name] = v
+			return nil
+		}
+		if _, ok := s.value(defaults).(*bool); ok {
+			fs.BoolFunc(s.flag, usage, record)
+		} else {
+			fs.Func(s.flag, usage, record)
+		}
+	}
+	return l
+}
+
+// Load returns the configuration, or an error naming every setting that is
+// missing or wrong.
+func (l *Loader) Load() (*Config, error) {
+	c := Default()
+	c.sources = make(map[string]string)
+	file := l.file
+	if file == "" {
+		file = l.Getenv("QUIZ_CONFIG")
+	}
+	if file != "" {
+		if err := c.readFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if v := l.Getenv(s.env); v != "" {
+			if err := set(s.value(c), v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+			c.sources[s.name] = s.env
+		}
+		if v, found := l.flags[s.name]; found {
+			if err := set(s.value(c), v); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", s.flag, err))
+			}
+			c.sources[s.name] = "-" + s.flag
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readFile overrides the defaults with the settings of the JSON file, which
+// must not name unknown settings.
+func (c *Config) readFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	// Mark what the file set by reading it again on its own.
+	var keys map[string]interface{}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	for _, name := range flatten("", keys) {
+		c.sources[name] = filename
+	}
+	return nil
+}
+
+func flatten(prefix string, m map[string]interface{}) []string {
+	var names []string
+	for k, v := range m {
+		if sub, ok := v.(map[string]interface{}); ok {
+			names = append(names, flatten(prefix+k+".", sub)...)
+			continue
+		}
+		names = append(names, prefix+k)
+	}
+	return names
+}
+
+func set(p interface{}, v string) error {
+	switch p := p.(type) {
+	case *string:
+		*p = v
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", v)
+		}
+		*p = b
+	case *Duration:
+		return setDuration(p, v)
+	}
+	return nil
+}
+
+func format(p interface{}) string {
+	switch p := p.(type) {
+	case *string:
+		if *p == "" {
+			return `""`
+		}
+		return *p
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *Duration:
+		return p.String()
+	}
+	return ""
+}
+
+// Validate reports every setting that is missing or wrong.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	if _, port, err := net.SplitHostPort(c.Addr); err != nil {
+		fail("addr: %q is not host:port", c.Addr)
+	} else if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		fail("addr: invalid port %q", port)
+	}
+	timeouts := []struct {
+		name string
+		d    Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+	}
+	for _, t := range timeouts {
+		if t.d <= 0 {
+			fail("%s: must be longer than 0s", t.name)
+		}
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		fail("tls: set both the cert_file and key_file")
+	} else if c.TLS.CertFile != "" && c.TLS.DevCert {
+		fail("tls.dev_cert: turn it off to use the cert_file")
+	}
+	if c.DB.Driver != "sqlite" {
+		fail("db.driver: unsupported driver %q, use sqlite", c.DB.Driver)
+	}
+	if c.DB.DSN == "" {
+		fail("db.dsn: name the database file")
+	}
+	switch c.Media.Store {
+	case "fs":
+		if c.Media.Dir == "" {
+			fail("media.dir: name the directory of the fs store")
+		}
+	case "s3":
+		if u, err := url.Parse(c.Media.S3.Endpoint); err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			fail("media.s3.endpoint: %q is not an http or https URL", c.Media.S3.Endpoint)
+		}
+		if c.Media.S3.Bucket == "" {
+			fail("media.s3.bucket: name the bucket")
+		}
+		if c.Media.S3.AccessKey == "" || c.Media.S3.SecretKey == "" {
+			fail("media.s3: set the access_key and secret_key")
+		}
+	default:
+		fail("media.store: unknown store %q, use fs or s3", c.Media.Store)
+	}
+	if c.SessionSecret != "" && len(c.SessionSecret) < 32 {
+		fail("session_secret: use at least 32 characters")
+	}
+	valid := false
+	for _, level := range LogLevels {
+		valid = valid || c.LogLevel == level
+	}
+	if !valid {
+		fail("log_level: unknown level %q, use %s", c.LogLevel, strings.Join(LogLevels, ", "))
+	}
+	return errors.Join(errs...)
+}
+
+// Print writes every setting with its value and where it was set, secrets
+// hidden.
+func (c *Config) Print(w io.Writer) {
+	width := 0
+	for _, s := range settings {
+		width = max(width, len(s.name))
+	}
+	for _, s := range settings {
+		value := format(s.value(c))
+		if s.secret && value != `""` {
+			value = "(hidden)"
+		}
+		source := c.sources[s.name]
+		if source == "" {
+			source = "default"
+		}
+		fmt.Fprintf(w, "%-*s = %s  [%s]\n", width, s.name, value, source)
+	}
+}
@@ -0,0 +1,131 @@
+package config_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// load loads the configuration from the environment and the flags.
+func load(t *testing.T, env map[string]string, args ...string) (*config.Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("quiz", flag.ContinueOnError)
+	loader := config.NewLoader(fs)
+	loader.Getenv = func(name string) string { return env[name] }
+	require.NoError(t, fs.Parse(args))
+	return loader.Load()
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	c, err := load(t, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ":4000", c.Addr)
+	assert.Equal(t, config.DB{Driver: "sqlite", DSN: "database/quiz.db"}, c.DB)
+	assert.Equal(t, "fs", c.Media.Store)
+	assert.Equal(t, "database/media", c.Media.Dir)
+	assert.Equal(t, "info", c.LogLevel)
+	assert.False(t, c.Features.CodeRunner)
+	assert.True(t, c.Features.PaperExams)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "quiz.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{
+		"addr": "127.0.0.1:8000",
+		"db": {"dsn": "file.db"},
+		"log_level": "warn",
+		"features": {"paper_exams": false}
+	}`), 0o644))
+
+	c, err := load(t, map[string]string{"QUIZ_CONFIG": file, "QUIZ_DB_DSN": "env.db", "QUIZ_LOG_LEVEL": "error"}, "-log-level", "debug")
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:8000", c.Addr, "from the file")
+	assert.Equal(t, "env.db", c.DB.DSN, "the environment overrides the file")
+	assert.Equal(t, "debug", c.LogLevel, "flags override the environment")
+	assert.False(t, c.Features.PaperExams)
+	assert.False(t, c.Features.CodeRunner, "defaults stay when the file leaves them out")
+
+	var out bytes.Buffer
+	c.Print(&out)
+	assert.Contains(t, out.String(), "addr                    = 127.0.0.1:8000  ["+file+"]\n")
+	assert.Contains(t, out.String(), "db.dsn                  = env.db  [QUIZ_DB_DSN]\n")
+	assert.Contains(t, out.String(), "db.driver               = sqlite  [default]\n")
+	assert.Contains(t, out.String(), "log_level               = debug  [-log-level]\n")
+}
+
+func TestLoad_S3(t *testing.T) {
+	env := map[string]string{
+		"QUIZ_MEDIA_STORE":    "s3",
+		"QUIZ_S3_ENDPOINT":    "http://localhost:9000",
+		"QUIZ_S3_BUCKET":      "quiz",
+		"QUIZ_S3_ACCESS_KEY":  "minio",
+		"QUIZ_S3_SECRET_KEY":  "minio-secret",
+		"QUIZ_SESSION_SECRET": "0123456789abcdef0123456789abcdef",
+	}
+	c, err := load(t, env)
+	require.NoError(t, err)
+	assert.Equal(t, "s3", c.Media.Store)
+	assert.Equal(t, config.S3{Endpoint: "http://localhost:9000", Bucket: "quiz", Region: "us-east-1", AccessKey: "minio", SecretKey: "minio-secret"}, c.Media.S3)
+
+	var out bytes.Buffer
+	c.Print(&out)
+	assert.Contains(t, out.String(), "media.store             = s3  [QUIZ_MEDIA_STORE]\n")
+	assert.Contains(t, out.String(), "media.s3.secret_key     = (hidden)  [QUIZ_S3_SECRET_KEY]\n")
+	assert.Contains(t, out.String(), "session_secret          = (hidden)  [QUIZ_SESSION_SECRET]\n")
+	assert.NotContains(t, out.String(), "minio-secret")
+
+	c, err = load(t, env, "-media-store", "fs")
+	require.NoError(t, err)
+	assert.Equal(t, "fs", c.Media.Store)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	_, err := load(t, map[string]string{"QUIZ_FEATURE_CODE_RUNNER": "maybe"})
+	assert.EqualError(t, err, `QUIZ_FEATURE_CODE_RUNNER: "maybe" is not true or false`)
+
+	_, err = load(t, map[string]string{"QUIZ_MEDIA_STORE": "s3", "QUIZ_SESSION_SECRET": "short"},
+		"-addr", "4000", "-db-driver", "postgres", "-db", "", "-log-level", "loud")
+	assert.EqualError(t, err, `addr: "4000" is not host:port
+db.driver: unsupported driver "postgres", use sqlite
+db.dsn: name the database file
+media.s3.endpoint: "" is not an http or https URL
+media.s3.bucket: name the bucket
+media.s3: set the access_key and secret_key
+session_secret: use at least 32 characters
+log_level: unknown level "loud", use debug, info, warn, error`)
+
+	file := filepath.Join(t.TempDir(), "quiz.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"adr": ":80"}`), 0o644))
+	_, err = load(t, nil, "-config", file)
+	assert.EqualError(t, err, file+`: json: unknown field "adr"`)
+}
+
+func TestLoad_Server(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "quiz.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"server": {"read_timeout": "5m"}, "tls": {"dev_cert": true}}`), 0o644))
+	c, err := load(t, map[string]string{"QUIZ_SHUTDOWN_TIMEOUT": "10s"}, "-config", file, "-idle-timeout", "1m30s")
+	require.NoError(t, err)
+	assert.Equal(t, config.Duration(5*time.Minute), c.Server.ReadTimeout)
+	assert.Equal(t, config.Duration(2*time.Minute), c.Server.WriteTimeout)
+	assert.Equal(t, config.Duration(90*time.Second), c.Server.IdleTimeout)
+	assert.Equal(t, config.Duration(10*time.Second), c.Server.ShutdownTimeout)
+	assert.True(t, c.TLS.DevCert)
+
+	_, err = load(t, map[string]string{"QUIZ_WRITE_TIMEOUT": "soon"})
+	assert.EqualError(t, err, `QUIZ_WRITE_TIMEOUT: "soon" is not a duration such as 30s`)
+	_, err = load(t, nil, "-read-timeout", "0s", "-tls-cert", "cert.pem")
+	assert.EqualError(t, err, "server.read_timeout: must be longer than 0s\ntls: set both the cert_file and key_file")
+	_, err = load(t, nil, "-tls-cert", "cert.pem", "-tls-key", "key.pem", "-tls-dev-cert")
+	assert.EqualError(t, err, "tls.dev_cert: turn it off to use the cert_file")
+
+	require.NoError(t, os.WriteFile(file, []byte(`{"server": {"idle_timeout": 60}}`), 0o644))
+	_, err = load(t, nil, "-config", file)
+	assert.ErrorContains(t, err, `write durations as strings such as "30s"`)
+}
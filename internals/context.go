@@ -7,7 +7,11 @@ import (
 )
 
 type AppContext struct {
-	Store *quiz.SQLiteStore
+	Store  *quiz.SQLiteStore
+	Runner quiz.CodeRunner
+	UserID uint
+
+	sessionSecret string
 }
 
 func (ctx *AppContext) WithContext(r *http.Request) *http.Request {
@@ -28,3 +32,14 @@ func StoreMiddleware(store *quiz.SQLiteStore) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// RunnerMiddleware makes the code runner available to handlers. It must run
+// after StoreMiddleware.
+func RunnerMiddleware(runner quiz.CodeRunner) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			GetAppContext(r).Runner = runner
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package formats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+var (
+	// aikenChoice matches a choice line such as "A. Paris" or "b) Lyon".
+	// Word pastes no-break spaces and tabs after the letter.
+	aikenChoice = regexp.MustCompile(`^([A-Za-z])[.)][\s\x{00a0}]+(.*)$`)
+	// aikenAnswer matches the answer line, "ANSWER: B", or "ANSWER: A, C"
+	// for a multi-choice question.
+	aikenAnswer = regexp.MustCompile(`(?i)^answer\s*:\s*(.*)$`)
+	// aikenAnswerLine finds an answer line in a whole file.
+	aikenAnswerLine = regexp.MustCompile(`(?m)^[ \t]*ANSWER[ \t]*:[ \t]*[A-Za-z]`)
+)
+
+// ImportAiken reads questions in the Aiken format of Moodle, which teachers
+// write in word processors:
+//
+//	What is the capital of France?
+//	A. Paris
+//	B. Lyon
+//	ANSWER: A
+//
+// The question text may run over several lines and paragraphs, up to its
+// first choice, and every question ends with its ANSWER line. Listing more
+// than one letter, as in "ANSWER: A, C", makes a multi-choice question,
+// which Moodle does not read.
+func ImportAiken(r io.Reader, name string) (*Result, error) {
+	res := &Result{}
+	q := quiz.NewQuiz()
+	q.Name = quizName(name)
+
+	var (
+		text    []string
+		choices []quiz.Choice
+		letters string
+		start   int
+		blank   bool
+	)
+	reset := func() {
+		text, choices, letters, start = nil, nil, "", 0
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), utf8BOM))
+		wasBlank := blank
+		blank = line == ""
+		switch {
+		case line == "":
+			if start > 0 && len(choices) == 0 {
+				text = append(text, "")
+			}
+		case start == 0:
+			start, text = n, []string{line}
+		case aikenAnswer.MatchString(line) && len(choices) > 0:
+			content := strings.TrimSpace(strings.Join(text, "\n"))
+			question, err := aikenQuestion(content, choices, letters, aikenAnswer.FindStringSubmatch(line)[1])
+			if err != nil {
+				res.problem(start, summary(content), "skipped: %v", err)
+			} else {
+				res.add(q, question, start)
+			}
+			reset()
+		case aikenChoice.MatchString(line):
+			m := aikenChoice.FindStringSubmatch(line)
+			letters += strings.ToUpper(m[1])
+			choices = append(choices, quiz.Choice{Content: strings.TrimSpace(m[2])})
+		case len(choices) == 0:
+			text = append(text, line)
+		case wasBlank:
+			// A paragraph after the choices starts the next question.
+			res.problem(start, summary(strings.Join(text, " ")), "skipped: no ANSWER line after the choices")
+			reset()
+			start, text = n, []string{line}
+		default:
+			// Word wraps long choices onto the next line.
+			last := &choices[len(choices)-1]
+			last.Content += " " + line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading Aiken: %w", err)
+	}
+	if start > 0 {
+		res.problem(start, summary(strings.Join(text, " ")), "skipped: no ANSWER line after the choices")
+	}
+	if len(q.Questions) > 0 {
+		res.Quizzes = append(res.Quizzes, q)
+	}
+	return res, nil
+}
+
+// aikenQuestion builds a choice question from its parsed parts. letters
+// holds the letter of each choice and answer the rest of the ANSWER line.
+func aikenQuestion(content string, choices []quiz.Choice, letters, answer string) (quiz.Question, error) {
+	if content == "" {
+		return quiz.Question{}, fmt.Errorf("the question is empty")
+	}
+	for i := range letters {
+		if letters[i] != byte('A'+i) {
+			return quiz.Question{}, fmt.Errorf("choice %c is not %c, letter the choices A, B, C and so on", letters[i], 'A'+i)
+		}
+	}
+	correct := strings.FieldsFunc(strings.ToUpper(answer), func(r rune) bool {
+		return r == ',' || r == ' ' || r == ';'
+	})
+	if len(correct) == 0 {
+		return quiz.Question{}, fmt.Errorf("the ANSWER line names no choice")
+	}
+	for _, letter := range correct {
+		i := strings.Index(letters, letter)
+		if len(letter) != 1 || i < 0 {
+			return quiz.Question{}, fmt.Errorf("ANSWER %s is not one of the choices A to %c", letter, letters[len(letters)-1])
+		}
+		choices[i].IsCorrect = true
+	}
+	question := quiz.Question{Type: quiz.TypeSingleChoice, Content: content, Choices: choices}
+	if len(correct) > 1 {
+		question.Type = quiz.TypeMultiChoice
+	}
+	return question, nil
+}
+
+// ExportAiken writes the choice questions of the quiz in the Aiken format.
+// The other questions, and the details Aiken has no place for, are left out
+// and returned as problems.
+func ExportAiken(w io.Writer, q *quiz.Quiz) ([]Problem, error) {
+	var b strings.Builder
+	var problems []Problem
+	for i := range q.Questions {
+		question := &q.Questions[i]
+		problem := func(format string, args ...interface{}) {
+			problems = append(problems, Problem{Question: summary(question.Prompt()), Message: fmt.Sprintf(format, args...)})
+		}
+		if question.Type != quiz.TypeSingleChoice && question.Type != quiz.TypeMultiChoice {
+			problem("skipped: Aiken has only choice questions, not %s", question.Type)
+			continue
+		}
+		if len(question.Choices) > 26 {
+			problem("skipped: Aiken letters at most 26 choices")
+			continue
+		}
+
+		content := strings.TrimSpace(question.Content)
+		for _, line := range strings.Split(content, "\n")[1:] {
+			line = strings.TrimSpace(line)
+			if aikenChoice.MatchString(line) || aikenAnswer.MatchString(line) {
+				problem("the question line %q reads back as a choice or answer", summary(line))
+				break
+			}
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(content + "\n")
+		var correct []string
+		for j, choice := range question.Choices {
+			letter := string(rune('A' + j))
+			if strings.Contains(choice.Content, "\n") {
+				problem("line breaks of choice %s left out", letter)
+			}
+			fmt.Fprintf(&b, "%s. %s\n", letter, strings.Join(strings.Fields(choice.Content), " "))
+			if choice.IsCorrect {
+				correct = append(correct, letter)
+			}
+		}
+		if len(correct) == 0 {
+			problem("no choice is correct, ANSWER names none")
+		}
+		if question.Type == quiz.TypeSingleChoice && len(correct) > 1 {
+			problem("written as multi-choice: %d choices are correct", len(correct))
+		}
+		fmt.Fprintf(&b, "ANSWER: %s\n", strings.Join(correct, ", "))
+
+		for _, choice := range question.Choices {
+			if choice.Feedback != "" {
+				problem("choice feedback left out")
+				break
+			}
+		}
+		if question.Explanation != "" {
+			problem("explanation left out")
+		}
+		if question.Points > 0 {
+			problem("points left out")
+		}
+		if question.Penalty > 0 || question.PenaltyFraction > 0 {
+			problem("penalty left out")
+		}
+		if question.ImageKey != "" || question.MediaKey != "" {
+			problem("media left out")
+		}
+		for _, choice := range question.Choices {
+			if choice.ThumbKey != "" {
+				problem("choice thumbnails left out")
+				break
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return problems, err
+}
+
+// looksAiken tells Aiken text from GIFT, which share the .txt extension, by
+// its ANSWER lines.
+func looksAiken(data []byte) bool {
+	return aikenAnswerLine.Match(data)
+}
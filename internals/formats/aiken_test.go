@@ -0,0 +1,116 @@
+package formats_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/formats"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const capitalsAiken = "\ufeffWhat is the capital of France?\n" +
+	"A. Paris\n" +
+	"B. Lyon\n" +
+	"ANSWER: A\n" +
+	"\n" +
+	"Which cities lie on the Danube?\n" +
+	"\n" +
+	"Pick all of them.\n" +
+	"a) Vienna\n" +
+	"b) Prague\n" +
+	"c) Budapest, the capital of\n" +
+	"Hungary\n" +
+	"Answer: a, c\n" +
+	"\n" +
+	"Forgotten answer\n" +
+	"A. yes\n" +
+	"B. no\n" +
+	"\n" +
+	"Skipped letter\n" +
+	"A. one\n" +
+	"C. two\n" +
+	"ANSWER: A\n" +
+	"Missing choice\n" +
+	"A. one\n" +
+	"B. two\n" +
+	"ANSWER: D\n" +
+	"Last\n" +
+	"A. one\n"
+
+func TestImportAiken(t *testing.T) {
+	res, err := formats.ImportAiken(strings.NewReader(capitalsAiken), "capitals.txt")
+	require.NoError(t, err)
+
+	var messages []string
+	for _, p := range res.Problems {
+		messages = append(messages, p.String())
+	}
+	assert.Equal(t, []string{
+		`line 15, "Forgotten answer": skipped: no ANSWER line after the choices`,
+		`line 19, "Skipped letter": skipped: choice C is not B, letter the choices A, B, C and so on`,
+		`line 23, "Missing choice": skipped: ANSWER D is not one of the choices A to B`,
+		`line 27, "Last": skipped: no ANSWER line after the choices`,
+	}, messages)
+
+	require.Len(t, res.Quizzes, 1)
+	q := res.Quizzes[0]
+	assert.Equal(t, "capitals", q.Name)
+	assert.Equal(t, []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "What is the capital of France?",
+			Choices: []quiz.Choice{{Content: "Paris", IsCorrect: true}, {Content: "Lyon"}}},
+		{Type: quiz.TypeMultiChoice, Content: "Which cities lie on the Danube?\n\nPick all of them.",
+			Choices: []quiz.Choice{{Content: "Vienna", IsCorrect: true}, {Content: "Prague"}, {Content: "Budapest, the capital of Hungary", IsCorrect: true}}},
+	}, q.Questions)
+}
+
+func TestImport_DetectsAiken(t *testing.T) {
+	res, err := formats.Import(strings.NewReader(capitalsAiken), "capitals.txt", "auto")
+	require.NoError(t, err)
+	require.Len(t, res.Quizzes, 1)
+	assert.Len(t, res.Quizzes[0].Questions, 2)
+
+	res, err = formats.Import(strings.NewReader("What is 2+2? {=4 ~5}\n"), "sums.txt", "auto")
+	require.NoError(t, err)
+	require.Len(t, res.Quizzes, 1)
+	assert.Equal(t, "What is 2+2?", res.Quizzes[0].Questions[0].Content)
+}
+
+func TestAiken_RoundTrip(t *testing.T) {
+	q := &quiz.Quiz{Name: "Lossy", Questions: []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "Capital of Italy?\n\nOne answer.", Explanation: "Since 1871.",
+			Choices: []quiz.Choice{{Content: "Rome", IsCorrect: true, Feedback: "Yes"}, {Content: "Milan\nor Turin"}}},
+		{Type: quiz.TypeMultiChoice, Content: "Primes?", Points: 2,
+			Choices: []quiz.Choice{{Content: "2", IsCorrect: true}, {Content: "3", IsCorrect: true}, {Content: "4"}}},
+		{Type: quiz.TypeEssay, Content: "Discuss"},
+	}}
+	var buf bytes.Buffer
+	problems, err := formats.ExportAiken(&buf, q)
+	require.NoError(t, err)
+	var messages []string
+	for _, p := range problems {
+		messages = append(messages, p.String())
+	}
+	assert.Equal(t, []string{
+		`"Capital of Italy? One answer.": line breaks of choice B left out`,
+		`"Capital of Italy? One answer.": choice feedback left out`,
+		`"Capital of Italy? One answer.": explanation left out`,
+		`"Primes?": points left out`,
+		`"Discuss": skipped: Aiken has only choice questions, not essay`,
+	}, messages)
+	assert.Equal(t, "Capital of Italy?\n\nOne answer.\nA. Rome\nB. Milan or Turin\nANSWER: A\n"+
+		"\nPrimes?\nA. 2\nB. 3\nC. 4\nANSWER: A, B\n", buf.String())
+
+	res, err := formats.ImportAiken(&buf, "lossy.txt")
+	require.NoError(t, err)
+	assert.Empty(t, res.Problems)
+	require.Len(t, res.Quizzes, 1)
+	assert.Equal(t, []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "Capital of Italy?\n\nOne answer.",
+			Choices: []quiz.Choice{{Content: "Rome", IsCorrect: true}, {Content: "Milan or Turin"}}},
+		{Type: quiz.TypeMultiChoice, Content: "Primes?",
+			Choices: []quiz.Choice{{Content: "2", IsCorrect: true}, {Content: "3", IsCorrect: true}, {Content: "4"}}},
+	}, res.Quizzes[0].Questions)
+}
@@ -0,0 +1,67 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// utf8BOM starts the CSV files written for spreadsheets, which otherwise
+// take them for the local 8-bit encoding.
+const utf8BOM = "\ufeff"
+
+// ImportCSV reads questions from a CSV file laid out as described at
+// sheetColumns. Fields may be separated by commas, semicolons or tabs, as
+// spreadsheets save them in different locales; the header row tells which.
+func ImportCSV(r io.Reader, name string) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte(utf8BOM))
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.Comma = csvSeparator(data)
+	cr.FieldsPerRecord = -1
+	var rows []sheetRow
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading CSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		rows = append(rows, sheetRow{line: line, cells: record})
+	}
+	return importSheet(rows, name)
+}
+
+// csvSeparator returns the most frequent of the separators on the first line.
+func csvSeparator(data []byte) rune {
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+	separator, most := ',', 0
+	for _, r := range []rune{',', ';', '\t'} {
+		if n := bytes.Count(first, []byte(string(r))); n > most {
+			separator, most = r, n
+		}
+	}
+	return separator
+}
+
+// ExportCSV writes the questions of the quiz as a CSV file, laid out as
+// described at sheetColumns. The question details that the columns cannot
+// hold are left out and returned as problems.
+func ExportCSV(w io.Writer, q *quiz.Quiz) ([]Problem, error) {
+	rows, problems := exportSheet(q)
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return problems, err
+	}
+	cw := csv.NewWriter(w)
+	cw.WriteAll(rows)
+	return problems, cw.Error()
+}
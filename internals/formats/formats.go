@@ -0,0 +1,194 @@
+// Package formats converts quizzes to and from the files of other quiz tools.
+//
+// Importers map what they can onto the quiz model and report the rest as
+// problems instead of failing, so one odd question does not hold up a
+// migration.
+package formats
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// Problem is a question an import skipped or could only partly convert.
+type Problem struct {
+	Line     int    // In the source file, zero when unknown
+	Question string // Name or start of the question
+	Message  string
+}
+
+func (p Problem) String() string {
+	var where []string
+	if p.Line > 0 {
+		where = append(where, fmt.Sprintf("line %d", p.Line))
+	}
+	if p.Question != "" {
+		where = append(where, fmt.Sprintf("%q", p.Question))
+	}
+	if len(where) == 0 {
+		return p.Message
+	}
+	return strings.Join(where, ", ") + ": " + p.Message
+}
+
+// Result holds the quizzes read from a file and the problems met on the way.
+type Result struct {
+	Quizzes  []*quiz.Quiz
+	Problems []Problem
+	// Media holds the images and recordings the questions refer to, by blob
+	// key, to be stored along with the quizzes.
+	Media map[string][]byte
+
+	// lines holds the source line of each question, where the importer
+	// knows it, so that Check can point at the rows it rejects.
+	lines map[*quiz.Quiz][]int
+}
+
+// add appends a question that starts on the line to the quiz.
+func (res *Result) add(q *quiz.Quiz, question quiz.Question, line int) {
+	if res.lines == nil {
+		res.lines = make(map[*quiz.Quiz][]int)
+	}
+	lines := res.lines[q]
+	for len(lines) < len(q.Questions) {
+		lines = append(lines, 0)
+	}
+	res.lines[q] = append(lines, line)
+	q.Questions = append(q.Questions, question)
+}
+
+// line returns the source line of the i-th question of the quiz, or zero.
+func (res *Result) line(q *quiz.Quiz, i int) int {
+	if lines := res.lines[q]; i < len(lines) {
+		return lines[i]
+	}
+	return 0
+}
+
+func (res *Result) problem(line int, question, format string, args ...interface{}) {
+	res.Problems = append(res.Problems, Problem{Line: line, Question: question, Message: fmt.Sprintf(format, args...)})
+}
+
+// Check drops the questions that validate rejects, and then the quizzes left
+// without questions, reporting each one.
+func (res *Result) Check(validate func(*quiz.Question) error) {
+	quizzes := res.Quizzes[:0]
+	for _, q := range res.Quizzes {
+		questions := q.Questions[:0]
+		var lines []int
+		for i, question := range q.Questions {
+			line := res.line(q, i)
+			if err := validate(&question); err != nil {
+				res.problem(line, summary(question.Content), "skipped: %v", err)
+				continue
+			}
+			questions = append(questions, question)
+			lines = append(lines, line)
+		}
+		q.Questions = questions
+		if res.lines != nil {
+			res.lines[q] = lines
+		}
+		if len(q.Questions) == 0 {
+			res.problem(0, q.Name, "skipped quiz: no question could be imported")
+			continue
+		}
+		quizzes = append(quizzes, q)
+	}
+	res.Quizzes = quizzes
+}
+
+// Importer reads the quizzes of a file. name is the file name, which names
+// the quiz when the file does not.
+type Importer func(r io.Reader, name string) (*Result, error)
+
+// Importers lists the importers by format name.
+var Importers = map[string]Importer{
+	"moodle":   ImportMoodleXML,
+	"gift":     ImportGIFT,
+	"qti":      ImportQTI,
+	"csv":      ImportCSV,
+	"xlsx":     ImportXLSX,
+	"markdown": ImportMarkdown,
+	"aiken":    ImportAiken,
+}
+
+// Detect guesses the format of a file from its extension.
+func Detect(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".xml":
+		return "moodle", nil
+	case ".gift", ".txt":
+		return "gift", nil
+	case ".zip":
+		return "qti", nil
+	case ".csv":
+		return "csv", nil
+	case ".xlsx":
+		return "xlsx", nil
+	case ".md", ".markdown":
+		return "markdown", nil
+	case ".aiken":
+		return "aiken", nil
+	}
+	return "", fmt.Errorf("cannot tell the format of %q, name it", filepath.Base(filename))
+}
+
+// Import reads a file in the named format, or in the format its name
+// suggests when format is "" or "auto". Text files are read as Aiken when
+// they have ANSWER lines and as GIFT otherwise.
+func Import(r io.Reader, filename, format string) (*Result, error) {
+	if format == "" || format == "auto" {
+		detected, err := Detect(filename)
+		if err != nil {
+			return nil, err
+		}
+		format = detected
+		if strings.EqualFold(filepath.Ext(filename), ".txt") {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return nil, err
+			}
+			if looksAiken(data) {
+				format = "aiken"
+			}
+			r = bytes.NewReader(data)
+		}
+	}
+	importer, found := Importers[format]
+	if !found {
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return importer(r, filename)
+}
+
+// quizName names a quiz after a file, dropping its directory and extension.
+func quizName(filename string) string {
+	base := filepath.Base(filename)
+	if name := strings.TrimSuffix(base, filepath.Ext(base)); name != "" && name != "." {
+		return name
+	}
+	return "Imported quiz"
+}
+
+// summary shortens question content for problem reports.
+func summary(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if len(content) > 60 {
+		cut := 60
+		for cut > 0 && !isRuneStart(content[cut]) {
+			cut--
+		}
+		return content[:cut] + "…"
+	}
+	return content
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,388 @@
+package formats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// giftBlock is the text of one GIFT question and the line it starts on.
+type giftBlock struct {
+	line int
+	text string
+}
+
+// giftItem is an answer of a GIFT answer block, such as "~%50%Paris#Close".
+type giftItem struct {
+	mark     byte // '=' or '~'
+	weight   float64
+	text     string
+	feedback string
+}
+
+// ImportGIFT reads questions in the GIFT text format of Moodle. Every
+// $CATEGORY of the file starts a quiz named after it.
+func ImportGIFT(r io.Reader, name string) (*Result, error) {
+	blocks, err := giftBlocks(r)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{}
+	category := quizName(name)
+	var current *quiz.Quiz
+	for _, block := range blocks {
+		if path, found := strings.CutPrefix(block.text, "$CATEGORY:"); found {
+			if name := moodleCategory(path); name != "" {
+				category, current = name, nil
+			}
+			continue
+		}
+		question, ok := convertGIFT(res, block)
+		if !ok {
+			continue
+		}
+		if current == nil {
+			current = quiz.NewQuiz()
+			current.Name = category
+			res.Quizzes = append(res.Quizzes, current)
+		}
+		res.add(current, question, block.line)
+	}
+	return res, nil
+}
+
+// giftBlocks splits GIFT text into questions, which are separated by blank
+// lines outside answer blocks, dropping // comment lines.
+func giftBlocks(r io.Reader) ([]giftBlock, error) {
+	var blocks []giftBlock
+	var current []string
+	start, depth := 0, 0
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(current, "\n")); text != "" {
+			blocks = append(blocks, giftBlock{line: start, text: text})
+		}
+		current = nil
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		if trimmed == "" && depth == 0 {
+			flush()
+			continue
+		}
+		if len(current) == 0 {
+			start = n
+		}
+		current = append(current, line)
+		for i := 0; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '{':
+				depth++
+			case '}':
+				depth = max(depth-1, 0)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading GIFT: %w", err)
+	}
+	flush()
+	return blocks, nil
+}
+
+func convertGIFT(res *Result, block giftBlock) (quiz.Question, bool) {
+	text := block.text
+	title := ""
+	if rest, found := strings.CutPrefix(text, "::"); found {
+		if end := strings.Index(rest, "::"); end >= 0 {
+			title, text = strings.TrimSpace(unescapeGIFT(rest[:end])), strings.TrimSpace(rest[end+2:])
+		}
+	}
+	format := "moodle"
+	if strings.HasPrefix(text, "[") {
+		if end := strings.Index(text, "]"); end > 0 {
+			switch f := text[1:end]; f {
+			case "html", "moodle", "plain", "markdown":
+				format, text = f, strings.TrimSpace(text[end+1:])
+			}
+		}
+	}
+
+	label := title
+	if label == "" {
+		label = summary(unescapeGIFT(text))
+	}
+	problem := func(msg string, args ...interface{}) {
+		res.problem(block.line, label, msg, args...)
+	}
+
+	open := indexUnescaped(text, '{')
+	if open < 0 {
+		problem("skipped: descriptions are not questions")
+		return quiz.Question{}, false
+	}
+	closing := indexUnescaped(text[open:], '}')
+	if closing < 0 {
+		problem("skipped: unclosed answer block")
+		return quiz.Question{}, false
+	}
+	stem, answers, after := text[:open], strings.TrimSpace(text[open+1:open+closing]), strings.TrimSpace(text[open+closing+1:])
+	question := quiz.Question{Content: giftMarkdown(stem, format), Meta: quiz.JSONMap{}}
+	if after != "" {
+		// A missing word question: the answers fill the gap in the text.
+		gap := " _____"
+		if !strings.ContainsAny(after[:1], ".,;:!?)") {
+			gap += " "
+		}
+		question.Content += gap + giftMarkdown(after, format)
+	}
+	if before, general, found := strings.Cut(answers, "####"); found {
+		answers, question.Explanation = strings.TrimSpace(before), unescapeGIFT(general)
+	}
+
+	switch {
+	case answers == "":
+		question.Type = quiz.TypeEssay
+
+	case strings.HasPrefix(answers, "#"):
+		question.Type = quiz.TypeNumeric
+		spec, err := giftNumeric(strings.TrimSpace(answers[1:]), problem)
+		if err != nil {
+			problem("skipped: %v", err)
+			return question, false
+		}
+		question.Numeric = spec
+
+	case isGIFTBool(answers):
+		question.Type = quiz.TypeSingleChoice
+		value, rest, _ := cutUnescaped(answers, '#')
+		wrong, right, _ := cutUnescaped(rest, '#')
+		isTrue := strings.HasPrefix(strings.ToUpper(strings.TrimSpace(value)), "T")
+		question.Choices = []quiz.Choice{
+			{Content: "True", IsCorrect: isTrue},
+			{Content: "False", IsCorrect: !isTrue},
+		}
+		// The first feedback is for a wrong answer, the second for a right one.
+		for i := range question.Choices {
+			if question.Choices[i].IsCorrect {
+				question.Choices[i].Feedback = unescapeGIFT(right)
+			} else {
+				question.Choices[i].Feedback = unescapeGIFT(wrong)
+			}
+		}
+
+	default:
+		items := giftItems(answers)
+		if len(items) == 0 {
+			problem("skipped: no answers in %q", answers)
+			return question, false
+		}
+		matching, allRight := true, true
+		for _, item := range items {
+			matching = matching && item.mark == '=' && strings.Contains(item.text, "->")
+			allRight = allRight && item.mark == '='
+		}
+		switch {
+		case matching:
+			question.Type = quiz.TypeMatching
+			question.Matching = &quiz.MatchingSpec{}
+			for _, item := range items {
+				prompt, answer, _ := strings.Cut(item.text, "->")
+				prompt, answer = strings.TrimSpace(prompt), unescapeGIFT(answer)
+				if prompt == "" {
+					question.Matching.Distractors = append(question.Matching.Distractors, answer)
+					continue
+				}
+				question.Matching.Pairs = append(question.Matching.Pairs, quiz.MatchPair{Prompt: giftMarkdown(prompt, format), Answer: answer})
+			}
+		case allRight:
+			question.Type = quiz.TypeShortText
+			question.Text = &quiz.TextSpec{}
+			for _, item := range items {
+				if item.weight < 100 {
+					problem("partial credit of %g%% for %q is not supported, the answer counts as wrong", item.weight, unescapeGIFT(item.text))
+					continue
+				}
+				question.Text.Accepted = append(question.Text.Accepted, unescapeGIFT(item.text))
+			}
+		default:
+			giftChoices(&question, items, format, problem)
+		}
+	}
+	return question, true
+}
+
+// giftChoices converts the answers of a multiple choice question. A single
+// "=" answer makes a single choice question; weighted "~%n%" answers make a
+// multiple answer question.
+func giftChoices(question *quiz.Question, items []giftItem, format string, problem func(string, ...interface{})) {
+	full, marked := 0, false
+	for _, item := range items {
+		if item.weight >= 100 {
+			full++
+		}
+		marked = marked || item.mark == '='
+	}
+	single := full == 1 && marked
+	question.Type = quiz.TypeMultiChoice
+	if single {
+		question.Type = quiz.TypeSingleChoice
+	}
+	weights := map[float64]bool{}
+	for _, item := range items {
+		correct := item.weight > 0
+		if single {
+			correct = item.weight >= 100
+			if item.weight > 0 && item.weight < 100 {
+				problem("partial credit of %g%% for %q is not supported, the answer counts as wrong", item.weight, unescapeGIFT(item.text))
+			}
+		} else if item.weight > 0 {
+			weights[item.weight] = true
+		}
+		if item.weight < 0 {
+			question.PenaltyFraction = min(max(question.PenaltyFraction, -item.weight/100), 1)
+		}
+		question.Choices = append(question.Choices, quiz.Choice{
+			Content:   giftMarkdown(item.text, format),
+			IsCorrect: correct,
+			Feedback:  unescapeGIFT(item.feedback),
+		})
+	}
+	if len(weights) > 1 {
+		problem("answer weights are not supported, the correct answers share the credit equally")
+	}
+}
+
+// giftNumeric reads the answers of a numeric question: "3:0.5" for 3 give
+// or take 0.5, "2..4" for a range, or weighted "=" answers in those forms.
+func giftNumeric(answers string, problem func(string, ...interface{})) (*quiz.NumericSpec, error) {
+	if !strings.HasPrefix(answers, "=") && !strings.HasPrefix(answers, "~") {
+		value, _, _ := cutUnescaped(answers, '#')
+		return parseGIFTNumber(value)
+	}
+	var spec *quiz.NumericSpec
+	for _, item := range giftItems(answers) {
+		if item.mark != '=' || item.weight < 100 || spec != nil {
+			if item.weight > 0 {
+				problem("only the first fully correct answer is imported, %q is dropped", unescapeGIFT(item.text))
+			}
+			continue
+		}
+		parsed, err := parseGIFTNumber(item.text)
+		if err != nil {
+			return nil, err
+		}
+		spec = parsed
+	}
+	if spec == nil {
+		return nil, fmt.Errorf("no fully correct answer")
+	}
+	return spec, nil
+}
+
+func parseGIFTNumber(s string) (*quiz.NumericSpec, error) {
+	s = strings.TrimSpace(unescapeGIFT(s))
+	if low, high, found := strings.Cut(s, ".."); found {
+		lo, err1 := strconv.ParseFloat(strings.TrimSpace(low), 64)
+		hi, err2 := strconv.ParseFloat(strings.TrimSpace(high), 64)
+		if err1 != nil || err2 != nil || hi < lo {
+			return nil, fmt.Errorf("invalid numeric range %q", s)
+		}
+		return &quiz.NumericSpec{Answer: (lo + hi) / 2, Tolerance: (hi - lo) / 2}, nil
+	}
+	value, tolerance, _ := strings.Cut(s, ":")
+	spec := &quiz.NumericSpec{}
+	var err error
+	if spec.Answer, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+		return nil, fmt.Errorf("invalid numeric answer %q", s)
+	}
+	if tolerance != "" {
+		if spec.Tolerance, err = strconv.ParseFloat(strings.TrimSpace(tolerance), 64); err != nil {
+			return nil, fmt.Errorf("invalid numeric tolerance %q", s)
+		}
+	}
+	return spec, nil
+}
+
+func isGIFTBool(answers string) bool {
+	value, _, _ := cutUnescaped(answers, '#')
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "T", "TRUE", "F", "FALSE":
+		return true
+	}
+	return false
+}
+
+// giftItems splits an answer block at every unescaped "=" or "~".
+func giftItems(answers string) []giftItem {
+	var items []giftItem
+	start := -1
+	add := func(end int) {
+		if start < 0 {
+			return
+		}
+		item := giftItem{mark: answers[start]}
+		body := strings.TrimSpace(answers[start+1 : end])
+		if item.mark == '=' {
+			item.weight = 100
+		}
+		if weight, rest, ok := cutWeight(body); ok {
+			item.weight, body = weight, rest
+		}
+		text, feedback, _ := cutUnescaped(body, '#')
+		item.text, item.feedback = strings.TrimSpace(text), feedback
+		items = append(items, item)
+	}
+	for i := 0; i < len(answers); i++ {
+		switch answers[i] {
+		case '\\':
+			i++
+		case '=', '~':
+			add(i)
+			start = i
+		}
+	}
+	add(len(answers))
+	return items
+}
+
+func indexUnescaped(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
+	}
+	return -1
+}
+
+var giftEscapes = strings.NewReplacer(`\~`, "~", `\=`, "=", `\#`, "#", `\{`, "{", `\}`, "}", `\:`, ":", `\n`, "\n", `\\`, `\`)
+
+func unescapeGIFT(s string) string {
+	return strings.TrimSpace(giftEscapes.Replace(s))
+}
+
+// giftMarkdown converts GIFT text in the given format into quiz content.
+func giftMarkdown(text, format string) string {
+	switch format {
+	case "html":
+		return htmlToMarkdown(unescapeGIFT(text))
+	case "markdown":
+		return unescapeGIFT(text)
+	default:
+		return escapeMarkdown(unescapeGIFT(text))
+	}
+}
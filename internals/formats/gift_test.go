@@ -0,0 +1,134 @@
+package formats_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/formats"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+)
+
+const giftSample = `// Week 1 questions
+$CATEGORY: $course$/top/Geography
+
+::Capital:: What is the capital of France? {
+  =Paris#Right!
+  ~Lyon#No, that is a city in the south.
+  ~%50%Paris, Texas
+}
+
+::Rivers:: Which are rivers? {
+  ~%50%Seine
+  ~%50%Danube
+  ~%-100%Alps#A mountain range
+}
+
+The sun rises in the east.{T#It does not!#Indeed.}
+
+Two plus two equals {=four =4}.
+
+::Pi:: What is pi to two decimals? {#3.14:0.005}
+
+::Range:: A number between 1 and 5 {#1..5}
+
+::Match:: Match the countries {
+  =France -> Paris
+  =Spain -> Madrid
+  = -> Berlin
+}
+
+::Essay:: Describe your holidays. {}
+
+This is only a description without answers.
+
+::Escaped:: What is 1 \= 1 \{really\}? {=yes ~no####Equality is reflexive.}
+
+::Broken:: Unclosed {=a
+`
+
+func TestImportGIFT(t *testing.T) {
+	res, err := formats.ImportGIFT(strings.NewReader(giftSample), "week1.gift")
+	assert.NoError(t, err)
+	assert.Len(t, res.Quizzes, 1)
+	q := res.Quizzes[0]
+	assert.Equal(t, "Geography", q.Name)
+	assert.Len(t, q.Questions, 9)
+
+	capital := q.Questions[0]
+	assert.Equal(t, quiz.TypeSingleChoice, capital.Type)
+	assert.Equal(t, "What is the capital of France?", capital.Content)
+	assert.Len(t, capital.Choices, 3)
+	assert.True(t, capital.Choices[0].IsCorrect)
+	assert.Equal(t, "Right!", capital.Choices[0].Feedback)
+	assert.False(t, capital.Choices[2].IsCorrect)
+
+	rivers := q.Questions[1]
+	assert.Equal(t, quiz.TypeMultiChoice, rivers.Type)
+	assert.True(t, rivers.Choices[0].IsCorrect && rivers.Choices[1].IsCorrect)
+	assert.False(t, rivers.Choices[2].IsCorrect)
+	assert.Equal(t, 1.0, rivers.PenaltyFraction)
+
+	sun := q.Questions[2]
+	assert.Equal(t, quiz.TypeSingleChoice, sun.Type)
+	assert.Equal(t, "The sun rises in the east.", sun.Content)
+	assert.True(t, sun.Choices[0].IsCorrect)
+	assert.Equal(t, "Indeed.", sun.Choices[0].Feedback)
+	assert.Equal(t, "It does not!", sun.Choices[1].Feedback)
+
+	missing := q.Questions[3]
+	assert.Equal(t, quiz.TypeShortText, missing.Type)
+	assert.Equal(t, "Two plus two equals _____.", missing.Content)
+	assert.Equal(t, []string{"four", "4"}, missing.Text.Accepted)
+
+	assert.Equal(t, &quiz.NumericSpec{Answer: 3.14, Tolerance: 0.005}, q.Questions[4].Numeric)
+	assert.Equal(t, &quiz.NumericSpec{Answer: 3, Tolerance: 2}, q.Questions[5].Numeric)
+
+	match := q.Questions[6]
+	assert.Equal(t, quiz.TypeMatching, match.Type)
+	assert.Equal(t, []quiz.MatchPair{{Prompt: "France", Answer: "Paris"}, {Prompt: "Spain", Answer: "Madrid"}}, match.Matching.Pairs)
+	assert.Equal(t, []string{"Berlin"}, match.Matching.Distractors)
+
+	assert.Equal(t, quiz.TypeEssay, q.Questions[7].Type)
+
+	escaped := q.Questions[8]
+	assert.Equal(t, "What is 1 = 1 {really}?", escaped.Content)
+	assert.Equal(t, "Equality is reflexive.", escaped.Explanation)
+
+	var messages []string
+	for _, p := range res.Problems {
+		messages = append(messages, p.String())
+	}
+	assert.Equal(t, []string{
+		`line 4, "Capital": partial credit of 50% for "Paris, Texas" is not supported, the answer counts as wrong`,
+		`line 32, "This is only a description without answers.": skipped: descriptions are not questions`,
+		`line 36, "Broken": skipped: unclosed answer block`,
+	}, messages)
+}
+
+func TestResult_Check(t *testing.T) {
+	res, err := formats.ImportGIFT(strings.NewReader("::A:: Pick one {=yes ~no}\n\n::B:: Nothing right {~a ~b}\n\n$CATEGORY: Empty\n\n::C:: Still nothing {~c}"), "checks.gift")
+	assert.NoError(t, err)
+	assert.Len(t, res.Quizzes, 2)
+
+	res.Check(func(question *quiz.Question) error {
+		for _, choice := range question.Choices {
+			if choice.IsCorrect {
+				return nil
+			}
+		}
+		return errors.New("no correct choice")
+	})
+	assert.Len(t, res.Quizzes, 1)
+	assert.Len(t, res.Quizzes[0].Questions, 1)
+	var messages []string
+	for _, p := range res.Problems {
+		messages = append(messages, p.String())
+	}
+	assert.Equal(t, []string{
+		`line 3, "Nothing right": skipped: no correct choice`,
+		`line 7, "Still nothing": skipped: no correct choice`,
+		`"Empty": skipped quiz: no question could be imported`,
+	}, messages)
+}
@@ -0,0 +1,260 @@
+package formats
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlToMarkdown converts the HTML that quiz tools store question text in
+// into the Markdown of quiz content. Unknown elements keep only their text.
+func htmlToMarkdown(src string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(src), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return escapeMarkdown(src)
+	}
+	var md markdownWriter
+	for _, n := range nodes {
+		md.node(n)
+	}
+	md.WriteString("\n")
+	text := blankLines.ReplaceAllString(md.String(), "\n\n")
+	return strings.TrimSpace(trailingSpace.ReplaceAllString(text, "$1\n"))
+}
+
+var (
+	blankLines = regexp.MustCompile(`[ \t]*\n(?:[ \t]*\n)+`)
+	// trailingSpace is the space left at the end of a line by the markup
+	// between block elements; two spaces mark a line break and stay.
+	trailingSpace = regexp.MustCompile(`([^ ]) \n`)
+)
+
+type markdownWriter struct {
+	strings.Builder
+	pre  bool
+	list []int // Item counters of the open lists; -1 for bullet lists
+}
+
+func (md *markdownWriter) block() {
+	md.WriteString("\n\n")
+}
+
+func (md *markdownWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		md.node(c)
+	}
+}
+
+// wrap writes the children of n between marks, such as ** for bold text.
+func (md *markdownWriter) wrap(n *html.Node, open, close string) {
+	var inner markdownWriter
+	inner.pre, inner.list = md.pre, md.list
+	inner.children(n)
+	text := inner.String()
+	if strings.TrimSpace(text) == "" {
+		md.WriteString(text)
+		return
+	}
+	// Marks must hug the text to count as Markdown.
+	lead := text[:len(text)-len(strings.TrimLeft(text, " \n"))]
+	trail := text[len(strings.TrimRight(text, " \n")):]
+	md.WriteString(lead + open + strings.TrimSpace(text) + close + trail)
+}
+
+func (md *markdownWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if md.pre {
+			md.WriteString(n.Data)
+			return
+		}
+		md.WriteString(escapeMarkdown(collapseSpace(n.Data)))
+		return
+	case html.ElementNode:
+	default:
+		md.children(n)
+		return
+	}
+
+	switch n.Data {
+	case "p", "div", "section", "blockquote", "table":
+		md.block()
+		md.children(n)
+		md.block()
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		md.block()
+		md.WriteString(strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+		md.children(n)
+		md.block()
+	case "br":
+		md.WriteString("  \n")
+	case "hr":
+		md.block()
+		md.WriteString("---")
+		md.block()
+	case "strong", "b":
+		md.wrap(n, "**", "**")
+	case "em", "i":
+		md.wrap(n, "*", "*")
+	case "del", "s", "strike":
+		md.wrap(n, "~~", "~~")
+	case "code", "tt", "kbd":
+		if md.pre {
+			md.children(n)
+			return
+		}
+		md.WriteString("`" + textContent(n) + "`")
+	case "pre":
+		md.block()
+		md.WriteString("```\n")
+		md.pre = true
+		md.children(n)
+		md.pre = false
+		md.WriteString("\n```")
+		md.block()
+	case "a":
+		href := attr(n, "href")
+		if href == "" {
+			md.children(n)
+			return
+		}
+		md.wrap(n, "[", "]("+href+")")
+	case "img":
+		// Embedded images are not imported; keep their description.
+		if alt := attr(n, "alt"); alt != "" {
+			md.WriteString(escapeMarkdown(alt))
+		}
+	case "ul", "ol":
+		counter := -1
+		if n.Data == "ol" {
+			counter = 0
+		}
+		md.list = append(md.list, counter)
+		md.block()
+		md.children(n)
+		md.block()
+		md.list = md.list[:len(md.list)-1]
+	case "li":
+		depth := len(md.list)
+		marker := "- "
+		if depth > 0 && md.list[depth-1] >= 0 {
+			md.list[depth-1]++
+			marker = strconv.Itoa(md.list[depth-1]) + ". "
+		}
+		md.WriteString("\n" + strings.Repeat("   ", max(depth-1, 0)) + marker)
+		md.children(n)
+	case "tr":
+		md.WriteString("\n")
+		md.children(n)
+	case "td", "th":
+		md.children(n)
+		md.WriteString(" ")
+	case "math":
+		// MathML keeps its TeX source in an annotation, as rendered math does.
+		tex := texAnnotation(n)
+		if tex == "" {
+			md.children(n)
+			return
+		}
+		if attr(n, "display") == "block" {
+			md.block()
+			md.WriteString("$$\n" + tex + "\n$$")
+			md.block()
+			return
+		}
+		md.WriteString("$" + tex + "$")
+	case "script", "style", "head":
+	default:
+		md.children(n)
+	}
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// texAnnotation returns the TeX source annotation of MathML, or "".
+func texAnnotation(n *html.Node) string {
+	if n.Type == html.ElementNode && n.Data == "annotation" && attr(n, "encoding") == "application/x-tex" {
+		return strings.TrimSpace(textContent(n))
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if tex := texAnnotation(c); tex != "" {
+			return tex
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+func collapseSpace(s string) string {
+	return spaces.ReplaceAllString(s, " ")
+}
+
+// texDelimiters finds the \( \) and \[ \] math of Moodle and MathJax.
+var texDelimiters = regexp.MustCompile(`(?s)\\\((.+?)\\\)|\\\[(.+?)\\\]|\$\$(.+?)\$\$`)
+
+// escapeMarkdown escapes text so it renders as written, turning TeX math
+// delimiters into the dollar signs of quiz content.
+func escapeMarkdown(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range texDelimiters.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(escapeText(s[last:m[0]]))
+		switch {
+		case m[2] >= 0:
+			b.WriteString("$" + strings.TrimSpace(s[m[2]:m[3]]) + "$")
+		case m[4] >= 0:
+			b.WriteString("$$" + strings.TrimSpace(s[m[4]:m[5]]) + "$$")
+		default:
+			b.WriteString("$$" + strings.TrimSpace(s[m[6]:m[7]]) + "$$")
+		}
+		last = m[1]
+	}
+	b.WriteString(escapeText(s[last:]))
+	return b.String()
+}
+
+var markdownSpecial = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`,
+	"<", `\<`, "$", `\$`, "#", `\#`, "|", `\|`, "~", `\~`,
+)
+
+func escapeText(s string) string {
+	return markdownSpecial.Replace(s)
+}
+
+// htmlToText returns the text of HTML, for fields shown as plain text such
+// as feedback.
+func htmlToText(src string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(src), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return strings.TrimSpace(src)
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(textContent(n))
+		b.WriteString(" ")
+	}
+	return strings.TrimSpace(collapseSpace(b.String()))
+}
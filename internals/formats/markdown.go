@@ -0,0 +1,643 @@
+package formats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"gopkg.in/yaml.v3"
+)
+
+// mdQuiz is the front matter of a quiz file.
+type mdQuiz struct {
+	Title           string                 `yaml:"title"`
+	Cover           string                 `yaml:"cover,omitempty"`
+	MaxAttempts     int                    `yaml:"max_attempts,omitempty"`
+	AttemptCooldown time.Duration          `yaml:"attempt_cooldown,omitempty"`
+	GradingPolicy   quiz.GradingPolicy     `yaml:"grading_policy,omitempty"`
+	RevealPolicy    quiz.RevealPolicy      `yaml:"reveal_policy,omitempty"`
+	ClosesAt        *time.Time             `yaml:"closes_at,omitempty"`
+	SubmitGrace     time.Duration          `yaml:"submit_grace,omitempty"`
+	Survey          bool                   `yaml:"survey,omitempty"`
+	Meta            map[string]interface{} `yaml:"meta,omitempty"`
+}
+
+// mdAttributes are the question details that the Markdown of a question
+// does not show. Image and media are blob keys.
+type mdAttributes struct {
+	Type              string                 `yaml:"type,omitempty"`
+	Points            float64                `yaml:"points,omitempty"`
+	Penalty           float64                `yaml:"penalty,omitempty"`
+	PenaltyFraction   float64                `yaml:"penalty_fraction,omitempty"`
+	Tags              []string               `yaml:"tags,omitempty,flow"`
+	Image             string                 `yaml:"image,omitempty"`
+	ImageAlt          string                 `yaml:"image_alt,omitempty"`
+	Media             string                 `yaml:"media,omitempty"`
+	MediaType         string                 `yaml:"media_type,omitempty"`
+	MaxPlays          int                    `yaml:"max_plays,omitempty"`
+	Captions          string                 `yaml:"captions,omitempty"`
+	Transcript        string                 `yaml:"transcript,omitempty"`
+	Patterns          []string               `yaml:"patterns,omitempty"`
+	CaseSensitive     bool                   `yaml:"case_sensitive,omitempty"`
+	Answer            *float64               `yaml:"answer,omitempty"`
+	Tolerance         float64                `yaml:"tolerance,omitempty"`
+	RelativeTolerance float64                `yaml:"relative_tolerance,omitempty"`
+	Units             []mdUnit               `yaml:"units,omitempty"`
+	UnitRequired      bool                   `yaml:"unit_required,omitempty"`
+	AllOrNothing      bool                   `yaml:"all_or_nothing,omitempty"`
+	Rubric            []mdCriterion          `yaml:"rubric,omitempty"`
+	Language          string                 `yaml:"language,omitempty"`
+	Starter           string                 `yaml:"starter,omitempty"`
+	Tests             string                 `yaml:"tests,omitempty"`
+	TimeoutSeconds    int                    `yaml:"timeout_seconds,omitempty"`
+	Meta              map[string]interface{} `yaml:"meta,omitempty"`
+}
+
+type mdUnit struct {
+	Unit       string  `yaml:"unit"`
+	Multiplier float64 `yaml:"multiplier"`
+}
+
+type mdCriterion struct {
+	Name        string  `yaml:"name"`
+	Description string  `yaml:"description,omitempty"`
+	Points      float64 `yaml:"points"`
+}
+
+// mdItem is an item of the answer list of a question.
+type mdItem struct {
+	checked  bool
+	text     string
+	feedback string
+}
+
+var (
+	taskItem    = regexp.MustCompile(`^[-*+] \[([ xX])\] ?(.*)$`)
+	bulletItem  = regexp.MustCompile(`^[-*+] (.*)$`)
+	orderedItem = regexp.MustCompile(`^\d{1,9}[.)] (.*)$`)
+)
+
+const explanationHeading = "### Explanation"
+
+// ImportMarkdown reads a quiz written in Markdown with YAML front matter, a
+// format for editing by hand and reviewing in git, one quiz per file:
+//
+//	---
+//	title: European capitals
+//	max_attempts: 3
+//	---
+//
+//	The description, in Markdown.
+//
+//	## What is the capital of France?
+//
+//	- [x] Paris
+//	  > Feedback for the choice.
+//	- [ ] Lyon
+//
+//	### Explanation
+//
+//	Paris has been the capital since 508.
+//
+//	## Sort these by size
+//	points: 2
+//	all_or_nothing: true
+//
+//	1. Ant
+//	2. Cat
+//	3. Horse
+//
+// The YAML front matter holds the quiz settings and the text before the
+// first question its description. Every question starts with a level-2
+// heading holding the first line of its text, or nothing when the text
+// starts with more than a plain line. The lines right below the heading are
+// YAML attributes, mdAttributes, up to a blank line. The text of the
+// question follows, ending with its answers: a task list of choices, a
+// numbered list of ordering items, or a bullet list of accepted short-text
+// answers, "prompt -> answer" matching pairs and "-> distractor" answers,
+// or likert labels. Task lists make single-choice questions when one choice
+// is ticked and multi-choice ones otherwise, and numbered lists ordering
+// questions; other types are named by the type attribute.
+func ImportMarkdown(r io.Reader, name string) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ReplaceAll(strings.TrimPrefix(string(data), utf8BOM), "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+
+	res := &Result{}
+	q := quiz.NewQuiz()
+	q.Name = quizName(name)
+	start := 0
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		end := -1
+		for i := 1; i < len(lines); i++ {
+			if l := strings.TrimSpace(lines[i]); l == "---" || l == "..." {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return nil, errors.New("line 1: the front matter is not closed with ---")
+		}
+		var fm mdQuiz
+		if err := strictYAML(strings.Join(lines[1:end], "\n"), &fm); err != nil {
+			return nil, fmt.Errorf("front matter: %w", err)
+		}
+		if fm.Title != "" {
+			q.Name = fm.Title
+		}
+		q.CoverKey = fm.Cover
+		q.MaxAttempts, q.AttemptCooldown, q.ClosesAt, q.Survey = fm.MaxAttempts, fm.AttemptCooldown, fm.ClosesAt, fm.Survey
+		q.SubmitGrace = fm.SubmitGrace
+		if fm.GradingPolicy != "" {
+			q.GradingPolicy = fm.GradingPolicy
+		}
+		if fm.RevealPolicy != "" {
+			q.RevealPolicy = fm.RevealPolicy
+		}
+		if fm.Meta != nil {
+			q.Meta = fm.Meta
+		}
+		start = end + 1
+	}
+
+	// Questions start at level-2 headings outside code blocks.
+	var headings []int
+	fenced := ""
+	for i := start; i < len(lines); i++ {
+		if fenced = fence(lines[i], fenced); fenced != "" {
+			continue
+		}
+		if lines[i] == "##" || strings.HasPrefix(lines[i], "## ") {
+			headings = append(headings, i)
+		}
+	}
+	headings = append(headings, len(lines))
+	q.Description = strings.TrimSpace(strings.Join(lines[start:headings[0]], "\n"))
+	for i := 0; i+1 < len(headings); i++ {
+		line := headings[i]
+		heading := strings.TrimSpace(strings.TrimPrefix(lines[line], "##"))
+		question, err := markdownQuestion(heading, lines[line+1:headings[i+1]])
+		if err != nil {
+			res.problem(line+1, summary(question.Content), "skipped: %v", err)
+			continue
+		}
+		res.add(q, question, line+1)
+	}
+	res.Quizzes = append(res.Quizzes, q)
+	return res, nil
+}
+
+func markdownQuestion(heading string, lines []string) (quiz.Question, error) {
+	question := quiz.Question{Content: heading}
+	var attrs mdAttributes
+	// Attributes end at a blank line, unless indented lines of a YAML
+	// block follow it.
+	end := 0
+	for end < len(lines) && !(strings.TrimSpace(lines[end]) == "" && !indented(lines[end+1:])) {
+		end++
+	}
+	if end > 0 {
+		if err := strictYAML(strings.Join(lines[:end], "\n"), &attrs); err != nil {
+			return question, fmt.Errorf("attributes: %w (leave a blank line between the heading and the question)", err)
+		}
+	}
+	lines = lines[end:]
+
+	fenced := ""
+	for i, line := range lines {
+		if fenced = fence(line, fenced); fenced == "" && strings.TrimSpace(line) == explanationHeading {
+			question.Explanation = strings.TrimSpace(strings.Join(lines[i+1:], "\n"))
+			lines = lines[:i]
+			break
+		}
+	}
+
+	question.Type = attrs.Type
+	var items []mdItem
+	body, tasks := answerList(lines, taskItem)
+	if question.Type == "" {
+		_, ordered := answerList(lines, orderedItem)
+		switch {
+		case len(tasks) > 0:
+			question.Type = quiz.TypeMultiChoice
+			checked := 0
+			for _, item := range tasks {
+				if item.checked {
+					checked++
+				}
+			}
+			if checked == 1 {
+				question.Type = quiz.TypeSingleChoice
+			}
+		case len(ordered) > 0:
+			question.Type = quiz.TypeOrdering
+		default:
+			question.Content = contentOf(heading, lines)
+			return question, errors.New("no type attribute and no task list of choices or numbered list of items to tell the type")
+		}
+	}
+	switch question.Type {
+	case quiz.TypeSingleChoice, quiz.TypeMultiChoice:
+		items = tasks
+	case quiz.TypeOrdering:
+		body, items = answerList(lines, orderedItem)
+	case quiz.TypeShortText, quiz.TypeMatching, quiz.TypeLikert:
+		body, items = answerList(lines, bulletItem)
+	default:
+		body = lines
+	}
+	question.Content = contentOf(heading, body)
+
+	var answers []string
+	for _, item := range items {
+		answers = append(answers, item.text)
+	}
+	switch question.Type {
+	case quiz.TypeSingleChoice, quiz.TypeMultiChoice:
+		for _, item := range items {
+			question.Choices = append(question.Choices, quiz.Choice{Content: item.text, IsCorrect: item.checked, Feedback: item.feedback})
+		}
+	case quiz.TypeShortText:
+		question.Text = &quiz.TextSpec{Accepted: answers, Patterns: attrs.Patterns, CaseSensitive: attrs.CaseSensitive}
+	case quiz.TypeNumeric:
+		if attrs.Answer == nil {
+			return question, errors.New("numeric questions need an answer attribute")
+		}
+		question.Numeric = &quiz.NumericSpec{Answer: *attrs.Answer, Tolerance: attrs.Tolerance, RelativeTolerance: attrs.RelativeTolerance, UnitRequired: attrs.UnitRequired}
+		for _, u := range attrs.Units {
+			question.Numeric.Units = append(question.Numeric.Units, quiz.NumericUnit{Unit: u.Unit, Multiplier: u.Multiplier})
+		}
+	case quiz.TypeOrdering:
+		question.Ordering = &quiz.OrderingSpec{Items: answers, AllOrNothing: attrs.AllOrNothing}
+	case quiz.TypeMatching:
+		question.Matching = &quiz.MatchingSpec{AllOrNothing: attrs.AllOrNothing}
+		for _, item := range answers {
+			prompt, answer, found := strings.Cut(item, "->")
+			if !found {
+				return question, fmt.Errorf("%q is not a prompt -> answer pair", item)
+			}
+			prompt, answer = strings.TrimSpace(prompt), strings.TrimSpace(answer)
+			if prompt == "" {
+				question.Matching.Distractors = append(question.Matching.Distractors, answer)
+			} else {
+				question.Matching.Pairs = append(question.Matching.Pairs, quiz.MatchPair{Prompt: prompt, Answer: answer})
+			}
+		}
+	case quiz.TypeLikert:
+		if len(answers) > 0 {
+			question.Likert = &quiz.LikertSpec{Labels: answers}
+		}
+	case quiz.TypeEssay:
+		for _, c := range attrs.Rubric {
+			if question.Essay == nil {
+				question.Essay = &quiz.EssaySpec{}
+			}
+			question.Essay.Rubric = append(question.Essay.Rubric, quiz.RubricCriterion{Name: c.Name, Description: c.Description, Points: c.Points})
+		}
+	case quiz.TypeCode:
+		question.Code = &quiz.CodeSpec{Language: attrs.Language, Starter: attrs.Starter, Tests: attrs.Tests, TimeoutSeconds: attrs.TimeoutSeconds}
+	case quiz.TypeCloze:
+	default:
+		return question, fmt.Errorf("unknown type %q", question.Type)
+	}
+
+	question.Points, question.Penalty, question.PenaltyFraction = attrs.Points, attrs.Penalty, attrs.PenaltyFraction
+	if len(attrs.Tags) > 0 {
+		question.Tags = attrs.Tags
+	}
+	question.ImageKey, question.ImageAlt = attrs.Image, attrs.ImageAlt
+	question.MediaKey, question.MediaType, question.MaxPlays = attrs.Media, attrs.MediaType, attrs.MaxPlays
+	question.Captions, question.Transcript = attrs.Captions, attrs.Transcript
+	question.Meta = attrs.Meta
+	return question, nil
+}
+
+// answerList splits the list that ends lines, made of items that match
+// marker, from the text before it. Indented lines continue an item, and
+// those quoted with > give its feedback.
+func answerList(lines []string, marker *regexp.Regexp) ([]string, []mdItem) {
+	start := len(lines)
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if !marker.MatchString(line) {
+			break
+		}
+		if marker == bulletItem && taskItem.MatchString(line) {
+			break
+		}
+		start = i
+	}
+
+	var items []mdItem
+	var text, feedback []string
+	flush := func() {
+		if len(items) > 0 {
+			items[len(items)-1].text = strings.Join(text, "\n")
+			items[len(items)-1].feedback = strings.Join(feedback, "\n")
+		}
+		text, feedback = nil, nil
+	}
+	for _, line := range lines[start:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if m := marker.FindStringSubmatch(line); m != nil && line[0] != ' ' && line[0] != '\t' {
+			flush()
+			item := mdItem{}
+			if marker == taskItem {
+				item.checked = m[1] != " "
+			}
+			items = append(items, item)
+			text = append(text, strings.TrimSpace(m[len(m)-1]))
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if quoted, found := strings.CutPrefix(line, ">"); found {
+			feedback = append(feedback, strings.TrimSpace(quoted))
+		} else {
+			text = append(text, line)
+		}
+	}
+	flush()
+	return lines[:start], items
+}
+
+// indented reports whether the first line of lines that is not blank is
+// indented.
+func indented(lines []string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			return line[0] == ' ' || line[0] == '\t'
+		}
+	}
+	return false
+}
+
+// contentOf joins the heading and the text below it into question content.
+func contentOf(heading string, lines []string) string {
+	body := strings.TrimSpace(strings.Join(lines, "\n"))
+	switch {
+	case heading == "":
+		return body
+	case body == "":
+		return heading
+	}
+	return heading + "\n\n" + body
+}
+
+// fence tracks fenced code blocks: given a line and the fence that is open,
+// or "", it returns the fence open after the line.
+func fence(line, open string) string {
+	trimmed := strings.TrimSpace(line)
+	if open != "" {
+		if strings.HasPrefix(trimmed, open) && strings.Trim(trimmed, open[:1]) == "" {
+			return ""
+		}
+		return open
+	}
+	for _, f := range []string{"```", "~~~"} {
+		if strings.HasPrefix(trimmed, f) {
+			return trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, f[:1]))]
+		}
+	}
+	return ""
+}
+
+// strictYAML decodes YAML, rejecting unknown keys so that typos are caught.
+func strictYAML(src string, v interface{}) error {
+	dec := yaml.NewDecoder(strings.NewReader(src))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// ExportMarkdown writes the quiz in Markdown with YAML front matter, as read
+// by ImportMarkdown. Choice thumbnails have no place in it and are
+// returned as problems, as are level-2 headings within questions, which
+// would start new questions and are written as level 3.
+func ExportMarkdown(w io.Writer, q *quiz.Quiz) ([]Problem, error) {
+	var b bytes.Buffer
+	var problems []Problem
+	fm := mdQuiz{
+		Title:           q.Name,
+		Cover:           q.CoverKey,
+		MaxAttempts:     q.MaxAttempts,
+		AttemptCooldown: q.AttemptCooldown,
+		GradingPolicy:   q.GradingPolicy,
+		RevealPolicy:    q.RevealPolicy,
+		ClosesAt:        q.ClosesAt,
+		SubmitGrace:     q.SubmitGrace,
+		Survey:          q.Survey,
+		Meta:            q.Meta,
+	}
+	if len(fm.Meta) == 0 {
+		fm.Meta = nil
+	}
+	front, err := yaml.Marshal(fm)
+	if err != nil {
+		return nil, err
+	}
+	b.WriteString("---\n")
+	b.Write(front)
+	b.WriteString("---\n")
+	if q.Description != "" {
+		description := demoteHeadings(q.Description)
+		if description != q.Description {
+			problems = append(problems, Problem{Question: q.Name, Message: "level-2 headings of the description are written as level 3"})
+		}
+		b.WriteString("\n" + description + "\n")
+	}
+
+	for i := range q.Questions {
+		question := &q.Questions[i]
+		problem := func(format string, args ...interface{}) {
+			problems = append(problems, Problem{Question: summary(question.Prompt()), Message: fmt.Sprintf(format, args...)})
+		}
+		content := question.Content
+		if demoted := demoteHeadings(content); demoted != content {
+			problem("level-2 headings are written as level 3")
+			content = demoted
+		}
+		heading, body := splitHeading(content)
+		b.WriteString("\n" + strings.TrimSpace("## "+heading) + "\n")
+
+		attrs, items := markdownAnswers(question)
+		for _, choice := range question.Choices {
+			if choice.ThumbKey != "" {
+				problem("choice thumbnails left out")
+				break
+			}
+		}
+		if attrs != nil {
+			out, err := yaml.Marshal(attrs)
+			if err != nil {
+				return problems, err
+			}
+			b.Write(out)
+		}
+		if body != "" {
+			b.WriteString("\n" + body + "\n")
+		}
+		if len(items) > 0 {
+			b.WriteString("\n")
+			for _, item := range items {
+				b.WriteString(item + "\n")
+			}
+		}
+		if question.Explanation != "" {
+			b.WriteString("\n" + explanationHeading + "\n\n" + question.Explanation + "\n")
+		}
+	}
+	_, err = w.Write(b.Bytes())
+	return problems, err
+}
+
+// markdownAnswers returns the attributes of a question, or nil when it has
+// none, and the lines of its answer list.
+func markdownAnswers(question *quiz.Question) (*mdAttributes, []string) {
+	attrs := &mdAttributes{
+		Type:            question.Type,
+		Points:          question.Points,
+		Penalty:         question.Penalty,
+		PenaltyFraction: question.PenaltyFraction,
+		Tags:            question.Tags,
+		Image:           question.ImageKey,
+		ImageAlt:        question.ImageAlt,
+		Media:           question.MediaKey,
+		MediaType:       question.MediaType,
+		MaxPlays:        question.MaxPlays,
+		Captions:        question.Captions,
+		Transcript:      question.Transcript,
+		Meta:            question.Meta,
+	}
+	var items []string
+	switch question.Type {
+	case quiz.TypeSingleChoice, quiz.TypeMultiChoice:
+		correct := 0
+		for _, choice := range question.Choices {
+			mark := " "
+			if choice.IsCorrect {
+				mark = "x"
+				correct++
+			}
+			items = append(items, "- ["+mark+"] "+indent(choice.Content))
+			if choice.Feedback != "" {
+				items = append(items, "  > "+strings.ReplaceAll(choice.Feedback, "\n", "\n  > "))
+			}
+		}
+		if len(question.Choices) > 0 && (correct == 1) == (question.Type == quiz.TypeSingleChoice) {
+			attrs.Type = ""
+		}
+	case quiz.TypeShortText:
+		if spec := question.Text; spec != nil {
+			items = bullets(spec.Accepted)
+			attrs.Patterns, attrs.CaseSensitive = spec.Patterns, spec.CaseSensitive
+		}
+	case quiz.TypeNumeric:
+		if spec := question.Numeric; spec != nil {
+			answer := spec.Answer
+			attrs.Answer, attrs.Tolerance, attrs.RelativeTolerance, attrs.UnitRequired = &answer, spec.Tolerance, spec.RelativeTolerance, spec.UnitRequired
+			for _, u := range spec.Units {
+				attrs.Units = append(attrs.Units, mdUnit{Unit: u.Unit, Multiplier: u.Multiplier})
+			}
+		}
+	case quiz.TypeOrdering:
+		if spec := question.Ordering; spec != nil {
+			for i, item := range spec.Items {
+				items = append(items, fmt.Sprintf("%d. %s", i+1, indent(item)))
+			}
+			attrs.AllOrNothing = spec.AllOrNothing
+			if len(spec.Items) > 0 {
+				attrs.Type = ""
+			}
+		}
+	case quiz.TypeMatching:
+		if spec := question.Matching; spec != nil {
+			for _, pair := range spec.Pairs {
+				items = append(items, "- "+pair.Prompt+" -> "+pair.Answer)
+			}
+			for _, distractor := range spec.Distractors {
+				items = append(items, "- -> "+distractor)
+			}
+			attrs.AllOrNothing = spec.AllOrNothing
+		}
+	case quiz.TypeLikert:
+		if question.Likert != nil {
+			items = bullets(question.Likert.Labels)
+		}
+	case quiz.TypeEssay:
+		if question.Essay != nil {
+			for _, c := range question.Essay.Rubric {
+				attrs.Rubric = append(attrs.Rubric, mdCriterion{Name: c.Name, Description: c.Description, Points: c.Points})
+			}
+		}
+	case quiz.TypeCode:
+		if spec := question.Code; spec != nil {
+			attrs.Language, attrs.Starter, attrs.Tests, attrs.TimeoutSeconds = spec.Language, spec.Starter, spec.Tests, spec.TimeoutSeconds
+		}
+	}
+	if len(attrs.Meta) == 0 {
+		attrs.Meta = nil
+	}
+	if isZero(attrs) {
+		return nil, items
+	}
+	return attrs, items
+}
+
+func isZero(attrs *mdAttributes) bool {
+	out, err := yaml.Marshal(attrs)
+	return err == nil && strings.TrimSpace(string(out)) == "{}"
+}
+
+func bullets(texts []string) []string {
+	var items []string
+	for _, text := range texts {
+		items = append(items, "- "+indent(text))
+	}
+	return items
+}
+
+// indent indents the lines after the first of a list item.
+func indent(text string) string {
+	return strings.ReplaceAll(text, "\n", "\n  ")
+}
+
+// plainLine matches lines that read as a paragraph, and so can be a heading.
+var plainLine = regexp.MustCompile(`^[^\s#>*+\-|<\x60~\[!=\d]|^\d+[^\d.)]`)
+
+// splitHeading splits question content into the line that heads it and the
+// text below. Content that does not start with a line of plain text is all
+// written below an empty heading.
+func splitHeading(content string) (string, string) {
+	first, rest, _ := strings.Cut(content, "\n\n")
+	if strings.Contains(first, "\n") || !plainLine.MatchString(first) {
+		return "", content
+	}
+	return first, strings.TrimSpace(rest)
+}
+
+// demoteHeadings turns level-2 headings outside code blocks into level 3.
+func demoteHeadings(content string) string {
+	lines := strings.Split(content, "\n")
+	fenced := ""
+	for i, line := range lines {
+		if fenced = fence(line, fenced); fenced == "" && (line == "##" || strings.HasPrefix(line, "## ")) {
+			lines[i] = "#" + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
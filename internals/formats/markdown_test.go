@@ -0,0 +1,201 @@
+package formats_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/formats"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const capitalsMarkdown = `---
+title: European capitals
+max_attempts: 3
+attempt_cooldown: 1h30m
+---
+
+How well do you know **Europe**?
+
+## What is the capital of France?
+
+- [x] Paris
+  > Right.
+- [ ] Lyon
+  > It is the third city.
+
+### Explanation
+
+Paris has been the capital since 508.
+
+## Which of these are capitals?
+type: multi-choice
+tags: [capitals, hard]
+
+- Pick carefully.
+- There may be one.
+
+* [x] Rome
+* [ ] Milan
+
+##
+type: short-text
+
+` + "```" + `
+## not a question
+` + "```" + `
+
+What does the code print?
+
+- not a question
+
+## Sort by population
+points: 2
+all_or_nothing: true
+
+1. Malta
+2. Ireland
+3. Germany
+
+## Broken
+point: 2
+
+- [x] a
+
+## Typeless
+
+Just text.
+`
+
+func TestImportMarkdown(t *testing.T) {
+	res, err := formats.ImportMarkdown(strings.NewReader(capitalsMarkdown), "capitals.md")
+	require.NoError(t, err)
+
+	var messages []string
+	for _, p := range res.Problems {
+		messages = append(messages, p.String())
+	}
+	assert.Equal(t, []string{
+		`line 49, "Broken": skipped: attributes: yaml: unmarshal errors:` + "\n" + `  line 1: field point not found in type formats.mdAttributes (leave a blank line between the heading and the question)`,
+		`line 54, "Typeless Just text.": skipped: no type attribute and no task list of choices or numbered list of items to tell the type`,
+	}, messages)
+
+	require.Len(t, res.Quizzes, 1)
+	q := res.Quizzes[0]
+	assert.Equal(t, "European capitals", q.Name)
+	assert.Equal(t, "How well do you know **Europe**?", q.Description)
+	assert.Equal(t, 3, q.MaxAttempts)
+	assert.Equal(t, 90*time.Minute, q.AttemptCooldown)
+	assert.Equal(t, quiz.GradeHighest, q.GradingPolicy)
+	require.Len(t, q.Questions, 4)
+
+	assert.Equal(t, quiz.Question{
+		Type:        quiz.TypeSingleChoice,
+		Content:     "What is the capital of France?",
+		Choices:     []quiz.Choice{{Content: "Paris", IsCorrect: true, Feedback: "Right."}, {Content: "Lyon", Feedback: "It is the third city."}},
+		Explanation: "Paris has been the capital since 508.",
+	}, q.Questions[0])
+	assert.Equal(t, quiz.Question{
+		Type:    quiz.TypeMultiChoice,
+		Content: "Which of these are capitals?\n\n- Pick carefully.\n- There may be one.",
+		Choices: []quiz.Choice{{Content: "Rome", IsCorrect: true}, {Content: "Milan"}},
+		Tags:    quiz.StringList{"capitals", "hard"},
+	}, q.Questions[1])
+	assert.Equal(t, quiz.TypeShortText, q.Questions[2].Type)
+	assert.Equal(t, "```\n## not a question\n```\n\nWhat does the code print?", q.Questions[2].Content)
+	assert.Equal(t, &quiz.TextSpec{Accepted: []string{"not a question"}}, q.Questions[2].Text)
+	assert.Equal(t, quiz.Question{
+		Type:     quiz.TypeOrdering,
+		Content:  "Sort by population",
+		Points:   2,
+		Ordering: &quiz.OrderingSpec{Items: []string{"Malta", "Ireland", "Germany"}, AllOrNothing: true},
+	}, q.Questions[3])
+}
+
+func TestMarkdown_RoundTrip(t *testing.T) {
+	closes := time.Date(2026, 12, 31, 23, 0, 0, 0, time.UTC)
+	q := quiz.NewQuiz()
+	q.Name = "Everything"
+	q.Description = "Covers every type.\n\n## Rules\n\nNone."
+	q.MaxAttempts, q.AttemptCooldown, q.ClosesAt, q.SubmitGrace = 2, time.Hour, &closes, 5*time.Minute
+	q.RevealPolicy = quiz.RevealImmediately
+	q.Meta = quiz.JSONMap{"course": "geo-101"}
+	q.Questions = append(sheetQuiz().Questions,
+		quiz.Question{Type: quiz.TypeSingleChoice, Content: "1. Not a list\n\nbut a question", Points: 2, Penalty: 0.5,
+			Choices: []quiz.Choice{{Content: "one\nline two", IsCorrect: true}, {Content: "other", ThumbKey: "images/thumb"}}},
+		quiz.Question{Type: quiz.TypeMultiChoice, Content: "Only one is right", PenaltyFraction: 0.25,
+			Choices: []quiz.Choice{{Content: "yes", IsCorrect: true}, {Content: "no"}}},
+		quiz.Question{Type: quiz.TypeShortText, Content: "## Heading\n\nName it", Text: &quiz.TextSpec{Accepted: []string{"it"}, Patterns: []string{"i.*"}, CaseSensitive: true}},
+		quiz.Question{Type: quiz.TypeNumeric, Content: "How long?", Numeric: &quiz.NumericSpec{Answer: 0, Tolerance: 0.1, Units: []quiz.NumericUnit{{Unit: "cm", Multiplier: 0.01}}, UnitRequired: true}},
+		quiz.Question{Type: quiz.TypeMatching, Content: "Pair up", Matching: &quiz.MatchingSpec{Pairs: []quiz.MatchPair{{Prompt: "a", Answer: "b"}}, AllOrNothing: true}},
+		quiz.Question{Type: quiz.TypeEssay, Content: "Discuss", Essay: &quiz.EssaySpec{Rubric: []quiz.RubricCriterion{{Name: "Depth", Description: "Goes deep", Points: 3}}}},
+		quiz.Question{Type: quiz.TypeCode, Content: "Write Add", Code: &quiz.CodeSpec{Language: "go", Starter: "package add\n", Tests: "package add\n\nfunc TestAdd(t *testing.T) {}\n", TimeoutSeconds: 5}},
+		quiz.Question{Type: quiz.TypeLikert, Content: "Rate it", ImageKey: "images/a", ImageAlt: "A chart", MediaKey: "attachments/b", MediaType: "audio/mpeg", MaxPlays: 2,
+			Captions: "WEBVTT\n\n00:00.000 --> 00:01.000\nHi", Transcript: "Hi", Meta: quiz.JSONMap{"source": "survey"}},
+	)
+
+	var buf bytes.Buffer
+	problems, err := formats.ExportMarkdown(&buf, q)
+	require.NoError(t, err)
+	var messages []string
+	for _, p := range problems {
+		messages = append(messages, p.String())
+	}
+	assert.Equal(t, []string{
+		`"Everything": level-2 headings of the description are written as level 3`,
+		`"1. Not a list but a question": choice thumbnails left out`,
+		`"## Heading Name it": level-2 headings are written as level 3`,
+	}, messages)
+
+	res, err := formats.ImportMarkdown(&buf, "ignored.md")
+	require.NoError(t, err)
+	assert.Empty(t, res.Problems)
+	require.Len(t, res.Quizzes, 1)
+	imported := res.Quizzes[0]
+
+	// Put back what the export reported as changed.
+	q.Description = strings.Replace(q.Description, "## Rules", "### Rules", 1)
+	q.Questions[10].Choices[1].ThumbKey = ""
+	q.Questions[12].Content = "### Heading\n\nName it"
+	assert.Equal(t, q, imported)
+}
+
+func TestExportMarkdown(t *testing.T) {
+	q := quiz.NewQuiz()
+	q.Name = "Capitals"
+	q.Questions = []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "What is the capital of France?", Explanation: "Since 508.",
+			Choices: []quiz.Choice{{Content: "Paris", IsCorrect: true, Feedback: "Right."}, {Content: "Lyon"}}},
+		{Type: quiz.TypeNumeric, Content: "- g in m/s²?", Points: 2, Numeric: &quiz.NumericSpec{Answer: 9.81, Tolerance: 0.05}},
+	}
+	var buf bytes.Buffer
+	_, err := formats.ExportMarkdown(&buf, q)
+	require.NoError(t, err)
+	assert.Equal(t, `---
+title: Capitals
+grading_policy: highest
+reveal_policy: after-submission
+---
+
+## What is the capital of France?
+
+- [x] Paris
+  > Right.
+- [ ] Lyon
+
+### Explanation
+
+Since 508.
+
+##
+type: numeric
+points: 2
+answer: 9.81
+tolerance: 0.05
+
+- g in m/s²?
+`, buf.String())
+}
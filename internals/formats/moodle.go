@@ -0,0 +1,423 @@
+package formats
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// moodleText is a text element of Moodle XML, such as questiontext.
+type moodleText struct {
+	Format string       `xml:"format,attr"`
+	Text   string       `xml:"text"`
+	Files  []moodleFile `xml:"file"`
+}
+
+type moodleFile struct {
+	Name string `xml:"name,attr"`
+}
+
+type moodleAnswer struct {
+	moodleText
+	Fraction  string     `xml:"fraction,attr"`
+	Feedback  moodleText `xml:"feedback"`
+	Tolerance string     `xml:"tolerance"`
+}
+
+type moodleSubquestion struct {
+	moodleText
+	Answer string `xml:"answer>text"`
+}
+
+type moodleUnit struct {
+	Name       string `xml:"unit_name"`
+	Multiplier string `xml:"multiplier"`
+}
+
+type moodleQuestion struct {
+	Type            string              `xml:"type,attr"`
+	Name            string              `xml:"name>text"`
+	Category        string              `xml:"category>text"`
+	QuestionText    moodleText          `xml:"questiontext"`
+	GeneralFeedback moodleText          `xml:"generalfeedback"`
+	DefaultGrade    string              `xml:"defaultgrade"`
+	Single          string              `xml:"single"`
+	UseCase         string              `xml:"usecase"`
+	Answers         []moodleAnswer      `xml:"answer"`
+	Subquestions    []moodleSubquestion `xml:"subquestion"`
+	Units           []moodleUnit        `xml:"units>unit"`
+}
+
+// ImportMoodleXML reads a Moodle XML question export. Every category of the
+// export becomes a quiz named after it.
+func ImportMoodleXML(r io.Reader, name string) (*Result, error) {
+	res := &Result{}
+	dec := xml.NewDecoder(r)
+	category := quizName(name)
+	var current *quiz.Quiz
+	sawQuiz := false
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid Moodle XML: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local == "quiz" {
+			sawQuiz = true
+			continue
+		}
+		if start.Name.Local != "question" {
+			continue
+		}
+		line, _ := dec.InputPos()
+		var mq moodleQuestion
+		if err := dec.DecodeElement(&mq, &start); err != nil {
+			return nil, fmt.Errorf("invalid Moodle XML at line %d: %w", line, err)
+		}
+		if mq.Type == "category" {
+			if name := moodleCategory(mq.Category); name != "" {
+				category, current = name, nil
+			}
+			continue
+		}
+		question, ok := convertMoodle(res, line, &mq)
+		if !ok {
+			continue
+		}
+		if current == nil {
+			current = quiz.NewQuiz()
+			current.Name = category
+			res.Quizzes = append(res.Quizzes, current)
+		}
+		res.add(current, question, line)
+	}
+	if !sawQuiz {
+		return nil, fmt.Errorf("not a Moodle XML file: no <quiz> element")
+	}
+	return res, nil
+}
+
+// moodleCategory names a quiz after the last part of a category path such
+// as "$course$/top/Week 1".
+func moodleCategory(path string) string {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	name := strings.TrimSpace(parts[len(parts)-1])
+	if name == "top" || strings.HasPrefix(name, "$") {
+		return ""
+	}
+	return name
+}
+
+func convertMoodle(res *Result, line int, mq *moodleQuestion) (quiz.Question, bool) {
+	label := strings.TrimSpace(mq.Name)
+	if label == "" {
+		label = summary(htmlToText(mq.QuestionText.Text))
+	}
+	problem := func(format string, args ...interface{}) {
+		res.problem(line, label, format, args...)
+	}
+
+	question := quiz.Question{
+		Content:     moodleMarkdown(mq.QuestionText),
+		Explanation: moodlePlain(mq.GeneralFeedback),
+		Meta:        quiz.JSONMap{},
+	}
+	if len(mq.QuestionText.Files) > 0 {
+		problem("embedded files are not imported")
+	}
+	if grade, err := strconv.ParseFloat(strings.TrimSpace(mq.DefaultGrade), 64); err == nil && grade > 0 {
+		question.Points = grade
+	}
+
+	switch mq.Type {
+	case "multichoice":
+		single := mq.Single != "false" && mq.Single != "0"
+		question.Type = quiz.TypeMultiChoice
+		if single {
+			question.Type = quiz.TypeSingleChoice
+		}
+		weights := map[float64]bool{}
+		for _, answer := range mq.Answers {
+			fraction := moodleFraction(answer.Fraction)
+			correct := fraction > 0
+			if single {
+				correct = fraction >= 100
+				if fraction > 0 && fraction < 100 {
+					problem("partial credit of %g%% for %q is not supported, the answer counts as wrong", fraction, htmlToText(answer.Text))
+				}
+			} else if fraction > 0 {
+				weights[fraction] = true
+			}
+			if fraction < 0 {
+				question.PenaltyFraction = min(max(question.PenaltyFraction, -fraction/100), 1)
+			}
+			question.Choices = append(question.Choices, quiz.Choice{
+				Content:   moodleMarkdown(answer.moodleText),
+				IsCorrect: correct,
+				Feedback:  moodlePlain(answer.Feedback),
+			})
+		}
+		if len(weights) > 1 {
+			problem("answer weights are not supported, the correct answers share the credit equally")
+		}
+
+	case "truefalse":
+		question.Type = quiz.TypeSingleChoice
+		for _, answer := range mq.Answers {
+			content := "False"
+			if strings.EqualFold(strings.TrimSpace(answer.Text), "true") {
+				content = "True"
+			}
+			question.Choices = append(question.Choices, quiz.Choice{
+				Content:   content,
+				IsCorrect: moodleFraction(answer.Fraction) >= 100,
+				Feedback:  moodlePlain(answer.Feedback),
+			})
+		}
+
+	case "shortanswer":
+		question.Type = quiz.TypeShortText
+		question.Text = &quiz.TextSpec{CaseSensitive: strings.TrimSpace(mq.UseCase) == "1"}
+		for _, answer := range mq.Answers {
+			text := htmlToText(answer.Text)
+			fraction := moodleFraction(answer.Fraction)
+			switch {
+			case fraction >= 100 && strings.Contains(text, "*"):
+				question.Text.Patterns = append(question.Text.Patterns, wildcardPattern(text, question.Text.CaseSensitive))
+			case fraction >= 100:
+				question.Text.Accepted = append(question.Text.Accepted, text)
+			case fraction > 0:
+				problem("partial credit of %g%% for %q is not supported, the answer counts as wrong", fraction, text)
+			}
+		}
+
+	case "numerical":
+		question.Type = quiz.TypeNumeric
+		for _, answer := range mq.Answers {
+			text := htmlToText(answer.Text)
+			fraction := moodleFraction(answer.Fraction)
+			if fraction < 100 || question.Numeric != nil {
+				if fraction > 0 && text != "*" {
+					problem("only the first fully correct answer is imported, %q is dropped", text)
+				}
+				continue
+			}
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				problem("skipped: invalid numeric answer %q", text)
+				return question, false
+			}
+			tolerance, _ := strconv.ParseFloat(strings.TrimSpace(answer.Tolerance), 64)
+			question.Numeric = &quiz.NumericSpec{Answer: value, Tolerance: tolerance}
+		}
+		if question.Numeric == nil {
+			problem("skipped: no fully correct answer")
+			return question, false
+		}
+		for _, unit := range mq.Units {
+			multiplier, err := strconv.ParseFloat(strings.TrimSpace(unit.Multiplier), 64)
+			if err != nil || multiplier == 0 {
+				problem("invalid multiplier for unit %q, the unit is dropped", unit.Name)
+				continue
+			}
+			question.Numeric.Units = append(question.Numeric.Units, quiz.NumericUnit{Unit: strings.TrimSpace(unit.Name), Multiplier: multiplier})
+		}
+
+	case "matching":
+		question.Type = quiz.TypeMatching
+		question.Matching = &quiz.MatchingSpec{}
+		for _, sub := range mq.Subquestions {
+			answer := strings.TrimSpace(sub.Answer)
+			if strings.TrimSpace(htmlToText(sub.Text)) == "" {
+				question.Matching.Distractors = append(question.Matching.Distractors, answer)
+				continue
+			}
+			question.Matching.Pairs = append(question.Matching.Pairs, quiz.MatchPair{Prompt: moodleMarkdown(sub.moodleText), Answer: answer})
+		}
+
+	case "essay":
+		question.Type = quiz.TypeEssay
+
+	case "cloze", "multianswer":
+		content, err := moodleCloze(mq.QuestionText)
+		if err != nil {
+			problem("skipped: %v", err)
+			return question, false
+		}
+		question.Type, question.Content = quiz.TypeCloze, content
+
+	case "description":
+		problem("skipped: descriptions are not questions")
+		return question, false
+
+	default:
+		problem("skipped: %s questions are not supported", mq.Type)
+		return question, false
+	}
+	return question, true
+}
+
+func moodleFraction(fraction string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSpace(fraction), 64)
+	return f
+}
+
+// moodleMarkdown converts Moodle text into quiz content.
+func moodleMarkdown(t moodleText) string {
+	switch t.Format {
+	case "markdown":
+		return strings.TrimSpace(t.Text)
+	case "plain_text":
+		return escapeMarkdown(strings.TrimSpace(t.Text))
+	default:
+		return htmlToMarkdown(t.Text)
+	}
+}
+
+// moodlePlain converts Moodle text into plain text, for feedback.
+func moodlePlain(t moodleText) string {
+	if t.Format == "markdown" || t.Format == "plain_text" {
+		return strings.TrimSpace(t.Text)
+	}
+	return htmlToText(t.Text)
+}
+
+// wildcardPattern turns a Moodle answer where * matches anything into a
+// regular expression.
+func wildcardPattern(answer string, caseSensitive bool) string {
+	parts := strings.Split(answer, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	pattern := strings.Join(parts, ".*")
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return pattern
+}
+
+// clozeMarker is an embedded answer of Moodle cloze text, such as
+// {1:SHORTANSWER:=Paris~%50%paris}.
+var clozeMarker = regexp.MustCompile(`\{(\d*):([A-Z_]+):((?:\\.|[^\\}])*)\}`)
+
+// moodleCloze converts the embedded answers of Moodle cloze text into
+// blanks. Only short-answer and exact numeric blanks have a counterpart.
+func moodleCloze(t moodleText) (string, error) {
+	markers := clozeMarker.FindAllStringSubmatch(t.Text, -1)
+	if len(markers) == 0 {
+		return "", fmt.Errorf("no embedded answers")
+	}
+	blanks := make([]string, len(markers))
+	for i, m := range markers {
+		numeric := false
+		switch m[2] {
+		case "SHORTANSWER", "SA", "MW", "SHORTANSWER_C", "SAC", "MWC":
+		case "NUMERICAL", "NM":
+			numeric = true
+		default:
+			return "", fmt.Errorf("%s blanks are not supported", m[2])
+		}
+		var accepted []string
+		for _, answer := range splitUnescaped(m[3], '~') {
+			answer, _, _ = cutUnescaped(answer, '#')
+			if strings.HasPrefix(answer, "=") {
+				answer = answer[1:]
+			} else if weight, rest, ok := cutWeight(answer); ok && weight >= 100 {
+				answer = rest
+			} else {
+				continue
+			}
+			if numeric && strings.Contains(answer, ":") {
+				return "", fmt.Errorf("numeric tolerances in blanks are not supported")
+			}
+			accepted = append(accepted, html.UnescapeString(unescapeMoodle(answer)))
+		}
+		if len(accepted) == 0 {
+			return "", fmt.Errorf("blank %d has no fully correct answer", i+1)
+		}
+		blanks[i] = "{{" + strings.Join(accepted, "|") + "}}"
+	}
+
+	// Placeholders from the private use area survive the HTML conversion,
+	// unlike the braces of the blanks.
+	i := 0
+	placeholders := clozeMarker.ReplaceAllStringFunc(t.Text, func(string) string {
+		i++
+		return fmt.Sprintf("\ue000%d\ue001", i-1)
+	})
+	content := moodleMarkdown(moodleText{Format: t.Format, Text: placeholders})
+	for i, blank := range blanks {
+		content = strings.Replace(content, fmt.Sprintf("\ue000%d\ue001", i), blank, 1)
+	}
+	return content, nil
+}
+
+// cutWeight splits a "%50%answer" weight off an answer.
+func cutWeight(answer string) (float64, string, bool) {
+	if !strings.HasPrefix(answer, "%") {
+		return 0, answer, false
+	}
+	end := strings.Index(answer[1:], "%")
+	if end < 0 {
+		return 0, answer, false
+	}
+	weight, err := strconv.ParseFloat(answer[1:end+1], 64)
+	if err != nil {
+		return 0, answer, false
+	}
+	return weight, answer[end+2:], true
+}
+
+// splitUnescaped splits s at every sep not escaped by a backslash.
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// cutUnescaped is like strings.Cut but skips separators escaped by a
+// backslash.
+func cutUnescaped(s string, sep byte) (before, after string, found bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+func unescapeMoodle(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return strings.TrimSpace(b.String())
+}
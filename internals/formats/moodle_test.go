@@ -0,0 +1,145 @@
+package formats_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/formats"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+)
+
+const moodleSample = `<?xml version="1.0" encoding="UTF-8"?>
+<quiz>
+  <question type="category">
+    <category><text>$course$/top/Chemistry</text></category>
+  </question>
+  <question type="multichoice">
+    <name><text>Water</text></name>
+    <questiontext format="html"><text><![CDATA[<p>What is <strong>water</strong> made of? \(H_2O\)</p>]]></text></questiontext>
+    <generalfeedback format="html"><text><![CDATA[<p>Two hydrogen atoms and one oxygen atom.</p>]]></text></generalfeedback>
+    <defaultgrade>2.0000000</defaultgrade>
+    <single>true</single>
+    <answer fraction="100" format="html"><text>Hydrogen and oxygen</text><feedback format="html"><text>Correct</text></feedback></answer>
+    <answer fraction="-50" format="html"><text>Carbon</text><feedback format="html"><text>&lt;p&gt;No&lt;/p&gt;</text></feedback></answer>
+  </question>
+  <question type="multichoice">
+    <name><text>Gases</text></name>
+    <questiontext format="plain_text"><text>Which are noble gases?</text></questiontext>
+    <single>false</single>
+    <answer fraction="70"><text>Neon</text></answer>
+    <answer fraction="30"><text>Argon</text></answer>
+    <answer fraction="0"><text>Oxygen</text></answer>
+  </question>
+  <question type="truefalse">
+    <name><text>Salt</text></name>
+    <questiontext format="html"><text>Salt dissolves in water.</text></questiontext>
+    <answer fraction="100"><text>true</text><feedback><text>Yes</text></feedback></answer>
+    <answer fraction="0"><text>false</text><feedback><text>It does</text></feedback></answer>
+  </question>
+  <question type="shortanswer">
+    <name><text>Symbol</text></name>
+    <questiontext format="html"><text>Symbol of gold?</text></questiontext>
+    <usecase>1</usecase>
+    <answer fraction="100"><text>Au</text></answer>
+    <answer fraction="100"><text>Au*</text></answer>
+    <answer fraction="50"><text>Ag</text></answer>
+  </question>
+  <question type="numerical">
+    <name><text>Boiling</text></name>
+    <questiontext format="html"><text>Boiling point of water?</text></questiontext>
+    <answer fraction="100"><text>100</text><tolerance>0.5</tolerance></answer>
+    <units><unit><multiplier>1</multiplier><unit_name>C</unit_name></unit></units>
+  </question>
+  <question type="matching">
+    <name><text>Elements</text></name>
+    <questiontext format="html"><text>Match the symbols</text></questiontext>
+    <subquestion format="html"><text>Fe</text><answer><text>Iron</text></answer></subquestion>
+    <subquestion format="html"><text>Cu</text><answer><text>Copper</text></answer></subquestion>
+    <subquestion format="html"><text></text><answer><text>Tin</text></answer></subquestion>
+  </question>
+  <question type="cloze">
+    <name><text>Capital</text></name>
+    <questiontext format="html"><text><![CDATA[<p>The capital of France is {1:SHORTANSWER:=Paris~%100%paris~%50%Lyon}.</p>]]></text></questiontext>
+  </question>
+  <question type="cloze">
+    <name><text>Pick</text></name>
+    <questiontext format="html"><text>Pick {1:MULTICHOICE:=a~b}</text></questiontext>
+  </question>
+  <question type="category">
+    <category><text>$course$/top/Essays</text></category>
+  </question>
+  <question type="essay">
+    <name><text>Lab report</text></name>
+    <questiontext format="html"><text><![CDATA[<p>Write about:</p><ul><li>the method</li><li>the results</li></ul>]]></text></questiontext>
+  </question>
+  <question type="description">
+    <name><text>Intro</text></name>
+    <questiontext format="html"><text>Welcome</text></questiontext>
+  </question>
+  <question type="calculated">
+    <name><text>Formula</text></name>
+    <questiontext format="html"><text>{a} + {b}</text></questiontext>
+  </question>
+</quiz>
+`
+
+func TestImportMoodleXML(t *testing.T) {
+	res, err := formats.ImportMoodleXML(strings.NewReader(moodleSample), "export.xml")
+	assert.NoError(t, err)
+	assert.Len(t, res.Quizzes, 2)
+	chemistry, essays := res.Quizzes[0], res.Quizzes[1]
+	assert.Equal(t, "Chemistry", chemistry.Name)
+	assert.Equal(t, "Essays", essays.Name)
+	assert.Len(t, chemistry.Questions, 7)
+
+	water := chemistry.Questions[0]
+	assert.Equal(t, quiz.TypeSingleChoice, water.Type)
+	assert.Equal(t, "What is **water** made of? $H_2O$", water.Content)
+	assert.Equal(t, "Two hydrogen atoms and one oxygen atom.", water.Explanation)
+	assert.Equal(t, 2.0, water.Points)
+	assert.Equal(t, 0.5, water.PenaltyFraction)
+	assert.True(t, water.Choices[0].IsCorrect)
+	assert.Equal(t, "Correct", water.Choices[0].Feedback)
+	assert.Equal(t, "No", water.Choices[1].Feedback)
+
+	gases := chemistry.Questions[1]
+	assert.Equal(t, quiz.TypeMultiChoice, gases.Type)
+	assert.True(t, gases.Choices[0].IsCorrect && gases.Choices[1].IsCorrect)
+	assert.False(t, gases.Choices[2].IsCorrect)
+
+	salt := chemistry.Questions[2]
+	assert.Equal(t, []quiz.Choice{{Content: "True", IsCorrect: true, Feedback: "Yes"}, {Content: "False", Feedback: "It does"}}, salt.Choices)
+
+	symbol := chemistry.Questions[3]
+	assert.Equal(t, &quiz.TextSpec{Accepted: []string{"Au"}, Patterns: []string{`Au.*`}, CaseSensitive: true}, symbol.Text)
+
+	boiling := chemistry.Questions[4]
+	assert.Equal(t, &quiz.NumericSpec{Answer: 100, Tolerance: 0.5, Units: []quiz.NumericUnit{{Unit: "C", Multiplier: 1}}}, boiling.Numeric)
+
+	elements := chemistry.Questions[5]
+	assert.Equal(t, []quiz.MatchPair{{Prompt: "Fe", Answer: "Iron"}, {Prompt: "Cu", Answer: "Copper"}}, elements.Matching.Pairs)
+	assert.Equal(t, []string{"Tin"}, elements.Matching.Distractors)
+
+	capital := chemistry.Questions[6]
+	assert.Equal(t, quiz.TypeCloze, capital.Type)
+	assert.Equal(t, "The capital of France is {{Paris|paris}}.", capital.Content)
+
+	assert.Equal(t, quiz.TypeEssay, essays.Questions[0].Type)
+	assert.Equal(t, "Write about:\n\n- the method\n- the results", essays.Questions[0].Content)
+
+	var messages []string
+	for _, p := range res.Problems {
+		messages = append(messages, p.String())
+	}
+	assert.Equal(t, []string{
+		`line 15, "Gases": answer weights are not supported, the correct answers share the credit equally`,
+		`line 29, "Symbol": partial credit of 50% for "Ag" is not supported, the answer counts as wrong`,
+		`line 54, "Pick": skipped: MULTICHOICE blanks are not supported`,
+		`line 65, "Intro": skipped: descriptions are not questions`,
+		`line 69, "Formula": skipped: calculated questions are not supported`,
+	}, messages)
+
+	_, err = formats.ImportMoodleXML(strings.NewReader("<html></html>"), "page.xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,386 @@
+package formats
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// QTI versions that packages are written in. Both are read.
+const (
+	QTI21 = "2.1"
+	QTI30 = "3.0"
+)
+
+const mathMLNamespace = "http://www.w3.org/1998/Math/MathML"
+
+// qtiVersion holds what differs between the files of two QTI versions,
+// besides the spelling of names.
+type qtiVersion struct {
+	kebab          bool // QTI 3.0 spells assessmentItem as qti-assessment-item
+	namespace      string
+	schemaLocation string
+	manifest       string // Namespace of imsmanifest.xml
+	schema         string
+	schemaVersion  string
+	testType       string // Resource types of the manifest
+	itemType       string
+}
+
+var qtiVersions = map[string]*qtiVersion{
+	QTI21: {
+		namespace:      "http://www.imsglobal.org/xsd/imsqti_v2p1",
+		schemaLocation: "http://www.imsglobal.org/xsd/imsqti_v2p1 http://www.imsglobal.org/xsd/qti/qtiv2p1/imsqti_v2p1.xsd",
+		manifest:       "http://www.imsglobal.org/xsd/imscp_v1p1",
+		schema:         "QTIv2.1 Package",
+		schemaVersion:  "1.0.0",
+		testType:       "imsqti_test_xmlv2p1",
+		itemType:       "imsqti_item_xmlv2p1",
+	},
+	QTI30: {
+		kebab:          true,
+		namespace:      "http://www.imsglobal.org/xsd/imsqtiasi_v3p0",
+		schemaLocation: "http://www.imsglobal.org/xsd/imsqtiasi_v3p0 https://purl.imsglobal.org/spec/qti/v3p0/schema/xsd/imsqti_asiv3p0_v1p0.xsd",
+		manifest:       "http://www.imsglobal.org/xsd/qti/qtiv3p0/imscp_v1p1",
+		schema:         "QTI Package",
+		schemaVersion:  "3.0.0",
+		testType:       "imsqti_test_xmlv3p0",
+		itemType:       "imsqti_item_xmlv3p0",
+	},
+}
+
+// element is an element of a QTI file, or a text node when Name is "".
+// Names are spelt as in QTI 2.1 whatever the version of the file, so one
+// reader and one writer serve both versions.
+type element struct {
+	Name     string
+	QTI      bool // A QTI element rather than XHTML or MathML content
+	Attrs    []xml.Attr
+	Children []*element
+	Text     string
+}
+
+// qti returns a QTI element with attributes given as name, value pairs.
+func qti(name string, attrs ...string) *element {
+	e := &element{Name: name, QTI: true}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		e.set(attrs[i], attrs[i+1])
+	}
+	return e
+}
+
+func textNode(s string) *element {
+	return &element{Text: s}
+}
+
+func (e *element) add(children ...*element) *element {
+	for _, c := range children {
+		if c != nil {
+			e.Children = append(e.Children, c)
+		}
+	}
+	return e
+}
+
+func (e *element) set(name, value string) {
+	for i := range e.Attrs {
+		if e.Attrs[i].Name.Local == name {
+			e.Attrs[i].Value = value
+			return
+		}
+	}
+	e.Attrs = append(e.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+}
+
+func (e *element) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child returns the first child element with the name, or nil.
+func (e *element) child(name string) *element {
+	for _, c := range e.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// all returns the child elements with the name.
+func (e *element) all(name string) []*element {
+	var found []*element
+	for _, c := range e.Children {
+		if c.Name == name {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// find returns the descendants of e that match, in document order, without
+// looking inside the matches.
+func (e *element) find(match func(*element) bool) []*element {
+	var found []*element
+	for _, c := range e.Children {
+		if c.Name == "" {
+			continue
+		}
+		if match(c) {
+			found = append(found, c)
+			continue
+		}
+		found = append(found, c.find(match)...)
+	}
+	return found
+}
+
+// remove drops the descendants of e that match.
+func (e *element) remove(match func(*element) bool) {
+	children := e.Children[:0]
+	for _, c := range e.Children {
+		if c.Name != "" && match(c) {
+			continue
+		}
+		c.remove(match)
+		children = append(children, c)
+	}
+	e.Children = children
+}
+
+// text returns the text of e and its descendants.
+func (e *element) text() string {
+	if e.Name == "" {
+		return e.Text
+	}
+	var b strings.Builder
+	for _, c := range e.Children {
+		b.WriteString(c.text())
+	}
+	return b.String()
+}
+
+// parseQTI reads a QTI file of either version.
+func parseQTI(r io.Reader) (*element, error) {
+	dec := xml.NewDecoder(r)
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	var stack []*element
+	var root *element
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			e := &element{Name: tok.Name.Local}
+			if name, found := strings.CutPrefix(e.Name, "qti-"); found {
+				e.Name, e.QTI = camelCase(name), true
+			}
+			for _, a := range tok.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" || a.Name.Space == "http://www.w3.org/2001/XMLSchema-instance" {
+					continue
+				}
+				name := a.Name.Local
+				if e.QTI {
+					name = camelCase(name)
+				}
+				e.set(name, a.Value)
+			}
+			if len(stack) > 0 {
+				stack[len(stack)-1].add(e)
+			} else if root == nil {
+				root = e
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].add(textNode(string(tok)))
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("empty file")
+	}
+	return root, nil
+}
+
+// camelCase turns the kebab-case names of QTI 3.0 into those of QTI 2.1.
+func camelCase(name string) string {
+	parts := strings.Split(name, "-")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// kebabCase turns the camel-case names of QTI 2.1 into those of QTI 3.0.
+func kebabCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// xml writes e as a QTI file of the version.
+func (e *element) xml(v *qtiVersion) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	e.write(&b, v)
+	b.WriteString("\n")
+	return b.String()
+}
+
+func (e *element) write(b *strings.Builder, v *qtiVersion) {
+	if e.Name == "" {
+		b.WriteString(xmlText.Replace(e.Text))
+		return
+	}
+	name := e.Name
+	if e.QTI && v.kebab {
+		name = "qti-" + kebabCase(name)
+	}
+	b.WriteString("<" + name)
+	for _, a := range e.Attrs {
+		attrName := a.Name.Local
+		if e.QTI && v.kebab && !strings.Contains(attrName, ":") {
+			attrName = kebabCase(attrName)
+		}
+		b.WriteString(" " + attrName + `="`)
+		xml.EscapeText(b, []byte(a.Value))
+		b.WriteString(`"`)
+	}
+	if len(e.Children) == 0 {
+		b.WriteString("/>")
+		return
+	}
+	b.WriteString(">")
+	for _, c := range e.Children {
+		c.write(b, v)
+	}
+	b.WriteString("</" + name + ">")
+}
+
+// xmlText escapes text nodes, keeping their line breaks readable.
+var xmlText = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// html writes content elements as HTML, for conversion to Markdown.
+func (e *element) html(b *strings.Builder) {
+	if e.Name == "" {
+		b.WriteString(html.EscapeString(e.Text))
+		return
+	}
+	b.WriteString("<" + e.Name)
+	for _, a := range e.Attrs {
+		b.WriteString(" " + a.Name.Local + `="` + html.EscapeString(a.Value) + `"`)
+	}
+	b.WriteString(">")
+	if a := atom.Lookup([]byte(e.Name)); a == atom.Img || a == atom.Br || a == atom.Hr {
+		return
+	}
+	for _, c := range e.Children {
+		c.html(b)
+	}
+	b.WriteString("</" + e.Name + ">")
+}
+
+// htmlElements parses rendered HTML into content elements, which are
+// written back as well-formed XHTML.
+func htmlElements(src string) []*element {
+	nodes, err := html.ParseFragment(strings.NewReader(src), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return []*element{textNode(src)}
+	}
+	var elements []*element
+	for _, n := range nodes {
+		if e := htmlElement(n); e != nil {
+			elements = append(elements, e)
+		}
+	}
+	return elements
+}
+
+func htmlElement(n *html.Node) *element {
+	switch n.Type {
+	case html.TextNode:
+		return textNode(n.Data)
+	case html.ElementNode:
+	default:
+		return nil
+	}
+	e := &element{Name: n.Data}
+	if n.Data == "math" {
+		e.set("xmlns", mathMLNamespace)
+	}
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key != "xmlns" {
+			e.set(a.Key, a.Val)
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		e.add(htmlElement(c))
+	}
+	return e
+}
+
+// qtiManifest is the imsmanifest.xml of a content package.
+type qtiManifest struct {
+	XMLName       xml.Name      `xml:"manifest"`
+	Namespace     string        `xml:"xmlns,attr,omitempty"`
+	Identifier    string        `xml:"identifier,attr"`
+	Schema        string        `xml:"metadata>schema"`
+	SchemaVersion string        `xml:"metadata>schemaversion"`
+	Organizations struct{}      `xml:"organizations"`
+	Resources     []qtiResource `xml:"resources>resource"`
+}
+
+type qtiResource struct {
+	Identifier   string          `xml:"identifier,attr"`
+	Type         string          `xml:"type,attr"`
+	Href         string          `xml:"href,attr,omitempty"`
+	Files        []qtiFile       `xml:"file"`
+	Dependencies []qtiDependency `xml:"dependency"`
+}
+
+type qtiFile struct {
+	Href string `xml:"href,attr"`
+}
+
+type qtiDependency struct {
+	IdentifierRef string `xml:"identifierref,attr"`
+}
+
+func qtiVersionOf(version string) (*qtiVersion, error) {
+	v, found := qtiVersions[version]
+	if !found {
+		return nil, fmt.Errorf("unknown QTI version %q, use %s or %s", version, QTI21, QTI30)
+	}
+	return v, nil
+}
@@ -0,0 +1,578 @@
+package formats
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/markup"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// qtiExporter builds the files of a QTI package.
+type qtiExporter struct {
+	version  *qtiVersion
+	media    func(key string) ([]byte, error)
+	files    map[string][]byte // Media files by path in the package
+	problems []Problem
+}
+
+// ExportQTI writes the quiz as a QTI package in version QTI21 or QTI30: a
+// zip of an assessment test, one item per question and the images and
+// recordings of the questions, read with media. Quiz settings such as
+// attempt limits have no QTI counterpart and are left out; the question
+// details that are left out are returned as problems.
+func ExportQTI(w io.Writer, q *quiz.Quiz, version string, media func(key string) ([]byte, error)) ([]Problem, error) {
+	v, err := qtiVersionOf(version)
+	if err != nil {
+		return nil, err
+	}
+	ex := &qtiExporter{version: v, media: media, files: make(map[string][]byte)}
+
+	manifest := qtiManifest{
+		Namespace:     v.manifest,
+		Identifier:    "manifest",
+		Schema:        v.schema,
+		SchemaVersion: v.schemaVersion,
+	}
+	test := qtiResource{Identifier: "test", Type: v.testType, Href: "assessment.xml", Files: []qtiFile{{Href: "assessment.xml"}}}
+	section := qti("assessmentSection", "identifier", "section", "title", q.Name, "visible", "true")
+	if q.Description != "" {
+		section.add(ex.rubricBlock("candidate", htmlElements(markup.Render(q.Description))...))
+	}
+	items := make(map[string]string)
+	var itemResources []qtiResource
+	for i := range q.Questions {
+		id := fmt.Sprintf("item-%d", len(itemResources)+1)
+		item, media := ex.item(id, &q.Questions[i])
+		if item == nil {
+			continue
+		}
+		href := "items/" + id + ".xml"
+		items[href] = item.xml(v)
+		section.add(qti("assessmentItemRef", "identifier", id, "href", href))
+		resource := qtiResource{Identifier: id, Type: v.itemType, Href: href, Files: []qtiFile{{Href: href}}}
+		for _, file := range media {
+			resource.Files = append(resource.Files, qtiFile{Href: file})
+		}
+		itemResources = append(itemResources, resource)
+		test.Dependencies = append(test.Dependencies, qtiDependency{IdentifierRef: id})
+	}
+	if len(itemResources) == 0 {
+		return ex.problems, fmt.Errorf("quiz %q has no question that can be exported", q.Name)
+	}
+	manifest.Resources = append([]qtiResource{test}, itemResources...)
+
+	assessment := qti("assessmentTest", "identifier", "test", "title", q.Name).add(
+		qti("outcomeDeclaration", "identifier", "SCORE", "cardinality", "single", "baseType", "float"),
+		qti("testPart", "identifier", "part", "navigationMode", "nonlinear", "submissionMode", "simultaneous").add(section),
+		qti("outcomeProcessing").add(
+			setOutcome("SCORE", qti("sum").add(qti("testVariables", "variableIdentifier", "SCORE"))),
+		),
+	)
+	ex.root(assessment)
+
+	manifestXML, err := xml.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return ex.problems, err
+	}
+	modified := time.Now()
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"imsmanifest.xml", append([]byte(xml.Header), manifestXML...)},
+		{"assessment.xml", []byte(assessment.xml(v))},
+	}
+	for _, resource := range itemResources {
+		files = append(files, struct {
+			name string
+			data []byte
+		}{resource.Href, []byte(items[resource.Href])})
+	}
+	names := make([]string, 0, len(ex.files))
+	for name := range ex.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		files = append(files, struct {
+			name string
+			data []byte
+		}{name, ex.files[name]})
+	}
+	for _, file := range files {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return ex.problems, err
+		}
+		if _, err := f.Write(file.data); err != nil {
+			return ex.problems, err
+		}
+	}
+	return ex.problems, zw.Close()
+}
+
+func (ex *qtiExporter) problem(question *quiz.Question, format string, args ...interface{}) {
+	ex.problems = append(ex.problems, Problem{Question: summary(question.Prompt()), Message: fmt.Sprintf(format, args...)})
+}
+
+// root adds the namespace of the version to a file root.
+func (ex *qtiExporter) root(e *element) {
+	e.Attrs = append([]xml.Attr{
+		{Name: xml.Name{Local: "xmlns"}, Value: ex.version.namespace},
+		{Name: xml.Name{Local: "xmlns:xsi"}, Value: "http://www.w3.org/2001/XMLSchema-instance"},
+		{Name: xml.Name{Local: "xsi:schemaLocation"}, Value: ex.version.schemaLocation},
+	}, e.Attrs...)
+}
+
+// rubricBlock and modalFeedback hold their content directly in QTI 2.1 and
+// in a content body in QTI 3.0.
+func (ex *qtiExporter) rubricBlock(view string, content ...*element) *element {
+	return qti("rubricBlock", "view", view).add(ex.contentBody(content)...)
+}
+
+func (ex *qtiExporter) contentBody(content []*element) []*element {
+	if ex.version.kebab {
+		return []*element{qti("contentBody").add(content...)}
+	}
+	return content
+}
+
+// mediaFile adds the blob to the package and returns its path relative to
+// the items, or "" when it cannot be read.
+func (ex *qtiExporter) mediaFile(question *quiz.Question, key string) string {
+	if ex.media == nil {
+		ex.problem(question, "media left out: no media storage")
+		return ""
+	}
+	data, err := ex.media(key)
+	if err != nil {
+		ex.problem(question, "media left out: %v", err)
+		return ""
+	}
+	name := "media/" + path.Base(key)
+	ex.files[name] = data
+	return "../" + name
+}
+
+// item builds the QTI item of a question, and lists the media files it
+// uses, or returns nil when the question has no QTI counterpart.
+func (ex *qtiExporter) item(id string, question *quiz.Question) (*element, []string) {
+	title := summary(question.Prompt())
+	if title == "" {
+		title = id
+	}
+	item := qti("assessmentItem", "identifier", id, "title", title, "adaptive", "false", "timeDependent", "false")
+	body := qti("itemBody")
+	var declarations []*element
+	var rules []*element
+	answered := qti("not").add(qti("isNull").add(variable("RESPONSE")))
+	feedbackCardinality, hasFeedback := "single", false
+
+	content := question.Content
+	if question.Type == quiz.TypeCloze {
+		content = clozePlaceholders(question.Content)
+	}
+	contentElements := htmlElements(markup.Render(content))
+
+	switch question.Type {
+	case quiz.TypeSingleChoice, quiz.TypeMultiChoice, quiz.TypeLikert:
+		cardinality, maxChoices := "single", "1"
+		if question.Type == quiz.TypeMultiChoice {
+			cardinality, maxChoices, feedbackCardinality = "multiple", "0", "multiple"
+		}
+		interaction := qti("choiceInteraction", "responseIdentifier", "RESPONSE", "shuffle", "false", "maxChoices", maxChoices)
+		var correct []string
+		if question.Type == quiz.TypeLikert {
+			labels := quiz.DefaultLikertLabels
+			if question.Likert != nil && len(question.Likert.Labels) > 0 {
+				labels = question.Likert.Labels
+			}
+			for i, label := range labels {
+				interaction.add(qti("simpleChoice", "identifier", fmt.Sprintf("CHOICE_%d", i+1)).add(textNode(label)))
+			}
+		}
+		for i, choice := range question.Choices {
+			if question.Type == quiz.TypeLikert {
+				break // Rating questions have labels rather than choices
+			}
+			choiceID := fmt.Sprintf("CHOICE_%d", i+1)
+			if choice.IsCorrect {
+				correct = append(correct, choiceID)
+			}
+			simple := qti("simpleChoice", "identifier", choiceID).add(htmlElements(markup.RenderInline(choice.Content))...)
+			if choice.ThumbKey != "" {
+				if src := ex.mediaFile(question, choice.ThumbKey); src != "" {
+					simple.add(image(src, ""))
+				}
+			}
+			if choice.Feedback != "" {
+				hasFeedback = true
+				simple.add(qti("feedbackInline", "outcomeIdentifier", "FEEDBACK", "identifier", choiceID, "showHide", "show").add(textNode(choice.Feedback)))
+			}
+			interaction.add(simple)
+		}
+		declarations = append(declarations, responseDeclaration("RESPONSE", cardinality, "identifier", correct))
+		body.add(contentElements...)
+		ex.addImage(body, question)
+		ex.addRecording(body, question, &declarations)
+		body.add(interaction)
+		if question.Type != quiz.TypeLikert {
+			rules = append(rules, creditIf(qti("match").add(variable("RESPONSE"), qti("correct", "identifier", "RESPONSE"))))
+		}
+		if hasFeedback {
+			rules = append(rules, setOutcome("FEEDBACK", variable("RESPONSE")))
+		}
+
+	case quiz.TypeShortText:
+		spec := question.Text
+		if spec == nil {
+			spec = &quiz.TextSpec{}
+		}
+		declaration := responseDeclaration("RESPONSE", "single", "string", spec.Accepted[:min(1, len(spec.Accepted))])
+		var conditions []*element
+		if len(spec.Accepted) > 0 {
+			mapping := qti("mapping", "defaultValue", "0")
+			for _, accepted := range spec.Accepted {
+				mapping.add(qti("mapEntry", "mapKey", accepted, "mappedValue", "1", "caseSensitive", strconv.FormatBool(spec.CaseSensitive)))
+			}
+			declaration.add(mapping)
+			conditions = append(conditions, qti("gt").add(qti("mapResponse", "identifier", "RESPONSE"), baseValue("float", "0")))
+		}
+		for _, pattern := range spec.Patterns {
+			if !spec.CaseSensitive {
+				ex.problem(question, "pattern %q is exported case-sensitive", pattern)
+			}
+			conditions = append(conditions, qti("patternMatch", "pattern", pattern).add(variable("RESPONSE")))
+		}
+		declarations = append(declarations, declaration)
+		body.add(contentElements...)
+		ex.addImage(body, question)
+		ex.addRecording(body, question, &declarations)
+		body.add((&element{Name: "p"}).add(qti("textEntryInteraction", "responseIdentifier", "RESPONSE")))
+		rules = append(rules, creditIf(anyOf(conditions)))
+
+	case quiz.TypeNumeric:
+		spec := question.Numeric
+		if spec == nil {
+			spec = &quiz.NumericSpec{}
+		}
+		declarations = append(declarations, responseDeclaration("RESPONSE", "single", "float", []string{formatFloat(spec.Answer)}))
+		equal := qti("equal", "toleranceMode", "exact")
+		switch {
+		case spec.Tolerance > 0:
+			equal = qti("equal", "toleranceMode", "absolute", "tolerance", formatFloat(spec.Tolerance))
+		case spec.RelativeTolerance > 0:
+			equal = qti("equal", "toleranceMode", "relative", "tolerance", formatFloat(spec.RelativeTolerance*100))
+		}
+		if len(spec.Units) > 0 || spec.UnitRequired {
+			ex.problem(question, "units left out: QTI numbers have no units")
+		}
+		body.add(contentElements...)
+		ex.addImage(body, question)
+		ex.addRecording(body, question, &declarations)
+		body.add((&element{Name: "p"}).add(qti("textEntryInteraction", "responseIdentifier", "RESPONSE")))
+		rules = append(rules, creditIf(equal.add(variable("RESPONSE"), qti("correct", "identifier", "RESPONSE"))))
+
+	case quiz.TypeOrdering:
+		spec := question.Ordering
+		if spec == nil {
+			spec = &quiz.OrderingSpec{}
+		}
+		interaction := qti("orderInteraction", "responseIdentifier", "RESPONSE", "shuffle", "true")
+		var order []string
+		for i, item := range spec.Items {
+			itemID := fmt.Sprintf("ITEM_%d", i+1)
+			order = append(order, itemID)
+			interaction.add(qti("simpleChoice", "identifier", itemID).add(htmlElements(markup.RenderInline(item))...))
+		}
+		declarations = append(declarations, responseDeclaration("RESPONSE", "ordered", "identifier", order))
+		body.add(contentElements...)
+		ex.addImage(body, question)
+		ex.addRecording(body, question, &declarations)
+		body.add(interaction)
+		if spec.AllOrNothing {
+			rules = append(rules, creditIf(qti("match").add(variable("RESPONSE"), qti("correct", "identifier", "RESPONSE"))))
+			break
+		}
+		// Each item in the right place earns its share of the points.
+		share := baseValue("float", formatFloat(1/float64(max(1, len(order)))))
+		for i, itemID := range order {
+			rules = append(rules, qti("responseCondition").add(qti("responseIf").add(
+				qti("match").add(qti("index", "n", strconv.Itoa(i+1)).add(variable("RESPONSE")), baseValue("identifier", itemID)),
+				setOutcome("SCORE", qti("sum").add(variable("SCORE"), qti("product").add(variable("MAXSCORE"), share))),
+			)))
+		}
+
+	case quiz.TypeMatching:
+		spec := question.Matching
+		if spec == nil {
+			spec = &quiz.MatchingSpec{}
+		}
+		prompts := qti("simpleMatchSet")
+		answers := qti("simpleMatchSet")
+		answerIDs := make(map[string]string)
+		for _, option := range spec.Options() {
+			answerID := fmt.Sprintf("ANSWER_%d", len(answerIDs)+1)
+			answerIDs[option] = answerID
+			answers.add(qti("simpleAssociableChoice", "identifier", answerID, "matchMax", strconv.Itoa(len(spec.Pairs))).add(htmlElements(markup.RenderInline(option))...))
+		}
+		var pairs []string
+		for i, pair := range spec.Pairs {
+			promptID := fmt.Sprintf("PROMPT_%d", i+1)
+			prompts.add(qti("simpleAssociableChoice", "identifier", promptID, "matchMax", "1").add(htmlElements(markup.RenderInline(pair.Prompt))...))
+			pairs = append(pairs, promptID+" "+answerIDs[pair.Answer])
+		}
+		declaration := responseDeclaration("RESPONSE", "multiple", "directedPair", pairs)
+		declarations = append(declarations, declaration)
+		body.add(contentElements...)
+		ex.addImage(body, question)
+		ex.addRecording(body, question, &declarations)
+		body.add(qti("matchInteraction", "responseIdentifier", "RESPONSE", "shuffle", "true", "maxAssociations", strconv.Itoa(len(spec.Pairs))).add(prompts, answers))
+		if spec.AllOrNothing {
+			rules = append(rules, creditIf(qti("match").add(variable("RESPONSE"), qti("correct", "identifier", "RESPONSE"))))
+			break
+		}
+		// Each right pair earns its share of the points.
+		mapping := qti("mapping", "defaultValue", "0", "lowerBound", "0")
+		for _, pair := range pairs {
+			mapping.add(qti("mapEntry", "mapKey", pair, "mappedValue", formatFloat(1/float64(len(pairs)))))
+		}
+		declaration.add(mapping)
+		rules = append(rules, setOutcome("SCORE", qti("product").add(variable("MAXSCORE"), qti("mapResponse", "identifier", "RESPONSE"))))
+
+	case quiz.TypeCloze:
+		segments, err := quiz.ParseCloze(question.Content)
+		if err != nil {
+			ex.problem(question, "skipped: %v", err)
+			return nil, nil
+		}
+		var credits, answeredAny []*element
+		for _, segment := range segments {
+			if segment.Blank < 0 {
+				continue
+			}
+			responseID := fmt.Sprintf("RESPONSE_%d", segment.Blank+1)
+			declaration := responseDeclaration(responseID, "single", "string", segment.Accepted[:1])
+			mapping := qti("mapping", "defaultValue", "0", "upperBound", "1")
+			for _, accepted := range segment.Accepted {
+				mapping.add(qti("mapEntry", "mapKey", accepted, "mappedValue", "1", "caseSensitive", "false"))
+			}
+			declarations = append(declarations, declaration.add(mapping))
+			credits = append(credits, qti("mapResponse", "identifier", responseID))
+			answeredAny = append(answeredAny, qti("not").add(qti("isNull").add(variable(responseID))))
+		}
+		fillBlanks(contentElements)
+		body.add(contentElements...)
+		ex.addImage(body, question)
+		ex.addRecording(body, question, &declarations)
+		answered = anyOf(answeredAny)
+		rules = append(rules, setOutcome("SCORE", qti("product").add(variable("MAXSCORE"),
+			qti("divide").add(qti("sum").add(credits...), baseValue("float", strconv.Itoa(len(credits)))))))
+
+	case quiz.TypeEssay:
+		declarations = append(declarations, qti("responseDeclaration", "identifier", "RESPONSE", "cardinality", "single", "baseType", "string"))
+		body.add(contentElements...)
+		ex.addImage(body, question)
+		ex.addRecording(body, question, &declarations)
+		body.add(qti("extendedTextInteraction", "responseIdentifier", "RESPONSE"))
+		if question.Essay != nil && len(question.Essay.Rubric) > 0 {
+			list := &element{Name: "ul"}
+			for _, criterion := range question.Essay.Rubric {
+				line := fmt.Sprintf("%s (%s points)", criterion.Name, formatFloat(criterion.Points))
+				if criterion.Description != "" {
+					line += ": " + criterion.Description
+				}
+				list.add((&element{Name: "li"}).add(textNode(line)))
+			}
+			body.add(ex.rubricBlock("scorer", list))
+		}
+
+	default:
+		ex.problem(question, "skipped: %s questions have no QTI counterpart", question.Type)
+		return nil, nil
+	}
+
+	if question.Explanation != "" || hasFeedback {
+		declarations = append(declarations, qti("outcomeDeclaration", "identifier", "FEEDBACK", "cardinality", feedbackCardinality, "baseType", "identifier"))
+	}
+	declarations = append(declarations,
+		qti("outcomeDeclaration", "identifier", "SCORE", "cardinality", "single", "baseType", "float").add(defaultValue("0")),
+		qti("outcomeDeclaration", "identifier", "MAXSCORE", "cardinality", "single", "baseType", "float").add(defaultValue(formatFloat(question.MaxPoints()))),
+	)
+	// The penalties are applied by the response processing; they are also
+	// declared so an import gets them back as they were set.
+	if question.Penalty > 0 {
+		declarations = append(declarations, qti("outcomeDeclaration", "identifier", "PENALTY", "cardinality", "single", "baseType", "float").add(defaultValue(formatFloat(question.Penalty))))
+	}
+	if question.PenaltyFraction > 0 {
+		declarations = append(declarations, qti("outcomeDeclaration", "identifier", "PENALTY_FRACTION", "cardinality", "single", "baseType", "float").add(defaultValue(formatFloat(question.PenaltyFraction))))
+	}
+	item.add(declarations...)
+	item.add(body)
+	if len(rules) > 0 {
+		if penalty := question.WrongPenalty(); penalty > 0 {
+			rules = append(rules, qti("responseCondition").add(qti("responseIf").add(
+				qti("and").add(answered, qti("lte").add(variable("SCORE"), baseValue("float", "0"))),
+				setOutcome("SCORE", baseValue("float", formatFloat(-penalty))),
+			)))
+		}
+		item.add(qti("responseProcessing").add(rules...))
+	}
+	if question.Explanation != "" {
+		item.add(qti("modalFeedback", "outcomeIdentifier", "FEEDBACK", "identifier", "EXPLANATION", "showHide", "hide", "title", "Explanation").
+			add(ex.contentBody(htmlElements(markup.Render(question.Explanation)))...))
+	}
+	ex.root(item)
+
+	var media []string
+	for _, img := range item.find(func(e *element) bool { return e.Name == "img" || e.Name == "object" }) {
+		src := img.attr("src")
+		if img.Name == "object" {
+			src = img.attr("data")
+		}
+		if rest, found := strings.CutPrefix(src, "../"); found {
+			media = append(media, rest)
+		}
+	}
+	return item, media
+}
+
+// addImage adds the question image after the content.
+func (ex *qtiExporter) addImage(body *element, question *quiz.Question) {
+	if question.ImageKey == "" {
+		return
+	}
+	if src := ex.mediaFile(question, question.ImageKey); src != "" {
+		body.add((&element{Name: "p"}).add(image(src, question.ImageAlt)))
+	}
+}
+
+// addRecording adds the question recording as a media interaction, which
+// counts the plays against the play limit.
+func (ex *qtiExporter) addRecording(body *element, question *quiz.Question, declarations *[]*element) {
+	if !question.HasMedia() {
+		return
+	}
+	if question.Captions != "" || question.Transcript != "" {
+		ex.problem(question, "captions and transcript left out: QTI media have neither")
+	}
+	src := ex.mediaFile(question, question.MediaKey)
+	if src == "" {
+		return
+	}
+	object := &element{Name: "object"}
+	object.set("data", src)
+	object.set("type", question.MediaType)
+	body.add(qti("mediaInteraction", "responseIdentifier", "MEDIA", "autostart", "false", "maxPlays", strconv.Itoa(question.MaxPlays)).add(object))
+	*declarations = append(*declarations, qti("responseDeclaration", "identifier", "MEDIA", "cardinality", "single", "baseType", "integer"))
+}
+
+func image(src, alt string) *element {
+	img := &element{Name: "img"}
+	img.set("src", src)
+	img.set("alt", alt)
+	return img
+}
+
+func responseDeclaration(id, cardinality, baseType string, correct []string) *element {
+	declaration := qti("responseDeclaration", "identifier", id, "cardinality", cardinality, "baseType", baseType)
+	if len(correct) > 0 {
+		response := qti("correctResponse")
+		for _, value := range correct {
+			response.add(qti("value").add(textNode(value)))
+		}
+		declaration.add(response)
+	}
+	return declaration
+}
+
+func defaultValue(value string) *element {
+	return qti("defaultValue").add(qti("value").add(textNode(value)))
+}
+
+func variable(id string) *element {
+	return qti("variable", "identifier", id)
+}
+
+func baseValue(baseType, value string) *element {
+	return qti("baseValue", "baseType", baseType).add(textNode(value))
+}
+
+func setOutcome(id string, expression *element) *element {
+	return qti("setOutcomeValue", "identifier", id).add(expression)
+}
+
+// creditIf gives the question points when the condition holds.
+func creditIf(condition *element) *element {
+	return qti("responseCondition").add(qti("responseIf").add(condition, setOutcome("SCORE", variable("MAXSCORE"))))
+}
+
+func anyOf(conditions []*element) *element {
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	return qti("or").add(conditions...)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// clozePlaceholders replaces the blanks of cloze content with placeholders
+// that survive rendering, for fillBlanks to turn into text entries.
+func clozePlaceholders(content string) string {
+	segments, err := quiz.ParseCloze(content)
+	if err != nil {
+		return content
+	}
+	var b strings.Builder
+	for _, segment := range segments {
+		if segment.Blank < 0 {
+			b.WriteString(segment.Text)
+			continue
+		}
+		fmt.Fprintf(&b, "\ue000%d\ue001", segment.Blank)
+	}
+	return b.String()
+}
+
+// fillBlanks replaces the placeholders in text nodes with text entries.
+func fillBlanks(elements []*element) {
+	for _, e := range elements {
+		var children []*element
+		for _, c := range e.Children {
+			if c.Name != "" || !strings.ContainsRune(c.Text, '\ue000') {
+				children = append(children, c)
+				continue
+			}
+			text := c.Text
+			for {
+				start := strings.IndexRune(text, '\ue000')
+				end := strings.IndexRune(text, '\ue001')
+				if start < 0 || end < start {
+					break
+				}
+				n, _ := strconv.Atoi(text[start+len("\ue000") : end])
+				if start > 0 {
+					children = append(children, textNode(text[:start]))
+				}
+				children = append(children, qti("textEntryInteraction", "responseIdentifier", fmt.Sprintf("RESPONSE_%d", n+1)))
+				text = text[end+len("\ue001"):]
+			}
+			if text != "" {
+				children = append(children, textNode(text))
+			}
+		}
+		e.Children = children
+		fillBlanks(e.Children)
+	}
+}
@@ -0,0 +1,614 @@
+package formats
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mbsof31/go-quiz/internals/blob"
+	"github.com/mbsof31/go-quiz/internals/media"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// qtiImporter reads the files of a QTI package.
+type qtiImporter struct {
+	res   *Result
+	files map[string]*zip.File
+}
+
+// ImportQTI reads a QTI 2.1 or 3.0 package. Every assessment test of the
+// package becomes a quiz; a package of items only becomes one quiz named
+// after the file. Images and recordings of the items are returned in the
+// media of the result.
+func ImportQTI(r io.Reader, name string) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid QTI package: %w", err)
+	}
+	im := &qtiImporter{res: &Result{}, files: make(map[string]*zip.File)}
+	for _, f := range zr.File {
+		im.files[path.Clean(f.Name)] = f
+	}
+	manifestFile, found := im.files["imsmanifest.xml"]
+	if !found {
+		return nil, fmt.Errorf("invalid QTI package: no imsmanifest.xml")
+	}
+	manifestData, err := im.read(manifestFile.Name)
+	if err != nil {
+		return nil, err
+	}
+	var manifest qtiManifest
+	if err := xml.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid imsmanifest.xml: %w", err)
+	}
+
+	var tests, items []string
+	for _, resource := range manifest.Resources {
+		href := resource.Href
+		if href == "" && len(resource.Files) > 0 {
+			href = resource.Files[0].Href
+		}
+		switch {
+		case strings.HasPrefix(resource.Type, "imsqti_test"):
+			tests = append(tests, path.Clean(href))
+		case strings.HasPrefix(resource.Type, "imsqti_item"):
+			items = append(items, path.Clean(href))
+		}
+	}
+	for _, test := range tests {
+		if err := im.test(test); err != nil {
+			return nil, fmt.Errorf("%s: %w", test, err)
+		}
+	}
+	if len(tests) == 0 && len(items) > 0 {
+		q := quiz.NewQuiz()
+		q.Name = quizName(name)
+		for _, item := range items {
+			if question, ok := im.item(item); ok {
+				q.Questions = append(q.Questions, question)
+			}
+		}
+		im.res.Quizzes = append(im.res.Quizzes, q)
+	}
+	if len(tests) == 0 && len(items) == 0 {
+		return nil, fmt.Errorf("invalid QTI package: the manifest lists no test or item")
+	}
+	return im.res, nil
+}
+
+func (im *qtiImporter) read(name string) ([]byte, error) {
+	f, found := im.files[path.Clean(name)]
+	if !found {
+		return nil, fmt.Errorf("missing file %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, media.MaxAttachmentSize+1))
+}
+
+func (im *qtiImporter) parse(name string) (*element, error) {
+	data, err := im.read(name)
+	if err != nil {
+		return nil, err
+	}
+	return parseQTI(bytes.NewReader(data))
+}
+
+// test reads an assessment test into a quiz, with the items of all its
+// sections in order.
+func (im *qtiImporter) test(name string) error {
+	root, err := im.parse(name)
+	if err != nil {
+		return err
+	}
+	if root.Name != "assessmentTest" {
+		return fmt.Errorf("not an assessment test")
+	}
+	q := quiz.NewQuiz()
+	q.Name = root.attr("title")
+	if q.Name == "" {
+		q.Name = quizName(name)
+	}
+	var description []string
+	for _, block := range root.find(func(e *element) bool { return e.Name == "rubricBlock" }) {
+		if strings.Contains(block.attr("view"), "candidate") {
+			description = append(description, contentMarkdown(block.Children))
+		}
+	}
+	q.Description = strings.Join(description, "\n\n")
+	for _, ref := range root.find(func(e *element) bool { return e.Name == "assessmentItemRef" }) {
+		if question, ok := im.item(path.Join(path.Dir(name), ref.attr("href"))); ok {
+			q.Questions = append(q.Questions, question)
+		}
+	}
+	im.res.Quizzes = append(im.res.Quizzes, q)
+	return nil
+}
+
+// qtiResponse is a response declaration of an item.
+type qtiResponse struct {
+	baseType    string
+	cardinality string
+	correct     []string
+	mapping     []qtiMapEntry
+}
+
+type qtiMapEntry struct {
+	key           string
+	value         float64
+	caseSensitive bool
+}
+
+// interactions that answer a question, as opposed to media interactions.
+var answerInteractions = map[string]bool{
+	"choiceInteraction": true, "orderInteraction": true, "matchInteraction": true,
+	"textEntryInteraction": true, "extendedTextInteraction": true,
+}
+
+// item reads an assessment item into a question, reporting what it cannot
+// convert.
+func (im *qtiImporter) item(name string) (quiz.Question, bool) {
+	root, err := im.parse(name)
+	if err != nil {
+		im.res.problem(0, name, "skipped: %v", err)
+		return quiz.Question{}, false
+	}
+	if root.Name != "assessmentItem" {
+		im.res.problem(0, name, "skipped: not an assessment item")
+		return quiz.Question{}, false
+	}
+	title := root.attr("title")
+	if title == "" {
+		title = name
+	}
+	fail := func(format string, args ...interface{}) (quiz.Question, bool) {
+		im.res.problem(0, title, "skipped: "+format, args...)
+		return quiz.Question{}, false
+	}
+
+	responses := make(map[string]*qtiResponse)
+	for _, declaration := range root.all("responseDeclaration") {
+		response := &qtiResponse{baseType: declaration.attr("baseType"), cardinality: declaration.attr("cardinality")}
+		if correct := declaration.child("correctResponse"); correct != nil {
+			for _, value := range correct.all("value") {
+				response.correct = append(response.correct, strings.TrimSpace(value.text()))
+			}
+		}
+		if mapping := declaration.child("mapping"); mapping != nil {
+			for _, entry := range mapping.all("mapEntry") {
+				value, _ := strconv.ParseFloat(entry.attr("mappedValue"), 64)
+				response.mapping = append(response.mapping, qtiMapEntry{
+					key:           entry.attr("mapKey"),
+					value:         value,
+					caseSensitive: entry.attr("caseSensitive") != "false",
+				})
+			}
+		}
+		responses[declaration.attr("identifier")] = response
+	}
+	outcomes := make(map[string]float64)
+	for _, declaration := range root.all("outcomeDeclaration") {
+		if value := declaration.find(func(e *element) bool { return e.Name == "value" }); len(value) > 0 {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(value[0].text()), 64); err == nil {
+				outcomes[declaration.attr("identifier")] = f
+			}
+		}
+	}
+	processing := root.child("responseProcessing")
+	if processing == nil {
+		processing = &element{}
+	}
+
+	question := quiz.Question{Points: outcomes["MAXSCORE"], Penalty: outcomes["PENALTY"], PenaltyFraction: outcomes["PENALTY_FRACTION"]}
+	var explanations []string
+	for _, feedback := range root.all("modalFeedback") {
+		explanations = append(explanations, contentMarkdown(feedback.Children))
+	}
+	question.Explanation = strings.Join(explanations, "\n\n")
+
+	body := root.child("itemBody")
+	if body == nil {
+		return fail("the item has no body")
+	}
+	// Scorer rubrics are not shown to learners; they become essay rubrics.
+	var rubric []*element
+	body.remove(func(e *element) bool {
+		if e.Name == "rubricBlock" && !strings.Contains(e.attr("view"), "candidate") {
+			rubric = append(rubric, e)
+			return true
+		}
+		return false
+	})
+	im.takeRecording(&question, name, body, title)
+	im.takeImage(&question, name, body, title)
+
+	interactions := body.find(func(e *element) bool { return strings.HasSuffix(e.Name, "Interaction") })
+	var kinds []string
+	for _, interaction := range interactions {
+		if !answerInteractions[interaction.Name] {
+			return fail("%s is not supported", interaction.Name)
+		}
+		if len(kinds) == 0 || kinds[len(kinds)-1] != interaction.Name {
+			kinds = append(kinds, interaction.Name)
+		}
+	}
+	if len(kinds) == 0 {
+		return fail("the item has no interaction")
+	}
+	if len(kinds) > 1 || (len(interactions) > 1 && kinds[0] != "textEntryInteraction") {
+		return fail("items with several interactions are not supported")
+	}
+	interaction := interactions[0]
+	response := responses[interaction.attr("responseIdentifier")]
+	if response == nil {
+		response = &qtiResponse{}
+	}
+	// Prompts of interactions are part of the question content.
+	var prompts []*element
+	for _, prompt := range interaction.all("prompt") {
+		prompts = append(prompts, &element{Name: "div", Children: prompt.Children})
+	}
+
+	switch interaction.Name {
+	case "choiceInteraction":
+		correct := response.correct
+		if len(correct) == 0 {
+			for _, entry := range response.mapping {
+				if entry.value > 0 {
+					correct = append(correct, entry.key)
+				}
+			}
+		}
+		switch {
+		case len(correct) == 0:
+			question.Type = quiz.TypeLikert
+			question.Likert = &quiz.LikertSpec{}
+		case interaction.attr("maxChoices") == "1" && len(correct) == 1:
+			question.Type = quiz.TypeSingleChoice
+		default:
+			question.Type = quiz.TypeMultiChoice
+		}
+		for _, simple := range interaction.all("simpleChoice") {
+			if question.Type == quiz.TypeLikert {
+				question.Likert.Labels = append(question.Likert.Labels, strings.TrimSpace(collapseSpace(simple.text())))
+				continue
+			}
+			choice := quiz.Choice{IsCorrect: contains(correct, simple.attr("identifier"))}
+			simple.remove(func(e *element) bool {
+				if e.Name == "feedbackInline" {
+					choice.Feedback = strings.TrimSpace(collapseSpace(e.text()))
+					return true
+				}
+				return false
+			})
+			if img := im.takeImages(name, simple); len(img) > 0 {
+				if data, err := im.image(name, img[0], media.ThumbSize); err != nil {
+					im.res.problem(0, title, "thumbnail left out: %v", err)
+				} else {
+					choice.ThumbKey = im.media(quiz.ImagePrefix, data)
+				}
+			}
+			choice.Content = contentMarkdown(simple.Children)
+			question.Choices = append(question.Choices, choice)
+		}
+
+	case "orderInteraction":
+		question.Type = quiz.TypeOrdering
+		choices := make(map[string]string)
+		for _, simple := range interaction.all("simpleChoice") {
+			choices[simple.attr("identifier")] = contentMarkdown(simple.Children)
+		}
+		spec := &quiz.OrderingSpec{AllOrNothing: len(processing.find(func(e *element) bool { return e.Name == "index" })) == 0}
+		for _, id := range response.correct {
+			spec.Items = append(spec.Items, choices[id])
+		}
+		question.Ordering = spec
+
+	case "matchInteraction":
+		question.Type = quiz.TypeMatching
+		sets := interaction.all("simpleMatchSet")
+		if len(sets) != 2 {
+			return fail("match interactions need two sets")
+		}
+		texts := make(map[string]string)
+		for _, set := range sets {
+			for _, choice := range set.all("simpleAssociableChoice") {
+				texts[choice.attr("identifier")] = contentMarkdown(choice.Children)
+			}
+		}
+		answers := make(map[string]string)
+		for _, pair := range response.correct {
+			source, target, _ := strings.Cut(strings.TrimSpace(pair), " ")
+			answers[source] = strings.TrimSpace(target)
+		}
+		spec := &quiz.MatchingSpec{AllOrNothing: len(response.mapping) == 0}
+		used := make(map[string]bool)
+		for _, choice := range sets[0].all("simpleAssociableChoice") {
+			target, found := answers[choice.attr("identifier")]
+			if !found {
+				continue
+			}
+			spec.Pairs = append(spec.Pairs, quiz.MatchPair{Prompt: texts[choice.attr("identifier")], Answer: texts[target]})
+			used[target] = true
+		}
+		for _, choice := range sets[1].all("simpleAssociableChoice") {
+			if !used[choice.attr("identifier")] {
+				spec.Distractors = append(spec.Distractors, texts[choice.attr("identifier")])
+			}
+		}
+		question.Matching = spec
+
+	case "extendedTextInteraction":
+		question.Type = quiz.TypeEssay
+		for _, block := range rubric {
+			for _, item := range block.find(func(e *element) bool { return e.Name == "li" || e.Name == "p" }) {
+				if m := rubricLine.FindStringSubmatch(strings.TrimSpace(collapseSpace(item.text()))); m != nil {
+					points, _ := strconv.ParseFloat(m[2], 64)
+					if question.Essay == nil {
+						question.Essay = &quiz.EssaySpec{}
+					}
+					question.Essay.Rubric = append(question.Essay.Rubric, quiz.RubricCriterion{Name: m[1], Points: points, Description: m[3]})
+				}
+			}
+		}
+
+	case "textEntryInteraction":
+		if len(interactions) > 1 || !standsAlone(body, interaction) {
+			return im.cloze(question, body, interactions, responses, title)
+		}
+		body.remove(func(e *element) bool { return e == interaction })
+		body.remove(emptyParagraph)
+		switch response.baseType {
+		case "float", "integer":
+			question.Type = quiz.TypeNumeric
+			if len(response.correct) == 0 {
+				return fail("the numeric answer has no correct value")
+			}
+			answer, err := strconv.ParseFloat(response.correct[0], 64)
+			if err != nil {
+				return fail("invalid numeric answer %q", response.correct[0])
+			}
+			spec := &quiz.NumericSpec{Answer: answer}
+			if equal := processing.find(func(e *element) bool { return e.Name == "equal" }); len(equal) > 0 {
+				tolerance, _ := strconv.ParseFloat(strings.Fields(equal[0].attr("tolerance") + " 0")[0], 64)
+				switch equal[0].attr("toleranceMode") {
+				case "absolute":
+					spec.Tolerance = tolerance
+				case "relative":
+					spec.RelativeTolerance = tolerance / 100
+				}
+			}
+			question.Numeric = spec
+		default:
+			question.Type = quiz.TypeShortText
+			spec := &quiz.TextSpec{CaseSensitive: true}
+			for _, entry := range response.mapping {
+				if entry.value > 0 {
+					spec.Accepted = appendNew(spec.Accepted, entry.key)
+					spec.CaseSensitive = entry.caseSensitive
+				}
+			}
+			if len(response.mapping) == 0 {
+				for _, value := range response.correct {
+					spec.Accepted = appendNew(spec.Accepted, value)
+				}
+			}
+			for _, pattern := range processing.find(func(e *element) bool { return e.Name == "patternMatch" }) {
+				spec.Patterns = append(spec.Patterns, pattern.attr("pattern"))
+			}
+			question.Text = spec
+		}
+	}
+
+	body.remove(func(e *element) bool { return answerInteractions[e.Name] })
+	question.Content = contentMarkdown(append(body.Children, prompts...))
+	return question, true
+}
+
+// rubricLine is a rubric criterion as exported, such as
+// "Method (2 points): follows the protocol".
+var rubricLine = regexp.MustCompile(`^(.+?) \(([\d.]+) points?\)(?:: (.*))?$`)
+
+// cloze reads an item whose text entries sit within the text, as cloze
+// content with one blank for every text entry.
+func (im *qtiImporter) cloze(question quiz.Question, body *element, interactions []*element, responses map[string]*qtiResponse, title string) (quiz.Question, bool) {
+	question.Type = quiz.TypeCloze
+	blanks := make(map[*element]string)
+	for _, interaction := range interactions {
+		response := responses[interaction.attr("responseIdentifier")]
+		var accepted []string
+		if response != nil {
+			for _, entry := range response.mapping {
+				if entry.value > 0 {
+					accepted = appendNew(accepted, strings.TrimSpace(entry.key))
+				}
+			}
+			if len(response.mapping) == 0 {
+				for _, value := range response.correct {
+					accepted = appendNew(accepted, value)
+				}
+			}
+		}
+		if len(accepted) == 0 {
+			im.res.problem(0, title, "skipped: a text entry has no correct answer")
+			return quiz.Question{}, false
+		}
+		blanks[interaction] = "{{" + strings.Join(accepted, "|") + "}}"
+	}
+	var placeholders []string
+	var replace func(e *element)
+	replace = func(e *element) {
+		for i, c := range e.Children {
+			if blank, found := blanks[c]; found {
+				e.Children[i] = textNode(fmt.Sprintf("\ue000%d\ue001", len(placeholders)))
+				placeholders = append(placeholders, blank)
+				continue
+			}
+			replace(c)
+		}
+	}
+	replace(body)
+	content := contentMarkdown(body.Children)
+	for i, blank := range placeholders {
+		content = strings.Replace(content, fmt.Sprintf("\ue000%d\ue001", i), blank, 1)
+	}
+	question.Content = content
+	return question, true
+}
+
+// standsAlone reports whether the interaction is alone in its paragraph at
+// the end of the body, as in a short-answer question rather than a cloze.
+func standsAlone(body, interaction *element) bool {
+	for i := len(body.Children) - 1; i >= 0; i-- {
+		c := body.Children[i]
+		if c.Name == "" && strings.TrimSpace(c.Text) == "" {
+			continue
+		}
+		if c == interaction {
+			return true
+		}
+		var others []*element
+		for _, grandchild := range c.Children {
+			if grandchild.Name != "" || strings.TrimSpace(grandchild.Text) != "" {
+				others = append(others, grandchild)
+			}
+		}
+		return len(others) == 1 && others[0] == interaction
+	}
+	return false
+}
+
+// takeImage removes the first image outside the interactions from the body
+// and makes it the question image.
+func (im *qtiImporter) takeImage(question *quiz.Question, name string, body *element, title string) {
+	images := body.find(func(e *element) bool {
+		return e.Name == "img" || (strings.HasSuffix(e.Name, "Interaction") && e.Name != "mediaInteraction")
+	})
+	if len(images) == 0 || images[0].Name != "img" || im.files[im.resolve(name, images[0].attr("src"))] == nil {
+		return
+	}
+	img := images[0]
+	body.remove(func(e *element) bool { return e == img })
+	body.remove(emptyParagraph)
+	data, err := im.image(name, img, media.QuestionSize)
+	if err != nil {
+		im.res.problem(0, title, "image left out: %v", err)
+		return
+	}
+	question.ImageKey, question.ImageAlt = im.media(quiz.ImagePrefix, data), img.attr("alt")
+}
+
+// takeImages removes the images in e that are files of the package.
+func (im *qtiImporter) takeImages(name string, e *element) []*element {
+	var images []*element
+	e.remove(func(c *element) bool {
+		if c.Name == "img" && im.files[im.resolve(name, c.attr("src"))] != nil {
+			images = append(images, c)
+			return true
+		}
+		return false
+	})
+	return images
+}
+
+// takeRecording removes the first media interaction from the body and
+// makes its object the question recording.
+func (im *qtiImporter) takeRecording(question *quiz.Question, name string, body *element, title string) {
+	interactions := body.find(func(e *element) bool { return e.Name == "mediaInteraction" })
+	if len(interactions) == 0 {
+		return
+	}
+	if len(interactions) > 1 {
+		im.res.problem(0, title, "only the first recording is imported")
+	}
+	interaction := interactions[0]
+	body.remove(func(e *element) bool { return e.Name == "mediaInteraction" })
+	var src string
+	if sources := interaction.find(func(e *element) bool { return e.attr("data") != "" || e.attr("src") != "" }); len(sources) > 0 {
+		if src = sources[0].attr("data"); src == "" {
+			src = sources[0].attr("src")
+		}
+	}
+	data, err := im.read(im.resolve(name, src))
+	if err != nil {
+		im.res.problem(0, title, "recording left out: %v", err)
+		return
+	}
+	data, contentType, err := media.Attachment(bytes.NewReader(data), path.Base(src))
+	if err != nil {
+		im.res.problem(0, title, "recording left out: %v", err)
+		return
+	}
+	question.MediaKey, question.MediaType = im.media(quiz.AttachmentPrefix, data), contentType
+	question.MaxPlays, _ = strconv.Atoi(interaction.attr("maxPlays"))
+}
+
+func (im *qtiImporter) image(name string, img *element, size media.Size) ([]byte, error) {
+	data, err := im.read(im.resolve(name, img.attr("src")))
+	if err != nil {
+		return nil, err
+	}
+	return media.Process(bytes.NewReader(data), size)
+}
+
+// media keeps a blob for the caller to store and returns its key.
+func (im *qtiImporter) media(prefix string, data []byte) string {
+	key := blob.ContentKey(prefix, data)
+	if im.res.Media == nil {
+		im.res.Media = make(map[string][]byte)
+	}
+	im.res.Media[key] = data
+	return key
+}
+
+// resolve returns the package path of a file referred to from the file
+// name.
+func (im *qtiImporter) resolve(name, src string) string {
+	if src == "" || strings.Contains(src, "://") {
+		return ""
+	}
+	return path.Join(path.Dir(name), src)
+}
+
+// emptyParagraph reports whether e is a paragraph left without content.
+func emptyParagraph(e *element) bool {
+	return e.Name == "p" && strings.TrimSpace(e.text()) == "" && len(e.find(func(*element) bool { return true })) == 0
+}
+
+// contentMarkdown converts XHTML content to the Markdown of quiz content.
+func contentMarkdown(content []*element) string {
+	var b strings.Builder
+	for _, e := range content {
+		e.html(&b)
+	}
+	return htmlToMarkdown(b.String())
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func appendNew(values []string, value string) []string {
+	if contains(values, value) {
+		return values
+	}
+	return append(values, value)
+}
@@ -0,0 +1,257 @@
+package formats_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/blob"
+	"github.com/mbsof31/go-quiz/internals/formats"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pngImage(t *testing.T) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 6))
+	for x := 0; x < 8; x++ {
+		img.Set(x, x%6, color.NRGBA{R: 200, A: 255})
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// qtiQuiz has a question of every type that QTI can carry.
+func qtiQuiz(t *testing.T) (*quiz.Quiz, map[string][]byte) {
+	picture := pngImage(t)
+	recording := append([]byte("ID3\x03\x00\x00\x00\x00\x00\x00"), make([]byte, 64)...)
+	blobs := map[string][]byte{
+		blob.ContentKey(quiz.ImagePrefix, picture):        picture,
+		blob.ContentKey(quiz.AttachmentPrefix, recording): recording,
+	}
+	pictureKey, recordingKey := blob.ContentKey(quiz.ImagePrefix, picture), blob.ContentKey(quiz.AttachmentPrefix, recording)
+
+	return &quiz.Quiz{
+		Name:        "Chemistry & physics",
+		Description: "Answer **every** question.",
+		Questions: []quiz.Question{
+			{
+				Type: quiz.TypeSingleChoice, Content: "What is *water* made of? $H_2O$", Points: 2, PenaltyFraction: 0.25,
+				Explanation: "Two hydrogen atoms and one oxygen atom.",
+				ImageKey:    pictureKey, ImageAlt: "A molecule",
+				Choices: []quiz.Choice{
+					{Content: "Hydrogen and oxygen", IsCorrect: true, Feedback: "Right"},
+					{Content: "Carbon", Feedback: "No, that is coal", ThumbKey: pictureKey},
+				},
+			},
+			{
+				Type: quiz.TypeMultiChoice, Content: "Which are noble gases?",
+				Choices: []quiz.Choice{{Content: "Neon", IsCorrect: true}, {Content: "Argon", IsCorrect: true}, {Content: "Oxygen"}},
+			},
+			{
+				Type: quiz.TypeShortText, Content: "Symbol of gold?", Penalty: 1,
+				Text: &quiz.TextSpec{Accepted: []string{"Au", "au."}, Patterns: []string{`A[uU]`}, CaseSensitive: true},
+			},
+			{
+				Type: quiz.TypeNumeric, Content: "Boiling point of water in °C?",
+				Numeric: &quiz.NumericSpec{Answer: 100, Tolerance: 0.5},
+			},
+			{
+				Type: quiz.TypeNumeric, Content: "Speed of light in km/s?",
+				Numeric: &quiz.NumericSpec{Answer: 299792, RelativeTolerance: 0.01},
+			},
+			{
+				Type: quiz.TypeOrdering, Content: "Order by mass",
+				Ordering: &quiz.OrderingSpec{Items: []string{"Electron", "Proton", "Atom"}},
+			},
+			{
+				Type: quiz.TypeOrdering, Content: "Order the steps",
+				Ordering: &quiz.OrderingSpec{Items: []string{"Heat", "Boil"}, AllOrNothing: true},
+			},
+			{
+				Type: quiz.TypeMatching, Content: "Match the symbols",
+				Matching: &quiz.MatchingSpec{
+					Pairs:       []quiz.MatchPair{{Prompt: "Fe", Answer: "Iron"}, {Prompt: "Cu", Answer: "Copper"}, {Prompt: "Ni", Answer: "Nickel"}},
+					Distractors: []string{"Tin"},
+				},
+			},
+			{
+				Type: quiz.TypeMatching, Content: "Match the states", Points: 3,
+				Matching: &quiz.MatchingSpec{Pairs: []quiz.MatchPair{{Prompt: "Ice", Answer: "Solid"}, {Prompt: "Steam", Answer: "Gas"}}, AllOrNothing: true},
+			},
+			{
+				Type: quiz.TypeCloze, Content: "The capital of **France** is {{Paris}} and of Italy {{Rome|Roma}}.",
+			},
+			{
+				Type: quiz.TypeEssay, Content: "Write about:\n\n- the method\n- the results",
+				Essay: &quiz.EssaySpec{Rubric: []quiz.RubricCriterion{{Name: "Method", Description: "Follows the protocol", Points: 2}, {Name: "Results", Points: 1.5}}},
+			},
+			{
+				Type: quiz.TypeLikert, Content: "The lab was useful",
+				Likert: &quiz.LikertSpec{Labels: []string{"No", "Somewhat", "Yes"}},
+			},
+			{
+				Type: quiz.TypeEssay, Content: "Describe what you hear",
+				MediaKey: recordingKey, MediaType: "audio/mpeg", MaxPlays: 2,
+			},
+		},
+	}, blobs
+}
+
+func TestQTI_RoundTrip(t *testing.T) {
+	for _, version := range []string{formats.QTI21, formats.QTI30} {
+		t.Run(version, func(t *testing.T) {
+			q, blobs := qtiQuiz(t)
+			var buf bytes.Buffer
+			problems, err := formats.ExportQTI(&buf, q, version, func(key string) ([]byte, error) {
+				return blobs[key], nil
+			})
+			require.NoError(t, err)
+			assert.Empty(t, problems)
+
+			res, err := formats.ImportQTI(bytes.NewReader(buf.Bytes()), "chemistry.zip")
+			require.NoError(t, err)
+			assert.Empty(t, res.Problems)
+			require.Len(t, res.Quizzes, 1)
+			imported := res.Quizzes[0]
+			for _, question := range imported.Questions {
+				for _, key := range []string{question.ImageKey, question.MediaKey} {
+					if key != "" {
+						assert.Contains(t, res.Media, key)
+					}
+				}
+			}
+
+			// Points and empty specs are filled in on export; the rest must
+			// come back as it was.
+			assert.Equal(t, q.Name, imported.Name)
+			assert.Equal(t, q.Description, imported.Description)
+			require.Len(t, imported.Questions, len(q.Questions))
+			for i := range q.Questions {
+				want := q.Questions[i]
+				want.Points = want.MaxPoints()
+				assert.Equal(t, want, imported.Questions[i], "question %d", i+1)
+			}
+		})
+	}
+}
+
+func TestExportQTI_Package(t *testing.T) {
+	q, blobs := qtiQuiz(t)
+	q.Questions = append(q.Questions,
+		quiz.Question{Type: quiz.TypeCode, Content: "Write Add", Code: &quiz.CodeSpec{Tests: "package add"}},
+		quiz.Question{Type: quiz.TypeNumeric, Content: "Length?", Numeric: &quiz.NumericSpec{Answer: 1, Units: []quiz.NumericUnit{{Unit: "m", Multiplier: 1}}}},
+	)
+	var buf bytes.Buffer
+	problems, err := formats.ExportQTI(&buf, q, formats.QTI30, func(key string) ([]byte, error) {
+		return blobs[key], nil
+	})
+	require.NoError(t, err)
+	var messages []string
+	for _, p := range problems {
+		messages = append(messages, p.String())
+	}
+	assert.Equal(t, []string{
+		`"Write Add": skipped: code questions have no QTI counterpart`,
+		`"Length?": units left out: QTI numbers have no units`,
+	}, messages)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		files[f.Name] = string(data)
+	}
+	assert.Contains(t, files, "items/item-14.xml")
+	assert.NotContains(t, files, "items/item-15.xml")
+	assert.Contains(t, files["imsmanifest.xml"], `type="imsqti_item_xmlv3p0"`)
+	assert.Contains(t, files["items/item-1.xml"], `<qti-assessment-item xmlns="http://www.imsglobal.org/xsd/imsqtiasi_v3p0"`)
+	assert.Contains(t, files["items/item-1.xml"], `<qti-choice-interaction response-identifier="RESPONSE" shuffle="false" max-choices="1">`)
+	assert.Contains(t, files["items/item-1.xml"], `<math xmlns="http://www.w3.org/1998/Math/MathML">`)
+	assert.Contains(t, files["items/item-13.xml"], `max-plays="2"`)
+	mediaFiles := 0
+	for name := range files {
+		if strings.HasPrefix(name, "media/") {
+			mediaFiles++
+		}
+	}
+	assert.Equal(t, 2, mediaFiles)
+}
+
+// A QTI 2.1 item as written by another tool, with its question in the
+// prompt and the standard response processing template.
+const foreignItem = `<?xml version="1.0" encoding="UTF-8"?>
+<assessmentItem xmlns="http://www.imsglobal.org/xsd/imsqti_v2p1" identifier="choice" title="Unattended Luggage" adaptive="false" timeDependent="false">
+  <responseDeclaration identifier="RESPONSE" cardinality="single" baseType="identifier">
+    <correctResponse><value>ChoiceA</value></correctResponse>
+  </responseDeclaration>
+  <outcomeDeclaration identifier="SCORE" cardinality="single" baseType="float"/>
+  <itemBody>
+    <p>Look at the text in the picture.</p>
+    <p><img src="images/sign.png" alt="NEVER LEAVE LUGGAGE UNATTENDED"/></p>
+    <choiceInteraction responseIdentifier="RESPONSE" shuffle="false" maxChoices="1">
+      <prompt>What does it say?</prompt>
+      <simpleChoice identifier="ChoiceA">You must stay with your luggage at all times.</simpleChoice>
+      <simpleChoice identifier="ChoiceB">Do not let someone else look after your luggage.</simpleChoice>
+    </choiceInteraction>
+  </itemBody>
+  <responseProcessing template="http://www.imsglobal.org/question/qti_v2p1/rptemplates/match_correct"/>
+</assessmentItem>`
+
+const foreignManifest = `<?xml version="1.0" encoding="UTF-8"?>
+<manifest xmlns="http://www.imsglobal.org/xsd/imscp_v1p1" identifier="m">
+  <resources>
+    <resource identifier="choice" type="imsqti_item_xmlv2p1" href="choice.xml"><file href="choice.xml"/></resource>
+    <resource identifier="hotspot" type="imsqti_item_xmlv2p1" href="hotspot.xml"><file href="hotspot.xml"/></resource>
+  </resources>
+</manifest>`
+
+const hotspotItem = `<assessmentItem xmlns="http://www.imsglobal.org/xsd/imsqti_v2p1" identifier="hotspot" title="Where is Edinburgh?">
+  <itemBody><hotspotInteraction responseIdentifier="RESPONSE" maxChoices="1"/></itemBody>
+</assessmentItem>`
+
+func TestImportQTI_Items(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string][]byte{
+		"imsmanifest.xml": []byte(foreignManifest),
+		"choice.xml":      []byte(foreignItem),
+		"hotspot.xml":     []byte(hotspotItem),
+		"images/sign.png": pngImage(t),
+	} {
+		f, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	res, err := formats.ImportQTI(bytes.NewReader(buf.Bytes()), "luggage.zip")
+	require.NoError(t, err)
+	require.Len(t, res.Quizzes, 1)
+	assert.Equal(t, "luggage", res.Quizzes[0].Name)
+	require.Len(t, res.Quizzes[0].Questions, 1)
+	question := res.Quizzes[0].Questions[0]
+	assert.Equal(t, quiz.TypeSingleChoice, question.Type)
+	assert.Equal(t, "Look at the text in the picture.\n\nWhat does it say?", question.Content)
+	assert.Equal(t, "NEVER LEAVE LUGGAGE UNATTENDED", question.ImageAlt)
+	assert.Contains(t, res.Media, question.ImageKey)
+	assert.Equal(t, []quiz.Choice{
+		{Content: "You must stay with your luggage at all times.", IsCorrect: true},
+		{Content: "Do not let someone else look after your luggage."},
+	}, question.Choices)
+	assert.Equal(t, []formats.Problem{{Question: "Where is Edinburgh?", Message: "skipped: hotspotInteraction is not supported"}}, res.Problems)
+
+	_, err = formats.ImportQTI(strings.NewReader("not a zip"), "broken.zip")
+	assert.Error(t, err)
+}
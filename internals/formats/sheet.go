@@ -0,0 +1,445 @@
+package formats
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// Spreadsheets hold one question per row, under a header row that names the
+// columns. The columns may come in any order and only question is required:
+//
+//	quiz         quiz of the question; the file name when empty
+//	type         question type; single-choice or multi-choice when empty,
+//	             as the correct flags tell
+//	question     question text in Markdown
+//	choices      answers separated by |, see below
+//	correct      1 or 0 for each choice, separated by |
+//	points       weight of the question; 1 when empty
+//	tags         tags separated by | or commas
+//	explanation  why the correct answer is correct
+//	feedback     feedback for each choice, separated by |
+//
+// What choices holds depends on the type: the choices of single-choice and
+// multi-choice questions, the accepted answers of short-text questions, the
+// answer of numeric questions as "9.81", "100 +- 0.5" or "100 +- 5%", the
+// items of ordering questions in the right order, "prompt -> answer" pairs
+// and "-> distractor" answers of matching questions, and the scale labels of
+// likert questions. Essay and cloze questions have no choices. A | within an
+// answer is written \|.
+var sheetColumns = []string{"quiz", "type", "question", "choices", "correct", "points", "tags", "explanation", "feedback"}
+
+// sheetRow is a row of a spreadsheet and the line, or row number, it is on.
+type sheetRow struct {
+	line  int
+	cells []string
+}
+
+// sheetTypes lists the question types a spreadsheet can hold.
+var sheetTypes = []string{quiz.TypeSingleChoice, quiz.TypeMultiChoice, quiz.TypeShortText, quiz.TypeNumeric, quiz.TypeOrdering, quiz.TypeMatching, quiz.TypeEssay, quiz.TypeCloze, quiz.TypeLikert}
+
+// importSheet reads the rows of a spreadsheet. Every row is checked and
+// those with mistakes are reported, all of them, and left out.
+func importSheet(rows []sheetRow, filename string) (*Result, error) {
+	for len(rows) > 0 && blankRow(rows[0].cells) {
+		rows = rows[1:]
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("empty spreadsheet")
+	}
+	res := &Result{}
+	header := rows[0]
+	columns := make(map[string]int)
+	for i, cell := range header.cells {
+		column := strings.ToLower(strings.TrimSpace(cell))
+		switch {
+		case column == "":
+		case !contains(sheetColumns, column):
+			res.problem(header.line, "", "unknown column %q is ignored", cell)
+		case columns[column] > 0:
+			return nil, fmt.Errorf("line %d: column %q appears twice", header.line, column)
+		default:
+			columns[column] = i + 1
+		}
+	}
+	if columns["question"] == 0 {
+		return nil, fmt.Errorf("line %d: the header row has no question column, name the columns %s", header.line, strings.Join(sheetColumns, ", "))
+	}
+
+	quizzes := make(map[string]*quiz.Quiz)
+	for _, row := range rows[1:] {
+		if blankRow(row.cells) {
+			continue
+		}
+		cell := func(column string) string {
+			if i := columns[column] - 1; i >= 0 && i < len(row.cells) {
+				return strings.TrimSpace(row.cells[i])
+			}
+			return ""
+		}
+		question, errs := sheetQuestion(cell)
+		if len(errs) > 0 {
+			res.problem(row.line, summary(question.Content), "skipped: %s", strings.Join(errs, "; "))
+			continue
+		}
+		name := cell("quiz")
+		if name == "" {
+			name = quizName(filename)
+		}
+		q := quizzes[name]
+		if q == nil {
+			q = quiz.NewQuiz()
+			q.Name = name
+			quizzes[name] = q
+			res.Quizzes = append(res.Quizzes, q)
+		}
+		res.add(q, question, row.line)
+	}
+	return res, nil
+}
+
+// sheetQuestion reads the cells of a row into a question and returns every
+// mistake found in them.
+func sheetQuestion(cell func(column string) string) (quiz.Question, []string) {
+	question := quiz.Question{Content: cell("question"), Explanation: cell("explanation")}
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+	if question.Content == "" {
+		fail("the question is empty")
+	}
+	if s := cell("points"); s != "" {
+		points, err := strconv.ParseFloat(s, 64)
+		if err != nil || points < 0 {
+			fail("points %q is not a positive number", s)
+		}
+		question.Points = points
+	}
+	question.Tags = sheetTags(cell("tags"))
+
+	choices := splitList(cell("choices"))
+	var flags []bool
+	for i, flag := range splitList(cell("correct")) {
+		correct, ok := parseFlag(flag)
+		if !ok {
+			fail("correct flag %d is %q, use 1 or 0", i+1, flag)
+		}
+		flags = append(flags, correct)
+	}
+	feedback := splitList(cell("feedback"))
+
+	question.Type = strings.ToLower(cell("type"))
+	if question.Type == "" {
+		if len(flags) == 0 {
+			fail("the type is empty and there are no correct flags to tell it")
+		}
+		question.Type = quiz.TypeMultiChoice
+		if count(flags) == 1 {
+			question.Type = quiz.TypeSingleChoice
+		}
+	}
+	if !contains(sheetTypes, question.Type) {
+		fail("unknown type %q, use one of %s", question.Type, strings.Join(sheetTypes, ", "))
+		return question, errs
+	}
+	choiceType := question.Type == quiz.TypeSingleChoice || question.Type == quiz.TypeMultiChoice
+	if !choiceType && len(flags) > 0 {
+		fail("%s questions have no correct flags", question.Type)
+	}
+	if !choiceType && len(feedback) > 0 {
+		fail("%s questions have no choice feedback", question.Type)
+	}
+	for i, choice := range choices {
+		if choice == "" {
+			fail("choice %d is empty", i+1)
+		}
+	}
+
+	switch question.Type {
+	case quiz.TypeSingleChoice, quiz.TypeMultiChoice:
+		if len(choices) == 0 {
+			fail("there are no choices")
+		}
+		if len(flags) != len(choices) {
+			fail("%d correct flags for %d choices", len(flags), len(choices))
+		}
+		if question.Type == quiz.TypeSingleChoice && count(flags) > 1 {
+			fail("%d correct choices for a single-choice question", count(flags))
+		}
+		if len(feedback) > len(choices) {
+			fail("%d feedbacks for %d choices", len(feedback), len(choices))
+		}
+		for i, content := range choices {
+			choice := quiz.Choice{Content: content}
+			if i < len(flags) {
+				choice.IsCorrect = flags[i]
+			}
+			if i < len(feedback) {
+				choice.Feedback = feedback[i]
+			}
+			question.Choices = append(question.Choices, choice)
+		}
+	case quiz.TypeShortText:
+		if len(choices) == 0 {
+			fail("there are no accepted answers")
+		}
+		question.Text = &quiz.TextSpec{Accepted: choices}
+	case quiz.TypeNumeric:
+		if len(choices) != 1 {
+			fail("numeric questions have one answer, not %d", len(choices))
+			break
+		}
+		spec, err := parseNumeric(choices[0])
+		if err != nil {
+			fail("%v", err)
+		}
+		question.Numeric = spec
+	case quiz.TypeOrdering:
+		question.Ordering = &quiz.OrderingSpec{Items: choices}
+	case quiz.TypeMatching:
+		question.Matching = &quiz.MatchingSpec{}
+		for _, choice := range choices {
+			prompt, answer, found := strings.Cut(choice, "->")
+			if !found {
+				fail("%q is not a prompt -> answer pair", choice)
+				continue
+			}
+			prompt, answer = strings.TrimSpace(prompt), strings.TrimSpace(answer)
+			if prompt == "" {
+				question.Matching.Distractors = append(question.Matching.Distractors, answer)
+			} else {
+				question.Matching.Pairs = append(question.Matching.Pairs, quiz.MatchPair{Prompt: prompt, Answer: answer})
+			}
+		}
+	case quiz.TypeLikert:
+		if len(choices) > 0 {
+			question.Likert = &quiz.LikertSpec{Labels: choices}
+		}
+	case quiz.TypeEssay, quiz.TypeCloze:
+		if len(choices) > 0 {
+			fail("%s questions have no choices", question.Type)
+		}
+	}
+	return question, errs
+}
+
+// exportSheet lays a quiz out in the rows of a spreadsheet, header first.
+// The question details that the columns cannot hold are returned as
+// problems, on the row of the question.
+func exportSheet(q *quiz.Quiz) ([][]string, []Problem) {
+	rows := [][]string{sheetColumns}
+	var problems []Problem
+	for i := range q.Questions {
+		question := &q.Questions[i]
+		line := len(rows) + 1
+		problem := func(format string, args ...interface{}) {
+			problems = append(problems, Problem{Line: line, Question: summary(question.Prompt()), Message: fmt.Sprintf(format, args...)})
+		}
+		if !contains(sheetTypes, question.Type) {
+			problems = append(problems, Problem{Question: summary(question.Prompt()), Message: fmt.Sprintf("skipped: %s questions have no spreadsheet layout", question.Type)})
+			continue
+		}
+
+		var choices, flags, feedback []string
+		switch question.Type {
+		case quiz.TypeSingleChoice, quiz.TypeMultiChoice:
+			for _, choice := range question.Choices {
+				choices = append(choices, choice.Content)
+				flags = append(flags, map[bool]string{true: "1", false: "0"}[choice.IsCorrect])
+				feedback = append(feedback, choice.Feedback)
+			}
+			if strings.Join(feedback, "") == "" {
+				feedback = nil
+			}
+		case quiz.TypeShortText:
+			if spec := question.Text; spec != nil {
+				choices = spec.Accepted
+				if len(spec.Patterns) > 0 {
+					problem("answer patterns left out")
+				}
+				if spec.CaseSensitive {
+					problem("case sensitivity left out")
+				}
+			}
+		case quiz.TypeNumeric:
+			if spec := question.Numeric; spec != nil {
+				choices = []string{formatNumeric(spec)}
+				if spec.Tolerance > 0 && spec.RelativeTolerance > 0 {
+					problem("relative tolerance left out: only one tolerance fits")
+				}
+				if len(spec.Units) > 0 {
+					problem("units left out")
+				}
+			}
+		case quiz.TypeOrdering:
+			if spec := question.Ordering; spec != nil {
+				choices = spec.Items
+				if spec.AllOrNothing {
+					problem("all-or-nothing scoring left out")
+				}
+			}
+		case quiz.TypeMatching:
+			if spec := question.Matching; spec != nil {
+				for _, pair := range spec.Pairs {
+					choices = append(choices, pair.Prompt+" -> "+pair.Answer)
+				}
+				for _, distractor := range spec.Distractors {
+					choices = append(choices, "-> "+distractor)
+				}
+				if spec.AllOrNothing {
+					problem("all-or-nothing scoring left out")
+				}
+			}
+		case quiz.TypeLikert:
+			if question.Likert != nil {
+				choices = question.Likert.Labels
+			}
+		case quiz.TypeEssay:
+			if question.Essay != nil && len(question.Essay.Rubric) > 0 {
+				problem("rubric left out, its total is the points")
+			}
+		}
+
+		points := ""
+		if question.Points > 0 || question.Essay != nil && len(question.Essay.Rubric) > 0 {
+			points = formatFloat(question.MaxPoints())
+		}
+		if question.Penalty > 0 || question.PenaltyFraction > 0 {
+			problem("penalty left out")
+		}
+		if question.ImageKey != "" || question.MediaKey != "" {
+			problem("media left out")
+		}
+		for _, choice := range question.Choices {
+			if choice.ThumbKey != "" {
+				problem("choice thumbnails left out")
+				break
+			}
+		}
+		rows = append(rows, []string{
+			q.Name,
+			question.Type,
+			question.Content,
+			joinList(choices),
+			joinList(flags),
+			points,
+			strings.Join(question.Tags, ", "),
+			question.Explanation,
+			joinList(feedback),
+		})
+	}
+	return rows, problems
+}
+
+// splitList splits a cell at the | that are not escaped as \|.
+func splitList(cell string) []string {
+	if strings.TrimSpace(cell) == "" {
+		return nil
+	}
+	var items []string
+	var b strings.Builder
+	for i := 0; i < len(cell); i++ {
+		switch {
+		case cell[i] == '\\' && i+1 < len(cell) && (cell[i+1] == '|' || cell[i+1] == '\\'):
+			i++
+			b.WriteByte(cell[i])
+		case cell[i] == '|':
+			items = append(items, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(cell[i])
+		}
+	}
+	return append(items, strings.TrimSpace(b.String()))
+}
+
+var listEscaper = strings.NewReplacer(`\`, `\\`, "|", `\|`)
+
+// joinList is the reverse of splitList.
+func joinList(items []string) string {
+	escaped := make([]string, len(items))
+	for i, item := range items {
+		escaped[i] = listEscaper.Replace(item)
+	}
+	return strings.Join(escaped, " | ")
+}
+
+func sheetTags(cell string) quiz.StringList {
+	var tags quiz.StringList
+	for _, tag := range strings.FieldsFunc(cell, func(r rune) bool { return r == ',' || r == '|' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseFlag reads a correct flag: 1, true, yes or x for a correct choice,
+// and 0, false, no or nothing for a wrong one.
+func parseFlag(s string) (correct, ok bool) {
+	switch strings.ToLower(s) {
+	case "1", "true", "yes", "x":
+		return true, true
+	case "0", "false", "no", "":
+		return false, true
+	}
+	return false, false
+}
+
+func count(flags []bool) int {
+	n := 0
+	for _, flag := range flags {
+		if flag {
+			n++
+		}
+	}
+	return n
+}
+
+// parseNumeric reads a numeric answer such as "9.81", "100 +- 0.5" or
+// "100 ± 5%".
+func parseNumeric(s string) (*quiz.NumericSpec, error) {
+	answer, tolerance, found := strings.Cut(strings.ReplaceAll(s, "±", "+-"), "+-")
+	spec := &quiz.NumericSpec{}
+	var err error
+	if spec.Answer, err = strconv.ParseFloat(strings.TrimSpace(answer), 64); err != nil {
+		return spec, fmt.Errorf("answer %q is not a number", s)
+	}
+	if !found {
+		return spec, nil
+	}
+	tolerance = strings.TrimSpace(tolerance)
+	percent, relative := strings.CutSuffix(tolerance, "%")
+	value, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+	if err != nil || value < 0 {
+		return spec, fmt.Errorf("tolerance %q is not a positive number", tolerance)
+	}
+	if relative {
+		spec.RelativeTolerance = value / 100
+	} else {
+		spec.Tolerance = value
+	}
+	return spec, nil
+}
+
+func formatNumeric(spec *quiz.NumericSpec) string {
+	switch {
+	case spec.Tolerance > 0:
+		return formatFloat(spec.Answer) + " +- " + formatFloat(spec.Tolerance)
+	case spec.RelativeTolerance > 0:
+		return formatFloat(spec.Answer) + " +- " + strconv.FormatFloat(spec.RelativeTolerance*100, 'g', 12, 64) + "%"
+	}
+	return formatFloat(spec.Answer)
+}
+
+func blankRow(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
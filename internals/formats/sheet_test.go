@@ -0,0 +1,150 @@
+package formats_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/formats"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const questionsCSV = `Question;Type;Choices;Correct;Points;Tags;Notes
+What is the capital of France?;;Paris | London | Berlin;1|0|0;;geography, capitals;
+"Which are prime?
+Pick all.";multi-choice;2|3|4;x|x|;2;;
+;single-choice;a|b;1|0;;;
+Pick one;single-choice;a|b|c;1|maybe;;;
+Spell colour;short-text;colour | color;;;;
+
+Speed of light in km/s?;numeric;300000 +- 1%;;;physics;
+Speed of sound?;numeric;343|340;;lots;;
+Match;matching;Fe -> Iron | Cu -> Copper | -> Tin;;;;
+Order;ordering;one|two|three;;;;
+Order again;sorting;one|two;;;;
+Fill {{in;cloze;;;;;
+`
+
+func TestImportCSV(t *testing.T) {
+	res, err := formats.ImportCSV(strings.NewReader(questionsCSV), "chemistry.csv")
+	require.NoError(t, err)
+
+	var messages []string
+	for _, p := range res.Problems {
+		messages = append(messages, p.String())
+	}
+	assert.Equal(t, []string{
+		`line 1: unknown column "Notes" is ignored`,
+		`line 5: skipped: the question is empty`,
+		`line 6, "Pick one": skipped: correct flag 2 is "maybe", use 1 or 0; 2 correct flags for 3 choices`,
+		`line 10, "Speed of sound?": skipped: points "lots" is not a positive number; numeric questions have one answer, not 2`,
+		`line 13, "Order again": skipped: unknown type "sorting", use one of single-choice, multi-choice, short-text, numeric, ordering, matching, essay, cloze, likert`,
+	}, messages)
+
+	require.Len(t, res.Quizzes, 1)
+	q := res.Quizzes[0]
+	assert.Equal(t, "chemistry", q.Name)
+	require.Len(t, q.Questions, 7)
+	assert.Equal(t, quiz.Question{
+		Type:    quiz.TypeSingleChoice,
+		Content: "What is the capital of France?",
+		Choices: []quiz.Choice{{Content: "Paris", IsCorrect: true}, {Content: "London"}, {Content: "Berlin"}},
+		Tags:    quiz.StringList{"geography", "capitals"},
+	}, q.Questions[0])
+	assert.Equal(t, quiz.TypeMultiChoice, q.Questions[1].Type)
+	assert.Equal(t, "Which are prime?\nPick all.", q.Questions[1].Content)
+	assert.Equal(t, 2.0, q.Questions[1].Points)
+	assert.Equal(t, []quiz.Choice{{Content: "2", IsCorrect: true}, {Content: "3", IsCorrect: true}, {Content: "4"}}, q.Questions[1].Choices)
+	assert.Equal(t, &quiz.TextSpec{Accepted: []string{"colour", "color"}}, q.Questions[2].Text)
+	assert.Equal(t, &quiz.NumericSpec{Answer: 300000, RelativeTolerance: 0.01}, q.Questions[3].Numeric)
+	assert.Equal(t, &quiz.MatchingSpec{
+		Pairs:       []quiz.MatchPair{{Prompt: "Fe", Answer: "Iron"}, {Prompt: "Cu", Answer: "Copper"}},
+		Distractors: []string{"Tin"},
+	}, q.Questions[4].Matching)
+	assert.Equal(t, &quiz.OrderingSpec{Items: []string{"one", "two", "three"}}, q.Questions[5].Ordering)
+
+	// Check reports the questions the store rejects on their rows too.
+	res.Check((&quiz.SQLiteStore{}).ValidateQuestion)
+	require.Len(t, res.Quizzes[0].Questions, 6)
+	assert.Equal(t, 14, res.Problems[len(res.Problems)-1].Line)
+}
+
+func TestImportCSV_Header(t *testing.T) {
+	_, err := formats.ImportCSV(strings.NewReader("\n\ntype,choices\nsingle-choice,a|b\n"), "empty.csv")
+	assert.EqualError(t, err, "line 3: the header row has no question column, name the columns quiz, type, question, choices, correct, points, tags, explanation, feedback")
+}
+
+// sheetQuiz has a question of every type that spreadsheets can carry.
+func sheetQuiz() *quiz.Quiz {
+	return &quiz.Quiz{
+		Name: "Everything",
+		Questions: []quiz.Question{
+			{Type: quiz.TypeSingleChoice, Content: "Pick | one", Explanation: "Because.", Tags: quiz.StringList{"basics"},
+				Choices: []quiz.Choice{{Content: `a \ b`, IsCorrect: true, Feedback: "Yes"}, {Content: "c|d", Feedback: "No"}}},
+			{Type: quiz.TypeMultiChoice, Content: "Pick some\n\n- really", Points: 3,
+				Choices: []quiz.Choice{{Content: "x", IsCorrect: true}, {Content: "y", IsCorrect: true}, {Content: "z"}}},
+			{Type: quiz.TypeShortText, Content: "Say hi", Text: &quiz.TextSpec{Accepted: []string{"hi", "hello"}}},
+			{Type: quiz.TypeNumeric, Content: "g?", Numeric: &quiz.NumericSpec{Answer: 9.81, Tolerance: 0.05}},
+			{Type: quiz.TypeNumeric, Content: "Pi?", Numeric: &quiz.NumericSpec{Answer: 3.14, RelativeTolerance: 0.07}},
+			{Type: quiz.TypeOrdering, Content: "Sort", Ordering: &quiz.OrderingSpec{Items: []string{"1", "2", "3"}}},
+			{Type: quiz.TypeMatching, Content: "Match", Matching: &quiz.MatchingSpec{
+				Pairs: []quiz.MatchPair{{Prompt: "H", Answer: "Hydrogen"}}, Distractors: []string{"Helium"}}},
+			{Type: quiz.TypeEssay, Content: "Discuss", Points: 5, Tags: quiz.StringList{"long", "graded"}},
+			{Type: quiz.TypeCloze, Content: "Paris is in {{France}}."},
+			{Type: quiz.TypeLikert, Content: "Enjoyed it?", Likert: &quiz.LikertSpec{Labels: []string{"No", "Yes"}}},
+		},
+	}
+}
+
+func TestSheet_RoundTrip(t *testing.T) {
+	formatsByName := map[string]struct {
+		export func(*bytes.Buffer, *quiz.Quiz) ([]formats.Problem, error)
+		read   formats.Importer
+	}{
+		"csv":  {func(buf *bytes.Buffer, q *quiz.Quiz) ([]formats.Problem, error) { return formats.ExportCSV(buf, q) }, formats.ImportCSV},
+		"xlsx": {func(buf *bytes.Buffer, q *quiz.Quiz) ([]formats.Problem, error) { return formats.ExportXLSX(buf, q) }, formats.ImportXLSX},
+	}
+	for name, format := range formatsByName {
+		t.Run(name, func(t *testing.T) {
+			q := sheetQuiz()
+			var buf bytes.Buffer
+			problems, err := format.export(&buf, q)
+			require.NoError(t, err)
+			assert.Empty(t, problems)
+
+			res, err := format.read(&buf, "ignored."+name)
+			require.NoError(t, err)
+			assert.Empty(t, res.Problems)
+			require.Len(t, res.Quizzes, 1)
+			assert.Equal(t, q.Name, res.Quizzes[0].Name)
+			assert.Equal(t, q.Questions, res.Quizzes[0].Questions)
+		})
+	}
+}
+
+func TestExportCSV_Problems(t *testing.T) {
+	q := &quiz.Quiz{Name: "Lossy", Questions: []quiz.Question{
+		{Type: quiz.TypeCode, Content: "Write Add", Code: &quiz.CodeSpec{Tests: "package add"}},
+		{Type: quiz.TypeShortText, Content: "Name it", Penalty: 1, Text: &quiz.TextSpec{Accepted: []string{"it"}, Patterns: []string{"i.*"}, CaseSensitive: true}},
+		{Type: quiz.TypeEssay, Content: "Discuss", Essay: &quiz.EssaySpec{Rubric: []quiz.RubricCriterion{{Name: "Depth", Points: 4}}}},
+	}}
+	var buf bytes.Buffer
+	problems, err := formats.ExportCSV(&buf, q)
+	require.NoError(t, err)
+	var messages []string
+	for _, p := range problems {
+		messages = append(messages, p.String())
+	}
+	assert.Equal(t, []string{
+		`"Write Add": skipped: code questions have no spreadsheet layout`,
+		`line 2, "Name it": answer patterns left out`,
+		`line 2, "Name it": case sensitivity left out`,
+		`line 2, "Name it": penalty left out`,
+		`line 3, "Discuss": rubric left out, its total is the points`,
+	}, messages)
+	assert.Equal(t, "\ufeffquiz,type,question,choices,correct,points,tags,explanation,feedback\n"+
+		"Lossy,short-text,Name it,it,,,,,\n"+
+		"Lossy,essay,Discuss,,,4,,,\n", buf.String())
+}
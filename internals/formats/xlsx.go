@@ -0,0 +1,262 @@
+package formats
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// ImportXLSX reads questions from the first worksheet of an Excel workbook,
+// laid out as described at sheetColumns.
+func ImportXLSX(r io.Reader, name string) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := readXLSX(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading workbook: %w", err)
+	}
+	return importSheet(rows, name)
+}
+
+// ExportXLSX writes the questions of the quiz as an Excel workbook, laid out
+// as described at sheetColumns. The question details that the columns
+// cannot hold are left out and returned as problems.
+func ExportXLSX(w io.Writer, q *quiz.Quiz) ([]Problem, error) {
+	rows, problems := exportSheet(q)
+	return problems, writeXLSX(w, rows)
+}
+
+// xlsxCell is a cell of a worksheet. Strings are stored in the shared
+// string table or inline, numbers and booleans in V.
+type xlsxCell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	V      string   `xml:"v"`
+	Inline xlsxText `xml:"is"`
+}
+
+// xlsxText is rich text: plain text in T, or runs of formatted text.
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	s := t.T
+	for _, run := range t.Runs {
+		s += run.T
+	}
+	return s
+}
+
+type xlsxRels struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// readXLSX reads the rows of the first worksheet of a workbook.
+func readXLSX(data []byte) ([]sheetRow, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an Excel workbook: %w", err)
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	decode := func(name string, v interface{}) error {
+		f := files[name]
+		if f == nil {
+			return fmt.Errorf("%s is missing", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		if err := xml.NewDecoder(rc).Decode(v); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decode("xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return nil, fmt.Errorf("the workbook has no worksheet")
+	}
+	var rels xlsxRels
+	if err := decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	sheet := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == workbook.Sheets[0].RelID {
+			sheet = rel.Target
+		}
+	}
+	if strings.HasPrefix(sheet, "/") {
+		sheet = strings.TrimPrefix(sheet, "/")
+	} else {
+		sheet = path.Join("xl", sheet)
+	}
+
+	var shared struct {
+		Items []xlsxText `xml:"si"`
+	}
+	if files["xl/sharedStrings.xml"] != nil {
+		if err := decode("xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+	var worksheet struct {
+		Rows []struct {
+			Number int        `xml:"r,attr"`
+			Cells  []xlsxCell `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decode(sheet, &worksheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([]sheetRow, 0, len(worksheet.Rows))
+	line := 0
+	for _, r := range worksheet.Rows {
+		line++
+		if r.Number > 0 {
+			line = r.Number
+		}
+		row := sheetRow{line: line}
+		for _, c := range r.Cells {
+			column := len(row.cells)
+			if c.Ref != "" {
+				column = xlsxColumn(c.Ref)
+			}
+			for len(row.cells) < column {
+				row.cells = append(row.cells, "")
+			}
+			value := c.V
+			switch c.Type {
+			case "s":
+				i, err := strconv.Atoi(c.V)
+				if err != nil || i < 0 || i >= len(shared.Items) {
+					return nil, fmt.Errorf("cell %s refers to a missing string", c.Ref)
+				}
+				value = shared.Items[i].String()
+			case "inlineStr":
+				value = c.Inline.String()
+			}
+			row.cells = append(row.cells, value)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// xlsxColumn returns the zero-based column of a cell reference such as "AB7".
+func xlsxColumn(ref string) int {
+	column := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A') + 1
+	}
+	return column - 1
+}
+
+// xlsxRef returns the reference of the cell in the zero-based column and the
+// row, such as "AB7".
+func xlsxRef(column, row int) string {
+	name := ""
+	for column++; column > 0; column = (column - 1) / 26 {
+		name = string(rune('A'+(column-1)%26)) + name
+	}
+	return name + strconv.Itoa(row)
+}
+
+const (
+	xlsxMain     = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	xlsxPackage  = "http://schemas.openxmlformats.org/package/2006/relationships"
+	xlsxDocument = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+)
+
+// writeXLSX writes rows as the only worksheet of a workbook, with text in
+// inline strings and the numbers of the points column as numbers.
+func writeXLSX(w io.Writer, rows [][]string) error {
+	var sheet strings.Builder
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="` + xlsxMain + `"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, value := range row {
+			if value == "" {
+				continue
+			}
+			ref := xlsxRef(j, i+1)
+			if _, err := strconv.ParseFloat(value, 64); err == nil && i > 0 && rows[0][j] == "points" {
+				fmt.Fprintf(&sheet, `<c r="%s"><v>%s</v></c>`, ref, value)
+				continue
+			}
+			fmt.Fprintf(&sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			xml.EscapeText(&sheet, []byte(value))
+			sheet.WriteString(`</t></is></c>`)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	files := []struct{ name, content string }{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="` + xlsxPackage + `">` +
+			`<Relationship Id="rId1" Type="` + xlsxDocument + `/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="` + xlsxMain + `" xmlns:r="` + xlsxDocument + `">` +
+			`<sheets><sheet name="Questions" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="` + xlsxPackage + `">` +
+			`<Relationship Id="rId1" Type="` + xlsxDocument + `/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		content := f.content
+		if !strings.HasPrefix(content, "<?xml") {
+			content = xml.Header + content
+		}
+		if _, err := io.WriteString(fw, content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,657 @@
+package markup
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MathML converts a LaTeX math expression into a MathML element that
+// browsers render natively. It supports the commonly used subset of LaTeX:
+// scripts, fractions, roots, Greek letters and symbols, functions, accents,
+// fonts, stretchy delimiters and matrix-like environments.
+func MathML(tex string, display bool) (string, error) {
+	p := &texParser{toks: tokenize(tex), display: display}
+	items, err := p.parseRow(nil)
+	if err != nil {
+		return "", err
+	}
+	if p.pos < len(p.toks) {
+		return "", fmt.Errorf("unexpected %q", p.toks[p.pos].text)
+	}
+
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		b.WriteString(` display="block"`)
+	}
+	b.WriteString("><semantics>")
+	b.WriteString(mrow(items))
+	b.WriteString(`<annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(tex))
+	b.WriteString("</annotation></semantics></math>")
+	return b.String(), nil
+}
+
+type tokenKind int
+
+const (
+	tokLetter tokenKind = iota
+	tokNumber
+	tokSymbol
+	tokCommand
+	tokText // The raw argument of a text command
+	tokOpen
+	tokClose
+	tokSup
+	tokSub
+	tokAmp
+)
+
+type texToken struct {
+	kind tokenKind
+	text string
+}
+
+// textCommands take their argument as raw text rather than math.
+var textCommands = map[string]bool{
+	"text": true, "textrm": true, "textit": true, "textbf": true, "mathrm": true, "operatorname": true,
+	"mathbf": true, "mathit": true, "mathbb": true, "mathcal": true, "mathsf": true, "mathtt": true, "boldsymbol": true,
+	"begin": true, "end": true,
+}
+
+func tokenize(tex string) []texToken {
+	var toks []texToken
+	for i := 0; i < len(tex); {
+		r, size := utf8.DecodeRuneInString(tex[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '\\':
+			j := i + 1
+			for j < len(tex) && isASCIILetter(tex[j]) {
+				j++
+			}
+			if j == i+1 && j < len(tex) {
+				_, size := utf8.DecodeRuneInString(tex[j:])
+				j += size
+			}
+			name := tex[i+1 : j]
+			toks = append(toks, texToken{tokCommand, name})
+			i = j
+			if textCommands[name] {
+				k := i
+				for k < len(tex) && tex[k] == ' ' {
+					k++
+				}
+				if k < len(tex) && tex[k] == '{' {
+					if end := matchingBrace(tex, k); end > 0 {
+						toks = append(toks, texToken{tokText, tex[k+1 : end]})
+						i = end + 1
+					}
+				}
+			}
+		case r >= '0' && r <= '9' || r == '.' && i+1 < len(tex) && tex[i+1] >= '0' && tex[i+1] <= '9':
+			j := i + 1
+			for j < len(tex) && (tex[j] >= '0' && tex[j] <= '9' || tex[j] == '.' && j+1 < len(tex) && tex[j+1] >= '0' && tex[j+1] <= '9') {
+				j++
+			}
+			toks = append(toks, texToken{tokNumber, tex[i:j]})
+			i = j
+		case unicode.IsLetter(r):
+			toks = append(toks, texToken{tokLetter, string(r)})
+			i += size
+		default:
+			kind := tokSymbol
+			switch r {
+			case '{':
+				kind = tokOpen
+			case '}':
+				kind = tokClose
+			case '^':
+				kind = tokSup
+			case '_':
+				kind = tokSub
+			case '&':
+				kind = tokAmp
+			}
+			toks = append(toks, texToken{kind, string(r)})
+			i += size
+		}
+	}
+	return toks
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// matchingBrace returns the index of the brace closing the one at open, or
+// -1 when it is never closed.
+func matchingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+type texParser struct {
+	toks    []texToken
+	pos     int
+	display bool
+}
+
+func (p *texParser) peek() *texToken {
+	if p.pos >= len(p.toks) {
+		return nil
+	}
+	return &p.toks[p.pos]
+}
+
+func (p *texParser) next() *texToken {
+	t := p.peek()
+	if t != nil {
+		p.pos++
+	}
+	return t
+}
+
+func (p *texParser) isCommand(name string) bool {
+	t := p.peek()
+	return t != nil && t.kind == tokCommand && t.text == name
+}
+
+// parseRow parses items until the end of input or a token stop accepts.
+func (p *texParser) parseRow(stop func(*texToken) bool) ([]string, error) {
+	var items []string
+	for {
+		t := p.peek()
+		if t == nil || t.kind == tokClose || (stop != nil && stop(t)) {
+			return items, nil
+		}
+		item, err := p.parseScripted()
+		if err != nil {
+			return nil, err
+		}
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+}
+
+// parseScripted parses an atom with its superscript and subscript.
+func (p *texParser) parseScripted() (string, error) {
+	base, limits := "<mrow></mrow>", false
+	if t := p.peek(); t.kind != tokSup && t.kind != tokSub {
+		var err error
+		if base, limits, err = p.parseAtom(); err != nil {
+			return "", err
+		}
+	}
+	for p.isCommand("limits") || p.isCommand("nolimits") {
+		limits = p.next().text == "limits"
+	}
+
+	var sup, sub string
+	for {
+		t := p.peek()
+		if t == nil || t.kind != tokSup && t.kind != tokSub {
+			break
+		}
+		p.next()
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", err
+		}
+		if t.kind == tokSup {
+			if sup != "" {
+				return "", fmt.Errorf("double superscript")
+			}
+			sup = arg
+		} else {
+			if sub != "" {
+				return "", fmt.Errorf("double subscript")
+			}
+			sub = arg
+		}
+	}
+	under, over, both := "msub", "msup", "msubsup"
+	if limits && p.display {
+		under, over, both = "munder", "mover", "munderover"
+	}
+	switch {
+	case sup != "" && sub != "":
+		return "<" + both + ">" + base + sub + sup + "</" + both + ">", nil
+	case sub != "":
+		return "<" + under + ">" + base + sub + "</" + under + ">", nil
+	case sup != "":
+		return "<" + over + ">" + base + sup + "</" + over + ">", nil
+	}
+	return base, nil
+}
+
+// parseArg parses a command or script argument: a group or a single atom.
+func (p *texParser) parseArg() (string, error) {
+	t := p.peek()
+	if t == nil {
+		return "", fmt.Errorf("missing argument")
+	}
+	if t.kind == tokNumber && len(t.text) > 1 {
+		// Like TeX, an ungrouped argument is a single digit.
+		digit := t.text[:1]
+		t.text = t.text[1:]
+		return "<mn>" + digit + "</mn>", nil
+	}
+	if t.kind == tokClose || t.kind == tokSup || t.kind == tokSub {
+		return "", fmt.Errorf("missing argument")
+	}
+	arg, _, err := p.parseAtom()
+	return arg, err
+}
+
+func (p *texParser) parseGroup() (string, error) {
+	items, err := p.parseRow(nil)
+	if err != nil {
+		return "", err
+	}
+	if t := p.next(); t == nil || t.kind != tokClose {
+		return "", fmt.Errorf("missing }")
+	}
+	return mrow(items), nil
+}
+
+// parseAtom parses a single item. limits reports whether scripts go above
+// and below the item in display mode.
+func (p *texParser) parseAtom() (item string, limits bool, err error) {
+	t := p.next()
+	switch t.kind {
+	case tokLetter:
+		return "<mi>" + html.EscapeString(t.text) + "</mi>", false, nil
+	case tokNumber:
+		return "<mn>" + t.text + "</mn>", false, nil
+	case tokOpen:
+		item, err = p.parseGroup()
+		return item, false, err
+	case tokSymbol:
+		return symbolOperator(t.text), false, nil
+	case tokCommand:
+		return p.parseCommand(t.text)
+	case tokClose:
+		return "", false, fmt.Errorf("unexpected }")
+	case tokAmp:
+		return "", false, fmt.Errorf("unexpected & outside of an environment")
+	case tokText:
+		return "<mtext>" + html.EscapeString(t.text) + "</mtext>", false, nil
+	}
+	return "", false, fmt.Errorf("unexpected %q", t.text)
+}
+
+func symbolOperator(s string) string {
+	switch s {
+	case "-":
+		s = "−"
+	case "*":
+		s = "∗"
+	case "'":
+		s = "′"
+	}
+	attrs := ""
+	switch s {
+	case "(", ")", "[", "]", "|":
+		attrs = ` stretchy="false"`
+	}
+	return "<mo" + attrs + ">" + html.EscapeString(s) + "</mo>"
+}
+
+func (p *texParser) parseCommand(name string) (string, bool, error) {
+	if s, ok := texIdentifiers[name]; ok {
+		return "<mi>" + s + "</mi>", false, nil
+	}
+	if s, ok := texOperators[name]; ok {
+		return "<mo>" + html.EscapeString(s) + "</mo>", false, nil
+	}
+	if s, ok := texLargeOperators[name]; ok {
+		return `<mo largeop="true" movablelimits="true">` + s + "</mo>", name != "int" && name != "iint" && name != "oint", nil
+	}
+	if texFunctions[name] {
+		return `<mi>` + name + `</mi>`, false, nil
+	}
+	if texLimitFunctions[name] {
+		return `<mi>` + name + `</mi>`, true, nil
+	}
+	if width, ok := texSpaces[name]; ok {
+		return `<mspace width="` + width + `"></mspace>`, false, nil
+	}
+	if accent, ok := texAccents[name]; ok {
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		if name == "underline" {
+			return `<munder accentunder="true">` + arg + `<mo>` + accent + `</mo></munder>`, false, nil
+		}
+		stretchy := "false"
+		if strings.HasPrefix(name, "wide") || strings.HasPrefix(name, "over") {
+			stretchy = "true"
+		}
+		return `<mover accent="true">` + arg + `<mo stretchy="` + stretchy + `">` + accent + `</mo></mover>`, false, nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "binom":
+		num, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		den, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		if name == "binom" {
+			return `<mrow><mo>(</mo><mfrac linethickness="0">` + num + den + `</mfrac><mo>)</mo></mrow>`, false, nil
+		}
+		return "<mfrac>" + num + den + "</mfrac>", false, nil
+	case "sqrt":
+		var index string
+		if t := p.peek(); t != nil && t.kind == tokSymbol && t.text == "[" {
+			p.next()
+			items, err := p.parseRow(func(t *texToken) bool { return t.kind == tokSymbol && t.text == "]" })
+			if err != nil {
+				return "", false, err
+			}
+			if t := p.next(); t == nil || t.text != "]" {
+				return "", false, fmt.Errorf("missing ] in \\sqrt")
+			}
+			index = mrow(items)
+		}
+		arg, err := p.parseArg()
+		if err != nil {
+			return "", false, err
+		}
+		if index != "" {
+			return "<mroot>" + arg + index + "</mroot>", false, nil
+		}
+		return "<msqrt>" + arg + "</msqrt>", false, nil
+	case "text", "textrm", "textit", "textbf":
+		text, err := p.textArg(name)
+		return "<mtext>" + html.EscapeString(text) + "</mtext>", false, err
+	case "mathrm", "operatorname":
+		text, err := p.textArg(name)
+		return `<mi mathvariant="normal">` + html.EscapeString(strings.TrimSpace(text)) + "</mi>", name == "operatorname", err
+	case "mathbf", "mathit", "mathbb", "mathcal", "mathsf", "mathtt", "boldsymbol":
+		text, err := p.textArg(name)
+		if err != nil {
+			return "", false, err
+		}
+		var items []string
+		for _, r := range text {
+			if !unicode.IsSpace(r) {
+				items = append(items, "<mi>"+html.EscapeString(string(mathAlphanumeric(name, r)))+"</mi>")
+			}
+		}
+		return mrow(items), false, nil
+	case "left":
+		return p.parseFenced()
+	case "not":
+		t := p.peek()
+		if t == nil || t.kind != tokCommand && t.kind != tokSymbol {
+			return "", false, fmt.Errorf("\\not needs a relation")
+		}
+		p.next()
+		rel := t.text
+		if s, ok := texOperators[rel]; ok {
+			rel = s
+		}
+		return "<mo>" + html.EscapeString(rel) + "̸</mo>", false, nil
+	case "begin":
+		return p.parseEnvironment()
+	case "displaystyle", "textstyle", "big", "Big", "bigg", "Bigg",
+		"bigl", "bigr", "Bigl", "Bigr", "biggl", "biggr", "Biggl", "Biggr":
+		return "", false, nil
+	case "right", "end", "limits", "nolimits":
+		return "", false, fmt.Errorf("unexpected \\%s", name)
+	case "\\":
+		return "", false, fmt.Errorf("unexpected line break outside of an environment")
+	}
+	return "", false, fmt.Errorf("unknown command \\%s", name)
+}
+
+func (p *texParser) textArg(name string) (string, error) {
+	t := p.next()
+	if t == nil || t.kind != tokText {
+		return "", fmt.Errorf("\\%s needs a {} argument", name)
+	}
+	return t.text, nil
+}
+
+// parseFenced parses \left<delim> ... \right<delim>.
+func (p *texParser) parseFenced() (string, bool, error) {
+	open, err := p.delimiter("left")
+	if err != nil {
+		return "", false, err
+	}
+	items, err := p.parseRow(func(t *texToken) bool { return t.kind == tokCommand && t.text == "right" })
+	if err != nil {
+		return "", false, err
+	}
+	if !p.isCommand("right") {
+		return "", false, fmt.Errorf("\\left without \\right")
+	}
+	p.next()
+	closing, err := p.delimiter("right")
+	if err != nil {
+		return "", false, err
+	}
+	return "<mrow>" + open + mrow(items) + closing + "</mrow>", false, nil
+}
+
+func (p *texParser) delimiter(name string) (string, error) {
+	t := p.next()
+	if t == nil || t.kind != tokSymbol && t.kind != tokCommand {
+		return "", fmt.Errorf("missing delimiter after \\%s", name)
+	}
+	s := t.text
+	if t.kind == tokCommand {
+		var ok bool
+		if s, ok = texOperators[t.text]; !ok {
+			return "", fmt.Errorf("invalid delimiter \\%s", t.text)
+		}
+	}
+	if s == "." {
+		return "", nil
+	}
+	return `<mo fence="true" stretchy="true">` + html.EscapeString(s) + "</mo>", nil
+}
+
+// environments maps an environment to its opening and closing fences.
+var environments = map[string][2]string{
+	"matrix":   {"", ""},
+	"pmatrix":  {"(", ")"},
+	"bmatrix":  {"[", "]"},
+	"Bmatrix":  {"{", "}"},
+	"vmatrix":  {"|", "|"},
+	"Vmatrix":  {"‖", "‖"},
+	"cases":    {"{", ""},
+	"aligned":  {"", ""},
+	"align":    {"", ""},
+	"align*":   {"", ""},
+	"gathered": {"", ""},
+	"array":    {"", ""},
+}
+
+func (p *texParser) parseEnvironment() (string, bool, error) {
+	name, err := p.textArg("begin")
+	if err != nil {
+		return "", false, err
+	}
+	fences, ok := environments[name]
+	if !ok {
+		return "", false, fmt.Errorf("unknown environment %q", name)
+	}
+	if name == "array" {
+		// The column specification only affects alignment.
+		if t := p.peek(); t != nil && t.kind == tokOpen {
+			p.next()
+			if _, err := p.parseGroup(); err != nil {
+				return "", false, err
+			}
+		}
+	}
+
+	align := ""
+	switch name {
+	case "cases":
+		align = ` columnalign="left"`
+	case "aligned", "align", "align*":
+		align = ` columnalign="right left"`
+	}
+	cellEnd := func(t *texToken) bool {
+		return t.kind == tokAmp || t.kind == tokCommand && (t.text == "\\" || t.text == "end")
+	}
+
+	var b strings.Builder
+	b.WriteString("<mtable" + align + "><mtr>")
+	for {
+		items, err := p.parseRow(cellEnd)
+		if err != nil {
+			return "", false, err
+		}
+		b.WriteString("<mtd>" + mrow(items) + "</mtd>")
+		t := p.next()
+		switch {
+		case t == nil || t.kind == tokClose:
+			return "", false, fmt.Errorf("missing \\end{%s}", name)
+		case t.kind == tokAmp:
+			continue
+		case t.text == "\\":
+			b.WriteString("</mtr><mtr>")
+			continue
+		}
+		end, err := p.textArg("end")
+		if err != nil {
+			return "", false, err
+		}
+		if end != name {
+			return "", false, fmt.Errorf("\\begin{%s} ended by \\end{%s}", name, end)
+		}
+		break
+	}
+	b.WriteString("</mtr></mtable>")
+
+	table := strings.ReplaceAll(b.String(), "<mtr><mtd><mrow></mrow></mtd></mtr></mtable>", "</mtable>")
+	open, closing := "", ""
+	if fences[0] != "" {
+		open = `<mo fence="true" stretchy="true">` + html.EscapeString(fences[0]) + "</mo>"
+	}
+	if fences[1] != "" {
+		closing = `<mo fence="true" stretchy="true">` + html.EscapeString(fences[1]) + "</mo>"
+	}
+	return "<mrow>" + open + table + closing + "</mrow>", false, nil
+}
+
+func mrow(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return "<mrow>" + strings.Join(items, "") + "</mrow>"
+}
+
+// mathAlphanumeric maps a letter or digit to its styled form in the Unicode
+// Mathematical Alphanumeric Symbols block.
+func mathAlphanumeric(font string, r rune) rune {
+	type offsets struct{ upper, lower, digit rune }
+	styles := map[string]offsets{
+		"mathbf":     {0x1D400, 0x1D41A, 0x1D7CE},
+		"boldsymbol": {0x1D468, 0x1D482, 0x1D7CE},
+		"mathit":     {0x1D434, 0x1D44E, 0},
+		"mathbb":     {0x1D538, 0x1D552, 0x1D7D8},
+		"mathcal":    {0x1D49C, 0x1D4B6, 0},
+		"mathsf":     {0x1D5A0, 0x1D5BA, 0x1D7E2},
+		"mathtt":     {0x1D670, 0x1D68A, 0x1D7F6},
+	}
+	// Letters that predate the block live in Letterlike Symbols instead.
+	exceptions := map[string]map[rune]rune{
+		"mathit":  {'h': 'ℎ'},
+		"mathbb":  {'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ'},
+		"mathcal": {'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ', 'R': 'ℛ', 'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ'},
+	}
+	if e, ok := exceptions[font][r]; ok {
+		return e
+	}
+	o := styles[font]
+	switch {
+	case r >= 'A' && r <= 'Z':
+		return o.upper + r - 'A'
+	case r >= 'a' && r <= 'z':
+		return o.lower + r - 'a'
+	case r >= '0' && r <= '9' && o.digit != 0:
+		return o.digit + r - '0'
+	}
+	return r
+}
+
+var texIdentifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ", "varepsilon": "ε", "zeta": "ζ",
+	"eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν",
+	"xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ", "sigma": "σ", "varsigma": "ς", "tau": "τ",
+	"upsilon": "υ", "phi": "ϕ", "varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π", "Sigma": "Σ",
+	"Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	"infty": "∞", "partial": "∂", "ell": "ℓ", "hbar": "ℏ", "emptyset": "∅", "varnothing": "∅",
+	"Re": "ℜ", "Im": "ℑ", "aleph": "ℵ",
+}
+
+var texOperators = map[string]string{
+	"cdot": "⋅", "times": "×", "div": "÷", "pm": "±", "mp": "∓", "ast": "∗", "star": "⋆", "circ": "∘", "bullet": "∙",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠", "ll": "≪", "gg": "≫",
+	"approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅", "propto": "∝",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←", "leftrightarrow": "↔",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "Leftrightarrow": "⇔", "implies": "⟹", "iff": "⟺", "mapsto": "↦",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆", "supset": "⊃", "supseteq": "⊇",
+	"cup": "∪", "cap": "∩", "setminus": "∖", "forall": "∀", "exists": "∃", "nexists": "∄", "neg": "¬", "lnot": "¬",
+	"land": "∧", "wedge": "∧", "lor": "∨", "vee": "∨", "oplus": "⊕", "otimes": "⊗",
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"mid": "∣", "parallel": "∥", "perp": "⊥", "angle": "∠", "triangle": "△", "nabla": "∇", "prime": "′",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+	"{": "{", "}": "}", "|": "‖", "%": "%", "$": "$", "#": "#", "&": "&", "_": "_", "vert": "|", "Vert": "‖",
+	"colon": ":",
+}
+
+var texLargeOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "bigcup": "⋃", "bigcap": "⋂", "bigoplus": "⨁", "bigotimes": "⨂",
+	"int": "∫", "iint": "∬", "oint": "∮",
+}
+
+var texFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"arcsin": true, "arccos": true, "arctan": true, "sinh": true, "cosh": true, "tanh": true,
+	"log": true, "ln": true, "lg": true, "exp": true, "det": true, "dim": true, "ker": true,
+	"deg": true, "arg": true, "gcd": true, "hom": true, "Pr": true,
+}
+
+var texLimitFunctions = map[string]bool{
+	"lim": true, "max": true, "min": true, "sup": true, "inf": true, "limsup": true, "liminf": true, "argmax": true, "argmin": true,
+}
+
+var texSpaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em", "!": "-0.1667em", " ": "0.25em",
+	"quad": "1em", "qquad": "2em",
+}
+
+var texAccents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "overline": "‾", "vec": "→", "overrightarrow": "→",
+	"dot": "˙", "ddot": "¨", "tilde": "~", "widetilde": "~", "underline": "_",
+}
@@ -0,0 +1,48 @@
+package markup_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/markup"
+	"github.com/stretchr/testify/assert"
+)
+
+// body strips the math element and TeX annotation around the rendered row.
+func body(t *testing.T, tex string, display bool) string {
+	out, err := markup.MathML(tex, display)
+	if !assert.NoError(t, err, tex) {
+		return ""
+	}
+	out = out[strings.Index(out, "<semantics>")+len("<semantics>"):]
+	return out[:strings.Index(out, "<annotation")]
+}
+
+func TestMathML(t *testing.T) {
+	tests := []struct {
+		tex, want string
+		display   bool
+	}{
+		{tex: "x_1^2", want: "<msubsup><mi>x</mi><mn>1</mn><mn>2</mn></msubsup>"},
+		{tex: "a-b", want: "<mrow><mi>a</mi><mo>−</mo><mi>b</mi></mrow>"},
+		{tex: `\frac12`, want: "<mfrac><mn>1</mn><mn>2</mn></mfrac>"},
+		{tex: `\sqrt{2}`, want: "<msqrt><mn>2</mn></msqrt>"},
+		{tex: `\alpha \leq 3.14`, want: "<mrow><mi>α</mi><mo>≤</mo><mn>3.14</mn></mrow>"},
+		{tex: `\sum_{i=1}^n i`, display: true, want: `<mrow><munderover><mo largeop="true" movablelimits="true">∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></munderover><mi>i</mi></mrow>`},
+		{tex: `\sum_{i=1}^n i`, want: `<mrow><msubsup><mo largeop="true" movablelimits="true">∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></msubsup><mi>i</mi></mrow>`},
+		{tex: `\mathbb{N}`, want: "<mi>ℕ</mi>"},
+		{tex: `\vec{v}`, want: `<mover accent="true"><mi>v</mi><mo stretchy="false">→</mo></mover>`},
+		{tex: `\text{if } x`, want: "<mrow><mtext>if </mtext><mi>x</mi></mrow>"},
+		{tex: `\begin{cases} 1 & x > 0 \\ 0 & \text{otherwise} \end{cases}`, want: `<mrow><mo fence="true" stretchy="true">{</mo><mtable columnalign="left"><mtr><mtd><mn>1</mn></mtd><mtd><mrow><mi>x</mi><mo>&gt;</mo><mn>0</mn></mrow></mtd></mtr><mtr><mtd><mn>0</mn></mtd><mtd><mtext>otherwise</mtext></mtd></mtr></mtable></mrow>`},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, body(t, tt.tex, tt.display), tt.tex)
+	}
+}
+
+func TestMathML_Errors(t *testing.T) {
+	for _, tex := range []string{`\frac{1}`, `{x`, `x}`, `x^1^2`, `\left( x`, `\begin{pmatrix} 1 \end{bmatrix}`, `a & b`, `\unknown`} {
+		_, err := markup.MathML(tex, false)
+		assert.Error(t, err, tex)
+	}
+}
@@ -0,0 +1,62 @@
+// Package markup renders the Markdown used in quiz content as sanitised
+// HTML, with LaTeX math between dollar signs rendered server-side as MathML.
+package markup
+
+import (
+	"bytes"
+	"html"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM, mathExtension{}))
+
+var policy = newPolicy()
+
+// newPolicy allows user-generated HTML and the MathML that math renders to.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowNoAttrs().OnElements("math", "semantics", "annotation", "mrow", "mi", "mn", "mo", "mtext", "mspace",
+		"msup", "msub", "msubsup", "munder", "mover", "munderover", "mfrac", "msqrt", "mroot",
+		"mtable", "mtr", "mtd")
+	p.AllowAttrs("xmlns", "display").OnElements("math")
+	p.AllowAttrs("encoding").OnElements("annotation")
+	p.AllowAttrs("mathvariant").OnElements("mi")
+	p.AllowAttrs("fence", "stretchy", "largeop", "movablelimits").OnElements("mo")
+	p.AllowAttrs("width").OnElements("mspace")
+	p.AllowAttrs("linethickness").OnElements("mfrac")
+	p.AllowAttrs("accent").OnElements("mover")
+	p.AllowAttrs("accentunder").OnElements("munder")
+	p.AllowAttrs("columnalign").OnElements("mtable")
+	return p
+}
+
+// Render converts Markdown to sanitised HTML.
+func Render(src string) string {
+	if strings.TrimSpace(src) == "" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(src), &buf); err != nil {
+		return html.EscapeString(src)
+	}
+	return policy.Sanitize(buf.String())
+}
+
+// RenderInline renders content shown within a line, such as a choice label,
+// leaving out the paragraph that wraps single-paragraph content.
+func RenderInline(src string) string {
+	out := strings.TrimSpace(Render(src))
+	inner, found := strings.CutPrefix(out, "<p>")
+	if !found || !strings.HasSuffix(inner, "</p>") {
+		return out
+	}
+	inner = strings.TrimSuffix(inner, "</p>")
+	if strings.Contains(inner, "<p>") {
+		return out
+	}
+	return inner
+}
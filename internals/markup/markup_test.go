@@ -0,0 +1,43 @@
+package markup_test
+
+import (
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/markup"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	assert.Equal(t, "<p>Pick the <strong>odd</strong> one out:</p>\n<ul>\n<li><code>x := 1</code></li>\n</ul>\n",
+		markup.Render("Pick the **odd** one out:\n\n- `x := 1`"))
+	assert.Equal(t, "", markup.Render("  \n"))
+
+	// Raw HTML and dangerous links never make it through.
+	out := markup.Render("<script>alert(1)</script>\n\nSee [this](javascript:alert(1)) <img src=x onerror=alert(1)>")
+	assert.NotContains(t, out, "<script")
+	assert.NotContains(t, out, "javascript:")
+	assert.NotContains(t, out, "onerror")
+}
+
+func TestRender_Math(t *testing.T) {
+	out := markup.Render("Solve $x^2 = 4$ for $x$.")
+	assert.Contains(t, out, "<msup><mi>x</mi><mn>2</mn></msup>")
+	assert.Contains(t, out, `<annotation encoding="application/x-tex">x^2 = 4</annotation>`)
+
+	out = markup.Render("$$\n\\frac{1}{2}\n$$")
+	assert.Contains(t, out, `display="block"`)
+	assert.Contains(t, out, "<mfrac><mn>1</mn><mn>2</mn></mfrac>")
+
+	// Prices, escaped dollars and code spans are not math.
+	assert.Equal(t, "<p>It costs $5 or $10.</p>\n", markup.Render("It costs $5 or $10."))
+	assert.Equal(t, "<p>Use $x$ literally</p>\n", markup.Render("Use \\$x$ literally"))
+	assert.Equal(t, "<p><code>$x$</code></p>\n", markup.Render("`$x$`"))
+
+	// Math that cannot be converted is shown as source.
+	assert.Equal(t, "<p><code>$\\nosuch$</code></p>\n", markup.Render("$\\nosuch$"))
+}
+
+func TestRenderInline(t *testing.T) {
+	assert.Equal(t, "Just <em>one</em>", markup.RenderInline("Just *one*"))
+	assert.Equal(t, "<p>One</p>\n<p>Two</p>", markup.RenderInline("One\n\nTwo"))
+}
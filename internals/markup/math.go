@@ -0,0 +1,175 @@
+package markup
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	kindMath      = ast.NewNodeKind("Math")
+	kindMathBlock = ast.NewNodeKind("MathBlock")
+)
+
+// mathNode is inline math written as $...$, or display math written as
+// $$...$$ within a line.
+type mathNode struct {
+	ast.BaseInline
+	tex     string
+	display bool
+}
+
+func (n *mathNode) Kind() ast.NodeKind {
+	return kindMath
+}
+
+func (n *mathNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"TeX": n.tex}, nil)
+}
+
+// mathBlock is display math between lines holding only $$.
+type mathBlock struct {
+	ast.BaseBlock
+}
+
+func (n *mathBlock) Kind() ast.NodeKind {
+	return kindMathBlock
+}
+
+func (n *mathBlock) IsRaw() bool {
+	return true
+}
+
+func (n *mathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type mathInlineParser struct{}
+
+func (mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Parse follows the Pandoc rules so prices are not mistaken for math: the
+// opening $ must not be followed by a space, and the closing $ must not
+// follow a space nor be followed by a digit.
+func (mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	delim := 1
+	if len(line) > 1 && line[1] == '$' {
+		delim = 2
+	}
+	body := line[delim:]
+	if len(body) == 0 || body[0] == ' ' || body[0] == '\t' || body[0] == '$' {
+		return nil
+	}
+	for i := 1; i < len(body); i++ {
+		switch {
+		case body[i] == '\\':
+			i++
+		case body[i] != '$':
+		case delim == 2:
+			if i+1 < len(body) && body[i+1] == '$' {
+				block.Advance(2*delim + i)
+				return &mathNode{tex: string(body[:i]), display: true}
+			}
+		case body[i-1] != ' ' && body[i-1] != '\t' && (i+1 == len(body) || body[i+1] < '0' || body[i+1] > '9'):
+			block.Advance(2*delim + i)
+			return &mathNode{tex: string(body[:i])}
+		}
+	}
+	return nil
+}
+
+type mathBlockParser struct{}
+
+func (mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, _ := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.Equal(bytes.TrimSpace(line[pos:]), []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+	return &mathBlock{}, parser.NoChildren
+}
+
+func (mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	if bytes.Equal(bytes.TrimSpace(line), []byte("$$")) {
+		reader.Advance(segment.Len())
+		return parser.Close
+	}
+	node.Lines().Append(segment)
+	reader.Advance(segment.Len() - 1)
+	return parser.Continue | parser.NoChildren
+}
+
+func (mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type mathRenderer struct{}
+
+func (mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMath, func(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			node := n.(*mathNode)
+			writeMath(w, node.tex, node.display)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	reg.Register(kindMathBlock, func(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			var tex bytes.Buffer
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				segment := lines.At(i)
+				tex.Write(segment.Value(source))
+			}
+			_, _ = w.WriteString("<p>")
+			writeMath(w, tex.String(), true)
+			_, _ = w.WriteString("</p>\n")
+		}
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// writeMath writes the MathML of the expression, or its source when it
+// cannot be converted.
+func writeMath(w util.BufWriter, tex string, display bool) {
+	if out, err := MathML(tex, display); err == nil {
+		_, _ = w.WriteString(out)
+		return
+	}
+	delim := "$"
+	if display {
+		delim = "$$"
+	}
+	_, _ = w.WriteString("<code>" + html.EscapeString(delim+tex+delim) + "</code>")
+}
+
+// mathExtension adds $...$ and $$...$$ math to Markdown.
+type mathExtension struct{}
+
+func (mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithInlineParsers(util.Prioritized(mathInlineParser{}, 150)),
+		parser.WithBlockParsers(util.Prioritized(mathBlockParser{}, 750)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(mathRenderer{}, 150)))
+}
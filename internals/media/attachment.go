@@ -0,0 +1,67 @@
+package media
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// MaxAttachmentSize is the largest audio or video file accepted, in bytes.
+const MaxAttachmentSize = 64 << 20
+
+// attachmentTypes maps file extensions to the recording types browsers play.
+var attachmentTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+	".weba": "audio/webm",
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".webm": "video/webm",
+	".ogv":  "video/ogg",
+}
+
+// sniffedTypes maps what http.DetectContentType finds to recording types.
+// Containers shared by audio and video defer to the file extension.
+var sniffedTypes = map[string]string{
+	"audio/mpeg":      "audio/mpeg",
+	"audio/wave":      "audio/wav",
+	"audio/aiff":      "audio/aiff",
+	"application/ogg": "audio/ogg",
+	"video/mp4":       "video/mp4",
+	"video/webm":      "video/webm",
+}
+
+// Attachment reads an uploaded audio or video recording and returns it with
+// its content type. Recordings are stored as they are; only their size and
+// type are checked.
+func Attachment(r io.Reader, filename string) ([]byte, string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxAttachmentSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > MaxAttachmentSize {
+		return nil, "", fmt.Errorf("%w: the limit is %d MB", ErrTooLarge, MaxAttachmentSize>>20)
+	}
+
+	byName := attachmentTypes[strings.ToLower(filepath.Ext(filename))]
+	sniffed, known := sniffedTypes[http.DetectContentType(data)]
+	switch {
+	case known && byName != "" && strings.SplitN(sniffed, "/", 2)[1] == strings.SplitN(byName, "/", 2)[1]:
+		// Same container: the extension tells audio from video.
+		return data, byName, nil
+	case known:
+		return data, sniffed, nil
+	case byName != "" && http.DetectContentType(data) == "application/octet-stream":
+		// Formats such as M4A and raw MP3 frames are not sniffed.
+		return data, byName, nil
+	default:
+		return nil, "", ErrUnsupportedMedia
+	}
+}
@@ -0,0 +1,140 @@
+// Package media checks, shrinks and versions the images uploaded for quizzes,
+// questions and choices, and checks the audio and video recordings of
+// questions.
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrTooLarge         = errors.New("file is too large")
+	ErrUnsupported      = errors.New("unsupported image type, use JPEG, PNG, GIF or WebP")
+	ErrUnsupportedMedia = errors.New("unsupported recording type, use MP3, M4A, Ogg, WAV, MP4 or WebM")
+)
+
+// MaxUploadSize is the largest image file accepted, in bytes.
+const MaxUploadSize = 8 << 20
+
+// maxPixels guards against small files that decode into huge images.
+const maxPixels = 40_000_000
+
+// Size is the box, in pixels, that a processed image must fit in.
+type Size struct {
+	Width, Height int
+}
+
+var (
+	CoverSize    = Size{Width: 1200, Height: 630}
+	QuestionSize = Size{Width: 1200, Height: 1200}
+	ThumbSize    = Size{Width: 320, Height: 320}
+)
+
+// Process reads an uploaded image, checks its type from its content rather
+// than its name, and shrinks it to fit in size. The image is re-encoded, which
+// also drops any metadata: JPEG stays JPEG and everything else becomes PNG.
+func Process(r io.Reader, size Size) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxUploadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxUploadSize {
+		return nil, fmt.Errorf("%w: the limit is %d MB", ErrTooLarge, MaxUploadSize>>20)
+	}
+
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+	default:
+		return nil, ErrUnsupported
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels", ErrTooLarge, config.Width, config.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	dst := Fit(src, size)
+	var out bytes.Buffer
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&out, dst)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Fit scales the image down, keeping its aspect ratio, so that it fits in
+// size. Images that already fit are returned as they are.
+func Fit(src image.Image, size Size) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size.Width && h <= size.Height {
+		return src
+	}
+	if w*size.Height > h*size.Width {
+		w, h = size.Width, max(1, h*size.Width/w)
+	} else {
+		w, h = max(1, w*size.Height/h), size.Height
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
+
+// ContentType returns the type of a processed image.
+func ContentType(data []byte) string {
+	return http.DetectContentType(data)
+}
+
+// Version identifies the content of an image.
+func Version(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:6])
+}
+
+// URL returns the path that serves the image blob with the key, or "" when
+// there is no image.
+func URL(key string) string {
+	if key == "" {
+		return ""
+	}
+	return "/media/" + key
+}
+
+// Serve writes an image with caching headers. Blob keys are named after
+// their content, so the response may be cached forever.
+func Serve(w http.ResponseWriter, r *http.Request, data []byte) {
+	if len(data) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType(data))
+	w.Header().Set("ETag", `"`+Version(data)+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
@@ -0,0 +1,119 @@
+package media_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/media"
+	"github.com/stretchr/testify/assert"
+)
+
+func encoded(t *testing.T, w, h int, asJPEG bool) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	var err error
+	if asJPEG {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	assert.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	out, err := media.Process(bytes.NewReader(encoded(t, 2000, 1000, false)), media.CoverSize)
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", media.ContentType(out))
+	config, _, err := image.DecodeConfig(bytes.NewReader(out))
+	assert.NoError(t, err)
+	assert.Equal(t, 1200, config.Width)
+	assert.Equal(t, 600, config.Height)
+
+	out, err = media.Process(bytes.NewReader(encoded(t, 100, 800, true)), media.ThumbSize)
+	assert.NoError(t, err)
+	assert.Equal(t, "image/jpeg", media.ContentType(out))
+	config, _, _ = image.DecodeConfig(bytes.NewReader(out))
+	assert.Equal(t, 40, config.Width)
+	assert.Equal(t, 320, config.Height)
+
+	// Small images keep their size.
+	out, err = media.Process(bytes.NewReader(encoded(t, 10, 10, false)), media.ThumbSize)
+	assert.NoError(t, err)
+	config, _, _ = image.DecodeConfig(bytes.NewReader(out))
+	assert.Equal(t, 10, config.Width)
+}
+
+func TestProcess_Rejects(t *testing.T) {
+	_, err := media.Process(strings.NewReader("<svg xmlns='http://www.w3.org/2000/svg'><script>alert(1)</script></svg>"), media.ThumbSize)
+	assert.ErrorIs(t, err, media.ErrUnsupported)
+
+	_, err = media.Process(bytes.NewReader(make([]byte, media.MaxUploadSize+1)), media.ThumbSize)
+	assert.ErrorIs(t, err, media.ErrTooLarge)
+
+	// A PNG header claiming a huge image is refused before decoding.
+	bomb := encoded(t, 1, 1, false)
+	copy(bomb[16:24], []byte{0, 0, 0x80, 0, 0, 0, 0x80, 0})
+	binary.BigEndian.PutUint32(bomb[29:33], crc32.ChecksumIEEE(bomb[12:29]))
+	_, err = media.Process(bytes.NewReader(bomb), media.ThumbSize)
+	assert.ErrorIs(t, err, media.ErrTooLarge)
+}
+
+func TestServe(t *testing.T) {
+	data := encoded(t, 4, 4, false)
+	url := media.URL("images/abc.png")
+	assert.Equal(t, "/media/images/abc.png", url)
+	assert.Equal(t, "", media.URL(""))
+
+	rec := httptest.NewRecorder()
+	media.Serve(rec, httptest.NewRequest(http.MethodGet, url, nil), data)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Cache-Control"), "immutable")
+
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	media.Serve(rec, req, data)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+}
+
+func TestAttachment(t *testing.T) {
+	mp3 := append([]byte("ID3\x03\x00\x00\x00\x00\x00\x00"), make([]byte, 64)...)
+	webm := append([]byte("\x1A\x45\xDF\xA3\x9F\x42\x86\x81\x01\x42\xF7\x81\x01\x42\xF2\x81\x04\x42\xF3\x81\x08\x42\x82\x84webm"), make([]byte, 64)...)
+	for _, tc := range []struct {
+		data     []byte
+		filename string
+		want     string
+	}{
+		{mp3, "listen.mp3", "audio/mpeg"},
+		{mp3, "listen.bin", "audio/mpeg"},
+		{webm, "clip.webm", "video/webm"},
+		{webm, "clip.weba", "audio/webm"},
+		{[]byte{0xFF, 0xFB, 0x90, 0x64, 0x00}, "frames.mp3", "audio/mpeg"},
+	} {
+		data, contentType, err := media.Attachment(bytes.NewReader(tc.data), tc.filename)
+		assert.NoError(t, err, tc.filename)
+		assert.Equal(t, tc.want, contentType, tc.filename)
+		assert.Equal(t, tc.data, data)
+	}
+
+	_, _, err := media.Attachment(bytes.NewReader(encoded(t, 4, 4, false)), "fake.mp3")
+	assert.ErrorIs(t, err, media.ErrUnsupportedMedia)
+	_, _, err = media.Attachment(strings.NewReader("plain text"), "notes.txt")
+	assert.ErrorIs(t, err, media.ErrUnsupportedMedia)
+	_, _, err = media.Attachment(bytes.NewReader(make([]byte, media.MaxAttachmentSize+1)), "big.mp3")
+	assert.ErrorIs(t, err, media.ErrTooLarge)
+}
@@ -0,0 +1,322 @@
+package paper
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mbsof31/go-quiz/internals/pdf"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// Media returns the blob of a key, such as Store.Media.
+type Media func(key string) ([]byte, error)
+
+// WriteQuestions writes the exam paper learners answer on: the questions
+// with their choices and room for the answers. Question images are read
+// through media, which may be nil to leave them out.
+func (e *Exam) WriteQuestions(w io.Writer, media Media) error {
+	f := newFlow(e.Title())
+	f.text(0, pdf.HelveticaBold, 18, e.Title())
+	if e.Quiz.Description != "" {
+		f.skip(4)
+		f.markdown(0, 10, e.Quiz.Description)
+	}
+	f.skip(14)
+	f.line(0, pdf.Helvetica, 10, "Name: ______________________________    Student number: ______________")
+	f.skip(10)
+
+	for _, item := range e.Items {
+		question := item.Question
+		// Keep the heading with the first lines of the question.
+		f.space(60)
+		f.skip(12)
+		f.line(0, pdf.HelveticaBold, 11, fmt.Sprintf("%d.", item.Number))
+		label := points(question)
+		f.page.Gray(0.4)
+		f.page.Text(margin+textWidth-pdf.Width(pdf.Helvetica, 9, label), f.top, pdf.Helvetica, 9, label)
+		f.page.Gray(0)
+		// The prompt starts beside the number.
+		f.top -= 11 * 1.4
+		f.markdown(20, 11, question.Prompt())
+		if question.ImageKey != "" && media != nil {
+			if err := f.image(20, question.ImageKey, media); err != nil {
+				return fmt.Errorf("question %d: %w", item.Number, err)
+			}
+		}
+		if question.HasMedia() {
+			f.text(20, pdf.Helvetica, 9, "(This question has a recording; it is played in the room.)")
+		}
+		f.skip(4)
+		answerSpace(f, item)
+	}
+	_, err := f.doc.WriteTo(w)
+	return err
+}
+
+// answerSpace writes the choices of the item or the room for its answer.
+func answerSpace(f *flow, item Item) {
+	question := item.Question
+	switch question.Type {
+	case quiz.TypeLikert:
+		labels := question.ScaleLabels()
+		f.text(20, pdf.Helvetica, 9, "Circle one:")
+		for i, label := range labels {
+			f.choice(letter(i), label)
+		}
+	case quiz.TypeShortText, quiz.TypeNumeric:
+		f.skip(10)
+		f.line(20, pdf.Helvetica, 11, "Answer: ______________________________________")
+	case quiz.TypeCloze:
+		f.text(20, pdf.Helvetica, 9, "Fill in the blanks.")
+	case quiz.TypeOrdering:
+		if question.Ordering == nil {
+			return
+		}
+		f.text(20, pdf.Helvetica, 9, "Put the items in order by writing their letters, first to last.")
+		for n, i := range item.Order {
+			f.text(20, pdf.Helvetica, 11, letter(n)+"   "+plainLine(question.Ordering.Items[i]))
+		}
+		f.skip(10)
+		f.line(20, pdf.Helvetica, 11, "Order: ______________________________________")
+	case quiz.TypeMatching:
+		if question.Matching == nil {
+			return
+		}
+		f.text(20, pdf.Helvetica, 9, "Write the letter of the matching option next to each number.")
+		for n, pair := range question.Matching.Pairs {
+			f.text(20, pdf.Helvetica, 11, fmt.Sprintf("____  %d. %s", n+1, plainLine(pair.Prompt)))
+		}
+		f.skip(4)
+		options := question.Matching.Options()
+		for n, i := range item.Order {
+			f.text(20, pdf.Helvetica, 11, letter(n)+"   "+plainLine(options[i]))
+		}
+	case quiz.TypeEssay:
+		f.box(12)
+	case quiz.TypeCode:
+		if question.Code != nil && question.Code.Starter != "" {
+			f.markdown(20, 10, "```\n"+question.Code.Starter+"\n```")
+		}
+		f.box(16)
+	default:
+		if question.Type == quiz.TypeMultiChoice {
+			f.text(20, pdf.Helvetica, 9, "Choose all that apply.")
+		}
+		for n, i := range item.Order {
+			f.choice(letter(n), question.Choices[i].Content)
+		}
+	}
+}
+
+// choice writes a choice with the bubble learners fill in.
+func (f *flow) choice(label, content string) {
+	lines := pdf.Wrap(pdf.Helvetica, 11, plainLine(content), textWidth-56)
+	for i, line := range lines {
+		f.line(56, pdf.Helvetica, 11, line)
+		if i == 0 {
+			f.page.Circle(margin+28, f.top-3.5, 6, 0.8)
+			f.page.Text(margin+40, f.top, pdf.Helvetica, 11, label)
+		}
+	}
+}
+
+// box draws a lined box of rows for a written answer, moving to a new page
+// when it does not fit.
+func (f *flow) box(rows int) {
+	const row = 22.0
+	height := float64(rows)*row + 8
+	f.skip(6)
+	f.space(height)
+	f.page.Rect(margin+20, f.top, textWidth-20, height, 0.8)
+	f.page.Gray(0.8)
+	for i := 1; i <= rows; i++ {
+		y := f.top + float64(i)*row
+		f.page.Line(margin+28, y, margin+textWidth-8, y, 0.4)
+	}
+	f.page.Gray(0)
+	f.top += height
+}
+
+// image draws a question image at most half a page high.
+func (f *flow) image(indent float64, key string, media Media) error {
+	data, err := media(key)
+	if err != nil {
+		return err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		// SVG and other formats the standard library cannot decode are
+		// left out rather than failing the whole paper.
+		f.text(indent, pdf.Helvetica, 9, "[image not printable]")
+		return nil
+	}
+	size := img.Bounds().Size()
+	w, h := float64(size.X), float64(size.Y)
+	scale := math.Min(1, math.Min((textWidth-indent)/w, (pageBottom-margin)/2/h))
+	w, h = w*scale, h*scale
+	f.skip(6)
+	f.space(h)
+	f.page.Image(img, margin+indent, f.top, w, h)
+	f.top += h
+	return nil
+}
+
+// points labels the points of a question.
+func points(q *quiz.Question) string {
+	if !q.Graded() {
+		return "not graded"
+	}
+	p := q.MaxPoints()
+	if p == 1 {
+		return "1 point"
+	}
+	return formatFloat(p) + " points"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// total returns the points of all graded questions.
+func (e *Exam) total() float64 {
+	total := 0.0
+	for _, item := range e.Items {
+		if item.Question.Graded() {
+			total += item.Question.MaxPoints()
+		}
+	}
+	return total
+}
+
+// Answer returns the answer of the item as written in the key, such as
+// "B, D", "C A B" for an ordering or "12.5 ± 0.1 (cm, m)".
+func (e *Exam) Answer(item Item) string {
+	question := item.Question
+	switch question.Type {
+	case quiz.TypeLikert:
+		return "survey, any answer"
+	case quiz.TypeShortText:
+		if question.Text == nil {
+			return ""
+		}
+		answers := append([]string(nil), question.Text.Accepted...)
+		for _, p := range question.Text.Patterns {
+			answers = append(answers, "/"+p+"/")
+		}
+		return strings.Join(answers, " or ")
+	case quiz.TypeNumeric:
+		spec := question.Numeric
+		if spec == nil {
+			return ""
+		}
+		answer := formatFloat(spec.Answer)
+		switch {
+		case spec.Tolerance > 0:
+			answer += " ± " + formatFloat(spec.Tolerance)
+		case spec.RelativeTolerance > 0:
+			answer += " ± " + formatFloat(spec.RelativeTolerance*100) + "%"
+		}
+		if len(spec.Units) > 0 {
+			var units []string
+			for _, u := range spec.Units {
+				units = append(units, u.Unit)
+			}
+			answer += " (" + strings.Join(units, ", ") + ")"
+		}
+		return answer
+	case quiz.TypeCloze:
+		segments, err := quiz.ParseCloze(question.Content)
+		if err != nil {
+			return ""
+		}
+		var blanks []string
+		for _, s := range segments {
+			if s.Blank >= 0 {
+				blanks = append(blanks, fmt.Sprintf("(%d) %s", s.Blank+1, strings.Join(s.Accepted, " or ")))
+			}
+		}
+		return strings.Join(blanks, "; ")
+	case quiz.TypeOrdering:
+		// The letter printed for each item, in the correct order.
+		letters := make([]string, len(item.Order))
+		for n, i := range item.Order {
+			letters[i] = letter(n)
+		}
+		return strings.Join(letters, " ")
+	case quiz.TypeMatching:
+		if question.Matching == nil {
+			return ""
+		}
+		options := question.Matching.Options()
+		printed := make(map[string]string)
+		for n, i := range item.Order {
+			printed[options[i]] = letter(n)
+		}
+		var pairs []string
+		for n, pair := range question.Matching.Pairs {
+			pairs = append(pairs, fmt.Sprintf("%d-%s", n+1, printed[pair.Answer]))
+		}
+		return strings.Join(pairs, ", ")
+	case quiz.TypeEssay:
+		return "graded by hand"
+	case quiz.TypeCode:
+		return "graded by running the tests"
+	}
+	var letters []string
+	for n, i := range item.Order {
+		if question.Choices[i].IsCorrect {
+			letters = append(letters, letter(n))
+		}
+	}
+	return strings.Join(letters, ", ")
+}
+
+// WriteKey writes the answer key of the exam: the answer and points of
+// every question, with the rubric of essays.
+func (e *Exam) WriteKey(w io.Writer) error {
+	title := "Answer key: " + e.Title()
+	f := newFlow(title)
+	f.text(0, pdf.HelveticaBold, 18, title)
+	f.text(0, pdf.Helvetica, 10, fmt.Sprintf("%d questions, %s points", len(e.Items), formatFloat(e.total())))
+	f.skip(10)
+	for _, item := range e.Items {
+		question := item.Question
+		f.skip(6)
+		f.text(0, pdf.HelveticaBold, 11, fmt.Sprintf("%d.  %s", item.Number, e.Answer(item)))
+		f.page.Gray(0.4)
+		f.text(20, pdf.Helvetica, 9, fmt.Sprintf("%s · %s", points(question), summary(question.Prompt())))
+		f.page.Gray(0)
+		if question.Type == quiz.TypeEssay && question.Essay != nil {
+			for _, c := range question.Essay.Rubric {
+				line := fmt.Sprintf("• %s (%s): %s", c.Name, formatFloat(c.Points), c.Description)
+				f.text(20, pdf.Helvetica, 9, strings.TrimSuffix(line, ": "))
+			}
+		}
+		if question.Explanation != "" {
+			f.text(20, pdf.Helvetica, 9, "Why: "+plainLine(question.Explanation))
+		}
+	}
+	_, err := f.doc.WriteTo(w)
+	return err
+}
+
+// summary shortens question content for the answer key.
+func summary(content string) string {
+	content = strings.Join(strings.Fields(plainLine(content)), " ")
+	if len(content) > 90 {
+		cut := 90
+		for cut > 0 && content[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		return content[:cut] + "…"
+	}
+	return content
+}
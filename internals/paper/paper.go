@@ -0,0 +1,213 @@
+// Package paper prints quizzes for exams on paper: the questions, an answer
+// key and a bubble sheet, in PDF. Exams can be printed in variants, which
+// shuffle the questions and their choices so neighbours do not share
+// answers; the order only depends on the quiz and the variant letter, so the
+// key and the sheets of a variant always match its paper.
+package paper
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	"github.com/mbsof31/go-quiz/internals/pdf"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// Variants are the letters exams are offered in. The empty variant keeps
+// the authored order.
+var Variants = []string{"A", "B", "C"}
+
+// Exam is a quiz laid out for paper in a variant.
+type Exam struct {
+	Quiz    *quiz.Quiz
+	Variant string
+	Items   []Item
+	// Printing identifies the print run on bubble sheets, so scans are
+	// graded against the quiz as it was printed; see quiz.Printing.
+	Printing uint
+}
+
+// Item is a question as printed.
+type Item struct {
+	Number   int // From 1
+	Question *quiz.Question
+	// Order is the printed order of the choices, ordering items or
+	// matching options: indexes into Choices, Ordering.Items or
+	// Matching.Options.
+	Order []int
+}
+
+// NewExam lays the quiz out in the variant: "" or one of Variants.
+// Ordering items and matching options are shuffled in every variant, as
+// their authored order gives the answer away.
+func NewExam(q *quiz.Quiz, variant string) (*Exam, error) {
+	if variant != "" && !contains(Variants, variant) {
+		return nil, fmt.Errorf("unknown variant %q, use %s", variant, strings.Join(Variants, ", "))
+	}
+	exam := &Exam{Quiz: q, Variant: variant}
+	order := identity(len(q.Questions))
+	if variant != "" {
+		order = quiz.Shuffle(len(q.Questions), seed(q.ID, variant, -1))
+	}
+	for n, i := range order {
+		question := &q.Questions[i]
+		item := Item{Number: n + 1, Question: question}
+		shuffled := func(count int) []int {
+			return quiz.Shuffle(count, seed(q.ID, variant, i))
+		}
+		switch {
+		case question.Type == quiz.TypeOrdering && question.Ordering != nil:
+			item.Order = shuffled(len(question.Ordering.Items))
+		case question.Type == quiz.TypeMatching && question.Matching != nil:
+			item.Order = shuffled(len(question.Matching.Options()))
+		case question.HasChoices() && variant != "":
+			item.Order = shuffled(len(question.Choices))
+		default:
+			item.Order = identity(len(question.Choices))
+		}
+		exam.Items = append(exam.Items, item)
+	}
+	return exam, nil
+}
+
+// Title names the exam and its variant.
+func (e *Exam) Title() string {
+	if e.Variant == "" {
+		return e.Quiz.Name
+	}
+	return fmt.Sprintf("%s – variant %s", e.Quiz.Name, e.Variant)
+}
+
+// seed derives the shuffle of question i, or of the questions for -1, from
+// the quiz and the variant.
+func seed(quizID uint, variant string, i int) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s/%d", quizID, variant, i)
+	return int64(h.Sum64() >> 1)
+}
+
+func identity(n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// letter labels the i-th printed choice.
+func letter(i int) string {
+	return string(rune('A' + i))
+}
+
+// Page layout, in points.
+const (
+	margin     = 56.0
+	textWidth  = pdf.A4Width - 2*margin
+	pageBottom = pdf.A4Height - margin
+)
+
+// flow writes text down the pages of a document, starting new pages with a
+// header and a page number as they fill up.
+type flow struct {
+	doc    *pdf.Document
+	page   *pdf.Page
+	top    float64 // Baseline of the last line
+	header string
+}
+
+func newFlow(title string) *flow {
+	f := &flow{doc: pdf.New(title), header: title}
+	f.newPage()
+	return f
+}
+
+func (f *flow) newPage() {
+	f.page = f.doc.AddPage()
+	f.page.Gray(0.4)
+	f.page.Text(margin, 36, pdf.Helvetica, 8, f.header)
+	number := fmt.Sprintf("Page %d", f.doc.Pages())
+	f.page.Text(pdf.A4Width-margin-pdf.Width(pdf.Helvetica, 8, number), pdf.A4Height-30, pdf.Helvetica, 8, number)
+	f.page.Gray(0)
+	f.top = margin
+}
+
+// space starts a new page unless height fits below the last line.
+func (f *flow) space(height float64) {
+	if f.top+height > pageBottom {
+		f.newPage()
+	}
+}
+
+// skip leaves a gap below the last line.
+func (f *flow) skip(height float64) {
+	f.top += height
+}
+
+// text writes wrapped text indented by indent.
+func (f *flow) text(indent float64, font pdf.Font, size float64, s string) {
+	for _, line := range pdf.Wrap(font, size, s, textWidth-indent) {
+		f.line(indent, font, size, line)
+	}
+}
+
+// line writes a line that fits the page width.
+func (f *flow) line(indent float64, font pdf.Font, size float64, s string) {
+	height := size * 1.4
+	f.space(height)
+	f.top += height
+	f.page.Text(margin+indent, f.top, font, size, s)
+}
+
+// markdown writes quiz content, printing Markdown as plain text and code
+// blocks in Courier.
+func (f *flow) markdown(indent float64, size float64, content string) {
+	code := false
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			f.text(indent, pdf.Helvetica, size, strings.Join(paragraph, "\n"))
+			paragraph = nil
+		}
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			flush()
+			code = !code
+			continue
+		}
+		if code {
+			f.text(indent+8, pdf.Courier, size-1, strings.ReplaceAll(line, "\t", "    "))
+			continue
+		}
+		paragraph = append(paragraph, plainLine(line))
+	}
+	flush()
+}
+
+var (
+	mdImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdHeading  = regexp.MustCompile(`^#{1,6}\s+`)
+	mdBullet   = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	mdEmphasis = strings.NewReplacer("**", "", "`", "")
+)
+
+// plainLine strips the Markdown markup of a line that reads badly on paper.
+func plainLine(line string) string {
+	line = mdImage.ReplaceAllString(line, "[image: $1]")
+	line = mdLink.ReplaceAllString(line, "$1")
+	line = mdHeading.ReplaceAllString(line, "")
+	line = mdBullet.ReplaceAllString(line, "$1• ")
+	return mdEmphasis.Replace(line)
+}
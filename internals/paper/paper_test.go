@@ -0,0 +1,204 @@
+package paper_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/paper"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func examQuiz() *quiz.Quiz {
+	return &quiz.Quiz{ID: 7, Name: "Geography", Description: "Answer **all** questions.", Questions: []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "Capital of France?",
+			Choices: []quiz.Choice{{Content: "Paris", IsCorrect: true}, {Content: "Lyon"}, {Content: "Nice"}, {Content: "Lille"}}},
+		{Type: quiz.TypeMultiChoice, Content: "Cities on the Danube?", Points: 2,
+			Choices: []quiz.Choice{{Content: "Vienna", IsCorrect: true}, {Content: "Prague"}, {Content: "Budapest", IsCorrect: true}}},
+		{Type: quiz.TypeNumeric, Content: "Height of the Eiffel tower?",
+			Numeric: &quiz.NumericSpec{Answer: 330, Tolerance: 5, Units: []quiz.NumericUnit{{Unit: "m", Multiplier: 1}}}},
+		{Type: quiz.TypeOrdering, Content: "North to south",
+			Ordering: &quiz.OrderingSpec{Items: []string{"Oslo", "Berlin", "Rome", "Tunis"}}},
+		{Type: quiz.TypeMatching, Content: "Match the countries",
+			Matching: &quiz.MatchingSpec{Pairs: []quiz.MatchPair{{Prompt: "Spain", Answer: "Madrid"}, {Prompt: "Peru", Answer: "Lima"}}, Distractors: []string{"Quito"}}},
+		{Type: quiz.TypeCloze, Content: "The Seine flows through {{Paris}} and {{Rouen|Le Havre}}."},
+		{Type: quiz.TypeEssay, Content: "Why do cities grow on rivers?",
+			Essay: &quiz.EssaySpec{Rubric: []quiz.RubricCriterion{{Name: "Trade", Points: 2}, {Name: "Water", Description: "Drinking and farming", Points: 1}}}},
+		{Type: quiz.TypeLikert, Content: "I liked this exam"},
+	}}
+}
+
+// pageText returns the inflated content streams of a PDF.
+func pageText(t *testing.T, data []byte) string {
+	t.Helper()
+	require.True(t, bytes.HasPrefix(data, []byte("%PDF-")))
+	var b strings.Builder
+	for _, m := range regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`).FindAllSubmatch(data, -1) {
+		zr, err := zlib.NewReader(bytes.NewReader(m[1]))
+		require.NoError(t, err)
+		inflated, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		b.Write(inflated)
+	}
+	return b.String()
+}
+
+func TestNewExam(t *testing.T) {
+	q := examQuiz()
+	authored, err := paper.NewExam(q, "")
+	require.NoError(t, err)
+	for i, item := range authored.Items {
+		assert.Equal(t, i+1, item.Number)
+		assert.Same(t, &q.Questions[i], item.Question)
+	}
+	assert.Equal(t, []int{0, 1, 2, 3}, authored.Items[0].Order)
+
+	orders := make(map[string]string)
+	for _, v := range paper.Variants {
+		exam, err := paper.NewExam(q, v)
+		require.NoError(t, err)
+		again, err := paper.NewExam(q, v)
+		require.NoError(t, err)
+		assert.Equal(t, exam.Items, again.Items, "variant %s is stable", v)
+		assert.Equal(t, "Geography – variant "+v, exam.Title())
+
+		var order []string
+		for _, item := range exam.Items {
+			order = append(order, item.Question.Content)
+		}
+		orders[strings.Join(order, "|")] = v
+	}
+	assert.Len(t, orders, len(paper.Variants), "the variants order the questions differently")
+
+	_, err = paper.NewExam(q, "D")
+	assert.EqualError(t, err, `unknown variant "D", use A, B, C`)
+}
+
+func TestExam_Answer(t *testing.T) {
+	exam, err := paper.NewExam(examQuiz(), "")
+	require.NoError(t, err)
+	var answers []string
+	for _, item := range exam.Items {
+		answers = append(answers, exam.Answer(item))
+	}
+	ordering, matching := exam.Items[3], exam.Items[4]
+	assert.Equal(t, []string{
+		"A",
+		"A, C",
+		"330 ± 5 (m)",
+		orderLetters(ordering.Order),
+		matchLetters(matching.Order),
+		"(1) Paris; (2) Rouen or Le Havre",
+		"graded by hand",
+		"survey, any answer",
+	}, answers)
+
+	// In a variant the key follows the shuffled choices.
+	exam, err = paper.NewExam(examQuiz(), "B")
+	require.NoError(t, err)
+	for _, item := range exam.Items {
+		if item.Question.Type != quiz.TypeSingleChoice {
+			continue
+		}
+		answer := exam.Answer(item)
+		require.Len(t, answer, 1)
+		assert.Equal(t, "Paris", item.Question.Choices[item.Order[answer[0]-'A']].Content)
+	}
+}
+
+// orderLetters returns the printed letters of the ordering items in the
+// correct order.
+func orderLetters(order []int) string {
+	letters := make([]string, len(order))
+	for n, i := range order {
+		letters[i] = string(rune('A' + n))
+	}
+	return strings.Join(letters, " ")
+}
+
+// matchLetters returns the key of the matching question: Madrid and Lima
+// are options 0 and 1.
+func matchLetters(order []int) string {
+	printed := make(map[int]string)
+	for n, i := range order {
+		printed[i] = string(rune('A' + n))
+	}
+	return fmt.Sprintf("1-%s, 2-%s", printed[0], printed[1])
+}
+
+func TestExam_WriteQuestions(t *testing.T) {
+	exam, err := paper.NewExam(examQuiz(), "")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, exam.WriteQuestions(&buf, nil))
+	text := pageText(t, buf.Bytes())
+	assert.Contains(t, text, "(Geography) Tj")
+	assert.Contains(t, text, "(Answer all questions.) Tj")
+	assert.Contains(t, text, "(Capital of France?) Tj")
+	assert.Contains(t, text, "(Paris) Tj")
+	assert.Contains(t, text, "(Choose all that apply.) Tj")
+	assert.Contains(t, text, "(2 points) Tj")
+	assert.Contains(t, text, "(The Seine flows through _____ and _____.) Tj")
+	assert.Contains(t, text, "(Strongly agree) Tj")
+	assert.NotContains(t, text, "Rouen")
+}
+
+func TestExam_WriteKey(t *testing.T) {
+	exam, err := paper.NewExam(examQuiz(), "A")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, exam.WriteKey(&buf))
+	text := pageText(t, buf.Bytes())
+	assert.Contains(t, text, "(Answer key: Geography \x96 variant A) Tj")
+	assert.Contains(t, text, "(8 questions, 10 points) Tj")
+	assert.Contains(t, text, "330 \xb1 5 \\(m\\)) Tj")
+	assert.Contains(t, text, "(\x95 Water \\(1\\): Drinking and farming) Tj")
+	assert.Contains(t, text, "(\x95 Trade \\(2\\)) Tj")
+}
+
+func TestExam_WriteSheet(t *testing.T) {
+	exam, err := paper.NewExam(examQuiz(), "C")
+	require.NoError(t, err)
+	items, err := exam.SheetItems()
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	for _, item := range items {
+		assert.True(t, item.Question.HasChoices())
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, exam.WriteSheet(&buf))
+	text := pageText(t, buf.Bytes())
+	assert.Equal(t, 4, strings.Count(text, " re f\n"), "four corner marks")
+	assert.Contains(t, text, "(Variant) Tj")
+	// Two answer rows of 4 and 3 bubbles, 80 student number bubbles, the
+	// variants A and B, with C filled in, and the code of print run 0.
+	assert.Equal(t, 4+3+80+2+16, strings.Count(text, "S\n")-strings.Count(text, " l S\n")-strings.Count(text, " re S\n"))
+	assert.Equal(t, 1, strings.Count(text, "c f\n"))
+
+	exam.Printing = 6
+	buf.Reset()
+	require.NoError(t, exam.WriteSheet(&buf))
+	assert.Equal(t, 1+2, strings.Count(pageText(t, buf.Bytes()), "c f\n"), "bits 2 and 3 of the code filled")
+	exam.Printing = 1 << 16
+	assert.EqualError(t, exam.WriteSheet(io.Discard), "print run 65536 does not fit the 16 bit sheet code")
+
+	q := &quiz.Quiz{Name: "Long"}
+	for i := 0; i < paper.MaxSheetQuestions+1; i++ {
+		q.Questions = append(q.Questions, quiz.Question{Type: quiz.TypeSingleChoice, Content: "Q",
+			Choices: []quiz.Choice{{Content: "yes", IsCorrect: true}, {Content: "no"}}})
+	}
+	exam, err = paper.NewExam(q, "")
+	require.NoError(t, err)
+	assert.EqualError(t, exam.WriteSheet(io.Discard), "the quiz has 76 choice questions, a bubble sheet has room for 75")
+
+	exam, err = paper.NewExam(&quiz.Quiz{Questions: []quiz.Question{{Type: quiz.TypeEssay, Content: "Why?"}}}, "")
+	require.NoError(t, err)
+	assert.EqualError(t, exam.WriteSheet(io.Discard), "the quiz has no choice questions to answer on a bubble sheet")
+}
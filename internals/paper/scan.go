@@ -0,0 +1,404 @@
+package paper
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/mbsof31/go-quiz/internals/pdf"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// Bubbles are read by the share of dark pixels inside them: at least
+// filledShare is a mark, below emptyShare is blank, and anything between is
+// faint and flagged for review.
+const (
+	filledShare = 0.45
+	emptyShare  = 0.2
+)
+
+// SheetScan is what ScanSheet reads from a filled bubble sheet.
+type SheetScan struct {
+	Printing      uint   // Print run from the sheet code
+	StudentNumber string // Digits of the filled columns
+	Variant       string // Empty when no variant bubble is filled
+	// Rows holds the answer rows in sheet order, whether printed or not.
+	Rows [MaxSheetQuestions]ScannedRow
+	// Review lists the marks of the student number and variant that need a
+	// person to check them. Answer rows are flagged by Exam.SheetAnswers.
+	Review []string
+}
+
+// ScannedRow is a row of answer bubbles read from a sheet.
+type ScannedRow struct {
+	Filled []int // Printed positions of the filled bubbles
+	Faint  []int // Printed positions of marks too faint to decide
+}
+
+// ScanSheet reads a scanned or photographed bubble sheet. It finds the
+// corner marks, maps the sheet layout onto the image through them, so that
+// rotated and skewed scans read too, and measures every bubble.
+func ScanSheet(img image.Image) (*SheetScan, error) {
+	gray := toGray(img)
+	threshold := otsu(gray)
+	marks, err := findMarks(gray, threshold)
+	if err != nil {
+		return nil, err
+	}
+	h, err := homography(MarkCentres(), marks)
+	if err != nil {
+		return nil, err
+	}
+	read := func(x, y, r float64) float64 {
+		return darkShare(gray, threshold, h, x, y, r)
+	}
+
+	scan := &SheetScan{}
+	for i := 0; i < codeBits; i++ {
+		x, y := CodeBubble(i)
+		share := read(x, y, codeRadius)
+		if share >= emptyShare && share < filledShare {
+			return nil, fmt.Errorf("cannot read bit %d of the sheet code, is this a sheet printed by the quiz app?", i+1)
+		}
+		if share >= filledShare {
+			scan.Printing |= 1 << i
+		}
+	}
+
+	// Student numbers shorter than the grid leave columns blank at either
+	// end, but not in the middle.
+	var digits []string
+	for c := 0; c < idDigits; c++ {
+		var filled, faint []int
+		for d := 0; d <= 9; d++ {
+			x, y := StudentBubble(c, d)
+			classify(read(x, y, bubbleRadius), d, &filled, &faint)
+		}
+		switch {
+		case len(filled) == 1 && len(faint) == 0:
+			digits = append(digits, digit(filled[0]))
+		case len(filled) == 0 && len(faint) == 0:
+			digits = append(digits, "")
+		default:
+			digits = append(digits, "?")
+			scan.Review = append(scan.Review, fmt.Sprintf("student number column %d: %s", c+1, describe(filled, faint, digit)))
+		}
+	}
+	for len(digits) > 0 && digits[0] == "" {
+		digits = digits[1:]
+	}
+	for len(digits) > 0 && digits[len(digits)-1] == "" {
+		digits = digits[:len(digits)-1]
+	}
+	for _, d := range digits {
+		if d == "" {
+			scan.Review = append(scan.Review, "student number: a column in the middle is blank")
+			break
+		}
+	}
+	scan.StudentNumber = strings.Join(digits, "")
+	if scan.StudentNumber == "" {
+		scan.Review = append(scan.Review, "student number: no digits filled")
+	}
+
+	var filled, faint []int
+	for i := range Variants {
+		x, y := VariantBubble(i)
+		classify(read(x, y, bubbleRadius), i, &filled, &faint)
+	}
+	switch {
+	case len(filled) == 1 && len(faint) == 0:
+		scan.Variant = Variants[filled[0]]
+	case len(filled) > 0 || len(faint) > 0:
+		scan.Review = append(scan.Review, "variant: "+describe(filled, faint, letter))
+	}
+
+	for r := range scan.Rows {
+		row := &scan.Rows[r]
+		for c := 0; c < columnLetters; c++ {
+			x, y := AnswerBubble(r, c)
+			classify(read(x, y, bubbleRadius), c, &row.Filled, &row.Faint)
+		}
+	}
+	return scan, nil
+}
+
+func classify(share float64, i int, filled, faint *[]int) {
+	switch {
+	case share >= filledShare:
+		*filled = append(*filled, i)
+	case share >= emptyShare:
+		*faint = append(*faint, i)
+	}
+}
+
+func digit(i int) string {
+	return fmt.Sprint(i)
+}
+
+// describe tells what is wrong with a group of bubbles where one should be
+// filled.
+func describe(filled, faint []int, label func(int) string) string {
+	labels := func(list []int) string {
+		var s []string
+		for _, i := range list {
+			s = append(s, label(i))
+		}
+		return strings.Join(s, ", ")
+	}
+	var problems []string
+	if len(filled) > 1 {
+		problems = append(problems, labels(filled)+" all filled")
+	}
+	if len(faint) > 0 {
+		problems = append(problems, labels(faint)+" faint")
+	}
+	return strings.Join(problems, ", ")
+}
+
+// SheetAnswers turns the answer rows of a scan into answers to the choice
+// questions of the exam, with the choices of the printed positions, and
+// flags the rows a person should check: faint marks, and several marks on a
+// single choice question. Faint marks are left out of the answers.
+func (e *Exam) SheetAnswers(scan *SheetScan) ([]quiz.Answer, []string, error) {
+	items, err := e.SheetItems()
+	if err != nil {
+		return nil, nil, err
+	}
+	var answers []quiz.Answer
+	var review []string
+	for r, item := range items {
+		row := scan.Rows[r]
+		question := item.Question
+		answer := quiz.Answer{QuestionID: question.ID}
+		var faint []int
+		for _, c := range row.Filled {
+			if c < len(item.Order) {
+				answer.ChoiceIDs = append(answer.ChoiceIDs, question.Choices[item.Order[c]].ID)
+			}
+		}
+		for _, c := range row.Faint {
+			if c < len(item.Order) {
+				faint = append(faint, c)
+			}
+		}
+		if len(faint) > 0 {
+			review = append(review, fmt.Sprintf("question %d: %s", item.Number, describe(nil, faint, letter)))
+		}
+		if question.Type == quiz.TypeSingleChoice && len(answer.ChoiceIDs) > 1 {
+			review = append(review, fmt.Sprintf("question %d: %s on a single choice question", item.Number, describe(row.Filled, nil, letter)))
+		}
+		answers = append(answers, answer)
+	}
+	return answers, review, nil
+}
+
+// toGray copies the image into grayscale once, as it is read many times.
+func toGray(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok {
+		return gray
+	}
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			gray.Set(x, y, color.GrayModel.Convert(img.At(x, y)))
+		}
+	}
+	return gray
+}
+
+// otsu returns the gray level that best splits the image into ink and
+// paper, by Otsu's method.
+func otsu(gray *image.Gray) uint8 {
+	var histogram [256]float64
+	b := gray.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := gray.Pix[gray.PixOffset(b.Min.X, y):gray.PixOffset(b.Max.X, y)]
+		for _, v := range row {
+			histogram[v]++
+		}
+	}
+	total, sum := float64(b.Dx()*b.Dy()), 0.0
+	for v, n := range histogram {
+		sum += float64(v) * n
+	}
+	var best, bestVariance, dark, darkSum float64
+	for v, n := range histogram {
+		dark += n
+		if dark == 0 {
+			continue
+		}
+		light := total - dark
+		if light == 0 {
+			break
+		}
+		darkSum += float64(v) * n
+		between := dark * light * math.Pow(darkSum/dark-(sum-darkSum)/light, 2)
+		if between > bestVariance {
+			best, bestVariance = float64(v), between
+		}
+	}
+	return uint8(best)
+}
+
+// findMarks finds the centres of the four corner marks in the image: the
+// square dark blobs of about the printed size nearest to each corner.
+func findMarks(gray *image.Gray, threshold uint8) ([4][2]float64, error) {
+	var marks [4][2]float64
+	b := gray.Bounds()
+	side := markSize * float64(b.Dx()) / pdf.A4Width // In pixels, roughly
+
+	w, h := b.Dx()/4, b.Dy()/5
+	regions := [4]image.Rectangle{
+		image.Rect(b.Min.X, b.Min.Y, b.Min.X+w, b.Min.Y+h),
+		image.Rect(b.Max.X-w, b.Min.Y, b.Max.X, b.Min.Y+h),
+		image.Rect(b.Min.X, b.Max.Y-h, b.Min.X+w, b.Max.Y),
+		image.Rect(b.Max.X-w, b.Max.Y-h, b.Max.X, b.Max.Y),
+	}
+	corners := [4]image.Point{b.Min, {b.Max.X, b.Min.Y}, {b.Min.X, b.Max.Y}, b.Max}
+	names := [4]string{"top left", "top right", "bottom left", "bottom right"}
+	for i, region := range regions {
+		best := math.Inf(1)
+		for _, blob := range blobs(gray, threshold, region) {
+			bw, bh := float64(blob.box.Dx()), float64(blob.box.Dy())
+			area := float64(blob.pixels)
+			if bw < side*0.6 || bw > side*1.6 || bh < side*0.6 || bh > side*1.6 || area < bw*bh*0.7 {
+				continue
+			}
+			dx, dy := blob.x-float64(corners[i].X), blob.y-float64(corners[i].Y)
+			if d := math.Hypot(dx, dy); d < best {
+				best, marks[i] = d, [2]float64{blob.x, blob.y}
+			}
+		}
+		if math.IsInf(best, 1) {
+			return marks, fmt.Errorf("cannot find the %s corner mark, scan the whole sheet", names[i])
+		}
+	}
+	return marks, nil
+}
+
+// blob is a connected area of dark pixels.
+type blob struct {
+	box    image.Rectangle
+	pixels int
+	x, y   float64 // Centre
+}
+
+// blobs returns the connected dark areas within the region.
+func blobs(gray *image.Gray, threshold uint8, region image.Rectangle) []blob {
+	seen := make([]bool, region.Dx()*region.Dy())
+	index := func(p image.Point) int {
+		return (p.Y-region.Min.Y)*region.Dx() + p.X - region.Min.X
+	}
+	dark := func(p image.Point) bool {
+		return p.In(region) && !seen[index(p)] && gray.GrayAt(p.X, p.Y).Y <= threshold
+	}
+	var found []blob
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			start := image.Pt(x, y)
+			if !dark(start) {
+				continue
+			}
+			b := blob{box: image.Rectangle{Min: start, Max: start.Add(image.Pt(1, 1))}}
+			var sumX, sumY float64
+			stack := []image.Point{start}
+			seen[index(start)] = true
+			for len(stack) > 0 {
+				p := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				b.pixels++
+				sumX += float64(p.X)
+				sumY += float64(p.Y)
+				b.box = b.box.Union(image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))})
+				for _, n := range []image.Point{{p.X + 1, p.Y}, {p.X - 1, p.Y}, {p.X, p.Y + 1}, {p.X, p.Y - 1}} {
+					if dark(n) {
+						seen[index(n)] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+			b.x, b.y = sumX/float64(b.pixels)+0.5, sumY/float64(b.pixels)+0.5
+			found = append(found, b)
+		}
+	}
+	return found
+}
+
+// darkShare returns the share of dark pixels within the inner part of the
+// bubble of radius r at x, y on the sheet, leaving out its printed outline.
+func darkShare(gray *image.Gray, threshold uint8, h [9]float64, x, y, r float64) float64 {
+	const step = 0.5 // Points between samples
+	inner := r * 0.65
+	dark, total := 0, 0
+	for dy := -inner; dy <= inner; dy += step {
+		for dx := -inner; dx <= inner; dx += step {
+			if dx*dx+dy*dy > inner*inner {
+				continue
+			}
+			px, py := project(h, x+dx, y+dy)
+			p := image.Pt(int(px), int(py))
+			if !p.In(gray.Bounds()) {
+				continue
+			}
+			total++
+			if gray.GrayAt(p.X, p.Y).Y <= threshold {
+				dark++
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(dark) / float64(total)
+}
+
+// homography returns the projective transform that maps the four points
+// from onto the four points to.
+func homography(from, to [4][2]float64) ([9]float64, error) {
+	// Each pair gives two rows of the linear system for the eight unknowns
+	// h0..h7, with h8 fixed to 1.
+	var m [8][9]float64
+	for i := 0; i < 4; i++ {
+		x, y, u, v := from[i][0], from[i][1], to[i][0], to[i][1]
+		m[2*i] = [9]float64{x, y, 1, 0, 0, 0, -u * x, -u * y, u}
+		m[2*i+1] = [9]float64{0, 0, 0, x, y, 1, -v * x, -v * y, v}
+	}
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-9 {
+			return [9]float64{}, errors.New("the corner marks do not form a sheet, scan the whole sheet")
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := m[row][col] / m[col][col]
+			for k := col; k < 9; k++ {
+				m[row][k] -= f * m[col][k]
+			}
+		}
+	}
+	var h [9]float64
+	for i := 0; i < 8; i++ {
+		h[i] = m[i][8] / m[i][i]
+	}
+	h[8] = 1
+	return h, nil
+}
+
+// project maps a point on the sheet to the image.
+func project(h [9]float64, x, y float64) (float64, float64) {
+	w := h[6]*x + h[7]*y + h[8]
+	return (h[0]*x + h[1]*y + h[2]) / w, (h[3]*x + h[4]*y + h[5]) / w
+}
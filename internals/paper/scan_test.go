@@ -0,0 +1,174 @@
+package paper_test
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/paper"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scanImage fakes a scan of a sheet at 2 pixels per point, turned by a
+// degree and shifted on the scanner glass.
+type scanImage struct {
+	*image.Gray
+}
+
+func newScanImage() *scanImage {
+	img := image.NewGray(image.Rect(0, 0, 1250, 1750))
+	for i := range img.Pix {
+		img.Pix[i] = 245
+	}
+	return &scanImage{img}
+}
+
+func (s *scanImage) pixel(x, y float64) (int, int) {
+	const angle = math.Pi / 180
+	px := 2 * (x*math.Cos(angle) - y*math.Sin(angle))
+	py := 2 * (x*math.Sin(angle) + y*math.Cos(angle))
+	return int(px + 24), int(py + 16)
+}
+
+// fill inks the points of the sheet within the shape.
+func (s *scanImage) fill(x0, y0, x1, y1 float64, inside func(x, y float64) bool, level uint8) {
+	for y := y0; y <= y1; y += 0.25 {
+		for x := x0; x <= x1; x += 0.25 {
+			if inside(x, y) {
+				px, py := s.pixel(x, y)
+				s.SetGray(px, py, color.Gray{Y: level})
+			}
+		}
+	}
+}
+
+func (s *scanImage) disc(cx, cy, r float64) {
+	s.fill(cx-r, cy-r, cx+r, cy+r, func(x, y float64) bool { return math.Hypot(x-cx, y-cy) <= r }, 30)
+}
+
+func (s *scanImage) ring(cx, cy, r float64) {
+	s.fill(cx-r-1, cy-r-1, cx+r+1, cy+r+1, func(x, y float64) bool {
+		d := math.Hypot(x-cx, y-cy)
+		return d >= r-0.4 && d <= r+0.4
+	}, 60)
+}
+
+func TestScanSheet(t *testing.T) {
+	exam, err := paper.NewExam(examQuiz(), "B")
+	require.NoError(t, err)
+	items, err := exam.SheetItems()
+	require.NoError(t, err)
+
+	img := newScanImage()
+	for _, c := range paper.MarkCentres() {
+		img.fill(c[0]-7, c[1]-7, c[0]+7, c[1]+7, func(x, y float64) bool { return true }, 20)
+	}
+	// Print run 5 and the pre-filled variant B
+	for bit := 0; bit < 16; bit++ {
+		x, y := paper.CodeBubble(bit)
+		if bit == 0 || bit == 2 {
+			img.disc(x, y, 4)
+		} else {
+			img.ring(x, y, 4)
+		}
+	}
+	x, y := paper.VariantBubble(1)
+	img.disc(x, y, 6)
+
+	// Student number 0417 in columns 2 to 5, with empty bubbles drawn.
+	for c := 0; c < 8; c++ {
+		for d := 0; d <= 9; d++ {
+			x, y := paper.StudentBubble(c, d)
+			img.ring(x, y, 6)
+		}
+	}
+	for c, d := range []int{0, 4, 1, 7} {
+		x, y := paper.StudentBubble(c+2, d)
+		img.disc(x, y, 5.5)
+	}
+
+	// The single choice question has the right choice filled and another
+	// faint, the multiple choice question two choices filled.
+	single, multi := 0, 1
+	if items[0].Question.Type == quiz.TypeMultiChoice {
+		single, multi = 1, 0
+	}
+	var paris, other int
+	for n, i := range items[single].Order {
+		if items[single].Question.Choices[i].Content == "Paris" {
+			paris = n
+		} else {
+			other = n
+		}
+	}
+	x, y = paper.AnswerBubble(single, paris)
+	img.disc(x, y, 5.5)
+	x, y = paper.AnswerBubble(single, other)
+	img.disc(x, y, 2.4)
+	for _, c := range []int{0, 2} {
+		x, y := paper.AnswerBubble(multi, c)
+		img.disc(x, y, 5.5)
+	}
+
+	scan, err := paper.ScanSheet(img)
+	require.NoError(t, err)
+	assert.Equal(t, uint(5), scan.Printing)
+	assert.Equal(t, "0417", scan.StudentNumber)
+	assert.Equal(t, "B", scan.Variant)
+	assert.Empty(t, scan.Review)
+	assert.Equal(t, []int{paris}, scan.Rows[single].Filled)
+	assert.Equal(t, []int{other}, scan.Rows[single].Faint)
+	assert.Equal(t, []int{0, 2}, scan.Rows[multi].Filled)
+	assert.Empty(t, scan.Rows[2].Filled)
+
+	answers, review, err := exam.SheetAnswers(scan)
+	require.NoError(t, err)
+	require.Len(t, answers, 2)
+	assert.Equal(t, items[single].Question.ID, answers[single].QuestionID)
+	assert.Len(t, answers[single].ChoiceIDs, 1)
+	assert.Len(t, answers[multi].ChoiceIDs, 2)
+	assert.Equal(t, []string{fmt.Sprintf("question %d: %c faint", items[single].Number, 'A'+other)}, review)
+}
+
+func TestScanSheet_Problems(t *testing.T) {
+	_, err := paper.ScanSheet(newScanImage())
+	assert.EqualError(t, err, "cannot find the top left corner mark, scan the whole sheet")
+
+	img := newScanImage()
+	for _, c := range paper.MarkCentres() {
+		img.fill(c[0]-7, c[1]-7, c[0]+7, c[1]+7, func(x, y float64) bool { return true }, 20)
+	}
+	for _, d := range []int{3, 5} {
+		x, y := paper.StudentBubble(0, d)
+		img.disc(x, y, 5.5)
+	}
+	x, y := paper.StudentBubble(2, 1)
+	img.disc(x, y, 5.5)
+	for i := range paper.Variants {
+		x, y := paper.VariantBubble(i)
+		img.disc(x, y, 5.5)
+	}
+	scan, err := paper.ScanSheet(img)
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), scan.Printing)
+	assert.Equal(t, "", scan.Variant)
+	assert.Equal(t, []string{
+		"student number column 1: 3, 5 all filled",
+		"student number: a column in the middle is blank",
+		"variant: A, B, C all filled",
+	}, scan.Review)
+
+	// Several marks on a single choice question are flagged.
+	exam, err := paper.NewExam(&quiz.Quiz{Questions: []quiz.Question{{Type: quiz.TypeSingleChoice, Content: "Q",
+		Choices: []quiz.Choice{{ID: 1, Content: "yes", IsCorrect: true}, {ID: 2, Content: "no"}}}}}, "")
+	require.NoError(t, err)
+	scan.Rows[0].Filled = []int{0, 1}
+	answers, review, err := exam.SheetAnswers(scan)
+	require.NoError(t, err)
+	assert.Equal(t, quiz.UintList{1, 2}, answers[0].ChoiceIDs)
+	assert.Equal(t, []string{"question 1: A, B all filled on a single choice question"}, review)
+}
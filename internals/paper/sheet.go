@@ -0,0 +1,175 @@
+package paper
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mbsof31/go-quiz/internals/pdf"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// Bubble sheet layout, in points from the top left of the page. Scans are
+// mapped onto it through the four corner marks.
+const (
+	markSize     = 14.0
+	markInset    = 36.0 // From the page edges to the centre of the marks
+	bubbleRadius = 6.0
+	bubbleStep   = 16.0 // Between the bubbles of a row
+	rowStep      = 17.0 // Between rows
+
+	idDigits = 8 // Columns of the student number
+	idLeft   = 96.0
+	idTop    = 166.0 // Centre of the digit 0 row
+
+	variantLeft = 330.0
+	variantTop  = idTop
+
+	answerTop     = 370.0 // Centre of the first answer row
+	answerLeft    = 84.0  // Centre of the first bubble of the first column
+	columnStep    = 166.0
+	sheetColumns  = 3
+	sheetRows     = 25
+	columnLetters = 8
+
+	codeBits   = 16
+	codeLeft   = 176.0
+	codeRadius = 4.0
+)
+
+// MaxSheetQuestions and MaxSheetChoices bound the choice questions a bubble
+// sheet has room for.
+const (
+	MaxSheetQuestions = sheetColumns * sheetRows
+	MaxSheetChoices   = columnLetters
+)
+
+// MarkCentres returns the centres of the corner marks: top left, top right,
+// bottom left and bottom right.
+func MarkCentres() [4][2]float64 {
+	right, bottom := pdf.A4Width-markInset, pdf.A4Height-markInset
+	return [4][2]float64{{markInset, markInset}, {right, markInset}, {markInset, bottom}, {right, bottom}}
+}
+
+// StudentBubble returns the centre of the bubble of digit d in column c of
+// the student number.
+func StudentBubble(c, d int) (x, y float64) {
+	return idLeft + float64(c)*bubbleStep, idTop + float64(d)*rowStep
+}
+
+// VariantBubble returns the centre of the bubble of Variants[i].
+func VariantBubble(i int) (x, y float64) {
+	return variantLeft + float64(i)*bubbleStep, variantTop
+}
+
+// AnswerBubble returns the centre of the bubble of choice c in row r of the
+// answers, counted down the columns.
+func AnswerBubble(r, c int) (x, y float64) {
+	column, row := r/sheetRows, r%sheetRows
+	return answerLeft + float64(column)*columnStep + float64(c)*bubbleStep, answerTop + float64(row)*rowStep
+}
+
+// CodeBubble returns the centre of bit i of the print run code, printed
+// along the bottom edge with the lowest bit first.
+func CodeBubble(i int) (x, y float64) {
+	return codeLeft + float64(i)*bubbleStep, pdf.A4Height - markInset
+}
+
+// SheetItems returns the items answered on the bubble sheet: the single and
+// multiple choice questions, in the printed order.
+func (e *Exam) SheetItems() ([]Item, error) {
+	var items []Item
+	for _, item := range e.Items {
+		switch item.Question.Type {
+		case quiz.TypeSingleChoice, quiz.TypeMultiChoice:
+		default:
+			continue
+		}
+		if len(item.Question.Choices) > MaxSheetChoices {
+			return nil, fmt.Errorf("question %d has %d choices, a bubble sheet has room for %d", item.Number, len(item.Question.Choices), MaxSheetChoices)
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("the quiz has no choice questions to answer on a bubble sheet")
+	}
+	if len(items) > MaxSheetQuestions {
+		return nil, fmt.Errorf("the quiz has %d choice questions, a bubble sheet has room for %d", len(items), MaxSheetQuestions)
+	}
+	return items, nil
+}
+
+// WriteSheet writes the bubble sheet of the exam: the student number, the
+// variant, pre-filled when the exam has one, and a row of bubbles for every
+// choice question, labelled with its number on the paper. Other questions
+// are answered on the paper itself. The Printing of the exam is written as
+// a code of filled bubbles along the bottom edge, for ScanSheet to read.
+func (e *Exam) WriteSheet(w io.Writer) error {
+	items, err := e.SheetItems()
+	if err != nil {
+		return err
+	}
+	if e.Printing >= 1<<codeBits {
+		return fmt.Errorf("print run %d does not fit the %d bit sheet code", e.Printing, codeBits)
+	}
+	doc := pdf.New("Answer sheet: " + e.Title())
+	page := doc.AddPage()
+	for _, c := range MarkCentres() {
+		page.Rect(c[0]-markSize/2, c[1]-markSize/2, markSize, markSize, 0)
+	}
+
+	page.Text(64, 70, pdf.HelveticaBold, 14, "Answer sheet: "+e.Title())
+	page.Text(64, 96, pdf.Helvetica, 10, "Name: ________________________________________")
+	page.Gray(0.4)
+	page.Text(64, 114, pdf.Helvetica, 8, "Fill the bubbles completely with a dark pen. Fill one bubble per digit of your student number.")
+	page.Gray(0)
+
+	page.Text(idLeft-bubbleRadius, idTop-32, pdf.HelveticaBold, 9, "Student number")
+	for c := 0; c < idDigits; c++ {
+		x, y := StudentBubble(c, 0)
+		page.Rect(x-bubbleRadius-1, y-bubbleStep-10, 2*bubbleRadius+2, 14, 0.6)
+		for d := 0; d <= 9; d++ {
+			x, y = StudentBubble(c, d)
+			bubble(page, x, y, fmt.Sprint(d), false)
+		}
+	}
+
+	page.Text(variantLeft-bubbleRadius, variantTop-32, pdf.HelveticaBold, 9, "Variant")
+	for i, v := range Variants {
+		x, y := VariantBubble(i)
+		bubble(page, x, y, v, v == e.Variant)
+	}
+
+	for r, item := range items {
+		x, y := AnswerBubble(r, 0)
+		number := fmt.Sprint(item.Number)
+		page.Text(x-bubbleRadius-4-pdf.Width(pdf.HelveticaBold, 9, number), y+3, pdf.HelveticaBold, 9, number)
+		for c := range item.Question.Choices {
+			x, y := AnswerBubble(r, c)
+			bubble(page, x, y, letter(c), false)
+		}
+	}
+
+	for i := 0; i < codeBits; i++ {
+		x, y := CodeBubble(i)
+		if e.Printing&(1<<i) != 0 {
+			page.Circle(x, y, codeRadius, 0)
+		} else {
+			page.Circle(x, y, codeRadius, 0.6)
+		}
+	}
+	_, err = doc.WriteTo(w)
+	return err
+}
+
+// bubble draws a bubble with its label in light gray inside, filled when
+// marked.
+func bubble(page *pdf.Page, x, y float64, label string, marked bool) {
+	if marked {
+		page.Circle(x, y, bubbleRadius, 0)
+		return
+	}
+	page.Circle(x, y, bubbleRadius, 0.6)
+	page.Gray(0.6)
+	page.Text(x-pdf.Width(pdf.Helvetica, 6, label)/2, y+2.1, pdf.Helvetica, 6, label)
+	page.Gray(0)
+}
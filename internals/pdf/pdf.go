@@ -0,0 +1,350 @@
+// Package pdf writes simple PDF documents: text in the standard Helvetica
+// and Courier fonts, lines, rectangles, circles and images.
+//
+// The standard fonts need no font files, which keeps the package free of
+// dependencies, but they only cover the Windows-1252 character set; other
+// characters are printed as "?". Coordinates are in points from the top
+// left corner of the page, as in images, and text is placed by its baseline.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// A4 page size in points.
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Font is one of the standard fonts every PDF reader has.
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+	Courier
+)
+
+var fontNames = []string{"Helvetica", "Helvetica-Bold", "Courier"}
+
+// Document is a PDF document of A4 pages.
+type Document struct {
+	Title  string
+	pages  []*Page
+	images []image.Image
+}
+
+// Page is a page of a document, drawn on in the order of the calls.
+type Page struct {
+	doc     *Document
+	content bytes.Buffer
+	images  []int
+}
+
+// New returns an empty document.
+func New(title string) *Document {
+	return &Document{Title: title}
+}
+
+// AddPage appends a blank page.
+func (d *Document) AddPage() *Page {
+	p := &Page{doc: d}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Pages returns the number of pages.
+func (d *Document) Pages() int {
+	return len(d.pages)
+}
+
+// y turns a distance from the top of the page into a PDF coordinate, which
+// counts from the bottom.
+func y(top float64) float64 {
+	return A4Height - top
+}
+
+// Gray sets the colour of the following text and shapes, from 0 for black
+// to 1 for white.
+func (p *Page) Gray(level float64) {
+	fmt.Fprintf(&p.content, "%s g %s G\n", num(level), num(level))
+}
+
+// Text writes s with its baseline at top.
+func (p *Page) Text(x, top float64, font Font, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /F%d %s Tf %s %s Td (%s) Tj ET\n", font+1, num(size), num(x), num(y(top)), escape(encode(s)))
+}
+
+// Line draws a line of the width.
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n", num(width), num(x1), num(y(y1)), num(x2), num(y(y2)))
+}
+
+// Rect draws the outline of a rectangle, or fills it when width is zero.
+func (p *Page) Rect(x, top, w, h, width float64) {
+	if width == 0 {
+		fmt.Fprintf(&p.content, "%s %s %s %s re f\n", num(x), num(y(top+h)), num(w), num(h))
+		return
+	}
+	fmt.Fprintf(&p.content, "%s w %s %s %s %s re S\n", num(width), num(x), num(y(top+h)), num(w), num(h))
+}
+
+// Circle draws the outline of a circle, or fills it when width is zero.
+func (p *Page) Circle(cx, cy, r, width float64) {
+	// Four Bézier curves with control points at k·r approximate a circle.
+	const k = 0.5523
+	cy = y(cy)
+	c := &p.content
+	if width > 0 {
+		fmt.Fprintf(c, "%s w ", num(width))
+	}
+	fmt.Fprintf(c, "%s %s m ", num(cx+r), num(cy))
+	fmt.Fprintf(c, "%s %s %s %s %s %s c ", num(cx+r), num(cy+k*r), num(cx+k*r), num(cy+r), num(cx), num(cy+r))
+	fmt.Fprintf(c, "%s %s %s %s %s %s c ", num(cx-k*r), num(cy+r), num(cx-r), num(cy+k*r), num(cx-r), num(cy))
+	fmt.Fprintf(c, "%s %s %s %s %s %s c ", num(cx-r), num(cy-k*r), num(cx-k*r), num(cy-r), num(cx), num(cy-r))
+	fmt.Fprintf(c, "%s %s %s %s %s %s c ", num(cx+k*r), num(cy-r), num(cx+r), num(cy-k*r), num(cx+r), num(cy))
+	if width > 0 {
+		c.WriteString("S\n")
+	} else {
+		c.WriteString("f\n")
+	}
+}
+
+// Image draws img scaled into the w by h box at x, top.
+func (p *Page) Image(img image.Image, x, top, w, h float64) {
+	p.doc.images = append(p.doc.images, img)
+	i := len(p.doc.images) - 1
+	p.images = append(p.images, i)
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /Im%d Do Q\n", num(w), num(h), num(x), num(y(top+h)), i+1)
+}
+
+// Width returns the width of s in the font, in points.
+func Width(font Font, size float64, s string) float64 {
+	total := 0
+	for _, r := range s {
+		total += glyphWidth(font, r)
+	}
+	return float64(total) * size / 1000
+}
+
+// Wrap breaks text into lines no wider than width, at spaces where it can.
+// Newlines in text start new lines.
+func Wrap(font Font, size float64, text string, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line == "" || Width(font, size, candidate) <= width {
+				line = candidate
+			} else {
+				lines = append(lines, line)
+				line = word
+			}
+			// Break words longer than the line.
+			for Width(font, size, line) > width && len([]rune(line)) > 1 {
+				runes := []rune(line)
+				cut := len(runes) - 1
+				for cut > 1 && Width(font, size, string(runes[:cut])) > width {
+					cut--
+				}
+				lines = append(lines, string(runes[:cut]))
+				line = string(runes[cut:])
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// WriteTo writes the document as a PDF file.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	pdf := &writer{}
+	pdf.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1 and 2 are the catalog and the page tree, then come the
+	// fonts, the images and, for each page, the page and its content.
+	const catalog, tree = 1, 2
+	fonts := 3
+	images := fonts + len(fontNames)
+	pages := images + len(d.images)
+
+	pdf.object(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", tree))
+	var kids []string
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", pages+2*i))
+	}
+	pdf.object(tree, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	for i, name := range fontNames {
+		pdf.object(fonts+i, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+	for i, img := range d.images {
+		bounds := img.Bounds()
+		rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+		for yy := bounds.Min.Y; yy < bounds.Max.Y; yy++ {
+			for xx := bounds.Min.X; xx < bounds.Max.X; xx++ {
+				r, g, b, a := img.At(xx, yy).RGBA()
+				// Transparent pixels are printed on white paper.
+				white := 0xffff - a
+				rgb = append(rgb, byte((r+white)>>8), byte((g+white)>>8), byte((b+white)>>8))
+			}
+		}
+		pdf.stream(images+i, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8",
+			bounds.Dx(), bounds.Dy()), rgb)
+	}
+	var fontRefs []string
+	for i := range fontNames {
+		fontRefs = append(fontRefs, fmt.Sprintf("/F%d %d 0 R", i+1, fonts+i))
+	}
+	for i, page := range d.pages {
+		var xobjects []string
+		for _, img := range page.images {
+			xobjects = append(xobjects, fmt.Sprintf("/Im%d %d 0 R", img+1, images+img))
+		}
+		resources := fmt.Sprintf("/Font << %s >>", strings.Join(fontRefs, " "))
+		if len(xobjects) > 0 {
+			resources += fmt.Sprintf(" /XObject << %s >>", strings.Join(xobjects, " "))
+		}
+		pdf.object(pages+2*i, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << %s >> /Contents %d 0 R >>",
+			tree, num(A4Width), num(A4Height), resources, pages+2*i+1))
+		pdf.stream(pages+2*i+1, "", page.content.Bytes())
+	}
+	info := pages + 2*len(d.pages)
+	pdf.object(info, fmt.Sprintf("<< /Title (%s) /Producer (go-quiz) >>", escape(encode(d.Title))))
+
+	xref := pdf.buf.Len()
+	fmt.Fprintf(&pdf.buf, "xref\n0 %d\n0000000000 65535 f \n", info+1)
+	for _, offset := range pdf.offsets {
+		fmt.Fprintf(&pdf.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf.buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", info+1, catalog, info, xref)
+	return pdf.buf.WriteTo(w)
+}
+
+// writer numbers the objects of a PDF file and records their offsets for
+// the cross-reference table. Objects must be written in order.
+type writer struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (w *writer) object(n int, body string) {
+	w.start(n)
+	fmt.Fprintf(&w.buf, "%s\nendobj\n", body)
+}
+
+// stream writes a Flate-compressed stream object with the extra dictionary
+// entries.
+func (w *writer) stream(n int, dict string, data []byte) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+	w.start(n)
+	fmt.Fprintf(&w.buf, "<< %s /Length %d /Filter /FlateDecode >>\nstream\n", strings.TrimSpace(dict), compressed.Len())
+	w.buf.Write(compressed.Bytes())
+	w.buf.WriteString("\nendstream\nendobj\n")
+}
+
+func (w *writer) start(n int) {
+	if n != len(w.offsets)+1 {
+		panic(fmt.Sprintf("pdf: object %d written out of order", n))
+	}
+	w.offsets = append(w.offsets, w.buf.Len())
+	fmt.Fprintf(&w.buf, "%d 0 obj\n", n)
+}
+
+// num formats a coordinate without needless digits.
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// escape escapes a PDF string literal.
+func escape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// winAnsi maps the characters of Windows-1252 outside Latin-1 to their code.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b,
+	'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// encode converts s to Windows-1252, replacing what it lacks with "?".
+func encode(s string) string {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 0x80 && (r >= 0x20 || r == '\t'):
+			b = append(b, byte(r))
+		case r >= 0xa0 && r <= 0xff:
+			b = append(b, byte(r))
+		case winAnsi[r] != 0:
+			b = append(b, winAnsi[r])
+		default:
+			b = append(b, '?')
+		}
+	}
+	return string(b)
+}
+
+// Advance widths of the printable ASCII characters, from space to tilde, in
+// thousandths of the font size, as published in the font metrics of the
+// standard fonts.
+var (
+	helveticaWidths = []int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = []int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// glyphWidth returns the width of a character. Outside ASCII it guesses
+// from the letter case, which is close enough to wrap lines.
+func glyphWidth(font Font, r rune) int {
+	if font == Courier {
+		return 600
+	}
+	widths := helveticaWidths
+	if font == HelveticaBold {
+		widths = helveticaBoldWidths
+	}
+	switch {
+	case r >= ' ' && r <= '~':
+		return widths[r-' ']
+	case unicode.IsUpper(r):
+		return 722
+	case unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 556
+	}
+	return 500
+}
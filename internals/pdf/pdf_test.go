@@ -0,0 +1,78 @@
+package pdf_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/pdf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocument_WriteTo(t *testing.T) {
+	doc := pdf.New("Exam (draft)")
+	page := doc.AddPage()
+	page.Text(56, 80, pdf.HelveticaBold, 14, "Hello (world) – café ✓")
+	page.Circle(100, 100, 6, 0.8)
+	page.Rect(56, 120, 100, 20, 0)
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	page.Image(img, 56, 150, 20, 10)
+	doc.AddPage().Line(0, 0, 10, 10, 1)
+	assert.Equal(t, 2, doc.Pages())
+
+	var buf bytes.Buffer
+	_, err := doc.WriteTo(&buf)
+	require.NoError(t, err)
+	data := buf.Bytes()
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+	assert.Contains(t, buf.String(), "/Title (Exam \\(draft\\))")
+	assert.Contains(t, buf.String(), "/Count 2")
+
+	// Every entry of the cross-reference table points at its object.
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	require.NotNil(t, startxref)
+	offset, _ := strconv.Atoi(string(startxref[1]))
+	require.True(t, bytes.HasPrefix(data[offset:], []byte("xref\n")))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[offset:], -1)
+	require.NotEmpty(t, entries)
+	for i, entry := range entries {
+		at, _ := strconv.Atoi(string(entry[1]))
+		assert.True(t, bytes.HasPrefix(data[at:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+
+	// The first page draws the text in Windows-1252.
+	var streams []string
+	for _, m := range regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`).FindAllSubmatch(data, -1) {
+		zr, err := zlib.NewReader(bytes.NewReader(m[1]))
+		require.NoError(t, err)
+		inflated, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		streams = append(streams, string(inflated))
+	}
+	require.Len(t, streams, 3) // The image and two pages
+	assert.Equal(t, "\xff\x00\x00\xff\xff\xff", streams[0])
+	assert.Contains(t, streams[1], "BT /F2 14 Tf 56 761.89 Td (Hello \\(world\\) \x96 caf\xe9 ?) Tj ET")
+	assert.Contains(t, streams[1], "/Im1 Do")
+	assert.Equal(t, "1 w 0 841.89 m 10 831.89 l S\n", streams[2])
+}
+
+func TestWrap(t *testing.T) {
+	lines := pdf.Wrap(pdf.Helvetica, 10, "The quick brown fox jumps\nover  the lazy dog", 60)
+	assert.Equal(t, []string{"The quick", "brown fox", "jumps", "over the lazy", "dog"}, lines)
+	for _, line := range lines {
+		assert.LessOrEqual(t, pdf.Width(pdf.Helvetica, 10, line), 60.0)
+	}
+	assert.Equal(t, []string{"abcdefghij", "klmn"}, pdf.Wrap(pdf.Courier, 10, "abcdefghijklmn", 60))
+	assert.Equal(t, []string{"", "x"}, pdf.Wrap(pdf.Helvetica, 10, "\nx", 60))
+	assert.Equal(t, 6.0, pdf.Width(pdf.Courier, 10, strings.Repeat("a", 1)))
+}
@@ -0,0 +1,70 @@
+package quiz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrPlayLimit = errors.New("play limit reached")
+
+// playGrace is how long after a play starts that requests for the start of
+// the recording still count as the same play. Players probe and re-request
+// the first bytes while they buffer.
+const playGrace = 5 * time.Second
+
+// Play counts how often a question recording was played in an attempt.
+type Play struct {
+	ID         uint      `gorm:"primaryKey"`
+	AttemptID  uint      `gorm:"uniqueIndex:idx_play" json:"attempt_id"`
+	QuestionID uint      `gorm:"uniqueIndex:idx_play" json:"question_id"`
+	Count      int       `json:"count"`
+	StartedAt  time.Time `json:"started_at"` // Of the latest play
+}
+
+// HasMedia tells whether the question carries an audio or video recording.
+func (q *Question) HasMedia() bool {
+	return q.MediaKey != ""
+}
+
+func (q *Question) IsVideo() bool {
+	return strings.HasPrefix(q.MediaType, "video/")
+}
+
+// ValidateCaptions checks that captions are a WebVTT file.
+func ValidateCaptions(captions string) error {
+	if captions == "" {
+		return nil
+	}
+	header, _, _ := strings.Cut(strings.TrimPrefix(captions, "\ufeff"), "\n")
+	header = strings.TrimSuffix(header, "\r")
+	if header != "WEBVTT" && !strings.HasPrefix(header, "WEBVTT ") && !strings.HasPrefix(header, "WEBVTT\t") {
+		return fmt.Errorf("captions must be WebVTT, starting with a WEBVTT line")
+	}
+	return nil
+}
+
+// PlaysLeft returns how often the question recording can still be played in
+// the attempt, or -1 when there is no limit.
+func (a *Attempt) PlaysLeft(question *Question) int {
+	if question.MaxPlays <= 0 {
+		return -1
+	}
+	for _, play := range a.Plays {
+		if play.QuestionID == question.ID {
+			return max(question.MaxPlays-play.Count, 0)
+		}
+	}
+	return question.MaxPlays
+}
+
+// HasPlayed tells whether the question recording was played in the attempt.
+func (a *Attempt) HasPlayed(question *Question) bool {
+	for _, play := range a.Plays {
+		if play.QuestionID == question.ID && play.Count > 0 {
+			return true
+		}
+	}
+	return false
+}
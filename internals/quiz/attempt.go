@@ -0,0 +1,235 @@
+package quiz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GradingPolicy decides which of a user's submitted attempts counts as the
+// official score for a quiz.
+type GradingPolicy string
+
+const (
+	GradeHighest GradingPolicy = "highest"
+	GradeLatest  GradingPolicy = "latest"
+	GradeAverage GradingPolicy = "average"
+	GradeFirst   GradingPolicy = "first"
+)
+
+// OrDefault returns the policy, falling back to GradeHighest when unset.
+func (p GradingPolicy) OrDefault() GradingPolicy {
+	if p == "" {
+		return GradeHighest
+	}
+	return p
+}
+
+var (
+	ErrAttemptLimitReached = errors.New("attempt limit reached")
+	ErrAttemptCooldown     = errors.New("attempt cooldown has not elapsed")
+	ErrAttemptInProgress   = errors.New("an attempt is already in progress")
+	ErrAttemptSubmitted    = errors.New("attempt has already been submitted")
+	ErrAnswerChecked       = errors.New("answer has already been checked")
+	ErrAnswerReviewed      = errors.New("answer has already been graded")
+)
+
+type Attempt struct {
+	ID          uint       `gorm:"primaryKey"`
+	QuizID      uint       `gorm:"index" json:"quiz_id"`
+	UserID      uint       `gorm:"index" json:"user_id"`
+	StartedAt   time.Time  `json:"started_at"`
+	SubmittedAt *time.Time `json:"submitted_at,omitempty"`
+	Score       float64    `json:"score"`
+	MaxScore    float64    `json:"max_score"`
+	// PendingReview is set while manually graded answers await a grader.
+	PendingReview bool     `gorm:"index" json:"pending_review,omitempty"`
+	Answers       []Answer `gorm:"foreignKey:AttemptID" json:"answers,omitempty"`
+	Plays         []Play   `gorm:"foreignKey:AttemptID" json:"plays,omitempty"`
+	// Paper attempts are read from a bubble sheet of the print run
+	// PrintingID. ScanReview lists the marks a person should check against
+	// the sheet.
+	PrintingID    uint       `gorm:"index" json:"printing_id,omitempty"`
+	StudentNumber string     `json:"student_number,omitempty"`
+	ScanReview    StringList `gorm:"type:json" json:"scan_review,omitempty"`
+}
+
+type Answer struct {
+	ID         uint     `gorm:"primaryKey"`
+	AttemptID  uint     `gorm:"index" json:"attempt_id"`
+	QuestionID uint     `gorm:"index" json:"question_id"`
+	ChoiceIDs  UintList `gorm:"type:json" json:"choice_ids,omitempty" form:"choice_ids,omitempty"`
+	Text       string   `json:"text,omitempty" form:"text,omitempty"`
+	// Items holds the item order of an ordering answer, or the picked
+	// option for every prompt of a matching answer.
+	Items  IntList `gorm:"type:json" json:"items,omitempty" form:"items,omitempty"`
+	Rating int     `json:"rating,omitempty" form:"rating,omitempty"` // From 1, zero when not rated
+	// Blanks holds the text typed into each blank of a cloze answer.
+	Blanks StringList `gorm:"type:json" json:"blanks,omitempty" form:"blanks,omitempty"`
+	// TestsPassed, TestsTotal and Output record the test run of a code answer,
+	// whose source is in Text.
+	TestsPassed int     `json:"tests_passed,omitempty"`
+	TestsTotal  int     `json:"tests_total,omitempty"`
+	Output      string  `json:"output,omitempty"`
+	Score       float64 `json:"score"`
+	MaxScore    float64 `json:"max_score"`
+	// Pending answers wait for a grader, who leaves rubric scores and a comment.
+	Pending      bool       `gorm:"index" json:"pending,omitempty"`
+	RubricScores FloatList  `gorm:"type:json" json:"rubric_scores,omitempty"`
+	Comment      string     `json:"comment,omitempty"`
+	GraderID     uint       `json:"grader_id,omitempty"`
+	GradedAt     *time.Time `json:"graded_at,omitempty"`
+}
+
+// Participation records that a user responded to a survey, whose response
+// is stored without the user. It holds the attempt limit and cooldown of
+// the survey.
+type Participation struct {
+	ID          uint      `gorm:"primaryKey"`
+	QuizID      uint      `gorm:"index" json:"quiz_id"`
+	UserID      uint      `gorm:"index" json:"user_id"`
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+func NewAttempt(quizID, userID uint, now time.Time) *Attempt {
+	return &Attempt{
+		QuizID:    quizID,
+		UserID:    userID,
+		StartedAt: now,
+		Answers:   []Answer{},
+	}
+}
+
+// Empty reports whether the question was left blank.
+func (a *Answer) Empty() bool {
+	if len(a.ChoiceIDs) > 0 || strings.TrimSpace(a.Text) != "" || a.Rating > 0 {
+		return false
+	}
+	for _, item := range a.Items {
+		if item >= 0 {
+			return false
+		}
+	}
+	for _, blank := range a.Blanks {
+		if strings.TrimSpace(blank) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Submitted reports whether the attempt has been handed in.
+func (a *Attempt) Submitted() bool {
+	return a.SubmittedAt != nil
+}
+
+// RefreshScore recomputes the attempt score from its answers after a
+// manually graded answer changed.
+func (a *Attempt) RefreshScore() {
+	a.Score, a.PendingReview = 0, false
+	for _, answer := range a.Answers {
+		a.Score += answer.Score
+		a.PendingReview = a.PendingReview || answer.Pending
+	}
+	if a.Score < 0 {
+		a.Score = 0
+	}
+}
+
+// Percent returns the attempt score as a percentage of the maximum score.
+func (a *Attempt) Percent() float64 {
+	if a.MaxScore == 0 {
+		return 0
+	}
+	return a.Score / a.MaxScore * 100
+}
+
+// CanStartAttempt checks the quiz attempt policy against the attempts the
+// user already made, which must be ordered by StartedAt.
+func CanStartAttempt(q *Quiz, prior []Attempt, now time.Time) error {
+	if q.Closed(now) {
+		return ErrQuizClosed
+	}
+	for _, a := range prior {
+		if !a.Submitted() {
+			return ErrAttemptInProgress
+		}
+	}
+	if q.MaxAttempts > 0 && len(prior) >= q.MaxAttempts {
+		return fmt.Errorf("%w: %d of %d used", ErrAttemptLimitReached, len(prior), q.MaxAttempts)
+	}
+	if q.AttemptCooldown > 0 && len(prior) > 0 {
+		last := prior[len(prior)-1].SubmittedAt
+		if wait := last.Add(q.AttemptCooldown).Sub(now); wait > 0 {
+			return fmt.Errorf("%w: try again in %s", ErrAttemptCooldown, wait.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// OfficialScore applies the grading policy to the submitted attempts, which
+// must be ordered by StartedAt. Attempts pending review count in their place
+// with the score they have so far; Provisional reports whether that score
+// may still change. It returns false when no attempt counts.
+func OfficialScore(policy GradingPolicy, attempts []Attempt) (score, maxScore float64, ok bool) {
+	submitted := submittedAttempts(attempts)
+	if len(submitted) == 0 {
+		return 0, 0, false
+	}
+
+	switch policy.OrDefault() {
+	case GradeLatest:
+		last := submitted[len(submitted)-1]
+		return last.Score, last.MaxScore, true
+	case GradeFirst:
+		return submitted[0].Score, submitted[0].MaxScore, true
+	case GradeAverage:
+		for _, a := range submitted {
+			score += a.Score
+			maxScore += a.MaxScore
+		}
+		n := float64(len(submitted))
+		return score / n, maxScore / n, true
+	default: // GradeHighest
+		best := submitted[0]
+		for _, a := range submitted[1:] {
+			if a.Percent() > best.Percent() {
+				best = a
+			}
+		}
+		return best.Score, best.MaxScore, true
+	}
+}
+
+// Provisional reports whether the official score under the policy may still
+// change because an attempt it counts is pending review.
+func Provisional(policy GradingPolicy, attempts []Attempt) bool {
+	submitted := submittedAttempts(attempts)
+	if len(submitted) == 0 {
+		return false
+	}
+	switch policy.OrDefault() {
+	case GradeLatest:
+		return submitted[len(submitted)-1].PendingReview
+	case GradeFirst:
+		return submitted[0].PendingReview
+	default: // GradeAverage and GradeHighest, where any attempt may count
+		for _, a := range submitted {
+			if a.PendingReview {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func submittedAttempts(attempts []Attempt) []Attempt {
+	submitted := make([]Attempt, 0, len(attempts))
+	for _, a := range attempts {
+		if a.Submitted() {
+			submitted = append(submitted, a)
+		}
+	}
+	return submitted
+}
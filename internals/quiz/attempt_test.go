@@ -0,0 +1,150 @@
+package quiz_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+)
+
+func submittedAttempt(score, maxScore float64, at time.Time) quiz.Attempt {
+	return quiz.Attempt{StartedAt: at, SubmittedAt: &at, Score: score, MaxScore: maxScore}
+}
+
+func TestCanStartAttempt(t *testing.T) {
+	now := time.Now()
+	q := quiz.NewQuiz()
+
+	// Unlimited attempts by default
+	err := quiz.CanStartAttempt(q, []quiz.Attempt{submittedAttempt(1, 2, now)}, now)
+	assert.NoError(t, err)
+
+	// An open attempt blocks a new one
+	open := []quiz.Attempt{{StartedAt: now}}
+	assert.ErrorIs(t, quiz.CanStartAttempt(q, open, now), quiz.ErrAttemptInProgress)
+
+	// Attempt limit
+	q.MaxAttempts = 2
+	prior := []quiz.Attempt{submittedAttempt(1, 2, now.Add(-time.Hour)), submittedAttempt(2, 2, now.Add(-time.Minute))}
+	assert.ErrorIs(t, quiz.CanStartAttempt(q, prior, now), quiz.ErrAttemptLimitReached)
+	assert.NoError(t, quiz.CanStartAttempt(q, prior[:1], now))
+
+	// Cooldown counts from the last submission
+	q.AttemptCooldown = 10 * time.Minute
+	assert.ErrorIs(t, quiz.CanStartAttempt(q, prior[1:], now), quiz.ErrAttemptCooldown)
+	assert.NoError(t, quiz.CanStartAttempt(q, prior[1:], now.Add(10*time.Minute)))
+}
+
+func TestOfficialScore(t *testing.T) {
+	now := time.Now()
+	attempts := []quiz.Attempt{
+		submittedAttempt(4, 10, now.Add(-3*time.Hour)),
+		submittedAttempt(9, 10, now.Add(-2*time.Hour)),
+		submittedAttempt(5, 10, now.Add(-time.Hour)),
+		{StartedAt: now}, // still open, ignored
+	}
+
+	tests := []struct {
+		policy quiz.GradingPolicy
+		score  float64
+	}{
+		{quiz.GradeHighest, 9},
+		{quiz.GradeLatest, 5},
+		{quiz.GradeFirst, 4},
+		{quiz.GradeAverage, 6},
+	}
+	for _, tt := range tests {
+		score, maxScore, ok := quiz.OfficialScore(tt.policy, attempts)
+		assert.True(t, ok, tt.policy)
+		assert.Equal(t, tt.score, score, tt.policy)
+		assert.Equal(t, 10.0, maxScore, tt.policy)
+	}
+
+	_, _, ok := quiz.OfficialScore(quiz.GradeHighest, attempts[3:])
+	assert.False(t, ok)
+
+	// An attempt pending review keeps its place: the first attempt still
+	// counts under "first", and the score is provisional until graded.
+	attempts[0].PendingReview = true
+	score, _, ok := quiz.OfficialScore(quiz.GradeFirst, attempts)
+	assert.True(t, ok)
+	assert.Equal(t, 4.0, score)
+	assert.True(t, quiz.Provisional(quiz.GradeFirst, attempts))
+	assert.False(t, quiz.Provisional(quiz.GradeLatest, attempts))
+	assert.True(t, quiz.Provisional(quiz.GradeHighest, attempts))
+}
+
+func TestGrade(t *testing.T) {
+	q := quiz.NewQuiz()
+	q.Questions = []quiz.Question{
+		{ID: 1, Type: quiz.TypeSingleChoice, Choices: []quiz.Choice{{ID: 1, IsCorrect: true}, {ID: 2}}},
+		{ID: 2, Type: quiz.TypeMultiChoice, Choices: []quiz.Choice{{ID: 3, IsCorrect: true}, {ID: 4, IsCorrect: true}, {ID: 5}}},
+		{ID: 3, Type: quiz.TypeSingleChoice, Choices: []quiz.Choice{{ID: 6, IsCorrect: true}}},
+	}
+	a := &quiz.Attempt{Answers: []quiz.Answer{
+		{QuestionID: 1, ChoiceIDs: quiz.UintList{1}},
+		{QuestionID: 2, ChoiceIDs: quiz.UintList{3}},
+	}}
+
+	quiz.Grade(q, a)
+	assert.Equal(t, 1.0, a.Answers[0].Score)
+	assert.Equal(t, 0.0, a.Answers[1].Score)
+	assert.Equal(t, 1.0, a.Score)
+	assert.Equal(t, 3.0, a.MaxScore)
+}
+
+func TestGrade_WeightedWithNegativeMarking(t *testing.T) {
+	q := quiz.NewQuiz()
+	q.Questions = []quiz.Question{
+		{ID: 1, Points: 3, Choices: []quiz.Choice{{ID: 1, IsCorrect: true}, {ID: 2}}},
+		{ID: 2, Points: 2, Penalty: 0.5, Choices: []quiz.Choice{{ID: 3, IsCorrect: true}, {ID: 4}}},
+		{ID: 3, Points: 4, PenaltyFraction: 0.25, Choices: []quiz.Choice{{ID: 5, IsCorrect: true}, {ID: 6}}},
+		{ID: 4, Penalty: 1, Choices: []quiz.Choice{{ID: 7, IsCorrect: true}}},
+	}
+	a := &quiz.Attempt{Answers: []quiz.Answer{
+		{QuestionID: 1, ChoiceIDs: quiz.UintList{1}},
+		{QuestionID: 2, ChoiceIDs: quiz.UintList{4}},
+		{QuestionID: 3, ChoiceIDs: quiz.UintList{6}},
+		{QuestionID: 4}, // left blank, no penalty
+	}}
+
+	quiz.Grade(q, a)
+	assert.Equal(t, 3.0, a.Answers[0].Score)
+	assert.Equal(t, -0.5, a.Answers[1].Score)
+	assert.Equal(t, -1.0, a.Answers[2].Score)
+	assert.Equal(t, 0.0, a.Answers[3].Score)
+	assert.Equal(t, 1.5, a.Score)
+	assert.Equal(t, 10.0, a.MaxScore)
+
+	// The attempt total never goes below zero
+	a = &quiz.Attempt{Answers: []quiz.Answer{{QuestionID: 2, ChoiceIDs: quiz.UintList{4}}}}
+	quiz.Grade(q, a)
+	assert.Equal(t, -0.5, a.Answers[0].Score)
+	assert.Equal(t, 0.0, a.Score)
+}
+
+func TestQuiz_RevealFeedback(t *testing.T) {
+	now := time.Now()
+	closed := now.Add(-time.Hour)
+	open := &quiz.Attempt{StartedAt: now}
+	done := &quiz.Attempt{StartedAt: now, SubmittedAt: &now}
+
+	q := quiz.NewQuiz()
+	assert.False(t, q.RevealFeedback(open, now))
+	assert.True(t, q.RevealFeedback(done, now))
+
+	q.RevealPolicy = quiz.RevealImmediately
+	assert.True(t, q.RevealFeedback(open, now))
+
+	q.RevealPolicy = quiz.RevealNever
+	assert.False(t, q.RevealFeedback(done, now))
+
+	q.RevealPolicy = quiz.RevealAfterClose
+	assert.False(t, q.RevealFeedback(done, now))
+	q.ClosesAt = &closed
+	assert.True(t, q.RevealFeedback(done, now))
+
+	// A closed quiz cannot be started
+	assert.ErrorIs(t, quiz.CanStartAttempt(q, nil, now), quiz.ErrQuizClosed)
+}
@@ -5,7 +5,8 @@ type Choice struct {
 	QuestionID uint    `gorm:"index"` // Foreign key
 	Content    string  `json:"content" form:"content"`
 	IsCorrect  bool    `json:"is_correct" form:"is_correct"`
-	Thumb      []byte  `json:"thumb,omitempty" form:"thumb,omitempty"`
+	Feedback   string  `json:"feedback,omitempty" form:"feedback,omitempty"`   // Shown when the choice is picked
+	ThumbKey   string  `json:"thumb_key,omitempty" form:"thumb_key,omitempty"` // Blob of the thumbnail image
 	Meta       JSONMap `gorm:"type:json" json:"meta,omitempty" form:"meta,omitempty"`
 }
 
@@ -13,7 +14,6 @@ func NewChoice() *Choice {
 	return &Choice{
 		Content:   "Choice 1",
 		IsCorrect: false,
-		Thumb:     nil,
 		Meta:      make(map[string]interface{}),
 	}
 }
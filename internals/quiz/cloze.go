@@ -0,0 +1,108 @@
+package quiz
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ClozeSegment is a piece of cloze question content: either plain text or a
+// blank with its accepted answers.
+type ClozeSegment struct {
+	Text     string
+	Blank    int // Index of the blank, or -1 for text
+	Accepted []string
+}
+
+// ParseCloze splits content such as "The capital of France is {{Paris}}"
+// into text and blanks. Alternatives are separated by "|", as in
+// "{{colour|color}}".
+func ParseCloze(content string) ([]ClozeSegment, error) {
+	var segments []ClozeSegment
+	blanks := 0
+	for content != "" {
+		start := strings.Index(content, "{{")
+		if start < 0 {
+			if strings.Contains(content, "}}") {
+				return nil, fmt.Errorf("unopened blank in %q", content)
+			}
+			segments = append(segments, ClozeSegment{Text: content, Blank: -1})
+			break
+		}
+		if strings.Contains(content[:start], "}}") {
+			return nil, fmt.Errorf("unopened blank in %q", content[:start])
+		}
+		if start > 0 {
+			segments = append(segments, ClozeSegment{Text: content[:start], Blank: -1})
+		}
+		end := strings.Index(content[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed blank in %q", content[start:])
+		}
+		inner := content[start+2 : start+end]
+		if strings.Contains(inner, "{{") {
+			return nil, fmt.Errorf("nested blank in %q", inner)
+		}
+		blank := ClozeSegment{Blank: blanks}
+		for _, alt := range strings.Split(inner, "|") {
+			if alt = strings.TrimSpace(alt); alt == "" {
+				return nil, fmt.Errorf("blank %d has an empty answer", blanks+1)
+			}
+			blank.Accepted = append(blank.Accepted, alt)
+		}
+		segments = append(segments, blank)
+		blanks++
+		content = content[start+end+2:]
+	}
+	if blanks == 0 {
+		return nil, fmt.Errorf("cloze question must have at least one {{blank}}")
+	}
+	return segments, nil
+}
+
+// clozeBlanks returns the accepted answers of every blank in the content.
+func clozeBlanks(content string) [][]string {
+	segments, err := ParseCloze(content)
+	if err != nil {
+		return nil
+	}
+	var blanks [][]string
+	for _, s := range segments {
+		if s.Blank >= 0 {
+			blanks = append(blanks, s.Accepted)
+		}
+	}
+	return blanks
+}
+
+// clozeCredit returns the fraction of blanks filled with an accepted answer,
+// compared like short-text answers.
+func clozeCredit(content string, response []string) float64 {
+	blanks := clozeBlanks(content)
+	if len(blanks) == 0 {
+		return 0
+	}
+	correct := 0
+	for i, accepted := range blanks {
+		if i < len(response) && (&TextSpec{Accepted: accepted}).Matches(response[i]) {
+			correct++
+		}
+	}
+	return partialCredit(correct, len(blanks), false)
+}
+
+// clozePrompt replaces every blank with an underscore line.
+func clozePrompt(content string) string {
+	segments, err := ParseCloze(content)
+	if err != nil {
+		return content
+	}
+	var b strings.Builder
+	for _, s := range segments {
+		if s.Blank >= 0 {
+			b.WriteString("_____")
+		} else {
+			b.WriteString(s.Text)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package quiz_test
+
+import (
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCloze(t *testing.T) {
+	segments, err := quiz.ParseCloze("The capital of France is {{Paris}} and of the UK is {{London | london city}}.")
+	assert.NoError(t, err)
+	assert.Equal(t, []quiz.ClozeSegment{
+		{Text: "The capital of France is ", Blank: -1},
+		{Blank: 0, Accepted: []string{"Paris"}},
+		{Text: " and of the UK is ", Blank: -1},
+		{Blank: 1, Accepted: []string{"London", "london city"}},
+		{Text: ".", Blank: -1},
+	}, segments)
+
+	for _, bad := range []string{"No blanks", "Unclosed {{Paris", "Unopened Paris}}", "Empty {{}}", "Empty {{a||b}}", "{{a {{b}} }}"} {
+		_, err := quiz.ParseCloze(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestGrade_Cloze(t *testing.T) {
+	q := quiz.NewQuiz()
+	q.Questions = []quiz.Question{
+		{ID: 1, Type: quiz.TypeCloze, Points: 2, Content: "{{Paris}} is in {{France}}, {{Rome|Roma}} is in {{Italy}}."},
+	}
+	a := &quiz.Attempt{Answers: []quiz.Answer{{QuestionID: 1, Blanks: quiz.StringList{"paris", "Spain", " roma ", ""}}}}
+
+	quiz.Grade(q, a)
+	assert.Equal(t, 1.0, a.Answers[0].Score)
+	assert.Equal(t, "_____ is in _____, _____ is in _____.", q.Questions[0].Prompt())
+}
@@ -0,0 +1,151 @@
+package quiz
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"time"
+)
+
+const defaultCodeTimeout = 10 * time.Second
+
+// CodeSpec configures a programming question. Learners complete the Starter
+// source and their submission is checked by Tests, a hidden Go test file in
+// the same package that learners never see.
+type CodeSpec struct {
+	Language string `json:"language,omitempty"` // Only "go" for now
+	Starter  string `json:"starter,omitempty"`
+	Tests    string `json:"tests"`
+	// TimeoutSeconds bounds compiling and running the tests; zero means the
+	// default of 10 seconds.
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// Scan implements the Scanner interface for CodeSpec
+func (cs *CodeSpec) Scan(value interface{}) error {
+	return scanJSON(value, cs)
+}
+
+// Value implements the Valuer interface for CodeSpec
+func (cs CodeSpec) Value() (driver.Value, error) {
+	return valueJSON(cs)
+}
+
+func (cs *CodeSpec) Validate() error {
+	if cs.Language != "" && cs.Language != "go" {
+		return fmt.Errorf("unsupported code language %q", cs.Language)
+	}
+	if cs.TimeoutSeconds < 0 {
+		return fmt.Errorf("code timeout cannot be negative")
+	}
+	names, err := cs.TestNames()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("code tests must contain at least one Test function")
+	}
+	if cs.declaresTestMain() {
+		return fmt.Errorf("code tests cannot declare TestMain, which the runner provides")
+	}
+	return nil
+}
+
+// declaresTestMain reports whether the test file declares a TestMain.
+func (cs *CodeSpec) declaresTestMain() bool {
+	f, err := parser.ParseFile(token.NewFileSet(), "tests_test.go", cs.Tests, 0)
+	if err != nil {
+		return false
+	}
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "TestMain" {
+			return true
+		}
+	}
+	return false
+}
+
+// Timeout returns how long a submission may take to compile and run.
+func (cs *CodeSpec) Timeout() time.Duration {
+	if cs.TimeoutSeconds <= 0 {
+		return defaultCodeTimeout
+	}
+	return time.Duration(cs.TimeoutSeconds) * time.Second
+}
+
+// Package returns the package name declared by the test file.
+func (cs *CodeSpec) Package() (string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "tests_test.go", cs.Tests, parser.PackageClauseOnly)
+	if err != nil {
+		return "", fmt.Errorf("invalid code tests: %w", err)
+	}
+	return f.Name.Name, nil
+}
+
+// TestNames returns the Test functions of the test file in source order.
+func (cs *CodeSpec) TestNames() ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "tests_test.go", cs.Tests, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid code tests: %w", err)
+	}
+	var names []string
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || !strings.HasPrefix(fn.Name.Name, "Test") || fn.Name.Name == "TestMain" {
+			continue
+		}
+		names = append(names, fn.Name.Name)
+	}
+	return names, nil
+}
+
+// CodeResult is the outcome of running the tests against a submission.
+type CodeResult struct {
+	Passed int
+	Total  int
+	Output string
+}
+
+// CodeRunner compiles a submission together with the question tests and
+// runs them. A submission that does not compile is a result with no passed
+// tests, not an error; errors are reserved for runner failures.
+type CodeRunner interface {
+	Run(ctx context.Context, spec *CodeSpec, source string) (*CodeResult, error)
+}
+
+// RunCode runs the tests of every answered code question of the quiz and
+// records the results on the answers, ready for Grade.
+func RunCode(ctx context.Context, runner CodeRunner, q *Quiz, answers []Answer) error {
+	questions := make(map[uint]*Question, len(q.Questions))
+	for i := range q.Questions {
+		questions[q.Questions[i].ID] = &q.Questions[i]
+	}
+	for i := range answers {
+		answer := &answers[i]
+		question, found := questions[answer.QuestionID]
+		if !found || question.Type != TypeCode || question.Code == nil || answer.Empty() {
+			continue
+		}
+		if runner == nil {
+			return fmt.Errorf("no code runner configured for question %d", question.ID)
+		}
+		result, err := runner.Run(ctx, question.Code, answer.Text)
+		if err != nil {
+			return fmt.Errorf("running tests for question %d: %w", question.ID, err)
+		}
+		answer.TestsPassed, answer.TestsTotal, answer.Output = result.Passed, result.Total, result.Output
+	}
+	return nil
+}
+
+// codeCredit gives credit for the share of tests the submission passed.
+func codeCredit(answer *Answer) float64 {
+	if answer.TestsTotal == 0 {
+		return 0
+	}
+	return float64(answer.TestsPassed) / float64(answer.TestsTotal)
+}
@@ -0,0 +1,78 @@
+package quiz_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+)
+
+const sumTests = `package sum
+
+import "testing"
+
+func TestSum(t *testing.T) {
+	if Sum(1, 2) != 3 {
+		t.Fatal("1 + 2")
+	}
+}
+
+func TestSumNegative(t *testing.T) {
+	if Sum(-1, -2) != -3 {
+		t.Fatal("-1 + -2")
+	}
+}
+
+func helper() {}
+`
+
+type fakeRunner struct {
+	sources []string
+}
+
+func (fr *fakeRunner) Run(ctx context.Context, spec *quiz.CodeSpec, source string) (*quiz.CodeResult, error) {
+	fr.sources = append(fr.sources, source)
+	return &quiz.CodeResult{Passed: 1, Total: 2, Output: "--- FAIL: TestSumNegative"}, nil
+}
+
+func TestCodeSpec(t *testing.T) {
+	spec := &quiz.CodeSpec{Tests: sumTests}
+	assert.NoError(t, spec.Validate())
+
+	names, err := spec.TestNames()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"TestSum", "TestSumNegative"}, names)
+	pkg, _ := spec.Package()
+	assert.Equal(t, "sum", pkg)
+
+	assert.Error(t, (&quiz.CodeSpec{Tests: "package sum\n\nfunc helper() {}\n"}).Validate())
+	assert.Error(t, (&quiz.CodeSpec{Tests: "not go"}).Validate())
+	assert.Error(t, (&quiz.CodeSpec{Tests: sumTests, Language: "python"}).Validate())
+	assert.EqualError(t, (&quiz.CodeSpec{Tests: sumTests + "\nfunc TestMain(m *testing.M) {}\n"}).Validate(), "code tests cannot declare TestMain, which the runner provides")
+}
+
+func TestGrade_Code(t *testing.T) {
+	q := quiz.NewQuiz()
+	q.Questions = []quiz.Question{
+		{ID: 1, Type: quiz.TypeCode, Points: 4, Code: &quiz.CodeSpec{Tests: sumTests}},
+		{ID: 2, Type: quiz.TypeCode, Points: 4, Code: &quiz.CodeSpec{Tests: sumTests}},
+	}
+	answers := []quiz.Answer{
+		{QuestionID: 1, Text: "package sum\n\nfunc Sum(a, b int) int { return a + b }\n"},
+		{QuestionID: 2, Text: "  "},
+	}
+
+	runner := &fakeRunner{}
+	assert.NoError(t, quiz.RunCode(context.Background(), runner, q, answers))
+	assert.Len(t, runner.sources, 1, "blank submissions are not run")
+
+	a := &quiz.Attempt{Answers: answers}
+	quiz.Grade(q, a)
+	assert.Equal(t, 2.0, a.Answers[0].Score)
+	assert.Equal(t, "--- FAIL: TestSumNegative", a.Answers[0].Output)
+	assert.Equal(t, 0.0, a.Answers[1].Score)
+	assert.Equal(t, 8.0, a.MaxScore)
+
+	assert.Error(t, quiz.RunCode(context.Background(), nil, q, answers))
+}
@@ -0,0 +1,101 @@
+package quiz
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+type RubricCriterion struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Points      float64 `json:"points"`
+}
+
+// EssaySpec configures a free-text question that a person grades. When a
+// rubric is given, the question is worth the sum of its criterion points.
+type EssaySpec struct {
+	Rubric []RubricCriterion `json:"rubric,omitempty"`
+}
+
+// Scan implements the Scanner interface for EssaySpec
+func (es *EssaySpec) Scan(value interface{}) error {
+	return scanJSON(value, es)
+}
+
+// Value implements the Valuer interface for EssaySpec
+func (es EssaySpec) Value() (driver.Value, error) {
+	return valueJSON(es)
+}
+
+func (es *EssaySpec) Validate() error {
+	for _, c := range es.Rubric {
+		if c.Name == "" || c.Points <= 0 {
+			return fmt.Errorf("rubric criterion needs a name and positive points")
+		}
+	}
+	return nil
+}
+
+// RubricTotal returns the points of all rubric criteria.
+func (es *EssaySpec) RubricTotal() float64 {
+	total := 0.0
+	for _, c := range es.Rubric {
+		total += c.Points
+	}
+	return total
+}
+
+// Review is a grader's verdict on a manually graded answer. Scores has one
+// entry per rubric criterion, or a single entry when there is no rubric.
+type Review struct {
+	GraderID uint
+	Scores   []float64
+	Comment  string
+}
+
+// ReviewItem is an answer waiting in the grading queue.
+type ReviewItem struct {
+	Answer    Answer
+	Question  Question
+	AttemptID uint
+	QuizID    uint
+	QuizName  string
+}
+
+// ApplyReview scores a pending answer to the question with the review.
+func ApplyReview(question *Question, answer *Answer, review Review, now time.Time) error {
+	var rubric []RubricCriterion
+	if question.Essay != nil {
+		rubric = question.Essay.Rubric
+	}
+
+	score := 0.0
+	if len(rubric) > 0 {
+		if len(review.Scores) != len(rubric) {
+			return fmt.Errorf("expected %d rubric scores, got %d", len(rubric), len(review.Scores))
+		}
+		for i, c := range rubric {
+			if review.Scores[i] < 0 || review.Scores[i] > c.Points {
+				return fmt.Errorf("score for %q must be between 0 and %g", c.Name, c.Points)
+			}
+			score += review.Scores[i]
+		}
+	} else {
+		if len(review.Scores) != 1 {
+			return fmt.Errorf("expected a single score, got %d", len(review.Scores))
+		}
+		score = review.Scores[0]
+		if score < 0 || score > question.MaxPoints() {
+			return fmt.Errorf("score must be between 0 and %g", question.MaxPoints())
+		}
+	}
+
+	answer.Score, answer.MaxScore = score, question.MaxPoints()
+	answer.RubricScores = review.Scores
+	answer.Comment = review.Comment
+	answer.GraderID = review.GraderID
+	answer.GradedAt = &now
+	answer.Pending = false
+	return nil
+}
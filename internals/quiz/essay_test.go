@@ -0,0 +1,72 @@
+package quiz_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+)
+
+func essayQuestion() quiz.Question {
+	return quiz.Question{ID: 1, Type: quiz.TypeEssay, Essay: &quiz.EssaySpec{Rubric: []quiz.RubricCriterion{
+		{Name: "Argument", Points: 6},
+		{Name: "Grammar", Points: 4},
+	}}}
+}
+
+func TestGrade_EssayStaysPending(t *testing.T) {
+	q := quiz.NewQuiz()
+	q.Questions = []quiz.Question{
+		essayQuestion(),
+		{ID: 2, Type: quiz.TypeShortText, Text: &quiz.TextSpec{Accepted: []string{"yes"}}},
+	}
+	a := &quiz.Attempt{Answers: []quiz.Answer{
+		{QuestionID: 1, Text: "Because..."},
+		{QuestionID: 2, Text: "yes"},
+	}}
+
+	quiz.Grade(q, a)
+	assert.True(t, a.Answers[0].Pending)
+	assert.True(t, a.PendingReview)
+	assert.Equal(t, 1.0, a.Score)
+	assert.Equal(t, 11.0, a.MaxScore)
+
+	// Pending attempts count with a provisional score
+	now := time.Now()
+	a.SubmittedAt = &now
+	score, _, ok := quiz.OfficialScore(quiz.GradeHighest, []quiz.Attempt{*a})
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, score)
+	assert.True(t, quiz.Provisional(quiz.GradeHighest, []quiz.Attempt{*a}))
+
+	// A blank essay needs no review
+	a = &quiz.Attempt{Answers: []quiz.Answer{{QuestionID: 1, Text: "  "}}}
+	quiz.Grade(q, a)
+	assert.False(t, a.PendingReview)
+}
+
+func TestApplyReview(t *testing.T) {
+	question := essayQuestion()
+	answer := &quiz.Answer{QuestionID: 1, Text: "Because...", Pending: true}
+	now := time.Now()
+
+	err := quiz.ApplyReview(&question, answer, quiz.Review{Scores: []float64{5}}, now)
+	assert.Error(t, err, "one score per criterion")
+	err = quiz.ApplyReview(&question, answer, quiz.Review{Scores: []float64{7, 4}}, now)
+	assert.Error(t, err, "score above criterion points")
+
+	err = quiz.ApplyReview(&question, answer, quiz.Review{GraderID: 3, Scores: []float64{5, 3.5}, Comment: "Good"}, now)
+	assert.NoError(t, err)
+	assert.False(t, answer.Pending)
+	assert.Equal(t, 8.5, answer.Score)
+	assert.Equal(t, 10.0, answer.MaxScore)
+	assert.Equal(t, uint(3), answer.GraderID)
+	assert.Equal(t, "Good", answer.Comment)
+
+	// Without a rubric a single score up to the question points is expected
+	plain := quiz.Question{Type: quiz.TypeEssay, Points: 5}
+	assert.Error(t, quiz.ApplyReview(&plain, answer, quiz.Review{Scores: []float64{6}}, now))
+	assert.NoError(t, quiz.ApplyReview(&plain, answer, quiz.Review{Scores: []float64{4}}, now))
+	assert.Equal(t, 4.0, answer.Score)
+}
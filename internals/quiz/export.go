@@ -0,0 +1,264 @@
+package quiz
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/blob"
+)
+
+// ExportVersion is the version of the export envelope written by
+// WriteExport. ReadExport upgrades older exports and refuses newer ones.
+//
+// Version 1 is the first envelope. Before it, SQLiteStore wrote a bare list
+// of quizzes and MemoryStore a map of quizzes by ID, both read as version 0.
+const ExportVersion = 1
+
+// Export is a JSON export of quizzes with their questions, choices and the
+// media blobs they refer to.
+type Export struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Quizzes    []*Quiz   `json:"quizzes"`
+	// Media holds the blobs of the quiz covers, question images and
+	// recordings and choice thumbnails by key, base64-encoded.
+	Media map[string][]byte `json:"media,omitempty"`
+}
+
+// ImportMode decides what an import does with a quiz whose ID is taken.
+type ImportMode string
+
+const (
+	ImportSkip      ImportMode = "skip"      // Keep the stored quiz
+	ImportOverwrite ImportMode = "overwrite" // Replace the stored quiz and its questions
+	// ImportMerge updates the stored quiz, replaces the questions with the
+	// same ID and adds the others, keeping the questions the export lacks.
+	ImportMerge ImportMode = "merge"
+)
+
+// ImportOptions set how an export is imported.
+type ImportOptions struct {
+	Mode ImportMode // Skip when empty
+	// DryRun reports what the import would do without storing anything.
+	DryRun bool
+}
+
+func (o ImportOptions) mode() (ImportMode, error) {
+	switch o.Mode {
+	case "":
+		return ImportSkip, nil
+	case ImportSkip, ImportOverwrite, ImportMerge:
+		return o.Mode, nil
+	}
+	return "", fmt.Errorf("unknown import mode %q, use skip, overwrite or merge", o.Mode)
+}
+
+// ImportAction is what an import did with a quiz.
+type ImportAction string
+
+const (
+	ImportCreated     ImportAction = "created"
+	ImportSkipped     ImportAction = "skipped"
+	ImportOverwritten ImportAction = "overwritten"
+	ImportMerged      ImportAction = "merged"
+)
+
+// ImportedQuiz is a quiz of an import and what was done with it.
+type ImportedQuiz struct {
+	ID     uint
+	Name   string
+	Action ImportAction
+}
+
+// ImportReport tells what an import did, or would do in a dry run.
+type ImportReport struct {
+	Quizzes []ImportedQuiz
+	Media   int // Blobs stored
+	DryRun  bool
+}
+
+func (r *ImportReport) String() string {
+	counts := make(map[ImportAction]int)
+	for _, q := range r.Quizzes {
+		counts[q.Action]++
+	}
+	var parts []string
+	for _, action := range []ImportAction{ImportCreated, ImportOverwritten, ImportMerged, ImportSkipped} {
+		if counts[action] > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", action, counts[action]))
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "no quizzes")
+	}
+	s := strings.Join(parts, ", ") + fmt.Sprintf(", %d media", r.Media)
+	if r.DryRun {
+		s += " (dry run, nothing stored)"
+	}
+	return s
+}
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+// ReadExport reads an export, upgrading the bare lists and maps of version 0
+// to the current envelope.
+func ReadExport(r io.Reader) (*Export, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("the export is empty")
+	}
+	if data[0] == '[' {
+		var quizzes []*Quiz
+		if err := json.Unmarshal(data, &quizzes); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal quizzes: %w", err)
+		}
+		return &Export{Quizzes: quizzes}, nil
+	}
+
+	var probe struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal export: %w", err)
+	}
+	if probe.Version == nil {
+		var byID map[int64]*Quiz
+		if err := json.Unmarshal(data, &byID); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal quizzes: %w", err)
+		}
+		e := &Export{}
+		for id, q := range byID {
+			if q != nil {
+				q.ID = uint(id)
+			}
+			e.Quizzes = append(e.Quizzes, q)
+		}
+		sort.Slice(e.Quizzes, func(i, j int) bool { return e.Quizzes[i].ID < e.Quizzes[j].ID })
+		return e, nil
+	}
+	if *probe.Version < 1 || *probe.Version > ExportVersion {
+		return nil, fmt.Errorf("export version %d is not supported, this version reads up to %d", *probe.Version, ExportVersion)
+	}
+	var e Export
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal export: %w", err)
+	}
+	return &e, nil
+}
+
+// WriteExport writes the export as indented JSON, stamped with
+// ExportVersion.
+func WriteExport(w io.Writer, e *Export) error {
+	e.Version = ExportVersion
+	if e.ExportedAt.IsZero() {
+		e.ExportedAt = time.Now().UTC()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(e); err != nil {
+		return fmt.Errorf("failed to marshal quizzes: %w", err)
+	}
+	return nil
+}
+
+func writeExportFile(filename string, e *Export) error {
+	var buf bytes.Buffer
+	if err := WriteExport(&buf, e); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+	return nil
+}
+
+func readExportFile(filename string) (*Export, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+	return ReadExport(f)
+}
+
+// checkExport validates every quiz and blob of an export before anything is
+// stored, so that a bad quiz fails the import as a whole.
+func checkExport(e *Export, validate func(*Quiz) error) error {
+	seen := make(map[uint]bool)
+	for i, q := range e.Quizzes {
+		if q == nil {
+			return fmt.Errorf("quiz %d is null", i+1)
+		}
+		if q.ID != 0 && seen[q.ID] {
+			return fmt.Errorf("quiz %d (%q): the ID %d is used twice", i+1, q.Name, q.ID)
+		}
+		seen[q.ID] = true
+		if err := validate(q); err != nil {
+			return fmt.Errorf("quiz %d (%q): %w", i+1, q.Name, err)
+		}
+	}
+	// Blobs are shared between quizzes by content, so a key must name the
+	// content it comes with rather than overwrite a blob other quizzes use.
+	for key, data := range e.Media {
+		if err := blob.CheckKey(key); err != nil {
+			return err
+		}
+		i := strings.LastIndex(key, "/")
+		if i < 0 || blob.ContentKey(key[:i], data) != key {
+			return fmt.Errorf("media %q does not match its content", key)
+		}
+	}
+	return nil
+}
+
+// MediaKeys returns the blob keys the quiz, its questions and their choices
+// refer to.
+func (q *Quiz) MediaKeys() []string {
+	var keys []string
+	add := func(key string) {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	add(q.CoverKey)
+	for _, question := range q.Questions {
+		add(question.ImageKey)
+		add(question.MediaKey)
+		for _, choice := range question.Choices {
+			add(choice.ThumbKey)
+		}
+	}
+	return keys
+}
+
+// mergeQuestions replaces the stored questions that have the ID of an
+// imported question and appends the other imported questions.
+func mergeQuestions(stored, imported []Question) []Question {
+	merged := append([]Question(nil), stored...)
+	index := make(map[uint]int)
+	for i, question := range merged {
+		if question.ID != 0 {
+			index[question.ID] = i
+		}
+	}
+	for _, question := range imported {
+		if i, found := index[question.ID]; found && question.ID != 0 {
+			merged[i] = question
+			continue
+		}
+		merged = append(merged, question)
+	}
+	return merged
+}
@@ -0,0 +1,105 @@
+package quiz
+
+// Grade scores every answer of the attempt against the quiz and updates the
+// attempt totals. Questions left unanswered count towards the maximum score
+// but are never penalised, and the attempt total never drops below zero.
+// Answers to manually graded questions are left pending with no score.
+// Rating questions and survey quizzes are not graded at all.
+func Grade(q *Quiz, a *Attempt) {
+	answers := make(map[uint]*Answer, len(a.Answers))
+	for i := range a.Answers {
+		answers[a.Answers[i].QuestionID] = &a.Answers[i]
+	}
+
+	a.Score, a.MaxScore, a.PendingReview = 0, 0, false
+	for i := range q.Questions {
+		question := &q.Questions[i]
+		answer, found := answers[question.ID]
+		if q.Survey || !question.Graded() {
+			if found {
+				answer.Score, answer.MaxScore = 0, 0
+			}
+			continue
+		}
+		if !found {
+			a.MaxScore += question.MaxPoints()
+			continue
+		}
+		if question.ManuallyGraded() {
+			answer.Score, answer.MaxScore = 0, question.MaxPoints()
+			answer.Pending = !answer.Empty()
+			a.MaxScore += answer.MaxScore
+			a.PendingReview = a.PendingReview || answer.Pending
+			continue
+		}
+		answer.Score, answer.MaxScore = gradeQuestion(question, answer), question.MaxPoints()
+		a.Score += answer.Score
+		a.MaxScore += answer.MaxScore
+	}
+	if a.Score < 0 {
+		a.Score = 0
+	}
+}
+
+// gradeQuestion weighs the answer by the question points and applies
+// negative marking to wrong answers.
+func gradeQuestion(question *Question, answer *Answer) float64 {
+	if answer.Empty() {
+		return 0
+	}
+	credit := questionCredit(question, answer)
+	if credit <= 0 {
+		if penalty := question.WrongPenalty(); penalty > 0 {
+			return -penalty
+		}
+		return 0
+	}
+	return credit * question.MaxPoints()
+}
+
+// questionCredit returns the fraction of the question points the answer earns.
+func questionCredit(question *Question, answer *Answer) float64 {
+	switch question.Type {
+	case TypeShortText:
+		return boolCredit(question.Text != nil && question.Text.Matches(answer.Text))
+	case TypeNumeric:
+		return boolCredit(question.Numeric != nil && question.Numeric.Matches(answer.Text))
+	case TypeOrdering:
+		if question.Ordering == nil {
+			return 0
+		}
+		return question.Ordering.Credit(answer.Items)
+	case TypeMatching:
+		if question.Matching == nil {
+			return 0
+		}
+		return question.Matching.Credit(answer.Items)
+	case TypeCloze:
+		return clozeCredit(question.Content, answer.Blanks)
+	case TypeCode:
+		return codeCredit(answer)
+	default:
+		return gradeChoices(question, answer)
+	}
+}
+
+func boolCredit(correct bool) float64 {
+	if correct {
+		return 1
+	}
+	return 0
+}
+
+// gradeChoices gives full credit when exactly the correct choices were picked.
+func gradeChoices(question *Question, answer *Answer) float64 {
+	picked := make(map[uint]bool, len(answer.ChoiceIDs))
+	for _, id := range answer.ChoiceIDs {
+		picked[id] = true
+	}
+	for _, choice := range question.Choices {
+		if choice.IsCorrect != picked[choice.ID] {
+			return 0
+		}
+	}
+	return 1
+}
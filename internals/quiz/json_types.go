@@ -29,3 +29,98 @@ func (jm JSONMap) Value() (driver.Value, error) {
 	}
 	return json.Marshal(jm)
 }
+
+// UintList is a type to handle []uint fields stored as JSON
+type UintList []uint
+
+// Scan implements the Scanner interface for UintList
+func (ul *UintList) Scan(value interface{}) error {
+	if value == nil {
+		*ul = UintList{}
+		return nil
+	}
+	data, ok := value.([]byte)
+	if !ok {
+		return gorm.ErrInvalidData
+	}
+	return json.Unmarshal(data, ul)
+}
+
+// Value implements the Valuer interface for UintList
+func (ul UintList) Value() (driver.Value, error) {
+	if len(ul) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(ul)
+}
+
+// scanJSON decodes a JSON column into dst
+func scanJSON(value interface{}, dst interface{}) error {
+	if value == nil {
+		return nil
+	}
+	switch data := value.(type) {
+	case []byte:
+		return json.Unmarshal(data, dst)
+	case string:
+		return json.Unmarshal([]byte(data), dst)
+	default:
+		return gorm.ErrInvalidData
+	}
+}
+
+// valueJSON encodes src for a JSON column
+func valueJSON(src interface{}) (driver.Value, error) {
+	return json.Marshal(src)
+}
+
+// IntList is a type to handle []int fields stored as JSON
+type IntList []int
+
+// Scan implements the Scanner interface for IntList
+func (il *IntList) Scan(value interface{}) error {
+	*il = IntList{}
+	return scanJSON(value, il)
+}
+
+// Value implements the Valuer interface for IntList
+func (il IntList) Value() (driver.Value, error) {
+	if len(il) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(il)
+}
+
+// FloatList is a type to handle []float64 fields stored as JSON
+type FloatList []float64
+
+// Scan implements the Scanner interface for FloatList
+func (fl *FloatList) Scan(value interface{}) error {
+	*fl = FloatList{}
+	return scanJSON(value, fl)
+}
+
+// Value implements the Valuer interface for FloatList
+func (fl FloatList) Value() (driver.Value, error) {
+	if len(fl) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(fl)
+}
+
+// StringList is a type to handle []string fields stored as JSON
+type StringList []string
+
+// Scan implements the Scanner interface for StringList
+func (sl *StringList) Scan(value interface{}) error {
+	*sl = StringList{}
+	return scanJSON(value, sl)
+}
+
+// Value implements the Valuer interface for StringList
+func (sl StringList) Value() (driver.Value, error) {
+	if len(sl) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(sl)
+}
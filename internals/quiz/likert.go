@@ -0,0 +1,45 @@
+package quiz
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+var DefaultLikertLabels = []string{"Strongly disagree", "Disagree", "Neutral", "Agree", "Strongly agree"}
+
+// LikertSpec configures a rating question. Ratings run from 1 to the number
+// of labels and have no right answer.
+type LikertSpec struct {
+	Labels []string `json:"labels"`
+}
+
+// Scan implements the Scanner interface for LikertSpec
+func (ls *LikertSpec) Scan(value interface{}) error {
+	return scanJSON(value, ls)
+}
+
+// Value implements the Valuer interface for LikertSpec
+func (ls LikertSpec) Value() (driver.Value, error) {
+	return valueJSON(ls)
+}
+
+func (ls *LikertSpec) Validate() error {
+	if len(ls.Labels) < 2 {
+		return fmt.Errorf("rating scale must have at least two labels")
+	}
+	for _, label := range ls.Labels {
+		if label == "" {
+			return fmt.Errorf("rating scale label cannot be empty")
+		}
+	}
+	return nil
+}
+
+// ScaleLabels returns the labels of a rating question, falling back to a
+// five point agreement scale.
+func (q *Question) ScaleLabels() []string {
+	if q.Likert != nil && len(q.Likert.Labels) > 0 {
+		return q.Likert.Labels
+	}
+	return DefaultLikertLabels
+}
@@ -0,0 +1,78 @@
+package quiz
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+type MatchPair struct {
+	Prompt string `json:"prompt"`
+	Answer string `json:"answer"`
+}
+
+// MatchingSpec pairs every prompt with its answer. Distractors are extra
+// answers that match no prompt.
+type MatchingSpec struct {
+	Pairs        []MatchPair `json:"pairs"`
+	Distractors  []string    `json:"distractors,omitempty"`
+	AllOrNothing bool        `json:"all_or_nothing,omitempty"`
+}
+
+// Scan implements the Scanner interface for MatchingSpec
+func (ms *MatchingSpec) Scan(value interface{}) error {
+	return scanJSON(value, ms)
+}
+
+// Value implements the Valuer interface for MatchingSpec
+func (ms MatchingSpec) Value() (driver.Value, error) {
+	return valueJSON(ms)
+}
+
+func (ms *MatchingSpec) Validate() error {
+	if len(ms.Pairs) == 0 {
+		return fmt.Errorf("matching question must have at least one pair")
+	}
+	for _, pair := range ms.Pairs {
+		if pair.Prompt == "" || pair.Answer == "" {
+			return fmt.Errorf("matching pair needs both a prompt and an answer")
+		}
+	}
+	return nil
+}
+
+// Options returns the distinct answers learners pick from, in a fixed order.
+func (ms *MatchingSpec) Options() []string {
+	seen := make(map[string]bool)
+	options := make([]string, 0, len(ms.Pairs)+len(ms.Distractors))
+	for _, answer := range ms.answers() {
+		if !seen[answer] {
+			seen[answer] = true
+			options = append(options, answer)
+		}
+	}
+	return options
+}
+
+func (ms *MatchingSpec) answers() []string {
+	answers := make([]string, 0, len(ms.Pairs)+len(ms.Distractors))
+	for _, pair := range ms.Pairs {
+		answers = append(answers, pair.Answer)
+	}
+	return append(answers, ms.Distractors...)
+}
+
+// Credit returns the fraction of prompts matched correctly. The response
+// holds, for each prompt, an index into Options or -1 when left blank.
+func (ms *MatchingSpec) Credit(response []int) float64 {
+	if len(ms.Pairs) == 0 {
+		return 0
+	}
+	options := ms.Options()
+	correct := 0
+	for i, pair := range ms.Pairs {
+		if i < len(response) && response[i] >= 0 && response[i] < len(options) && options[response[i]] == pair.Answer {
+			correct++
+		}
+	}
+	return partialCredit(correct, len(ms.Pairs), ms.AllOrNothing)
+}
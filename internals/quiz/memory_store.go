@@ -1,9 +1,8 @@
 package quiz
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -97,6 +96,7 @@ func (s *MemoryStore) Store(quiz Quiz) error {
 	s.Lock()
 	defer s.Unlock()
 	s.QuizLastId++
+	quiz.ID = uint(s.QuizLastId)
 	s.Quizzes[s.QuizLastId] = &quiz
 	return nil
 }
@@ -124,20 +124,76 @@ func (s *MemoryStore) Delete(id int64) error {
 	return nil
 }
 
+// ExportQuizzes writes every quiz to the file as described at Export, in
+// the same list as SQLiteStore, with the map keys as quiz IDs.
 func (s *MemoryStore) ExportQuizzes(filename string) error {
-	data, err := json.MarshalIndent(s.Quizzes, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(filename, data, 0644)
+	s.RLock()
+	e := &Export{Quizzes: make([]*Quiz, 0, len(s.Quizzes))}
+	for id, q := range s.Quizzes {
+		exported := *q
+		exported.ID = uint(id)
+		e.Quizzes = append(e.Quizzes, &exported)
+	}
+	s.RUnlock()
+	sort.Slice(e.Quizzes, func(i, j int) bool { return e.Quizzes[i].ID < e.Quizzes[j].ID })
+	return writeExportFile(filename, e)
 }
 
-func (s *MemoryStore) ImportQuizzes(filename string) error {
-	data, err := os.ReadFile(filename)
+// ImportQuizzes imports the quizzes of an export file. Quizzes whose ID is
+// taken are skipped, overwritten or merged as opts.Mode says; quizzes without
+// an ID get the next one. The import works on a copy of the store, so that
+// either every quiz is imported or, on any error, none. The store keeps no
+// media, so the media of the export is ignored.
+func (s *MemoryStore) ImportQuizzes(filename string, opts ImportOptions) (*ImportReport, error) {
+	mode, err := opts.mode()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	e, err := readExportFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkExport(e, s.ValidateQuiz); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	quizzes := make(map[int64]*Quiz, len(s.Quizzes)+len(e.Quizzes))
+	for id, q := range s.Quizzes {
+		quizzes[id] = q
+	}
+	last := s.QuizLastId
+	report := &ImportReport{DryRun: opts.DryRun}
+	for _, q := range e.Quizzes {
+		id := int64(q.ID)
+		stored, found := quizzes[id]
+		action := ImportCreated
+		switch {
+		case id == 0 || !found:
+			if id == 0 {
+				id = last + 1
+			}
+			last = max(last, id)
+			q.ID = uint(id)
+			quizzes[id] = q
+		case mode == ImportOverwrite:
+			action = ImportOverwritten
+			quizzes[id] = q
+		case mode == ImportMerge:
+			action = ImportMerged
+			merged := *q
+			merged.Questions = mergeQuestions(stored.Questions, q.Questions)
+			quizzes[id] = &merged
+		default:
+			action = ImportSkipped
+		}
+		report.Quizzes = append(report.Quizzes, ImportedQuiz{ID: q.ID, Name: q.Name, Action: action})
+	}
+	if !opts.DryRun {
+		s.Quizzes, s.QuizLastId = quizzes, last
 	}
-	return json.Unmarshal(data, &s.Quizzes)
+	return report, nil
 }
 
 func (s *MemoryStore) AddAssignment(quizID int64, assignment Question) error {
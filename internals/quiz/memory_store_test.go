@@ -3,6 +3,7 @@ package quiz_test
 import (
 	"github.com/mbsof31/go-quiz/internals/quiz"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -120,19 +121,68 @@ func TestMemoryStore_ImportExport(t *testing.T) {
 
 	// Create a new store and import quizzes
 	newStore := quiz.NewStore()
-	err = newStore.ImportQuizzes(filename)
+	_, err = newStore.ImportQuizzes(filename, quiz.ImportOptions{})
 	assert.NoError(t, err)
 
 	// Verify imported quizzes
 	importedQuizzes := newStore.ListAllQuizzes()
 	assert.Len(t, importedQuizzes, 1)
 	assert.Equal(t, "Quiz for Export", importedQuizzes[0].Name)
+	assert.Equal(t, uint(1), importedQuizzes[0].ID)
+	assert.Len(t, importedQuizzes[0].Questions, 1)
 
 	// Cleanup
 	err = os.Remove(filename)
 	assert.NoError(t, err)
 }
 
+func TestMemoryStore_ImportModes(t *testing.T) {
+	store := quiz.NewStore()
+	for _, name := range []string{"First", "Second"} {
+		q := quiz.NewQuiz()
+		q.Name = name
+		q.Questions = []quiz.Question{{ID: 1, Content: "Old"}}
+		assert.NoError(t, store.Store(*q))
+	}
+	filename := filepath.Join(t.TempDir(), "quizzes.json")
+	// An older export: a map of quizzes by ID.
+	assert.NoError(t, os.WriteFile(filename, []byte(`{
+		"1": {"name": "First again", "questions": [{"ID": 1, "content": "New"}, {"content": "Added"}]},
+		"7": {"name": "Seventh", "questions": [{"content": "Q"}]}
+	}`), 0o644))
+
+	report, err := store.ImportQuizzes(filename, quiz.ImportOptions{Mode: quiz.ImportMerge, DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "created 1, merged 1, 0 media (dry run, nothing stored)", report.String())
+	assert.Len(t, store.ListAllQuizzes(), 2)
+
+	_, err = store.ImportQuizzes(filename, quiz.ImportOptions{Mode: quiz.ImportMerge})
+	assert.NoError(t, err)
+	first, err := store.FindQuizByID(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "First again", first.Name)
+	assert.Equal(t, []quiz.Question{{ID: 1, Content: "New"}, {Content: "Added"}}, first.Questions)
+	seventh, err := store.FindQuizByID(7)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), seventh.ID)
+
+	report, err = store.ImportQuizzes(filename, quiz.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "skipped 2, 0 media", report.String())
+
+	_, err = store.ImportQuizzes(filename, quiz.ImportOptions{Mode: "replace"})
+	assert.EqualError(t, err, `unknown import mode "replace", use skip, overwrite or merge`)
+
+	// One invalid quiz fails the whole import.
+	assert.NoError(t, os.WriteFile(filename, []byte(`{"version": 1, "quizzes": [
+		{"name": "Fine", "questions": [{"content": "Q"}]},
+		{"name": "", "questions": [{"content": "Q"}]}
+	]}`), 0o644))
+	_, err = store.ImportQuizzes(filename, quiz.ImportOptions{Mode: quiz.ImportOverwrite})
+	assert.EqualError(t, err, `quiz 2 (""): quiz name cannot be empty`)
+	assert.Len(t, store.ListAllQuizzes(), 3)
+}
+
 func TestMemoryStore_ValidateQuiz(t *testing.T) {
 	store := quiz.NewStore()
 
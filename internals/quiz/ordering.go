@@ -0,0 +1,68 @@
+package quiz
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/rand"
+)
+
+// OrderingSpec lists the items of an ordering question in their correct order.
+type OrderingSpec struct {
+	Items []string `json:"items"`
+	// AllOrNothing disables partial credit for items in the right position.
+	AllOrNothing bool `json:"all_or_nothing,omitempty"`
+}
+
+// Scan implements the Scanner interface for OrderingSpec
+func (o *OrderingSpec) Scan(value interface{}) error {
+	return scanJSON(value, o)
+}
+
+// Value implements the Valuer interface for OrderingSpec
+func (o OrderingSpec) Value() (driver.Value, error) {
+	return valueJSON(o)
+}
+
+func (o *OrderingSpec) Validate() error {
+	if len(o.Items) < 2 {
+		return fmt.Errorf("ordering question must have at least two items")
+	}
+	for _, item := range o.Items {
+		if item == "" {
+			return fmt.Errorf("ordering item cannot be empty")
+		}
+	}
+	return nil
+}
+
+// Credit returns the fraction of items the response, a sequence of item
+// indexes, puts in the right position.
+func (o *OrderingSpec) Credit(response []int) float64 {
+	if len(o.Items) == 0 {
+		return 0
+	}
+	correct := 0
+	for position, item := range response {
+		if position < len(o.Items) && item == position {
+			correct++
+		}
+	}
+	if len(response) != len(o.Items) {
+		// A response with missing or extra items is never fully right.
+		correct = min(correct, len(o.Items)-1)
+	}
+	return partialCredit(correct, len(o.Items), o.AllOrNothing)
+}
+
+func partialCredit(correct, total int, allOrNothing bool) float64 {
+	if allOrNothing && correct < total {
+		return 0
+	}
+	return float64(correct) / float64(total)
+}
+
+// Shuffle returns a permutation of 0..n-1 that is stable for a given seed, so
+// a page shows the same order every time it is loaded for an attempt.
+func Shuffle(n int, seed int64) []int {
+	return rand.New(rand.NewSource(seed)).Perm(n)
+}
@@ -0,0 +1,73 @@
+package quiz_test
+
+import (
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderingSpec_Credit(t *testing.T) {
+	spec := quiz.OrderingSpec{Items: []string{"Mix", "Knead", "Proof", "Bake"}}
+	assert.NoError(t, spec.Validate())
+
+	assert.Equal(t, 1.0, spec.Credit([]int{0, 1, 2, 3}))
+	assert.Equal(t, 0.5, spec.Credit([]int{1, 0, 2, 3}))
+	assert.Equal(t, 0.0, spec.Credit([]int{3, 2, 1, 0}))
+	assert.Equal(t, 0.75, spec.Credit([]int{0, 1, 2}), "missing item")
+
+	spec.AllOrNothing = true
+	assert.Equal(t, 0.0, spec.Credit([]int{1, 0, 2, 3}))
+	assert.Equal(t, 1.0, spec.Credit([]int{0, 1, 2, 3}))
+
+	assert.Error(t, (&quiz.OrderingSpec{Items: []string{"Only"}}).Validate())
+}
+
+func TestMatchingSpec_Credit(t *testing.T) {
+	spec := quiz.MatchingSpec{
+		Pairs: []quiz.MatchPair{
+			{Prompt: "Dog", Answer: "Mammal"},
+			{Prompt: "Cat", Answer: "Mammal"},
+			{Prompt: "Eagle", Answer: "Bird"},
+		},
+		Distractors: []string{"Fish"},
+	}
+	assert.NoError(t, spec.Validate())
+	assert.Equal(t, []string{"Mammal", "Bird", "Fish"}, spec.Options())
+
+	assert.Equal(t, 1.0, spec.Credit([]int{0, 0, 1}))
+	assert.InDelta(t, 2.0/3, spec.Credit([]int{0, 2, 1}), 1e-9)
+	assert.InDelta(t, 1.0/3, spec.Credit([]int{0, -1}), 1e-9)
+	assert.Equal(t, 0.0, spec.Credit([]int{7, 7, 7}))
+
+	spec.AllOrNothing = true
+	assert.Equal(t, 0.0, spec.Credit([]int{0, 2, 1}))
+
+	assert.Error(t, (&quiz.MatchingSpec{Pairs: []quiz.MatchPair{{Prompt: "Dog"}}}).Validate())
+}
+
+func TestGrade_PartialCredit(t *testing.T) {
+	q := quiz.NewQuiz()
+	q.Questions = []quiz.Question{
+		{ID: 1, Type: quiz.TypeOrdering, Points: 4, Penalty: 1, Ordering: &quiz.OrderingSpec{Items: []string{"a", "b", "c", "d"}}},
+		{ID: 2, Type: quiz.TypeMatching, Points: 2, Penalty: 1, Matching: &quiz.MatchingSpec{Pairs: []quiz.MatchPair{{Prompt: "x", Answer: "1"}, {Prompt: "y", Answer: "2"}}}},
+		{ID: 3, Type: quiz.TypeMatching, Penalty: 1, Matching: &quiz.MatchingSpec{Pairs: []quiz.MatchPair{{Prompt: "x", Answer: "1"}}}},
+	}
+	a := &quiz.Attempt{Answers: []quiz.Answer{
+		{QuestionID: 1, Items: quiz.IntList{0, 2, 1, 3}},
+		{QuestionID: 2, Items: quiz.IntList{1, 1}},
+		{QuestionID: 3, Items: quiz.IntList{-1}}, // left blank
+	}}
+
+	quiz.Grade(q, a)
+	assert.Equal(t, 2.0, a.Answers[0].Score)
+	assert.Equal(t, 1.0, a.Answers[1].Score)
+	assert.Equal(t, 0.0, a.Answers[2].Score)
+	assert.Equal(t, 7.0, a.MaxScore)
+}
+
+func TestShuffle(t *testing.T) {
+	order := quiz.Shuffle(5, 42)
+	assert.Equal(t, order, quiz.Shuffle(5, 42))
+	assert.ElementsMatch(t, []int{0, 1, 2, 3, 4}, order)
+}
@@ -0,0 +1,34 @@
+package quiz
+
+import (
+	"database/sql/driver"
+	"errors"
+	"time"
+)
+
+// ErrSheetRecorded is returned for a second bubble sheet of the same
+// student in a print run.
+var ErrSheetRecorded = errors.New("a sheet with this student number was already recorded for the print run")
+
+// Printing records a print run of bubble sheets with a copy of the quiz as
+// it was printed, so scanned sheets are graded against the questions and
+// choices on the paper even after the quiz is edited.
+type Printing struct {
+	ID        uint         `gorm:"primaryKey"`
+	QuizID    uint         `gorm:"index"`
+	Quiz      QuizSnapshot `gorm:"type:json"`
+	CreatedAt time.Time
+}
+
+// QuizSnapshot is a copy of a quiz with its questions and choices.
+type QuizSnapshot Quiz
+
+// Scan implements the Scanner interface for QuizSnapshot
+func (qs *QuizSnapshot) Scan(value interface{}) error {
+	return scanJSON(value, (*Quiz)(qs))
+}
+
+// Value implements the Valuer interface for QuizSnapshot
+func (qs QuizSnapshot) Value() (driver.Value, error) {
+	return valueJSON(Quiz(qs))
+}
@@ -1,19 +1,123 @@
 package quiz
 
+import "errors"
+
+// ErrInvalidQuestion is returned for a question that does not validate.
+var ErrInvalidQuestion = errors.New("invalid question")
+
+const (
+	TypeSingleChoice = "single-choice"
+	TypeMultiChoice  = "multi-choice"
+	TypeShortText    = "short-text"
+	TypeNumeric      = "numeric"
+	TypeOrdering     = "ordering"
+	TypeMatching     = "matching"
+	TypeEssay        = "essay"
+	TypeCloze        = "cloze"
+	TypeLikert       = "likert"
+	TypeCode         = "code"
+)
+
 type Question struct {
 	ID      uint     `gorm:"primaryKey"`
 	QuizID  uint     `gorm:"index"` // Foreign key
 	Type    string   `json:"type" form:"type"`
 	Content string   `json:"content" form:"content"`
 	Choices []Choice `gorm:"foreignKey:QuestionID" json:"choices,omitempty" form:"choices,omitempty"`
-	Meta    JSONMap  `gorm:"type:json" json:"meta,omitempty" form:"meta,omitempty"`
+	// The image blob is shown with the question content, described by ImageAlt.
+	ImageKey string `json:"image_key,omitempty"`
+	ImageAlt string `json:"image_alt,omitempty" form:"image_alt,omitempty"`
+	// The audio or video blob of listening and viewing questions. MaxPlays
+	// limits how often it can be played in an attempt; zero means no limit.
+	// Captions holds WebVTT cues and Transcript the full text, for learners
+	// who cannot hear or see the recording.
+	MediaKey   string `json:"media_key,omitempty"`
+	MediaType  string `json:"media_type,omitempty"`
+	MaxPlays   int    `json:"max_plays,omitempty" form:"max_plays,omitempty"`
+	Captions   string `json:"captions,omitempty" form:"captions,omitempty"`
+	Transcript string `json:"transcript,omitempty" form:"transcript,omitempty"`
+	// Text and Numeric hold the accepted answers of short-text and numeric
+	// questions, which have no choices.
+	Text    *TextSpec    `gorm:"type:json" json:"text,omitempty" form:"text,omitempty"`
+	Numeric *NumericSpec `gorm:"type:json" json:"numeric,omitempty" form:"numeric,omitempty"`
+	// Ordering and Matching hold the items of ordering and matching questions.
+	Ordering *OrderingSpec `gorm:"type:json" json:"ordering,omitempty" form:"ordering,omitempty"`
+	Matching *MatchingSpec `gorm:"type:json" json:"matching,omitempty" form:"matching,omitempty"`
+	Essay    *EssaySpec    `gorm:"type:json" json:"essay,omitempty" form:"essay,omitempty"`
+	Likert   *LikertSpec   `gorm:"type:json" json:"likert,omitempty" form:"likert,omitempty"`
+	Code     *CodeSpec     `gorm:"type:json" json:"code,omitempty" form:"code,omitempty"`
+	// Explanation tells learners why the correct answer is correct.
+	Explanation string `json:"explanation,omitempty" form:"explanation,omitempty"`
+	// Points is the weight of the question; zero means the default of 1.
+	Points float64 `json:"points,omitempty" form:"points,omitempty"`
+	// Penalty is deducted for a wrong answer. When it is zero,
+	// PenaltyFraction of the question points is deducted instead.
+	Penalty         float64 `json:"penalty,omitempty" form:"penalty,omitempty"`
+	PenaltyFraction float64 `json:"penalty_fraction,omitempty" form:"penalty_fraction,omitempty"`
+	// Tags group questions by topic, for authors.
+	Tags StringList `gorm:"type:json" json:"tags,omitempty" form:"tags,omitempty"`
+	Meta JSONMap    `gorm:"type:json" json:"meta,omitempty" form:"meta,omitempty"`
 }
 
 func NewQuestion() *Question {
 	return &Question{
-		Type:    "multi-choice",
+		Type:    TypeMultiChoice,
 		Content: "Untitled question",
 		Choices: []Choice{},
 		Meta:    make(map[string]interface{}),
 	}
 }
+
+// MaxPoints returns the points awarded for a fully correct answer.
+func (q *Question) MaxPoints() float64 {
+	if q.Type == TypeEssay && q.Essay != nil && len(q.Essay.Rubric) > 0 {
+		return q.Essay.RubricTotal()
+	}
+	if q.Points <= 0 {
+		return 1
+	}
+	return q.Points
+}
+
+// WrongPenalty returns the points deducted for a wrong answer.
+func (q *Question) WrongPenalty() float64 {
+	if q.Penalty > 0 {
+		return q.Penalty
+	}
+	return q.PenaltyFraction * q.MaxPoints()
+}
+
+// HasChoices reports whether the question is answered by picking choices.
+func (q *Question) HasChoices() bool {
+	switch q.Type {
+	case TypeShortText, TypeNumeric, TypeOrdering, TypeMatching, TypeEssay, TypeCloze, TypeLikert, TypeCode:
+		return false
+	default:
+		return true
+	}
+}
+
+// Graded reports whether answers to the question have a right answer.
+func (q *Question) Graded() bool {
+	return q.Type != TypeLikert
+}
+
+// ManuallyGraded reports whether answers need a person to grade them.
+func (q *Question) ManuallyGraded() bool {
+	return q.Type == TypeEssay
+}
+
+// Checkable reports whether an answer can be graded on its own while the
+// attempt is in progress, which rules out answers that need a grader or a
+// test run.
+func (q *Question) Checkable() bool {
+	return q.Graded() && !q.ManuallyGraded() && q.Type != TypeCode
+}
+
+// Prompt returns the content shown to learners, with cloze blanks hidden.
+func (q *Question) Prompt() string {
+	if q.Type == TypeCloze {
+		return clozePrompt(q.Content)
+	}
+	return q.Content
+}
@@ -1,20 +1,78 @@
 package quiz
 
+import (
+	"errors"
+	"time"
+)
+
+// RevealPolicy decides when learners see explanations and choice feedback.
+type RevealPolicy string
+
+const (
+	RevealImmediately    RevealPolicy = "immediately"
+	RevealAfterSubmitted RevealPolicy = "after-submission"
+	RevealAfterClose     RevealPolicy = "after-close"
+	RevealNever          RevealPolicy = "never"
+)
+
+var ErrQuizClosed = errors.New("quiz is closed")
+
 type Quiz struct {
-	ID          uint       `gorm:"primaryKey"`
-	Name        string     `json:"name" form:"name"`
-	Description string     `json:"description,omitempty" form:"description,omitempty"`
-	Questions   []Question `gorm:"foreignKey:QuizID;references:ID" json:"questions,omitempty" form:"questions,omitempty"`
-	Meta        JSONMap    `gorm:"type:json" json:"meta,omitempty" form:"meta,omitempty"`
+	ID              uint          `gorm:"primaryKey"`
+	Name            string        `json:"name" form:"name"`
+	OwnerID         uint          `gorm:"index" json:"owner_id,omitempty"` // Zero when anyone may manage the quiz
+	Description     string        `json:"description,omitempty" form:"description,omitempty"`
+	CoverKey        string        `json:"cover_key,omitempty"` // Blob of the cover image shown on quiz cards
+	Questions       []Question    `gorm:"foreignKey:QuizID;references:ID" json:"questions,omitempty" form:"questions,omitempty"`
+	MaxAttempts     int           `json:"max_attempts,omitempty" form:"max_attempts,omitempty"`
+	AttemptCooldown time.Duration `json:"attempt_cooldown,omitempty" form:"attempt_cooldown,omitempty"`
+	GradingPolicy   GradingPolicy `json:"grading_policy,omitempty" form:"grading_policy,omitempty"`
+	RevealPolicy    RevealPolicy  `json:"reveal_policy,omitempty" form:"reveal_policy,omitempty"`
+	ClosesAt        *time.Time    `json:"closes_at,omitempty" form:"closes_at,omitempty"`
+	// SubmitGrace is how long after ClosesAt attempts started before the
+	// close may still be submitted.
+	SubmitGrace time.Duration `json:"submit_grace,omitempty" form:"submit_grace,omitempty"`
+	// Survey quizzes are ungraded, and submitted responses are not linked
+	// to the user who gave them: a Participation records who responded.
+	Survey bool    `json:"survey,omitempty" form:"survey,omitempty"`
+	Meta   JSONMap `gorm:"type:json" json:"meta,omitempty" form:"meta,omitempty"`
 }
 
 var store = NewStore()
 
 func NewQuiz() *Quiz {
 	return &Quiz{
-		Name:        "Untitled quiz",
-		Description: "",
-		Questions:   []Question{},
-		Meta:        make(map[string]interface{}),
+		Name:          "Untitled quiz",
+		Description:   "",
+		Questions:     []Question{},
+		GradingPolicy: GradeHighest,
+		RevealPolicy:  RevealAfterSubmitted,
+		Meta:          make(map[string]interface{}),
+	}
+}
+
+// Closed reports whether the quiz close date has passed.
+func (q *Quiz) Closed(now time.Time) bool {
+	return q.ClosesAt != nil && !now.Before(*q.ClosesAt)
+}
+
+// AcceptsSubmission reports whether attempts may still be submitted: until
+// the close date, and for the grace period after it.
+func (q *Quiz) AcceptsSubmission(now time.Time) bool {
+	return q.ClosesAt == nil || now.Before(q.ClosesAt.Add(q.SubmitGrace))
+}
+
+// RevealFeedback reports whether explanations and choice feedback may be
+// shown for the attempt. An unset policy reveals after submission.
+func (q *Quiz) RevealFeedback(a *Attempt, now time.Time) bool {
+	switch q.RevealPolicy {
+	case RevealImmediately:
+		return true
+	case RevealAfterClose:
+		return a.Submitted() && q.Closed(now)
+	case RevealNever:
+		return false
+	default: // RevealAfterSubmitted
+		return a.Submitted()
 	}
 }
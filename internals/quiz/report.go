@@ -0,0 +1,75 @@
+package quiz
+
+// ItemReport aggregates the responses to one question of a quiz.
+type ItemReport struct {
+	Question  Question
+	Labels    []string // Rating labels or choice contents
+	Counts    []int    // Responses per label
+	Responses int
+	Mean      float64 // Mean rating, for rating questions only
+	Texts     []string
+}
+
+// Report aggregates the submitted answers to a quiz without naming anyone.
+type Report struct {
+	Quiz     *Quiz
+	Attempts int
+	Items    []ItemReport
+}
+
+// BuildReport computes response distributions per question from the
+// answers of submitted attempts.
+func BuildReport(q *Quiz, attempts int, answers []Answer) Report {
+	byQuestion := make(map[uint][]Answer)
+	for _, a := range answers {
+		if !a.Empty() {
+			byQuestion[a.QuestionID] = append(byQuestion[a.QuestionID], a)
+		}
+	}
+
+	report := Report{Quiz: q, Attempts: attempts}
+	for _, question := range q.Questions {
+		item := ItemReport{Question: question}
+		responses := byQuestion[question.ID]
+		item.Responses = len(responses)
+
+		switch {
+		case question.Type == TypeLikert:
+			item.Labels = question.ScaleLabels()
+			item.Counts = make([]int, len(item.Labels))
+			sum, rated := 0, 0
+			for _, a := range responses {
+				if a.Rating >= 1 && a.Rating <= len(item.Labels) {
+					item.Counts[a.Rating-1]++
+					sum += a.Rating
+					rated++
+				}
+			}
+			if rated > 0 {
+				item.Mean = float64(sum) / float64(rated)
+			}
+		case question.HasChoices():
+			index := make(map[uint]int, len(question.Choices))
+			for i, choice := range question.Choices {
+				item.Labels = append(item.Labels, choice.Content)
+				index[choice.ID] = i
+			}
+			item.Counts = make([]int, len(item.Labels))
+			for _, a := range responses {
+				for _, id := range a.ChoiceIDs {
+					if i, found := index[id]; found {
+						item.Counts[i]++
+					}
+				}
+			}
+		default:
+			for _, a := range responses {
+				if a.Text != "" {
+					item.Texts = append(item.Texts, a.Text)
+				}
+			}
+		}
+		report.Items = append(report.Items, item)
+	}
+	return report
+}
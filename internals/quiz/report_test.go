@@ -0,0 +1,61 @@
+package quiz_test
+
+import (
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrade_Survey(t *testing.T) {
+	q := quiz.NewQuiz()
+	q.Questions = []quiz.Question{
+		{ID: 1, Type: quiz.TypeLikert},
+		{ID: 2, Type: quiz.TypeSingleChoice, Choices: []quiz.Choice{{ID: 1, IsCorrect: true}, {ID: 2}}},
+	}
+	a := &quiz.Attempt{Answers: []quiz.Answer{{QuestionID: 1, Rating: 4}, {QuestionID: 2, ChoiceIDs: quiz.UintList{1}}}}
+
+	// Rating questions never count towards the score
+	quiz.Grade(q, a)
+	assert.Equal(t, 0.0, a.Answers[0].MaxScore)
+	assert.Equal(t, 1.0, a.Score)
+	assert.Equal(t, 1.0, a.MaxScore)
+
+	// Survey quizzes are not graded at all
+	q.Survey = true
+	quiz.Grade(q, a)
+	assert.Equal(t, 0.0, a.Score)
+	assert.Equal(t, 0.0, a.MaxScore)
+}
+
+func TestBuildReport(t *testing.T) {
+	q := quiz.NewQuiz()
+	q.Survey = true
+	q.Questions = []quiz.Question{
+		{ID: 1, Type: quiz.TypeLikert, Likert: &quiz.LikertSpec{Labels: []string{"Bad", "OK", "Good"}}},
+		{ID: 2, Type: quiz.TypeMultiChoice, Choices: []quiz.Choice{{ID: 1, Content: "Tea"}, {ID: 2, Content: "Coffee"}}},
+		{ID: 3, Type: quiz.TypeEssay},
+	}
+	answers := []quiz.Answer{
+		{QuestionID: 1, Rating: 3},
+		{QuestionID: 1, Rating: 2},
+		{QuestionID: 1, Rating: 3},
+		{QuestionID: 1}, // skipped
+		{QuestionID: 2, ChoiceIDs: quiz.UintList{1, 2}},
+		{QuestionID: 2, ChoiceIDs: quiz.UintList{2}},
+		{QuestionID: 3, Text: "More biscuits"},
+	}
+
+	report := quiz.BuildReport(q, 4, answers)
+	assert.Equal(t, 4, report.Attempts)
+	assert.Len(t, report.Items, 3)
+
+	rating := report.Items[0]
+	assert.Equal(t, []string{"Bad", "OK", "Good"}, rating.Labels)
+	assert.Equal(t, []int{0, 1, 2}, rating.Counts)
+	assert.Equal(t, 3, rating.Responses)
+	assert.InDelta(t, 8.0/3, rating.Mean, 1e-9)
+
+	assert.Equal(t, []int{1, 2}, report.Items[1].Counts)
+	assert.Equal(t, []string{"More biscuits"}, report.Items[2].Texts)
+}
@@ -0,0 +1,200 @@
+package quiz
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TextSpec configures how short-text answers are matched.
+type TextSpec struct {
+	// Accepted answers are compared ignoring surrounding and repeated
+	// whitespace, and letter case unless CaseSensitive is set.
+	Accepted []string `json:"accepted"`
+	// Patterns are regular expressions that must match the whole answer.
+	Patterns      []string `json:"patterns,omitempty"`
+	CaseSensitive bool     `json:"case_sensitive,omitempty"`
+}
+
+// Scan implements the Scanner interface for TextSpec
+func (ts *TextSpec) Scan(value interface{}) error {
+	return scanJSON(value, ts)
+}
+
+// Value implements the Valuer interface for TextSpec
+func (ts TextSpec) Value() (driver.Value, error) {
+	return valueJSON(ts)
+}
+
+func (ts *TextSpec) Validate() error {
+	if len(ts.Accepted) == 0 && len(ts.Patterns) == 0 {
+		return fmt.Errorf("short-text question must have at least one accepted answer or pattern")
+	}
+	for _, p := range ts.Patterns {
+		if _, err := ts.compile(p); err != nil {
+			return fmt.Errorf("invalid answer pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func (ts *TextSpec) compile(pattern string) (*regexp.Regexp, error) {
+	flags := "(?i)"
+	if ts.CaseSensitive {
+		flags = ""
+	}
+	return regexp.Compile(flags + `^(?:` + pattern + `)$`)
+}
+
+// Matches reports whether the response is one of the accepted answers.
+func (ts *TextSpec) Matches(response string) bool {
+	response = normalizeText(response, ts.CaseSensitive)
+	for _, accepted := range ts.Accepted {
+		if normalizeText(accepted, ts.CaseSensitive) == response {
+			return true
+		}
+	}
+	for _, p := range ts.Patterns {
+		if re, err := ts.compile(p); err == nil && re.MatchString(response) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeText(s string, caseSensitive bool) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if !caseSensitive {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+// NumericUnit is a unit accepted by a numeric question. The response value
+// is divided by Multiplier before it is compared, so with an answer in metres
+// the unit "cm" has a multiplier of 100.
+type NumericUnit struct {
+	Unit       string  `json:"unit"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// NumericSpec configures how numeric answers are matched.
+type NumericSpec struct {
+	Answer            float64       `json:"answer"`
+	Tolerance         float64       `json:"tolerance,omitempty"`          // Absolute
+	RelativeTolerance float64       `json:"relative_tolerance,omitempty"` // Fraction of Answer
+	Units             []NumericUnit `json:"units,omitempty"`
+	UnitRequired      bool          `json:"unit_required,omitempty"`
+}
+
+// Scan implements the Scanner interface for NumericSpec
+func (ns *NumericSpec) Scan(value interface{}) error {
+	return scanJSON(value, ns)
+}
+
+// Value implements the Valuer interface for NumericSpec
+func (ns NumericSpec) Value() (driver.Value, error) {
+	return valueJSON(ns)
+}
+
+func (ns *NumericSpec) Validate() error {
+	if ns.Tolerance < 0 || ns.RelativeTolerance < 0 {
+		return fmt.Errorf("numeric tolerance cannot be negative")
+	}
+	for _, u := range ns.Units {
+		if u.Unit == "" || u.Multiplier == 0 {
+			return fmt.Errorf("numeric unit needs a name and a non-zero multiplier")
+		}
+	}
+	if ns.UnitRequired && len(ns.Units) == 0 {
+		return fmt.Errorf("numeric question requires a unit but lists none")
+	}
+	return nil
+}
+
+// Matches reports whether the response, such as "12.5 cm", is within
+// tolerance of the expected answer.
+func (ns *NumericSpec) Matches(response string) bool {
+	value, unit, err := parseNumber(response)
+	if err != nil {
+		return false
+	}
+	if unit == "" && ns.UnitRequired {
+		return false
+	}
+	if unit != "" {
+		found := false
+		for _, u := range ns.Units {
+			if u.Unit == unit {
+				value, found = value/u.Multiplier, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	diff := math.Abs(value - ns.Answer)
+	return diff <= ns.Tolerance || diff <= ns.RelativeTolerance*math.Abs(ns.Answer)
+}
+
+// parseNumber splits a response into its leading number and trailing unit.
+// Commas are read as described at normalizeNumber.
+func parseNumber(s string) (float64, string, error) {
+	s = strings.TrimSpace(s)
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(unicode.IsDigit(r) || strings.ContainsRune("+-.,eE", r))
+	})
+	if end < 0 {
+		end = len(s)
+	}
+	// Back off until the prefix parses, so "5eV" reads as 5 with unit "eV".
+	for number := s[:end]; number != ""; number = number[:len(number)-1] {
+		normal, err := normalizeNumber(number)
+		if err != nil {
+			return 0, "", fmt.Errorf("%q: %w", s, err)
+		}
+		if value, err := strconv.ParseFloat(normal, 64); err == nil {
+			return value, strings.TrimSpace(s[len(number):]), nil
+		}
+	}
+	return 0, "", fmt.Errorf("%q is not a number", s)
+}
+
+// normalizeNumber rewrites the commas of a number for strconv.ParseFloat.
+// A single comma without a dot is a decimal comma, as in "9,81"; otherwise
+// commas separate thousands, as in "1,234.5", and must be followed by
+// groups of three digits. A single comma followed by three digits, as in
+// "1,000", reads either way and is an error. Commas in other places are
+// left for ParseFloat to reject.
+func normalizeNumber(s string) (string, error) {
+	if !strings.Contains(s, ",") {
+		return s, nil
+	}
+	mantissa := s
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa = s[:i]
+	}
+	if strings.Count(s, ",") == 1 && !strings.Contains(s, ".") {
+		_, decimals, _ := strings.Cut(mantissa, ",")
+		if len(decimals) == 3 && strings.Trim(decimals, "0123456789") == "" {
+			return "", fmt.Errorf("the comma could separate decimals or thousands")
+		}
+		return strings.Replace(s, ",", ".", 1), nil
+	}
+	integer, _, _ := strings.Cut(mantissa, ".")
+	groups := strings.Split(strings.TrimLeft(integer, "+-"), ",")
+	if len(groups[0]) == 0 || len(groups[0]) > 3 {
+		return s, nil
+	}
+	for _, group := range groups[1:] {
+		if len(group) != 3 {
+			return s, nil
+		}
+	}
+	return strings.ReplaceAll(s, ",", ""), nil
+}
@@ -0,0 +1,85 @@
+package quiz_test
+
+import (
+	"testing"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextSpec_Matches(t *testing.T) {
+	spec := quiz.TextSpec{Accepted: []string{"New  York"}, Patterns: []string{`colou?r`}}
+	assert.NoError(t, spec.Validate())
+
+	assert.True(t, spec.Matches("new york"))
+	assert.True(t, spec.Matches("  NEW   YORK "))
+	assert.True(t, spec.Matches("Color"))
+	assert.True(t, spec.Matches("colour"))
+	assert.False(t, spec.Matches("colours"))
+	assert.False(t, spec.Matches("York"))
+
+	spec.CaseSensitive = true
+	assert.False(t, spec.Matches("new york"))
+	assert.True(t, spec.Matches("New York"))
+
+	assert.Error(t, (&quiz.TextSpec{}).Validate())
+	assert.Error(t, (&quiz.TextSpec{Patterns: []string{"("}}).Validate())
+}
+
+func TestNumericSpec_Matches(t *testing.T) {
+	spec := quiz.NumericSpec{Answer: 9.81, Tolerance: 0.05}
+	assert.NoError(t, spec.Validate())
+	assert.True(t, spec.Matches("9.8"))
+	assert.True(t, spec.Matches(" 9,81 "))
+	assert.False(t, spec.Matches("9.7"))
+	assert.False(t, spec.Matches("nine"))
+	assert.False(t, spec.Matches("9.81 m/s"), "unknown unit")
+
+	spec = quiz.NumericSpec{Answer: 1000, RelativeTolerance: 0.01}
+	assert.True(t, spec.Matches("1009"))
+	assert.False(t, spec.Matches("1011"))
+	assert.True(t, spec.Matches("1e3"))
+	assert.True(t, spec.Matches("1,000.0"))
+	assert.True(t, spec.Matches("1000,0"))
+	assert.False(t, spec.Matches("1,000"), "the comma could separate decimals or thousands")
+	assert.False(t, spec.Matches("1,00.0"), "thousands come in groups of three")
+
+	spec = quiz.NumericSpec{Answer: 1234.5}
+	assert.True(t, spec.Matches("1,234.5"))
+	assert.True(t, spec.Matches("1234,5"))
+	assert.False(t, spec.Matches("1,234"))
+	spec = quiz.NumericSpec{Answer: 1234567}
+	assert.True(t, spec.Matches("1,234,567"))
+	assert.False(t, spec.Matches("1,234,56"))
+
+	spec = quiz.NumericSpec{
+		Answer: 1.5,
+		Units:  []quiz.NumericUnit{{Unit: "m", Multiplier: 1}, {Unit: "cm", Multiplier: 100}},
+	}
+	assert.True(t, spec.Matches("1.5"))
+	assert.True(t, spec.Matches("1.5 m"))
+	assert.True(t, spec.Matches("150cm"))
+	assert.False(t, spec.Matches("150 m"))
+	spec.UnitRequired = true
+	assert.False(t, spec.Matches("1.5"))
+
+	assert.Error(t, (&quiz.NumericSpec{Tolerance: -1}).Validate())
+	assert.Error(t, (&quiz.NumericSpec{Units: []quiz.NumericUnit{{Unit: "m"}}}).Validate())
+}
+
+func TestGrade_TextAndNumeric(t *testing.T) {
+	q := quiz.NewQuiz()
+	q.Questions = []quiz.Question{
+		{ID: 1, Type: quiz.TypeShortText, Text: &quiz.TextSpec{Accepted: []string{"Paris"}}},
+		{ID: 2, Type: quiz.TypeNumeric, Points: 2, Penalty: 1, Numeric: &quiz.NumericSpec{Answer: 42}},
+	}
+	a := &quiz.Attempt{Answers: []quiz.Answer{
+		{QuestionID: 1, Text: "paris"},
+		{QuestionID: 2, Text: "41"},
+	}}
+
+	quiz.Grade(q, a)
+	assert.Equal(t, 1.0, a.Answers[0].Score)
+	assert.Equal(t, -1.0, a.Answers[1].Score)
+	assert.Equal(t, 3.0, a.MaxScore)
+}
@@ -0,0 +1,294 @@
+package quiz
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StartAttempt opens a new attempt for the user once the quiz attempt limit
+// and cooldown allow it.
+func (s *SQLiteStore) StartAttempt(quizID, userID uint) (*Attempt, error) {
+	var attempt *Attempt
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var quiz Quiz
+		if err := tx.First(&quiz, quizID).Error; err != nil {
+			return err
+		}
+		var prior []Attempt
+		if err := tx.Where("quiz_id = ? AND user_id = ?", quizID, userID).Order("started_at").Find(&prior).Error; err != nil {
+			return err
+		}
+		if quiz.Survey {
+			var participations []Participation
+			if err := tx.Where("quiz_id = ? AND user_id = ?", quizID, userID).Find(&participations).Error; err != nil {
+				return err
+			}
+			for _, p := range participations {
+				submittedAt := p.SubmittedAt
+				prior = append(prior, Attempt{QuizID: quizID, UserID: userID, StartedAt: submittedAt, SubmittedAt: &submittedAt})
+			}
+			sort.SliceStable(prior, func(i, j int) bool { return prior[i].StartedAt.Before(prior[j].StartedAt) })
+		}
+		now := time.Now()
+		if err := CanStartAttempt(&quiz, prior, now); err != nil {
+			return err
+		}
+		attempt = NewAttempt(quizID, userID, now)
+		return tx.Create(attempt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+func (s *SQLiteStore) FindAttemptByID(id uint) (*Attempt, error) {
+	var attempt Attempt
+	result := s.DB.Preload("Answers").Preload("Plays").First(&attempt, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &attempt, nil
+}
+
+func (s *SQLiteStore) ListAttempts(quizID, userID uint) ([]Attempt, error) {
+	var attempts []Attempt
+	result := s.DB.Where("quiz_id = ? AND user_id = ?", quizID, userID).Order("started_at").Find(&attempts)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list attempts: %w", result.Error)
+	}
+	return attempts, nil
+}
+
+// SubmitAttempt grades the answers and closes the attempt. It returns
+// ErrQuizClosed once the quiz close date and grace period have passed.
+func (s *SQLiteStore) SubmitAttempt(attemptID uint, answers []Answer) (*Attempt, error) {
+	var attempt Attempt
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&attempt, attemptID).Error; err != nil {
+			return err
+		}
+		if attempt.Submitted() {
+			return ErrAttemptSubmitted
+		}
+		var quiz Quiz
+		if err := tx.Preload("Questions.Choices").First(&quiz, attempt.QuizID).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if !quiz.AcceptsSubmission(now) {
+			return ErrQuizClosed
+		}
+
+		// Answers checked during the attempt stand, whatever was posted
+		// for their questions since.
+		if err := tx.Where("attempt_id = ?", attempt.ID).Find(&attempt.Answers).Error; err != nil {
+			return err
+		}
+		checked := make(map[uint]bool, len(attempt.Answers))
+		for _, answer := range attempt.Answers {
+			checked[answer.QuestionID] = true
+		}
+		for _, answer := range answers {
+			if checked[answer.QuestionID] {
+				continue
+			}
+			answer.ID, answer.AttemptID = 0, attempt.ID
+			attempt.Answers = append(attempt.Answers, answer)
+		}
+		Grade(&quiz, &attempt)
+		attempt.SubmittedAt = &now
+		if quiz.Survey {
+			// The response keeps neither the user nor the time of day;
+			// the participation alone records who responded.
+			if err := tx.Create(&Participation{QuizID: quiz.ID, UserID: attempt.UserID, SubmittedAt: now}).Error; err != nil {
+				return err
+			}
+			day := now.UTC().Truncate(24 * time.Hour)
+			attempt.UserID, attempt.StartedAt, attempt.SubmittedAt = 0, day, &day
+			if err := tx.Model(&Play{}).Where("attempt_id = ?", attempt.ID).Update("started_at", day).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&attempt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// CheckAnswer grades the answer to a single question of an attempt in
+// progress and keeps it, so that the learner can see its feedback but no
+// longer change it. Only quizzes that reveal feedback immediately allow it.
+func (s *SQLiteStore) CheckAnswer(attemptID uint, answer Answer) (*Answer, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var attempt Attempt
+		if err := tx.First(&attempt, attemptID).Error; err != nil {
+			return err
+		}
+		if attempt.Submitted() {
+			return ErrAttemptSubmitted
+		}
+		var quiz Quiz
+		if err := tx.Preload("Questions.Choices").First(&quiz, attempt.QuizID).Error; err != nil {
+			return err
+		}
+		if !quiz.AcceptsSubmission(time.Now()) {
+			return ErrQuizClosed
+		}
+		if quiz.Survey || quiz.RevealPolicy != RevealImmediately {
+			return fmt.Errorf("quiz %d does not reveal answers before submission", quiz.ID)
+		}
+		var question *Question
+		for i := range quiz.Questions {
+			if quiz.Questions[i].ID == answer.QuestionID {
+				question = &quiz.Questions[i]
+			}
+		}
+		if question == nil || !question.Checkable() {
+			return fmt.Errorf("question %d cannot be checked", answer.QuestionID)
+		}
+		if answer.Empty() {
+			return fmt.Errorf("answer the question before checking it")
+		}
+		var checked int64
+		if err := tx.Model(&Answer{}).Where("attempt_id = ? AND question_id = ?", attempt.ID, question.ID).Count(&checked).Error; err != nil {
+			return err
+		}
+		if checked > 0 {
+			return ErrAnswerChecked
+		}
+		answer.ID, answer.AttemptID = 0, attempt.ID
+		answer.Score, answer.MaxScore = gradeQuestion(question, &answer), question.MaxPoints()
+		return tx.Create(&answer).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &answer, nil
+}
+
+// OfficialScore returns the user's score for the quiz under its grading policy.
+func (s *SQLiteStore) OfficialScore(quizID, userID uint) (score, maxScore float64, ok bool, err error) {
+	var quiz Quiz
+	if err := s.DB.First(&quiz, quizID).Error; err != nil {
+		return 0, 0, false, err
+	}
+	attempts, err := s.ListAttempts(quizID, userID)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	score, maxScore, ok = OfficialScore(quiz.GradingPolicy, attempts)
+	return score, maxScore, ok, nil
+}
+
+// ListPendingReviews returns the answers awaiting a grader on the quizzes
+// owned by ownerID and on quizzes without an owner, oldest first.
+func (s *SQLiteStore) ListPendingReviews(ownerID uint) ([]ReviewItem, error) {
+	var answers []Answer
+	result := s.DB.Joins("JOIN attempts ON attempts.id = answers.attempt_id").
+		Joins("JOIN quizzes ON quizzes.id = attempts.quiz_id").
+		Where("answers.pending = ? AND quizzes.owner_id IN ?", true, []uint{ownerID, 0}).
+		Order("answers.id").
+		Find(&answers)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list pending reviews: %w", result.Error)
+	}
+
+	items := make([]ReviewItem, 0, len(answers))
+	for _, answer := range answers {
+		item, err := s.reviewItem(s.DB, answer)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, nil
+}
+
+// FindReviewItem loads a manually graded answer with its question and quiz.
+func (s *SQLiteStore) FindReviewItem(answerID uint) (*ReviewItem, error) {
+	var answer Answer
+	if err := s.DB.First(&answer, answerID).Error; err != nil {
+		return nil, err
+	}
+	return s.reviewItem(s.DB, answer)
+}
+
+func (s *SQLiteStore) reviewItem(tx *gorm.DB, answer Answer) (*ReviewItem, error) {
+	item := &ReviewItem{Answer: answer, AttemptID: answer.AttemptID}
+	if err := tx.First(&item.Question, answer.QuestionID).Error; err != nil {
+		return nil, err
+	}
+	var quiz Quiz
+	if err := tx.Select("id", "name").First(&quiz, item.Question.QuizID).Error; err != nil {
+		return nil, err
+	}
+	item.QuizID, item.QuizName = quiz.ID, quiz.Name
+	return item, nil
+}
+
+// ReviewAnswer records a grader's scores for a manually graded answer that
+// is still pending and updates the attempt, which leaves review once no
+// answer is pending.
+func (s *SQLiteStore) ReviewAnswer(answerID uint, review Review) (*Attempt, error) {
+	var attempt Attempt
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var answer Answer
+		if err := tx.First(&answer, answerID).Error; err != nil {
+			return err
+		}
+		var question Question
+		if err := tx.First(&question, answer.QuestionID).Error; err != nil {
+			return err
+		}
+		if !question.ManuallyGraded() {
+			return fmt.Errorf("question %d is graded automatically", question.ID)
+		}
+		if !answer.Pending {
+			return ErrAnswerReviewed
+		}
+		if err := ApplyReview(&question, &answer, review, time.Now()); err != nil {
+			return err
+		}
+		if err := tx.Save(&answer).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Preload("Answers").First(&attempt, answer.AttemptID).Error; err != nil {
+			return err
+		}
+		attempt.RefreshScore()
+		return tx.Model(&attempt).Updates(map[string]interface{}{
+			"score":          attempt.Score,
+			"pending_review": attempt.PendingReview,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// QuizReport aggregates the answers of every submitted attempt at the quiz.
+func (s *SQLiteStore) QuizReport(quizID uint) (*Report, error) {
+	quiz, err := s.FindQuizByID(quizID)
+	if err != nil {
+		return nil, err
+	}
+	var attempts int64
+	submitted := s.DB.Model(&Attempt{}).Select("id").Where("quiz_id = ? AND submitted_at IS NOT NULL", quizID)
+	if err := submitted.Count(&attempts).Error; err != nil {
+		return nil, fmt.Errorf("failed to count attempts: %w", err)
+	}
+	var answers []Answer
+	if err := s.DB.Where("attempt_id IN (?)", submitted).Find(&answers).Error; err != nil {
+		return nil, fmt.Errorf("failed to list answers: %w", err)
+	}
+	report := BuildReport(quiz, int(attempts), answers)
+	return &report, nil
+}
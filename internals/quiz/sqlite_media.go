@@ -0,0 +1,239 @@
+package quiz
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/blob"
+	"gorm.io/gorm"
+)
+
+var errNoBlobs = errors.New("no blob store configured")
+
+// Blob key prefixes. Keys are named after the content, so identical files
+// share a blob wherever they are used.
+const (
+	ImagePrefix      = "images"
+	AttachmentPrefix = "attachments"
+)
+
+// SetQuizCover replaces the cover image of the quiz; nil removes it.
+func (s *SQLiteStore) SetQuizCover(ctx context.Context, quizID uint, data []byte) error {
+	rows := func() *gorm.DB { return s.DB.Model(&Quiz{}).Where("id = ?", quizID) }
+	return s.setMedia(ctx, rows, "cover_key", ImagePrefix, data, nil)
+}
+
+// SetQuestionImage replaces the image of a question of the quiz; nil
+// removes it.
+func (s *SQLiteStore) SetQuestionImage(ctx context.Context, quizID, questionID uint, data []byte, alt string) error {
+	rows := func() *gorm.DB { return s.DB.Model(&Question{}).Where("id = ? AND quiz_id = ?", questionID, quizID) }
+	return s.setMedia(ctx, rows, "image_key", ImagePrefix, data, map[string]interface{}{"image_alt": alt})
+}
+
+// SetChoiceThumb replaces the thumbnail of a choice of the quiz; nil
+// removes it.
+func (s *SQLiteStore) SetChoiceThumb(ctx context.Context, quizID, choiceID uint, data []byte) error {
+	rows := func() *gorm.DB {
+		questions := s.DB.Model(&Question{}).Select("id").Where("quiz_id = ?", quizID)
+		return s.DB.Model(&Choice{}).Where("id = ? AND question_id IN (?)", choiceID, questions)
+	}
+	return s.setMedia(ctx, rows, "thumb_key", ImagePrefix, data, nil)
+}
+
+// PutMedia stores blobs named with ContentKey, such as the media of
+// imported questions, before the rows that refer to them.
+func (s *SQLiteStore) PutMedia(ctx context.Context, blobs map[string][]byte) error {
+	if len(blobs) == 0 {
+		return nil
+	}
+	if s.Blobs == nil {
+		return errNoBlobs
+	}
+	for key, data := range blobs {
+		if err := s.Blobs.Put(ctx, key, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Media returns the blob of an image key found on a quiz, question or choice.
+func (s *SQLiteStore) Media(ctx context.Context, key string) ([]byte, error) {
+	if s.Blobs == nil {
+		return nil, errNoBlobs
+	}
+	return s.Blobs.Get(ctx, key)
+}
+
+// setMedia stores data as a blob and points the single row selected by rows
+// at it, releasing the blob the row pointed at before.
+func (s *SQLiteStore) setMedia(ctx context.Context, rows func() *gorm.DB, column, prefix string, data []byte, extra map[string]interface{}) error {
+	if s.Blobs == nil {
+		return errNoBlobs
+	}
+	var old []sql.NullString // NULL in rows added before the column
+	if err := rows().Pluck(column, &old).Error; err != nil {
+		return err
+	}
+	if len(old) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	key := ""
+	if len(data) > 0 {
+		key = blob.ContentKey(prefix, data)
+		if err := s.Blobs.Put(ctx, key, data); err != nil {
+			return err
+		}
+	}
+	updates := map[string]interface{}{column: key}
+	for name, value := range extra {
+		updates[name] = value
+	}
+	if err := updated(rows().Updates(updates)); err != nil {
+		return err
+	}
+	if old[0].String != key {
+		return s.releaseBlob(ctx, old[0].String)
+	}
+	return nil
+}
+
+// SetQuestionMedia replaces the audio or video recording of a question of
+// the quiz; nil removes it.
+func (s *SQLiteStore) SetQuestionMedia(ctx context.Context, quizID, questionID uint, data []byte, contentType string) error {
+	rows := func() *gorm.DB { return s.DB.Model(&Question{}).Where("id = ? AND quiz_id = ?", questionID, quizID) }
+	if len(data) == 0 {
+		contentType = ""
+	}
+	return s.setMedia(ctx, rows, "media_key", AttachmentPrefix, data, map[string]interface{}{"media_type": contentType})
+}
+
+// UpdateMediaSettings sets the play limit, captions and transcript of the
+// recording of a question of the quiz.
+func (s *SQLiteStore) UpdateMediaSettings(quizID, questionID uint, maxPlays int, captions, transcript string) error {
+	if maxPlays < 0 {
+		return fmt.Errorf("play limit cannot be negative")
+	}
+	if err := ValidateCaptions(captions); err != nil {
+		return err
+	}
+	return updated(s.DB.Model(&Question{}).Where("id = ? AND quiz_id = ?", questionID, quizID).
+		Updates(map[string]interface{}{"max_plays": maxPlays, "captions": captions, "transcript": transcript}))
+}
+
+// OpenMedia opens the blob of a recording for streaming.
+func (s *SQLiteStore) OpenMedia(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if s.Blobs == nil {
+		return nil, errNoBlobs
+	}
+	return s.Blobs.Open(ctx, key)
+}
+
+// StartPlay counts a play of the question recording in the attempt, unless
+// the previous play started less than playGrace ago. It returns ErrPlayLimit
+// once the question play limit is used up.
+func (s *SQLiteStore) StartPlay(attemptID uint, question *Question, now time.Time) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		play := Play{AttemptID: attemptID, QuestionID: question.ID}
+		if err := tx.Where(&play).FirstOrInit(&play).Error; err != nil {
+			return err
+		}
+		if play.Count > 0 && now.Sub(play.StartedAt) < playGrace {
+			return nil
+		}
+		if question.MaxPlays > 0 && play.Count >= question.MaxPlays {
+			return ErrPlayLimit
+		}
+		play.Count++
+		play.StartedAt = now
+		return tx.Save(&play).Error
+	})
+}
+
+// releaseBlob deletes a blob once no quiz, question or choice uses it.
+func (s *SQLiteStore) releaseBlob(ctx context.Context, key string) error {
+	if key == "" {
+		return nil
+	}
+	for _, use := range []struct {
+		model  interface{}
+		column string
+	}{{&Quiz{}, "cover_key"}, {&Question{}, "image_key"}, {&Question{}, "media_key"}, {&Choice{}, "thumb_key"}} {
+		var count int64
+		if err := s.DB.Model(use.model).Where(use.column+" = ?", key).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+	return s.Blobs.Delete(ctx, key)
+}
+
+// MoveMediaToBlobs moves the images that older versions kept in the
+// database into the blob store and drops their columns. It does nothing
+// once the columns are gone.
+func (s *SQLiteStore) MoveMediaToBlobs(ctx context.Context) error {
+	for _, legacy := range []struct {
+		model          interface{}
+		column, target string
+	}{
+		{&Quiz{}, "cover", "cover_key"},
+		{&Question{}, "image", "image_key"},
+		{&Choice{}, "thumb", "thumb_key"},
+	} {
+		migrator := s.DB.Migrator()
+		if !migrator.HasColumn(legacy.model, legacy.column) {
+			continue
+		}
+		if s.Blobs == nil {
+			return errNoBlobs
+		}
+		var lastID uint
+		for {
+			var rows []struct {
+				ID   uint
+				Data []byte
+			}
+			err := s.DB.Model(legacy.model).Select("id, "+legacy.column+" AS data").
+				Where("id > ? AND "+legacy.column+" IS NOT NULL AND length("+legacy.column+") > 0", lastID).
+				Order("id").Limit(100).Find(&rows).Error
+			if err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				break
+			}
+			for _, row := range rows {
+				key := blob.ContentKey(ImagePrefix, row.Data)
+				if err := s.Blobs.Put(ctx, key, row.Data); err != nil {
+					return fmt.Errorf("moving %s of %d: %w", legacy.column, row.ID, err)
+				}
+				if err := s.DB.Model(legacy.model).Where("id = ?", row.ID).Update(legacy.target, key).Error; err != nil {
+					return err
+				}
+				lastID = row.ID
+			}
+		}
+		if err := migrator.DropColumn(legacy.model, legacy.column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updated turns an update that matched no row into ErrRecordNotFound.
+func updated(result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package quiz
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecordPrinting returns the print run of the quiz as it is now, which must
+// be loaded with its questions and choices. The latest print run of the quiz
+// is reused when the quiz has not changed since.
+func (s *SQLiteStore) RecordPrinting(q *Quiz) (*Printing, error) {
+	snapshot, err := json.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	var latest []Printing
+	if err := s.DB.Where("quiz_id = ?", q.ID).Order("id DESC").Limit(1).Find(&latest).Error; err != nil {
+		return nil, err
+	}
+	if len(latest) == 1 {
+		if stored, err := json.Marshal(Quiz(latest[0].Quiz)); err == nil && bytes.Equal(stored, snapshot) {
+			return &latest[0], nil
+		}
+	}
+	printing := &Printing{QuizID: q.ID, Quiz: QuizSnapshot(*q)}
+	if err := s.DB.Create(printing).Error; err != nil {
+		return nil, err
+	}
+	return printing, nil
+}
+
+func (s *SQLiteStore) FindPrinting(id uint) (*Printing, error) {
+	var printing Printing
+	if err := s.DB.First(&printing, id).Error; err != nil {
+		return nil, err
+	}
+	return &printing, nil
+}
+
+// RecordPaperAttempt grades the answers read from a bubble sheet of the
+// print run against the quiz as printed, and stores them as a submitted
+// attempt of the student. Only the questions answered on the sheet count;
+// questions written on the exam paper are left to the teacher.
+func (s *SQLiteStore) RecordPaperAttempt(p *Printing, studentNumber string, answers []Answer, review []string) (*Attempt, error) {
+	printed := Quiz(p.Quiz)
+	onSheet := make(map[uint]bool, len(answers))
+	for _, answer := range answers {
+		onSheet[answer.QuestionID] = true
+	}
+	graded := printed
+	graded.Questions = nil
+	for _, question := range printed.Questions {
+		if onSheet[question.ID] {
+			graded.Questions = append(graded.Questions, question)
+		}
+	}
+
+	now := time.Now()
+	attempt := NewAttempt(p.QuizID, 0, now)
+	attempt.SubmittedAt = &now
+	attempt.PrintingID = p.ID
+	attempt.StudentNumber = studentNumber
+	attempt.ScanReview = review
+	attempt.Answers = answers
+	Grade(&graded, attempt)
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if studentNumber != "" {
+			var count int64
+			if err := tx.Model(&Attempt{}).Where("printing_id = ? AND student_number = ?", p.ID, studentNumber).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrSheetRecorded
+			}
+		}
+		return tx.Create(attempt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// ListPaperAttempts returns the attempts read from bubble sheets of the
+// quiz, latest first.
+func (s *SQLiteStore) ListPaperAttempts(quizID uint) ([]Attempt, error) {
+	var attempts []Attempt
+	result := s.DB.Where("quiz_id = ? AND printing_id <> 0", quizID).Order("id DESC").Find(&attempts)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list paper attempts: %w", result.Error)
+	}
+	return attempts, nil
+}
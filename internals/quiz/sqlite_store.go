@@ -1,16 +1,22 @@
 package quiz
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/glebarez/sqlite"
+	"github.com/mbsof31/go-quiz/internals/blob"
 	"gorm.io/gorm"
 )
 
 type SQLiteStore struct {
 	DB *gorm.DB
+	// Blobs keeps the images of quizzes, questions and choices; rows only
+	// hold their keys.
+	Blobs blob.Store
 }
 
 func NewSQLiteStore(dsn string) (*SQLiteStore, error) {
@@ -26,8 +32,21 @@ func NewSQLiteStore(dsn string) (*SQLiteStore, error) {
 	return store, nil
 }
 
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	db, err := s.DB.DB()
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
 func (s *SQLiteStore) migrate() error {
-	return s.DB.AutoMigrate(&Quiz{}, &Question{}, &Choice{})
+	if err := s.DB.AutoMigrate(&Quiz{}, &Question{}, &Choice{}, &Attempt{}, &Answer{}, &Play{}, &Printing{}, &User{}, &Setting{}, &Participation{}); err != nil {
+		return err
+	}
+	// Older seeds misspelt the rating question type.
+	return s.DB.Model(&Question{}).Where("type = ?", "liquert-scale").Update("type", TypeLikert).Error
 }
 
 func (s *SQLiteStore) ListAllQuizzes() ([]*Quiz, error) {
@@ -78,7 +97,15 @@ func (s *SQLiteStore) ValidateQuiz(quiz *Quiz) error {
 }
 
 func (s *SQLiteStore) Store(quiz Quiz) error {
-	if err := s.ValidateQuiz(&quiz); err != nil {
+	if err := s.validateAll(&quiz); err != nil {
+		return err
+	}
+	return s.DB.Create(&quiz).Error
+}
+
+// validateAll validates the quiz with its questions and their choices.
+func (s *SQLiteStore) validateAll(quiz *Quiz) error {
+	if err := s.ValidateQuiz(quiz); err != nil {
 		return err
 	}
 	for _, question := range quiz.Questions {
@@ -91,7 +118,7 @@ func (s *SQLiteStore) Store(quiz Quiz) error {
 			}
 		}
 	}
-	return s.DB.Create(&quiz).Error
+	return nil
 }
 
 func (s *SQLiteStore) Update(id uint, quiz Quiz) error {
@@ -101,48 +128,427 @@ func (s *SQLiteStore) Update(id uint, quiz Quiz) error {
 	return s.DB.Model(&Quiz{}).Where("id = ?", id).Updates(quiz).Error
 }
 
+// SaveQuiz stores the quiz as edited, with its questions and choices: new
+// ones are created, and the stored ones it no longer has are deleted.
+// Question and choice IDs that belong to another quiz or question are
+// treated as new.
+func (s *SQLiteStore) SaveQuiz(quiz *Quiz) error {
+	if err := s.ValidateQuiz(quiz); err != nil {
+		return err
+	}
+	for i := range quiz.Questions {
+		question := &quiz.Questions[i]
+		if err := s.ValidateQuestion(question); err != nil {
+			return fmt.Errorf("question %d: %w", i+1, err)
+		}
+		for _, choice := range question.Choices {
+			if err := s.ValidateChoice(&choice); err != nil {
+				return fmt.Errorf("question %d: %w", i+1, err)
+			}
+		}
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if quiz.ID == 0 {
+			return tx.Create(quiz).Error
+		}
+		var stored Quiz
+		if err := tx.Preload("Questions.Choices").First(&stored, quiz.ID).Error; err != nil {
+			return err
+		}
+		storedChoices := make(map[uint]map[uint]bool, len(stored.Questions))
+		for _, question := range stored.Questions {
+			storedChoices[question.ID] = make(map[uint]bool, len(question.Choices))
+			for _, choice := range question.Choices {
+				storedChoices[question.ID][choice.ID] = true
+			}
+		}
+		kept := make(map[uint]bool, len(quiz.Questions))
+		var removedChoices []uint
+		for i := range quiz.Questions {
+			question := &quiz.Questions[i]
+			choices, found := storedChoices[question.ID]
+			if !found {
+				question.ID = 0
+			}
+			question.QuizID = quiz.ID
+			kept[question.ID] = true
+			keptChoices := make(map[uint]bool, len(question.Choices))
+			for j := range question.Choices {
+				choice := &question.Choices[j]
+				if !choices[choice.ID] {
+					choice.ID = 0
+				}
+				choice.QuestionID = question.ID
+				keptChoices[choice.ID] = true
+			}
+			for id := range choices {
+				if !keptChoices[id] {
+					removedChoices = append(removedChoices, id)
+				}
+			}
+		}
+		var removed []Question
+		for _, question := range stored.Questions {
+			if !kept[question.ID] {
+				removed = append(removed, question)
+			}
+		}
+		if err := deleteQuestions(tx, removed); err != nil {
+			return err
+		}
+		if len(removedChoices) > 0 {
+			if err := tx.Delete(&Choice{}, removedChoices).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(quiz).Error
+	})
+}
+
 func (s *SQLiteStore) Delete(id uint) error {
 	return s.DB.Delete(&Quiz{}, id).Error
 }
 
+// Backup writes a copy of the whole database to the file, which must not
+// exist. The copy is consistent even while the database is in use.
+func (s *SQLiteStore) Backup(filename string) error {
+	if _, err := os.Stat(filename); err == nil {
+		return fmt.Errorf("%s already exists", filename)
+	}
+	return s.DB.Exec("VACUUM INTO ?", filename).Error
+}
+
+// ExportQuizzes writes every quiz, with its questions, choices and media, to
+// the file as described at Export.
 func (s *SQLiteStore) ExportQuizzes(filename string) error {
-	quizzes, err := s.ListAllQuizzes()
+	e, err := s.Export(context.Background())
 	if err != nil {
-		return fmt.Errorf("failed to list quizzes: %w", err)
+		return err
 	}
-	data, err := json.MarshalIndent(quizzes, "", "  ")
+	return writeExportFile(filename, e)
+}
+
+// ImportQuizzes imports the quizzes of an export file, see Import.
+func (s *SQLiteStore) ImportQuizzes(filename string, opts ImportOptions) (*ImportReport, error) {
+	e, err := readExportFile(filename)
 	if err != nil {
-		return fmt.Errorf("failed to marshal quizzes: %w", err)
+		return nil, err
 	}
-	if err := os.WriteFile(filename, data, 0644); err != nil {
-		return fmt.Errorf("failed to write to file: %w", err)
+	return s.Import(context.Background(), e, opts)
+}
+
+// Export gathers the quizzes with the IDs, or all quizzes when none are
+// given, with their questions, choices and media. Media missing from the
+// blob store is left out.
+func (s *SQLiteStore) Export(ctx context.Context, ids ...uint) (*Export, error) {
+	var quizzes []*Quiz
+	query := s.DB.Preload("Questions.Choices").Order("id")
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
 	}
-	return nil
+	if err := query.Find(&quizzes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list quizzes: %w", err)
+	}
+	if len(quizzes) < len(ids) {
+		return nil, fmt.Errorf("cannot find all the quizzes with the ids of: %v", ids)
+	}
+	e := &Export{Version: ExportVersion, ExportedAt: time.Now().UTC(), Quizzes: quizzes, Media: make(map[string][]byte)}
+	for _, q := range quizzes {
+		for _, key := range q.MediaKeys() {
+			if _, done := e.Media[key]; done {
+				continue
+			}
+			data, err := s.Media(ctx, key)
+			if errors.Is(err, blob.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("quiz %d: media %s: %w", q.ID, key, err)
+			}
+			e.Media[key] = data
+		}
+	}
+	return e, nil
 }
 
-func (s *SQLiteStore) ImportQuizzes(filename string) error {
-	data, err := os.ReadFile(filename)
+// Import stores the quizzes of an export in one transaction: either all of
+// them are stored or, on any error, none. Quizzes whose ID is taken are
+// skipped, overwritten or merged as opts.Mode says, and the others keep
+// their ID. Question and choice IDs that other rows use are replaced.
+func (s *SQLiteStore) Import(ctx context.Context, e *Export, opts ImportOptions) (*ImportReport, error) {
+	mode, err := opts.mode()
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return nil, err
 	}
-	var quizzes []*Quiz
-	if err := json.Unmarshal(data, &quizzes); err != nil {
-		return fmt.Errorf("failed to unmarshal quizzes: %w", err)
+	if err := checkExport(e, s.validateAll); err != nil {
+		return nil, err
+	}
+	report := &ImportReport{DryRun: opts.DryRun}
+	var replaced []string
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		media := make(map[string][]byte)
+		for _, q := range e.Quizzes {
+			action, keys, err := importQuiz(tx, q, mode)
+			if err != nil {
+				return fmt.Errorf("importing %q: %w", q.Name, err)
+			}
+			report.Quizzes = append(report.Quizzes, ImportedQuiz{ID: q.ID, Name: q.Name, Action: action})
+			if action == ImportSkipped {
+				continue
+			}
+			replaced = append(replaced, keys...)
+			for _, key := range q.MediaKeys() {
+				if data, found := e.Media[key]; found {
+					media[key] = data
+				}
+			}
+		}
+		report.Media = len(media)
+		if opts.DryRun {
+			return errDryRun
+		}
+		// Blobs are stored last, so that a failure rolls the rows back.
+		return s.PutMedia(ctx, media)
+	})
+	if errors.Is(err, errDryRun) {
+		return report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The media of overwritten and merged quizzes that nothing uses any more.
+	released := make(map[string]bool)
+	for _, key := range replaced {
+		if released[key] {
+			continue
+		}
+		released[key] = true
+		if err := s.releaseBlob(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// importQuiz stores an imported quiz in the transaction, deciding by mode
+// what to do when its ID is taken. It returns the media keys of the stored
+// quiz it replaced, which may no longer be used. Overwriting a quiz fails
+// when it would delete questions that attempts answered.
+func importQuiz(tx *gorm.DB, q *Quiz, mode ImportMode) (ImportAction, []string, error) {
+	var stored Quiz
+	found := false
+	if q.ID != 0 {
+		result := tx.Preload("Questions.Choices").Limit(1).Find(&stored, q.ID)
+		if result.Error != nil {
+			return "", nil, result.Error
+		}
+		found = result.RowsAffected > 0
+	}
+	full := tx.Session(&gorm.Session{FullSaveAssociations: true})
+	if !found {
+		if err := freeIDs(tx, q.Questions, nil); err != nil {
+			return "", nil, err
+		}
+		return ImportCreated, nil, tx.Create(q).Error
+	}
+
+	switch mode {
+	case ImportOverwrite:
+		imported := make(map[uint]bool)
+		for _, question := range q.Questions {
+			imported[question.ID] = true
+		}
+		var dropped []uint
+		for _, question := range stored.Questions {
+			if !imported[question.ID] {
+				dropped = append(dropped, question.ID)
+			}
+		}
+		if len(dropped) > 0 {
+			var answered []uint
+			if err := tx.Model(&Answer{}).Where("question_id IN ?", dropped).Distinct().Pluck("question_id", &answered).Error; err != nil {
+				return "", nil, err
+			}
+			if len(answered) > 0 {
+				return "", nil, fmt.Errorf("overwriting quiz %d would delete question %d, which attempts answered; merge instead", q.ID, answered[0])
+			}
+		}
+		if err := deleteQuestions(tx, stored.Questions); err != nil {
+			return "", nil, err
+		}
+		if err := freeIDs(tx, q.Questions, nil); err != nil {
+			return "", nil, err
+		}
+		return ImportOverwritten, stored.MediaKeys(), full.Save(q).Error
+	case ImportMerge:
+		own := make(map[uint]bool)
+		for _, question := range stored.Questions {
+			own[question.ID] = true
+		}
+		// The choices of replaced questions are replaced too.
+		var replacedIDs []uint
+		for _, question := range q.Questions {
+			if own[question.ID] {
+				replacedIDs = append(replacedIDs, question.ID)
+			}
+		}
+		if len(replacedIDs) > 0 {
+			if err := tx.Where("question_id IN ?", replacedIDs).Delete(&Choice{}).Error; err != nil {
+				return "", nil, err
+			}
+		}
+		if err := freeIDs(tx, q.Questions, own); err != nil {
+			return "", nil, err
+		}
+		return ImportMerged, stored.MediaKeys(), full.Save(q).Error
 	}
-	for _, quiz := range quizzes {
-		if err := s.Store(*quiz); err != nil {
-			return fmt.Errorf("failed to store quiz: %w", err)
+	return ImportSkipped, nil, nil
+}
+
+// freeIDs clears the question and choice IDs that stored rows or earlier
+// rows of the import use, so that the imported rows get new ones rather
+// than being dropped as conflicts. The questions with IDs in keep belong to
+// the quiz being imported and keep their ID.
+func freeIDs(tx *gorm.DB, questions []Question, keep map[uint]bool) error {
+	var questionIDs, choiceIDs []uint
+	for _, question := range questions {
+		if question.ID != 0 {
+			questionIDs = append(questionIDs, question.ID)
+		}
+		for _, choice := range question.Choices {
+			if choice.ID != 0 {
+				choiceIDs = append(choiceIDs, choice.ID)
+			}
+		}
+	}
+	taken := func(model interface{}, ids []uint) (map[uint]bool, error) {
+		set := make(map[uint]bool)
+		if len(ids) == 0 {
+			return set, nil
+		}
+		var found []uint
+		if err := tx.Model(model).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range found {
+			set[id] = true
+		}
+		return set, nil
+	}
+	takenQuestions, err := taken(&Question{}, questionIDs)
+	if err != nil {
+		return err
+	}
+	takenChoices, err := taken(&Choice{}, choiceIDs)
+	if err != nil {
+		return err
+	}
+	for i := range questions {
+		question := &questions[i]
+		if takenQuestions[question.ID] && !keep[question.ID] {
+			question.ID = 0
+		}
+		takenQuestions[question.ID] = question.ID != 0
+		question.QuizID = 0
+		for j := range question.Choices {
+			choice := &question.Choices[j]
+			if takenChoices[choice.ID] {
+				choice.ID = 0
+			}
+			takenChoices[choice.ID] = choice.ID != 0
+			choice.QuestionID = 0
 		}
 	}
 	return nil
 }
 
+// deleteQuestions deletes the questions with their choices.
+func deleteQuestions(tx *gorm.DB, questions []Question) error {
+	var ids []uint
+	for _, question := range questions {
+		ids = append(ids, question.ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Where("question_id IN ?", ids).Delete(&Choice{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&Question{}, ids).Error
+}
+
 func (s *SQLiteStore) ValidateQuestion(question *Question) error {
 	if question.Content == "" {
 		return fmt.Errorf("question content cannot be empty")
 	}
-	if len(question.Choices) == 0 {
-		return fmt.Errorf("question must have at least one choice")
+	switch question.Type {
+	case TypeShortText:
+		if question.Text == nil {
+			return fmt.Errorf("short-text question must have accepted answers")
+		}
+		if err := question.Text.Validate(); err != nil {
+			return err
+		}
+	case TypeNumeric:
+		if question.Numeric == nil {
+			return fmt.Errorf("numeric question must have an expected value")
+		}
+		if err := question.Numeric.Validate(); err != nil {
+			return err
+		}
+	case TypeOrdering:
+		if question.Ordering == nil {
+			return fmt.Errorf("ordering question must have items")
+		}
+		if err := question.Ordering.Validate(); err != nil {
+			return err
+		}
+	case TypeMatching:
+		if question.Matching == nil {
+			return fmt.Errorf("matching question must have pairs")
+		}
+		if err := question.Matching.Validate(); err != nil {
+			return err
+		}
+	case TypeCloze:
+		if _, err := ParseCloze(question.Content); err != nil {
+			return err
+		}
+	case TypeCode:
+		if question.Code == nil {
+			return fmt.Errorf("code question must have tests")
+		}
+		if err := question.Code.Validate(); err != nil {
+			return err
+		}
+	case TypeLikert:
+		if question.Likert != nil {
+			if err := question.Likert.Validate(); err != nil {
+				return err
+			}
+		}
+	case TypeEssay:
+		if question.Essay != nil {
+			if err := question.Essay.Validate(); err != nil {
+				return err
+			}
+		}
+	default:
+		if len(question.Choices) == 0 {
+			return fmt.Errorf("question must have at least one choice")
+		}
+	}
+	if question.Points < 0 || question.Penalty < 0 {
+		return fmt.Errorf("question points and penalty cannot be negative")
+	}
+	if question.PenaltyFraction < 0 || question.PenaltyFraction > 1 {
+		return fmt.Errorf("question penalty fraction must be between 0 and 1")
+	}
+	if question.MaxPlays < 0 {
+		return fmt.Errorf("question play limit cannot be negative")
+	}
+	if err := ValidateCaptions(question.Captions); err != nil {
+		return err
 	}
 	return nil
 }
@@ -159,6 +565,38 @@ func (s *SQLiteStore) AddAssignment(quizID uint, assignment Question) error {
 	return s.DB.Save(&quiz).Error
 }
 
+// AddQuestions validates the questions and adds them all to the quiz, or
+// none of them. A question that does not validate is reported as
+// ErrInvalidQuestion.
+func (s *SQLiteStore) AddQuestions(quizID uint, questions []Question) error {
+	for i := range questions {
+		question := &questions[i]
+		if err := s.ValidateQuestion(question); err != nil {
+			return fmt.Errorf("%w %d: %v", ErrInvalidQuestion, i+1, err)
+		}
+		for _, choice := range question.Choices {
+			if err := s.ValidateChoice(&choice); err != nil {
+				return fmt.Errorf("%w %d: %v", ErrInvalidQuestion, i+1, err)
+			}
+		}
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&Quiz{}, quizID).Error; err != nil {
+			return err
+		}
+		for i := range questions {
+			questions[i].ID, questions[i].QuizID = 0, quizID
+			for j := range questions[i].Choices {
+				questions[i].Choices[j].ID, questions[i].Choices[j].QuestionID = 0, 0
+			}
+			if err := tx.Create(&questions[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (s *SQLiteStore) RemoveAssignment(quizID uint, assignmentID uint) error {
 	var assignment Question
 	result := s.DB.Where("quiz_id = ? AND id = ?", quizID, assignmentID).First(&assignment)
@@ -1,10 +1,17 @@
 package quiz_test
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/mbsof31/go-quiz/internals/blob"
 	"github.com/mbsof31/go-quiz/internals/quiz"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 func setupStore(t *testing.T) *quiz.SQLiteStore {
@@ -14,7 +21,7 @@ func setupStore(t *testing.T) *quiz.SQLiteStore {
 }
 
 func teardownStore(store *quiz.SQLiteStore) {
-	store.DB.Exec("DROP TABLE quizzes; DROP TABLE questions; DROP TABLE choices;") // Clean up
+	store.DB.Exec("DROP TABLE quizzes; DROP TABLE questions; DROP TABLE choices; DROP TABLE attempts; DROP TABLE answers; DROP TABLE plays; DROP TABLE printings; DROP TABLE users; DROP TABLE settings; DROP TABLE participations;") // Clean up
 }
 
 func TestSQLiteStore_Quiz(t *testing.T) {
@@ -108,6 +115,71 @@ func TestSQLiteStore_Question(t *testing.T) {
 	assert.Len(t, q.Questions, 1)
 }
 
+func TestSQLiteStore_AddQuestions(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+
+	assert.NoError(t, store.Store(quiz.Quiz{Name: "Geography", Questions: []quiz.Question{{Type: quiz.TypeCloze, Content: "{{Paris}}"}}}))
+	questions := []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "Capital of France?", Choices: []quiz.Choice{{Content: "Paris", IsCorrect: true}}},
+		{Type: quiz.TypeSingleChoice, Content: "Capital of Italy?"},
+	}
+	err := store.AddQuestions(1, questions)
+	assert.ErrorIs(t, err, quiz.ErrInvalidQuestion)
+	assert.EqualError(t, err, "invalid question 2: question must have at least one choice")
+	q, err := store.FindQuizByID(1)
+	assert.NoError(t, err)
+	assert.Len(t, q.Questions, 1, "nothing is added")
+
+	questions[1].Choices = []quiz.Choice{{Content: "Rome", IsCorrect: true}}
+	assert.NoError(t, store.AddQuestions(1, questions))
+	q, err = store.FindQuizByID(1)
+	assert.NoError(t, err)
+	if assert.Len(t, q.Questions, 3) {
+		assert.Equal(t, "Capital of Italy?", q.Questions[2].Content)
+		assert.Equal(t, "Rome", q.Questions[2].Choices[0].Content)
+	}
+
+	assert.Error(t, store.AddQuestions(9, questions), "no quiz 9")
+}
+
+func TestSQLiteStore_SaveQuiz(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+
+	q := &quiz.Quiz{Name: "Geography", Questions: []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "Capital of France?",
+			Choices: []quiz.Choice{{Content: "Paris", IsCorrect: true}, {Content: "Lyon"}}},
+		{Type: quiz.TypeShortText, Content: "Capital of Italy?", Text: &quiz.TextSpec{Accepted: []string{"Rome"}}},
+	}}
+	assert.NoError(t, store.SaveQuiz(q))
+	assert.NoError(t, store.Store(quiz.Quiz{Name: "Other", Questions: []quiz.Question{{Type: quiz.TypeCloze, Content: "{{x}}"}}}))
+
+	q, err := store.FindQuizByID(1)
+	assert.NoError(t, err)
+	q.Questions[0].Choices = []quiz.Choice{q.Questions[0].Choices[0], {Content: "Marseille"}}
+	q.Questions = []quiz.Question{q.Questions[0], {ID: 3, Type: quiz.TypeShortText, Content: "Capital of Spain?", Text: &quiz.TextSpec{Accepted: []string{"Madrid"}}}}
+	assert.NoError(t, store.SaveQuiz(q))
+
+	q, err = store.FindQuizByID(1)
+	assert.NoError(t, err)
+	assert.Len(t, q.Questions, 2)
+	assert.Equal(t, uint(1), q.Questions[0].ID)
+	assert.Equal(t, []string{"Paris", "Marseille"}, []string{q.Questions[0].Choices[0].Content, q.Questions[0].Choices[1].Content})
+	assert.Equal(t, uint(1), q.Questions[0].Choices[0].ID)
+	assert.Equal(t, "Capital of Spain?", q.Questions[1].Content)
+	assert.NotEqual(t, uint(3), q.Questions[1].ID, "the question of another quiz is not taken over")
+	var choices int64
+	store.DB.Model(&quiz.Choice{}).Count(&choices)
+	assert.Equal(t, int64(2), choices, "removed choices are deleted")
+	other, err := store.FindQuizByID(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "{{x}}", other.Questions[0].Content)
+
+	q.Questions[1].Text = nil
+	assert.EqualError(t, store.SaveQuiz(q), "question 2: short-text question must have accepted answers")
+}
+
 func TestSQLiteStore_Choice(t *testing.T) {
 	store := setupStore(t)
 	defer teardownStore(store)
@@ -128,39 +200,690 @@ func TestSQLiteStore_Choice(t *testing.T) {
 	assert.Equal(t, "Choice 1", qz.Questions[0].Choices[0].Content)
 }
 
-/*func TestImportExportQuizzes(t *testing.T) {
+func TestSQLiteStore_Attempt(t *testing.T) {
 	store := setupStore(t)
 	defer teardownStore(store)
 
-	// Create and store a quiz with questions and choices
 	q1 := quiz.NewQuiz()
-	q1.Name = "Quiz 1"
+	q1.Name = "Quiz with Attempts"
+	q1.MaxAttempts = 2
+	q1.GradingPolicy = quiz.GradeHighest
+	q := quiz.NewQuestion()
+	q.Type = quiz.TypeSingleChoice
+	q.Choices = []quiz.Choice{{Content: "Right", IsCorrect: true}, {Content: "Wrong"}}
+	q1.Questions = []quiz.Question{*q}
+	err := store.Store(*q1)
+	assert.NoError(t, err)
+
+	qz, err := store.FindQuizByID(1)
+	assert.NoError(t, err)
+	question := qz.Questions[0]
+
+	// First attempt answers correctly
+	a1, err := store.StartAttempt(1, 7)
+	assert.NoError(t, err)
+	_, err = store.StartAttempt(1, 7)
+	assert.ErrorIs(t, err, quiz.ErrAttemptInProgress)
+	a1, err = store.SubmitAttempt(a1.ID, []quiz.Answer{{QuestionID: question.ID, ChoiceIDs: quiz.UintList{question.Choices[0].ID}}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, a1.Score)
+	_, err = store.SubmitAttempt(a1.ID, nil)
+	assert.ErrorIs(t, err, quiz.ErrAttemptSubmitted)
+
+	// Second attempt answers wrongly
+	a2, err := store.StartAttempt(1, 7)
+	assert.NoError(t, err)
+	a2, err = store.SubmitAttempt(a2.ID, []quiz.Answer{{QuestionID: question.ID, ChoiceIDs: quiz.UintList{question.Choices[1].ID}}})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, a2.Score)
+
+	found, err := store.FindAttemptByID(a2.ID)
+	assert.NoError(t, err)
+	assert.Len(t, found.Answers, 1)
+	assert.Equal(t, quiz.UintList{question.Choices[1].ID}, found.Answers[0].ChoiceIDs)
+
+	// The limit is reached, and the best attempt counts
+	_, err = store.StartAttempt(1, 7)
+	assert.ErrorIs(t, err, quiz.ErrAttemptLimitReached)
+	score, maxScore, ok, err := store.OfficialScore(1, 7)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, score)
+	assert.Equal(t, 1.0, maxScore)
+
+	// Other users are not affected
+	_, err = store.StartAttempt(1, 8)
+	assert.NoError(t, err)
+}
+
+func TestSQLiteStore_CheckAnswer(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+
+	q1 := quiz.NewQuiz()
+	q1.Name = "Checked as you go"
+	q1.RevealPolicy = quiz.RevealImmediately
 	q1.Questions = []quiz.Question{
-		{
-			Content: "Question 1",
-			Choices: []quiz.Choice{
-				{Content: "Choice 1"},
-				{Content: "Choice 2"},
-			},
-		},
+		{Type: quiz.TypeSingleChoice, Content: "Pick right", Choices: []quiz.Choice{{Content: "Right", IsCorrect: true}, {Content: "Wrong"}}},
+		{Type: quiz.TypeEssay, Content: "Explain"},
+	}
+	assert.NoError(t, store.Store(*q1))
+	qz, err := store.FindQuizByID(1)
+	assert.NoError(t, err)
+	choice, essay := qz.Questions[0], qz.Questions[1]
+	right, wrong := choice.Choices[0].ID, choice.Choices[1].ID
+
+	a, err := store.StartAttempt(1, 7)
+	assert.NoError(t, err)
+	_, err = store.CheckAnswer(a.ID, quiz.Answer{QuestionID: choice.ID})
+	assert.EqualError(t, err, "answer the question before checking it")
+	checked, err := store.CheckAnswer(a.ID, quiz.Answer{QuestionID: choice.ID, ChoiceIDs: quiz.UintList{wrong}})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, checked.Score)
+	_, err = store.CheckAnswer(a.ID, quiz.Answer{QuestionID: choice.ID, ChoiceIDs: quiz.UintList{right}})
+	assert.ErrorIs(t, err, quiz.ErrAnswerChecked)
+	_, err = store.CheckAnswer(a.ID, quiz.Answer{QuestionID: essay.ID, Text: "Because"})
+	assert.ErrorContains(t, err, "cannot be checked")
+
+	// The checked answer stands when the attempt is submitted.
+	a, err = store.SubmitAttempt(a.ID, []quiz.Answer{
+		{QuestionID: choice.ID, ChoiceIDs: quiz.UintList{right}},
+		{QuestionID: essay.ID, Text: "Because"},
+	})
+	assert.NoError(t, err)
+	found, err := store.FindAttemptByID(a.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, found.Answers, 2) {
+		assert.Equal(t, quiz.UintList{wrong}, found.Answers[0].ChoiceIDs)
+	}
+	assert.Equal(t, 0.0, found.Score)
+	_, err = store.CheckAnswer(a.ID, quiz.Answer{QuestionID: choice.ID, ChoiceIDs: quiz.UintList{right}})
+	assert.ErrorIs(t, err, quiz.ErrAttemptSubmitted)
+
+	assert.NoError(t, store.Store(quiz.Quiz{Name: "Revealed after submission", Questions: []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "Pick right", Choices: []quiz.Choice{{Content: "Right", IsCorrect: true}}},
+	}}))
+	a, err = store.StartAttempt(2, 7)
+	assert.NoError(t, err)
+	_, err = store.CheckAnswer(a.ID, quiz.Answer{QuestionID: 3, ChoiceIDs: quiz.UintList{3}})
+	assert.EqualError(t, err, "quiz 2 does not reveal answers before submission")
+}
+
+func TestSQLiteStore_SubmitAfterClose(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+
+	assert.NoError(t, store.Store(quiz.Quiz{Name: "Timed", Questions: []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "Pick right", Choices: []quiz.Choice{{Content: "Right", IsCorrect: true}}},
+	}}))
+	late, err := store.StartAttempt(1, 7)
+	assert.NoError(t, err)
+	inGrace, err := store.StartAttempt(1, 8)
+	assert.NoError(t, err)
+
+	// The quiz closes while both attempts are open
+	closed := time.Now().Add(-time.Minute)
+	assert.NoError(t, store.DB.Model(&quiz.Quiz{}).Where("id = ?", 1).Updates(map[string]interface{}{"closes_at": closed}).Error)
+	_, err = store.SubmitAttempt(late.ID, nil)
+	assert.ErrorIs(t, err, quiz.ErrQuizClosed)
+	found, err := store.FindAttemptByID(late.ID)
+	assert.NoError(t, err)
+	assert.False(t, found.Submitted())
+
+	// A grace period lets attempts started before the close be handed in
+	assert.NoError(t, store.DB.Model(&quiz.Quiz{}).Where("id = ?", 1).Update("submit_grace", 5*time.Minute).Error)
+	_, err = store.SubmitAttempt(inGrace.ID, nil)
+	assert.NoError(t, err)
+	_, err = store.StartAttempt(1, 9)
+	assert.ErrorIs(t, err, quiz.ErrQuizClosed)
+}
+
+func TestSQLiteStore_PaperAttempts(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+
+	q1 := quiz.NewQuiz()
+	q1.Name = "Paper exam"
+	q1.Questions = []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "Capital of France?", Choices: []quiz.Choice{{Content: "Paris", IsCorrect: true}, {Content: "Lyon"}}},
+		{Type: quiz.TypeEssay, Content: "Why?"},
+	}
+	assert.NoError(t, store.Store(*q1))
+	q, err := store.FindQuizByID(1)
+	assert.NoError(t, err)
+
+	printing, err := store.RecordPrinting(q)
+	assert.NoError(t, err)
+	again, err := store.RecordPrinting(q)
+	assert.NoError(t, err)
+	assert.Equal(t, printing.ID, again.ID, "an unchanged quiz reuses its print run")
+
+	// Sheets are graded against the quiz as printed, not as edited since.
+	question := q.Questions[0]
+	assert.NoError(t, store.DB.Model(&quiz.Choice{}).Where("id = ?", question.Choices[0].ID).Update("is_correct", false).Error)
+	found, err := store.FindPrinting(printing.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Paris", found.Quiz.Questions[0].Choices[0].Content)
+	answers := []quiz.Answer{{QuestionID: question.ID, ChoiceIDs: quiz.UintList{question.Choices[0].ID}}}
+	a, err := store.RecordPaperAttempt(found, "1234", answers, []string{"question 1: B faint"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, a.Score)
+	assert.Equal(t, 1.0, a.MaxScore, "the essay written on paper is not counted")
+	assert.True(t, a.Submitted())
+
+	stored, err := store.FindAttemptByID(a.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, printing.ID, stored.PrintingID)
+	assert.Equal(t, "1234", stored.StudentNumber)
+	assert.Equal(t, quiz.StringList{"question 1: B faint"}, stored.ScanReview)
+
+	_, err = store.RecordPaperAttempt(found, "1234", answers, nil)
+	assert.ErrorIs(t, err, quiz.ErrSheetRecorded)
+
+	q, err = store.FindQuizByID(1)
+	assert.NoError(t, err)
+	changed, err := store.RecordPrinting(q)
+	assert.NoError(t, err)
+	assert.NotEqual(t, printing.ID, changed.ID, "an edited quiz gets a new print run")
+}
+
+func TestSQLiteStore_TextAndNumericQuestions(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+
+	q1 := quiz.NewQuiz()
+	q1.Name = "Quiz with typed answers"
+	q1.Questions = []quiz.Question{
+		{Type: quiz.TypeShortText, Content: "Capital of France?", Text: &quiz.TextSpec{Accepted: []string{"Paris"}}},
+		{Type: quiz.TypeNumeric, Content: "g in m/s^2?", Numeric: &quiz.NumericSpec{Answer: 9.81, Tolerance: 0.01}},
+		{Type: quiz.TypeShortText, Content: "No answers"},
 	}
 	err := store.Store(*q1)
+	assert.Error(t, err)
+
+	q1.Questions = q1.Questions[:2]
+	err = store.Store(*q1)
 	assert.NoError(t, err)
 
-	// Export quizzes to a file
-	err = store.ExportQuizzes("test_export.json")
+	q, err := store.FindQuizByID(1)
 	assert.NoError(t, err)
+	assert.Equal(t, []string{"Paris"}, q.Questions[0].Text.Accepted)
+	assert.Nil(t, q.Questions[0].Numeric)
+	assert.Equal(t, 9.81, q.Questions[1].Numeric.Answer)
+	assert.Nil(t, q.Questions[1].Text)
+}
+
+func TestSQLiteStore_ReviewQueue(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
 
-	// Import quizzes from the file
-	err = store.ImportQuizzes("test_import.json")
+	q1 := quiz.NewQuiz()
+	q1.Name = "Quiz with Essay"
+	q1.OwnerID = 5
+	q1.Questions = []quiz.Question{{Type: quiz.TypeEssay, Content: "Discuss", Points: 10}}
+	err := store.Store(*q1)
+	assert.NoError(t, err)
+	qz, err := store.FindQuizByID(1)
 	assert.NoError(t, err)
 
-	// Verify the imported quizzes
-	quizzes, err := store.ListAllQuizzes()
+	a, err := store.StartAttempt(1, 7)
 	assert.NoError(t, err)
-	assert.Len(t, quizzes, 1)
-	assert.Equal(t, "Quiz 1", quizzes[0].Name)
-	assert.Len(t, quizzes[0].Questions, 1)
-	assert.Len(t, quizzes[0].Questions[0].Choices, 2)
+	a, err = store.SubmitAttempt(a.ID, []quiz.Answer{{QuestionID: qz.Questions[0].ID, Text: "My essay"}})
+	assert.NoError(t, err)
+	assert.True(t, a.PendingReview)
+
+	// Only the owner sees the answer in the queue
+	items, err := store.ListPendingReviews(6)
+	assert.NoError(t, err)
+	assert.Len(t, items, 0)
+	items, err = store.ListPendingReviews(5)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "Quiz with Essay", items[0].QuizName)
+	assert.Equal(t, "My essay", items[0].Answer.Text)
+
+	a, err = store.ReviewAnswer(items[0].Answer.ID, quiz.Review{GraderID: 5, Scores: []float64{8}, Comment: "Nice"})
+	assert.NoError(t, err)
+	assert.False(t, a.PendingReview)
+	assert.Equal(t, 8.0, a.Score)
+	_, err = store.ReviewAnswer(items[0].Answer.ID, quiz.Review{GraderID: 5, Scores: []float64{2}})
+	assert.ErrorIs(t, err, quiz.ErrAnswerReviewed, "a graded answer is not graded again")
+
+	items, err = store.ListPendingReviews(5)
+	assert.NoError(t, err)
+	assert.Len(t, items, 0)
+	score, _, ok, err := store.OfficialScore(1, 7)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 8.0, score)
+
+	// Anyone grades the answers of a quiz without an owner
+	q2 := quiz.Quiz{Name: "Ownerless", Questions: []quiz.Question{{Type: quiz.TypeEssay, Content: "Discuss"}}}
+	assert.NoError(t, store.Store(q2))
+	a, err = store.StartAttempt(2, 7)
+	assert.NoError(t, err)
+	_, err = store.SubmitAttempt(a.ID, []quiz.Answer{{QuestionID: 2, Text: "Another essay"}})
+	assert.NoError(t, err)
+	for _, graderID := range []uint{0, 5, 6} {
+		items, err = store.ListPendingReviews(graderID)
+		assert.NoError(t, err)
+		if assert.Len(t, items, 1) {
+			assert.Equal(t, "Ownerless", items[0].QuizName)
+		}
+	}
+}
+
+func TestSQLiteStore_SurveyReport(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+
+	q1 := quiz.NewQuiz()
+	q1.Name = "Survey"
+	q1.Survey = true
+	q1.Questions = []quiz.Question{{Type: quiz.TypeLikert, Content: "I liked the course"}}
+	err := store.Store(*q1)
+	assert.NoError(t, err)
+	qz, err := store.FindQuizByID(1)
+	assert.NoError(t, err)
+
+	for user, rating := range map[uint]int{1: 5, 2: 4} {
+		a, err := store.StartAttempt(1, user)
+		assert.NoError(t, err)
+		a, err = store.SubmitAttempt(a.ID, []quiz.Answer{{QuestionID: qz.Questions[0].ID, Rating: rating}})
+		assert.NoError(t, err)
+		assert.Equal(t, uint(0), a.UserID, "survey responses are anonymous")
+	}
+	_, err = store.StartAttempt(1, 3) // never submitted
+	assert.NoError(t, err)
+
+	report, err := store.QuizReport(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Attempts)
+	assert.Equal(t, []int{0, 0, 0, 1, 1}, report.Items[0].Counts)
+	assert.Equal(t, 4.5, report.Items[0].Mean)
+}
+
+func TestSQLiteStore_SurveyParticipation(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+
+	q1 := quiz.NewQuiz()
+	q1.Name = "Survey"
+	q1.Survey = true
+	q1.MaxAttempts = 1
+	q1.Questions = []quiz.Question{{Type: quiz.TypeLikert, Content: "I liked the course"}}
+	assert.NoError(t, store.Store(*q1))
+
+	a, err := store.StartAttempt(1, 7)
+	assert.NoError(t, err)
+	a, err = store.SubmitAttempt(a.ID, []quiz.Answer{{QuestionID: 1, Rating: 4}})
+	assert.NoError(t, err)
+	_, err = store.StartAttempt(1, 7)
+	assert.ErrorIs(t, err, quiz.ErrAttemptLimitReached, "the limit holds although the response is anonymous")
+	_, err = store.StartAttempt(1, 8)
+	assert.NoError(t, err)
+
+	stored, err := store.FindAttemptByID(a.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(0), stored.UserID)
+	day := stored.StartedAt.UTC()
+	assert.Equal(t, day.Truncate(24*time.Hour), day, "only the day is kept")
+	assert.Equal(t, day, stored.SubmittedAt.UTC())
+	var participations []quiz.Participation
+	assert.NoError(t, store.DB.Find(&participations).Error)
+	assert.Len(t, participations, 1)
+	assert.Equal(t, uint(7), participations[0].UserID)
+}
+
+func TestSQLiteStore_Media(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+	ctx := context.Background()
+	blobs := blob.NewFSStore(t.TempDir())
+	store.Blobs = blobs
+
+	for _, name := range []string{"Quiz 1", "Quiz 2"} {
+		q := quiz.NewQuiz()
+		q.Name = name
+		q.Questions = []quiz.Question{{Type: quiz.TypeSingleChoice, Content: "Pick", Choices: []quiz.Choice{{Content: "A", IsCorrect: true}}}}
+		assert.NoError(t, store.Store(*q))
+	}
+	q1, err := store.FindQuizByID(1)
+	assert.NoError(t, err)
+	question, choice := q1.Questions[0], q1.Questions[0].Choices[0]
+
+	assert.NoError(t, store.SetQuizCover(ctx, 1, []byte("cover")))
+	assert.NoError(t, store.SetQuestionImage(ctx, 1, question.ID, []byte("image"), "A diagram"))
+	assert.NoError(t, store.SetChoiceThumb(ctx, 1, choice.ID, []byte("image")))
+
+	// Questions and choices of another quiz are out of reach.
+	assert.ErrorIs(t, store.SetQuestionImage(ctx, 2, question.ID, []byte("x"), ""), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, store.SetChoiceThumb(ctx, 2, choice.ID, []byte("x")), gorm.ErrRecordNotFound)
+
+	q1, _ = store.FindQuizByID(1)
+	cover, err := store.Media(ctx, q1.CoverKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("cover"), cover)
+	assert.Equal(t, "A diagram", q1.Questions[0].ImageAlt)
+	imageKey := q1.Questions[0].ImageKey
+	assert.Equal(t, imageKey, q1.Questions[0].Choices[0].ThumbKey)
+
+	// The blob is shared until its last user lets go.
+	assert.NoError(t, store.SetQuestionImage(ctx, 1, question.ID, nil, ""))
+	_, err = store.Media(ctx, imageKey)
+	assert.NoError(t, err)
+	assert.NoError(t, store.SetChoiceThumb(ctx, 1, choice.ID, nil))
+	_, err = store.Media(ctx, imageKey)
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+	q1, _ = store.FindQuizByID(1)
+	assert.Empty(t, q1.Questions[0].ImageKey)
+}
+
+func TestSQLiteStore_MoveMediaToBlobs(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+	ctx := context.Background()
+
+	q := quiz.NewQuiz()
+	q.Name = "Quiz 1"
+	q.Questions = []quiz.Question{{Type: quiz.TypeSingleChoice, Content: "Pick", Choices: []quiz.Choice{{Content: "A"}, {Content: "B"}}}}
+	assert.NoError(t, store.Store(*q))
+	// Older versions kept thumbnails in the choice rows.
+	assert.NoError(t, store.DB.Exec("ALTER TABLE choices ADD COLUMN `thumb` blob").Error)
+	assert.NoError(t, store.DB.Exec("UPDATE choices SET thumb = ? WHERE content = 'A'", []byte("thumb")).Error)
+
+	assert.ErrorContains(t, store.MoveMediaToBlobs(ctx), "no blob store")
+	store.Blobs = blob.NewFSStore(t.TempDir())
+	assert.NoError(t, store.MoveMediaToBlobs(ctx))
+	assert.False(t, store.DB.Migrator().HasColumn(&quiz.Choice{}, "thumb"))
+	assert.NoError(t, store.MoveMediaToBlobs(ctx))
+
+	q1, err := store.FindQuizByID(1)
+	assert.NoError(t, err)
+	choices := q1.Questions[0].Choices
+	assert.NotEmpty(t, choices[0].ThumbKey)
+	assert.Empty(t, choices[1].ThumbKey)
+	thumb, err := store.Media(ctx, choices[0].ThumbKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("thumb"), thumb)
+}
+
+func TestSQLiteStore_QuestionMedia(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+	ctx := context.Background()
+	store.Blobs = blob.NewFSStore(t.TempDir())
+
+	q := quiz.NewQuiz()
+	q.Name = "Listening"
+	q.Questions = []quiz.Question{{Type: quiz.TypeShortText, Content: "What did you hear?", Text: &quiz.TextSpec{Accepted: []string{"hello"}}}}
+	assert.NoError(t, store.Store(*q))
+	q1, _ := store.FindQuizByID(1)
+	question := q1.Questions[0]
+
+	assert.NoError(t, store.SetQuestionMedia(ctx, 1, question.ID, []byte("ID3 audio"), "audio/mpeg"))
+	assert.Error(t, store.UpdateMediaSettings(1, question.ID, 2, "not captions", ""))
+	assert.NoError(t, store.UpdateMediaSettings(1, question.ID, 2, "WEBVTT\n\n00:00.000 --> 00:01.000\nHello", "Hello"))
+	q1, _ = store.FindQuizByID(1)
+	question = q1.Questions[0]
+	assert.True(t, question.HasMedia())
+	assert.False(t, question.IsVideo())
+	assert.Equal(t, 2, question.MaxPlays)
+	f, err := store.OpenMedia(ctx, question.MediaKey)
+	assert.NoError(t, err)
+	f.Close()
+
+	a, err := store.StartAttempt(1, 7)
+	assert.NoError(t, err)
+	start := time.Now()
+	assert.NoError(t, store.StartPlay(a.ID, &question, start))
+	// Requests soon after a play started belong to the same play.
+	assert.NoError(t, store.StartPlay(a.ID, &question, start.Add(time.Second)))
+	assert.NoError(t, store.StartPlay(a.ID, &question, start.Add(time.Minute)))
+	assert.ErrorIs(t, store.StartPlay(a.ID, &question, start.Add(2*time.Minute)), quiz.ErrPlayLimit)
+	a, _ = store.FindAttemptByID(a.ID)
+	assert.Equal(t, 0, a.PlaysLeft(&question))
+	assert.True(t, a.HasPlayed(&question))
+
+	assert.NoError(t, store.SetQuestionMedia(ctx, 1, question.ID, nil, "audio/mpeg"))
+	q1, _ = store.FindQuizByID(1)
+	assert.False(t, q1.Questions[0].HasMedia())
+	assert.Empty(t, q1.Questions[0].MediaType)
+	_, err = store.OpenMedia(ctx, question.MediaKey)
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+}
+
+func TestSQLiteStore_ExportImport(t *testing.T) {
+	store := setupStore(t)
+	ctx := context.Background()
+	store.Blobs = blob.NewFSStore(t.TempDir())
+
+	for _, name := range []string{"Quiz 1", "Quiz 2"} {
+		q := quiz.NewQuiz()
+		q.Name = name
+		q.Questions = []quiz.Question{{Type: quiz.TypeSingleChoice, Content: "Pick", Choices: []quiz.Choice{{Content: "A", IsCorrect: true}, {Content: "B"}}}}
+		assert.NoError(t, store.Store(*q))
+	}
+	assert.NoError(t, store.SetQuizCover(ctx, 2, []byte("cover")))
+	filename := filepath.Join(t.TempDir(), "export.json")
+	assert.NoError(t, store.ExportQuizzes(filename))
+	teardownStore(store)
+
+	// Import into an empty database with its own blob store.
+	store = setupStore(t)
+	defer teardownStore(store)
+	store.Blobs = blob.NewFSStore(t.TempDir())
+	report, err := store.ImportQuizzes(filename, quiz.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "created 2, 1 media", report.String())
+	q2, err := store.FindQuizByID(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "Quiz 2", q2.Name)
+	assert.Len(t, q2.Questions[0].Choices, 2)
+	cover, err := store.Media(ctx, q2.CoverKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("cover"), cover)
+
+	// The same file again is skipped by default.
+	report, err = store.ImportQuizzes(filename, quiz.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "skipped 2, 0 media", report.String())
+
+	// Older exports are bare lists of quizzes.
+	assert.NoError(t, os.WriteFile(filename, []byte(`[{"ID": 9, "name": "Legacy", "questions": [{"ID": 1, "type": "essay", "content": "Discuss"}]}]`), 0o644))
+	_, err = store.ImportQuizzes(filename, quiz.ImportOptions{})
+	assert.NoError(t, err)
+	legacy, err := store.FindQuizByID(9)
+	assert.NoError(t, err)
+	// Question 1 belongs to quiz 1, so the imported question gets a new ID.
+	assert.NotEqual(t, uint(1), legacy.Questions[0].ID)
+	q1, _ := store.FindQuizByID(1)
+	assert.Equal(t, "Pick", q1.Questions[0].Content)
+
+	assert.NoError(t, os.WriteFile(filename, []byte(`{"version": 2, "quizzes": []}`), 0o644))
+	_, err = store.ImportQuizzes(filename, quiz.ImportOptions{})
+	assert.EqualError(t, err, "export version 2 is not supported, this version reads up to 1")
+}
+
+func TestSQLiteStore_ImportModes(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+	ctx := context.Background()
+
+	q := quiz.NewQuiz()
+	q.Name = "Stored"
+	q.Questions = []quiz.Question{
+		{Type: quiz.TypeSingleChoice, Content: "Kept", Choices: []quiz.Choice{{Content: "A", IsCorrect: true}}},
+		{Type: quiz.TypeSingleChoice, Content: "Changed", Choices: []quiz.Choice{{Content: "A", IsCorrect: true}}},
+	}
+	assert.NoError(t, store.Store(*q))
+	exported := func() *quiz.Export {
+		e, err := store.Export(ctx, 1)
+		assert.NoError(t, err)
+		return e
+	}
+
+	e := exported()
+	e.Quizzes[0].Name = "Merged"
+	e.Quizzes[0].Questions = e.Quizzes[0].Questions[1:]
+	e.Quizzes[0].Questions[0].Content = "Replaced"
+	e.Quizzes[0].Questions[0].Choices = []quiz.Choice{{Content: "B", IsCorrect: true}}
+	e.Quizzes[0].Questions = append(e.Quizzes[0].Questions, quiz.Question{Type: quiz.TypeEssay, Content: "Added"})
+	report, err := store.Import(ctx, e, quiz.ImportOptions{Mode: quiz.ImportMerge, DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []quiz.ImportedQuiz{{ID: 1, Name: "Merged", Action: quiz.ImportMerged}}, report.Quizzes)
+	stored, _ := store.FindQuizByID(1)
+	assert.Equal(t, "Stored", stored.Name)
+	assert.Len(t, stored.Questions, 2)
+
+	_, err = store.Import(ctx, e, quiz.ImportOptions{Mode: quiz.ImportMerge})
+	assert.NoError(t, err)
+	stored, _ = store.FindQuizByID(1)
+	assert.Equal(t, "Merged", stored.Name)
+	var contents []string
+	for _, question := range stored.Questions {
+		contents = append(contents, question.Content)
+	}
+	assert.Equal(t, []string{"Kept", "Replaced", "Added"}, contents)
+	assert.Len(t, stored.Questions[1].Choices, 1)
+	assert.Equal(t, "B", stored.Questions[1].Choices[0].Content)
+
+	e = exported()
+	e.Quizzes[0].Name = "Overwritten"
+	e.Quizzes[0].Questions = []quiz.Question{{ID: 500, Type: quiz.TypeEssay, Content: "Only"}}
+	_, err = store.Import(ctx, e, quiz.ImportOptions{Mode: quiz.ImportOverwrite})
+	assert.NoError(t, err)
+	stored, _ = store.FindQuizByID(1)
+	assert.Equal(t, "Overwritten", stored.Name)
+	assert.Len(t, stored.Questions, 1)
+	assert.Equal(t, uint(500), stored.Questions[0].ID)
+	var choices int64
+	store.DB.Model(&quiz.Choice{}).Count(&choices)
+	assert.Zero(t, choices)
+
+	// Repeated IDs get new ones instead of overwriting each other.
+	e = &quiz.Export{Quizzes: []*quiz.Quiz{
+		{ID: 2, Name: "Repeats", Questions: []quiz.Question{{ID: 500, Type: quiz.TypeSingleChoice, Content: "Q",
+			Choices: []quiz.Choice{{ID: 700, Content: "A", IsCorrect: true}, {ID: 700, Content: "B"}}}}},
+	}}
+	_, err = store.Import(ctx, e, quiz.ImportOptions{})
+	assert.NoError(t, err)
+	repeats, _ := store.FindQuizByID(2)
+	assert.Len(t, repeats.Questions[0].Choices, 2)
+	stored, _ = store.FindQuizByID(1)
+	assert.Equal(t, "Only", stored.Questions[0].Content)
+
+	// A failure after the rows are written rolls all of them back.
+	cover := blob.ContentKey(quiz.ImagePrefix, []byte("cover"))
+	e = &quiz.Export{
+		Quizzes: []*quiz.Quiz{
+			{ID: 3, Name: "New", Questions: []quiz.Question{{Type: quiz.TypeEssay, Content: "Q"}}},
+			{ID: 4, Name: "Covered", CoverKey: cover, Questions: []quiz.Question{{Type: quiz.TypeEssay, Content: "Q"}}},
+		},
+		Media: map[string][]byte{cover: []byte("cover")},
+	}
+	_, err = store.Import(ctx, e, quiz.ImportOptions{})
+	assert.ErrorContains(t, err, "no blob store")
+	_, err = store.FindQuizByID(3)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// Media must be named after its content, so that an import cannot
+	// replace the blob of another quiz.
+	e.Media = map[string][]byte{cover: []byte("forged")}
+	_, err = store.Import(ctx, e, quiz.ImportOptions{})
+	assert.EqualError(t, err, fmt.Sprintf("media %q does not match its content", cover))
+}
+
+func TestSQLiteStore_ImportOverwriteAnswered(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+	ctx := context.Background()
+	store.Blobs = blob.NewFSStore(t.TempDir())
+
+	assert.NoError(t, store.Store(quiz.Quiz{Name: "Stored", Questions: []quiz.Question{
+		{Type: quiz.TypeShortText, Content: "Answered", Text: &quiz.TextSpec{Accepted: []string{"yes"}}},
+		{Type: quiz.TypeShortText, Content: "Unanswered", Text: &quiz.TextSpec{Accepted: []string{"yes"}}},
+	}}))
+	assert.NoError(t, store.SetQuestionImage(ctx, 1, 2, []byte("diagram"), "A diagram"))
+	a, err := store.StartAttempt(1, 7)
+	assert.NoError(t, err)
+	_, err = store.SubmitAttempt(a.ID, []quiz.Answer{{QuestionID: 1, Text: "yes"}})
+	assert.NoError(t, err)
+
+	e, err := store.Export(ctx, 1)
+	assert.NoError(t, err)
+	image := e.Quizzes[0].Questions[1].ImageKey
+	e.Quizzes[0].Questions = e.Quizzes[0].Questions[1:]
+	_, err = store.Import(ctx, e, quiz.ImportOptions{Mode: quiz.ImportOverwrite})
+	assert.EqualError(t, err, `importing "Stored": overwriting quiz 1 would delete question 1, which attempts answered; merge instead`)
+
+	// Keeping the answered question, the image the import drops is released.
+	e, err = store.Export(ctx, 1)
+	assert.NoError(t, err)
+	e.Quizzes[0].Questions[1].ImageKey = ""
+	_, err = store.Import(ctx, e, quiz.ImportOptions{Mode: quiz.ImportOverwrite})
+	assert.NoError(t, err)
+	_, err = store.Media(ctx, image)
+	assert.Error(t, err, "the unused image is deleted")
+}
+
+func TestSQLiteStore_Users(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+
+	ada, err := store.CreateUser(" Ada ", "ada@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "Ada", ada.Name)
+	assert.Len(t, ada.Token, 48)
+	grace, err := store.CreateUser("Grace", "")
+	assert.NoError(t, err)
+	assert.NotEqual(t, ada.Token, grace.Token)
+	_, err = store.CreateUser("Linus", "")
+	assert.NoError(t, err, "users without an email")
+
+	found, err := store.FindUserByToken(ada.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, ada.ID, found.ID)
+	_, err = store.FindUserByToken("nope")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	_, err = store.CreateUser("Ada Again", "ada@example.com")
+	assert.EqualError(t, err, "a user with the email ada@example.com already exists")
+	_, err = store.CreateUser("", "")
+	assert.EqualError(t, err, "user name cannot be empty")
+	_, err = store.CreateUser("Bob", "bob")
+	assert.EqualError(t, err, `invalid email address "bob"`)
+}
+
+func TestSQLiteStore_SessionSecret(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+
+	secret, err := store.SessionSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 64)
+	again, err := store.SessionSecret()
+	assert.NoError(t, err)
+	assert.Equal(t, secret, again, "kept once generated")
+}
+
+func TestSQLiteStore_Backup(t *testing.T) {
+	store := setupStore(t)
+	defer teardownStore(store)
+	assert.NoError(t, store.Store(quiz.Quiz{Name: "Kept", Questions: []quiz.Question{{Type: quiz.TypeEssay, Content: "Q"}}}))
+
+	filename := filepath.Join(t.TempDir(), "backup.db")
+	assert.NoError(t, store.Backup(filename))
+	assert.EqualError(t, store.Backup(filename), filename+" already exists")
+
+	copied, err := quiz.NewSQLiteStore(filename)
+	assert.NoError(t, err)
+	all, err := copied.ListAllQuizzes()
+	assert.NoError(t, err)
+	if assert.Len(t, all, 1) {
+		assert.Equal(t, "Kept", all[0].Name)
+	}
+	kept, err := copied.FindQuizByID(1)
+	assert.NoError(t, err)
+	assert.Len(t, kept.Questions, 1)
 }
-*/
@@ -0,0 +1,82 @@
+package quiz
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AnonymousIDs is the first user ID given to anonymous visitors. Accounts
+// are numbered from 1 and stay below it, so a visitor is never given the ID
+// of an account.
+const AnonymousIDs = 1 << 31
+
+// User is an account created by an administrator. Its ID is the user ID
+// quizzes and attempts belong to; a browser becomes the user by following
+// the sign-in link of its token.
+type User struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"not null"`
+	Email     string `gorm:"index"`
+	Token     string `gorm:"uniqueIndex" json:"-"`
+	CreatedAt time.Time
+}
+
+// CreateUser stores a new user with a fresh sign-in token.
+func (s *SQLiteStore) CreateUser(name, email string) (*User, error) {
+	name, email = strings.TrimSpace(name), strings.TrimSpace(email)
+	if name == "" {
+		return nil, fmt.Errorf("user name cannot be empty")
+	}
+	if email != "" && !strings.Contains(email, "@") {
+		return nil, fmt.Errorf("invalid email address %q", email)
+	}
+	var b [24]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	user := &User{Name: name, Email: email, Token: hex.EncodeToString(b[:])}
+	if email != "" {
+		var taken int64
+		if err := s.DB.Model(&User{}).Where("email = ?", email).Count(&taken).Error; err != nil {
+			return nil, err
+		}
+		if taken > 0 {
+			return nil, fmt.Errorf("a user with the email %s already exists", email)
+		}
+	}
+	if err := s.DB.Create(user).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// FindUserByToken returns the user with the sign-in token.
+func (s *SQLiteStore) FindUserByToken(token string) (*User, error) {
+	var user User
+	if err := s.DB.Where("token = ?", token).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Setting is a value the server keeps for itself in the database.
+type Setting struct {
+	Name  string `gorm:"primaryKey"`
+	Value string
+}
+
+// SessionSecret returns the secret that signs user cookies when none is
+// configured. It is generated on first use and kept, so cookies stay valid
+// across restarts.
+func (s *SQLiteStore) SessionSecret() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	setting := Setting{Name: "session_secret"}
+	err := s.DB.Where(&setting).Attrs(Setting{Value: hex.EncodeToString(b[:])}).FirstOrCreate(&setting).Error
+	return setting.Value, err
+}
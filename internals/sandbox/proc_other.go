@@ -0,0 +1,19 @@
+//go:build !unix
+
+package sandbox
+
+import (
+	"context"
+	"os/exec"
+)
+
+// isolate leaves the command as is; the context still kills the process on
+// timeout, but not the processes it spawned.
+func isolate(cmd *exec.Cmd) {}
+
+// limitedCommand runs the program without resource limits, which this
+// platform does not support. Where the platform cannot pass extra files
+// either, as on Windows, no test report arrives and every test fails.
+func (gr *GoRunner) limitedCommand(ctx context.Context, dir, name string, args ...string) *exec.Cmd {
+	return gr.command(ctx, dir, name, args...)
+}
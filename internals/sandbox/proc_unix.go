@@ -0,0 +1,42 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// isolate puts the command in its own process group and kills the whole
+// group when the context is done.
+func isolate(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// limitedCommand is like command with memory, CPU and file size limits
+// applied to the process where the platform supports them.
+func (gr *GoRunner) limitedCommand(ctx context.Context, dir, name string, args ...string) *exec.Cmd {
+	return gr.command(ctx, dir, "/bin/sh", append([]string{"-c", limitScript(gr.MemoryLimit, gr.CPUTime, int64(gr.MaxOutput)), name}, args...)...)
+}
+
+// limitScript sets the limits with the shell ulimit builtin before replacing
+// the shell with the test binary.
+func limitScript(memory int64, cpu time.Duration, fileSize int64) string {
+	script := ""
+	if memory > 0 {
+		script += fmt.Sprintf("ulimit -d %d; ", memory>>10)
+	}
+	if cpu > 0 {
+		script += fmt.Sprintf("ulimit -t %d; ", max(int64(cpu.Seconds()+0.5), 1))
+	}
+	if fileSize > 0 {
+		script += fmt.Sprintf("ulimit -f %d; ", (fileSize>>10)+1)
+	}
+	return script + `exec "$0" "$@"`
+}
@@ -0,0 +1,320 @@
+// Package sandbox grades code submissions by compiling them with the
+// question tests and running every test in a separate, resource-limited
+// process.
+//
+// The grade does not come from the test output, which the submission can
+// write to as well: a test passes only when its process exits with status
+// zero after a generated TestMain reports it passed over a file descriptor
+// of its own, tagged with a nonce that changes on every test. The nonce is
+// not compiled into the binary: a harness package, which initializes before
+// the submission, reads it from another file descriptor and closes it. The
+// sources are deleted before the tests run, in an empty directory, so the
+// submission cannot read the hidden tests.
+//
+// The limits keep a runaway or greedy submission from taking the server down;
+// they are not a security boundary against hostile code, which could still
+// read the nonce from the memory of its own process, so run the server as an
+// unprivileged user.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// GoRunner runs Go submissions with the local Go toolchain.
+type GoRunner struct {
+	GoBin    string // Defaults to "go" on the PATH
+	CacheDir string // Build cache shared between runs
+	// MemoryLimit and MaxOutput bound the test process memory and the output
+	// kept for the learner, in bytes.
+	MemoryLimit int64
+	MaxOutput   int
+	// CPUTime bounds the CPU seconds of the test process on top of the
+	// wall-clock timeout of the question.
+	CPUTime time.Duration
+}
+
+func NewGoRunner() *GoRunner {
+	return &GoRunner{
+		GoBin:       "go",
+		CacheDir:    filepath.Join(os.TempDir(), "go-quiz-sandbox-cache"),
+		MemoryLimit: 512 << 20,
+		MaxOutput:   64 << 10,
+		CPUTime:     10 * time.Second,
+	}
+}
+
+var _ quiz.CodeRunner = (*GoRunner)(nil)
+
+// harnessImport is the import path of the harness package in the scratch
+// module, which submissions may not import.
+const harnessImport = "submission/harness"
+
+// harness reads the nonce of the run from the file descriptor the runner
+// passes as its second extra file. The submission package imports it through
+// the generated TestMain, so it initializes first, before any code of the
+// submission runs.
+const harness = `package harness
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+var nonce string
+
+func init() {
+	f := os.NewFile(4, "nonce")
+	b, _ := io.ReadAll(io.LimitReader(f, 64))
+	f.Close()
+	nonce = string(b)
+}
+
+// Report writes the exit code and the test that ran, with the nonce, to the
+// file descriptor the runner passes as its first extra file.
+func Report(code int) {
+	report := os.NewFile(3, "report")
+	fmt.Fprintf(report, "%s %d %s\n", nonce, code, flag.Lookup("test.run").Value)
+	report.Close()
+}
+`
+
+// testMain is the TestMain compiled with the tests, in their package.
+const testMain = `package %s
+
+import (
+	"os"
+	"testing"
+
+	"submission/harness"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	harness.Report(code)
+	os.Exit(code)
+}
+`
+
+// Run compiles the submission with the tests in a scratch module and runs
+// every hidden test on its own, counting those that pass.
+func (gr *GoRunner) Run(ctx context.Context, spec *quiz.CodeSpec, source string) (*quiz.CodeResult, error) {
+	names, err := spec.TestNames()
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := spec.Package()
+	if err != nil {
+		return nil, err
+	}
+	result := &quiz.CodeResult{Total: len(names)}
+	if f, err := parser.ParseFile(token.NewFileSet(), "solution.go", source, parser.ImportsOnly); err == nil {
+		if f.Name.Name != pkg {
+			result.Output = fmt.Sprintf("Compilation failed: the submission must be in package %s, not %s.", pkg, f.Name.Name)
+			return result, nil
+		}
+		for _, spec := range f.Imports {
+			if path, _ := strconv.Unquote(spec.Path.Value); path == harnessImport {
+				result.Output = fmt.Sprintf("Compilation failed: the submission cannot import %s.", path)
+				return result, nil
+			}
+		}
+	}
+
+	root, err := os.MkdirTemp("", "go-quiz-run-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(root)
+	// The go command ignores a go.mod directly in the temp directory, which
+	// is root for the commands below.
+	dir := filepath.Join(root, "submission")
+	for _, d := range []string{dir, filepath.Join(dir, "harness"), filepath.Join(root, "bin"), filepath.Join(root, "run")} {
+		if err := os.Mkdir(d, 0o700); err != nil {
+			return nil, err
+		}
+	}
+	files := map[string]string{
+		"go.mod":             "module submission\n\ngo 1.22\n",
+		"solution.go":        source,
+		"solution_test.go":   spec.Tests,
+		"zz_harness_test.go": fmt.Sprintf(testMain, pkg),
+		"harness/harness.go": harness,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, spec.Timeout())
+	defer cancel()
+
+	// Compile first so build errors are reported as such and the toolchain
+	// does not count against the limits of the test process.
+	binary := filepath.Join(root, "bin", "submission.test")
+	out := newLimitedBuffer(gr.MaxOutput)
+	build := gr.command(ctx, dir, gr.goBin(), "test", "-c", "-o", binary, ".")
+	build.Env = gr.buildEnv(root)
+	build.Stdout, build.Stderr = out, out
+	if err := build.Run(); err != nil {
+		if ctx.Err() != nil {
+			result.Output = "Compilation timed out.\n" + out.String()
+			return result, nil
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("compiling submission: %w", err)
+		}
+		result.Output = "Compilation failed:\n" + out.String()
+		return result, nil
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+
+	out = newLimitedBuffer(gr.MaxOutput)
+	for _, name := range names {
+		passed, err := gr.runTest(ctx, filepath.Join(root, "run"), binary, name, out)
+		if err != nil {
+			return nil, err
+		}
+		if passed {
+			result.Passed++
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	result.Output = out.String()
+	if ctx.Err() != nil {
+		result.Output += "\nTests timed out after " + spec.Timeout().String() + "."
+	}
+	return result, nil
+}
+
+// runTest runs the named test alone in a new process and reports whether it
+// passed: the process must exit with status zero after the harness reported
+// the test, with the nonce it was given, as passed.
+func (gr *GoRunner) runTest(ctx context.Context, dir, binary, name string, out io.Writer) (bool, error) {
+	nonce, err := newNonce()
+	if err != nil {
+		return false, err
+	}
+	nonces, nonceWriter, err := os.Pipe()
+	if err != nil {
+		return false, err
+	}
+	defer nonces.Close()
+	_, err = io.WriteString(nonceWriter, nonce)
+	nonceWriter.Close()
+	if err != nil {
+		return false, err
+	}
+	reports, writer, err := os.Pipe()
+	if err != nil {
+		return false, err
+	}
+	defer reports.Close()
+	pattern := "^" + name + "$"
+	run := gr.limitedCommand(ctx, dir, binary, "-test.v", "-test.count=1", "-test.run", pattern)
+	run.Env = []string{"HOME=" + dir, "TMPDIR=" + dir, "GOMAXPROCS=2"}
+	run.Stdout, run.Stderr = out, out
+	run.ExtraFiles = []*os.File{writer, nonces}
+	runErr := run.Run()
+	writer.Close()
+
+	// A process the test started may still hold the pipe open.
+	reports.SetReadDeadline(time.Now().Add(time.Second))
+	report, _ := io.ReadAll(io.LimitReader(reports, 1<<10))
+	return runErr == nil && string(report) == fmt.Sprintf("%s 0 %s\n", nonce, pattern), nil
+}
+
+// newNonce returns a random token that the submission cannot guess.
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (gr *GoRunner) goBin() string {
+	if gr.GoBin == "" {
+		return "go"
+	}
+	return gr.GoBin
+}
+
+// buildEnv keeps the toolchain offline and away from the server environment.
+func (gr *GoRunner) buildEnv(dir string) []string {
+	cache := gr.CacheDir
+	if cache == "" {
+		cache = filepath.Join(dir, ".cache")
+	}
+	return []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + dir,
+		"TMPDIR=" + dir,
+		"GOPATH=" + filepath.Join(dir, ".gopath"),
+		"GOCACHE=" + cache,
+		"GOFLAGS=-mod=mod",
+		"GOPROXY=off",
+		"GOTOOLCHAIN=local",
+		"CGO_ENABLED=0",
+	}
+}
+
+// command starts the program in its own process group so a timeout kills
+// everything it spawned.
+func (gr *GoRunner) command(ctx context.Context, dir, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	isolate(cmd)
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// limitedBuffer keeps the first max bytes written to it and drops the rest.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func newLimitedBuffer(max int) *limitedBuffer {
+	return &limitedBuffer{max: max}
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	if lb.max > 0 && lb.buf.Len()+len(p) > lb.max {
+		lb.buf.Write(p[:lb.max-lb.buf.Len()])
+		lb.truncated = true
+		return len(p), nil
+	}
+	return lb.buf.Write(p)
+}
+
+func (lb *limitedBuffer) String() string {
+	if lb.truncated {
+		return lb.buf.String() + "\n[output truncated]"
+	}
+	return lb.buf.String()
+}
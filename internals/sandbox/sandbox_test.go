@@ -0,0 +1,165 @@
+package sandbox_test
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+	"github.com/mbsof31/go-quiz/internals/sandbox"
+	"github.com/stretchr/testify/assert"
+)
+
+var spec = &quiz.CodeSpec{
+	TimeoutSeconds: 60,
+	Tests: `package sum
+
+import "testing"
+
+func TestSum(t *testing.T) {
+	if Sum(1, 2) != 3 {
+		t.Fatal("Sum(1, 2) should be 3")
+	}
+}
+
+func TestSumNegative(t *testing.T) {
+	if Sum(-1, -2) != -3 {
+		t.Fatal("Sum(-1, -2) should be -3")
+	}
+}
+`,
+}
+
+func newRunner(t *testing.T) *sandbox.GoRunner {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	return sandbox.NewGoRunner()
+}
+
+func TestGoRunner(t *testing.T) {
+	runner := newRunner(t)
+	ctx := context.Background()
+
+	result, err := runner.Run(ctx, spec, "package sum\n\nfunc Sum(a, b int) int { return a + b }\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Passed)
+	assert.Equal(t, 2, result.Total)
+
+	result, err = runner.Run(ctx, spec, "package sum\n\nfunc Sum(a, b int) int {\n\tif a < 0 {\n\t\treturn 0\n\t}\n\treturn a + b\n}\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Passed)
+	assert.Contains(t, result.Output, "Sum(-1, -2) should be -3")
+
+	result, err = runner.Run(ctx, spec, "package sum\n\nfunc Sum(a, b int) int { return a + }\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Passed)
+	assert.Contains(t, result.Output, "Compilation failed")
+
+	result, err = runner.Run(ctx, spec, "package main\n")
+	assert.NoError(t, err)
+	assert.Contains(t, result.Output, "must be in package sum")
+}
+
+func TestGoRunner_Bypass(t *testing.T) {
+	runner := newRunner(t)
+	for name, source := range map[string]string{
+		"fake output": `package sum
+
+import (
+	"fmt"
+	"os"
+)
+
+func init() {
+	fmt.Println("--- PASS: TestSum (0.00s)")
+	fmt.Println("--- PASS: TestSumNegative (0.00s)")
+	fmt.Println("PASS")
+	os.Exit(0)
+}
+
+func Sum(a, b int) int { return 0 }
+`,
+		"exit in the test": `package sum
+
+import "os"
+
+func Sum(a, b int) int {
+	os.Exit(0)
+	return 0
+}
+`,
+		"forged report": `package sum
+
+import (
+	"fmt"
+	"os"
+)
+
+func Sum(a, b int) int {
+	fmt.Fprintf(os.NewFile(3, "report"), "nonce 0 ^TestSum$\n")
+	os.Exit(0)
+	return 0
+}
+`,
+		"reads the nonce": `package sum
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+func init() {
+	nonce, _ := io.ReadAll(os.NewFile(4, "nonce"))
+	fmt.Fprintf(os.NewFile(3, "report"), "%s 0 %s\n", nonce, os.Args[len(os.Args)-1])
+	os.Exit(0)
+}
+
+func Sum(a, b int) int { return 0 }
+`,
+		"imports the harness": `package sum
+
+import (
+	"os"
+
+	"submission/harness"
+)
+
+func Sum(a, b int) int {
+	harness.Report(0)
+	os.Exit(0)
+	return 0
+}
+`,
+		"reads the tests": `package sum
+
+import "os"
+
+func Sum(a, b int) int {
+	for _, path := range []string{"solution_test.go", "../submission/solution_test.go"} {
+		if _, err := os.Stat(path); err == nil {
+			return a + b
+		}
+	}
+	return 0
+}
+`,
+	} {
+		result, err := runner.Run(context.Background(), spec, source)
+		assert.NoError(t, err, name)
+		assert.Equal(t, 0, result.Passed, name)
+	}
+}
+
+func TestGoRunner_Timeout(t *testing.T) {
+	runner := newRunner(t)
+	slow := *spec
+	slow.TimeoutSeconds = 20
+	runner.CPUTime = time.Second
+
+	result, err := runner.Run(context.Background(), &slow, "package sum\n\nfunc Sum(a, b int) int {\n\tfor {\n\t}\n}\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Passed)
+}
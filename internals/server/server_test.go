@@ -0,0 +1,111 @@
+package server_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mbsof31/go-quiz/internals/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve runs srv on a free port of the loopback interface until the
+// returned cancel is called, and reports what Serve returned on done.
+func serve(t *testing.T, srv *http.Server, drain time.Duration) (addr string, cancel func(), done <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- server.Serve(ctx, srv, ln, drain) }()
+	return ln.Addr().String(), cancel, result
+}
+
+func TestServe_DrainsRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+	addr, cancel, done := serve(t, srv, 5*time.Second)
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr)
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{string(body), err}
+	}()
+	<-started
+	cancel()
+	select {
+	case err := <-done:
+		t.Fatalf("stopped with a request in flight: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	_, err := net.Dial("tcp", addr)
+	assert.Error(t, err, "no new connections while draining")
+
+	close(release)
+	resp := <-responses
+	require.NoError(t, resp.err)
+	assert.Equal(t, "done", resp.body)
+	assert.NoError(t, <-done)
+}
+
+func TestServe_DrainTimeout(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}
+	addr, cancel, done := serve(t, srv, 20*time.Millisecond)
+	go http.Get("http://" + addr)
+	<-started
+	cancel()
+	assert.ErrorIs(t, <-done, context.DeadlineExceeded)
+}
+
+func TestDevCertificate(t *testing.T) {
+	cert, err := server.DevCertificate()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"localhost"}, cert.Leaf.DNSNames)
+
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "secure")
+		}),
+		TLSConfig: server.TLSConfig(cert),
+	}
+	addr, cancel, done := serve(t, srv, time.Second)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, err := client.Get("https://" + addr)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "secure", string(body))
+	assert.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+
+	client.CloseIdleConnections()
+	cancel()
+	assert.NoError(t, <-done)
+}
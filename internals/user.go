@@ -0,0 +1,85 @@
+package internals
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+const userCookie = "quiz_uid"
+
+// UserMiddleware identifies the visitor with a long-lived cookie, signed
+// with the secret, so attempts can be attributed to someone. Cookies without
+// a valid signature are replaced by a new anonymous visitor. It must run
+// after StoreMiddleware.
+func UserMiddleware(secret string) func(http.Handler) http.Handler {
+	if secret == "" {
+		panic("internals: UserMiddleware needs a session secret")
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := GetAppContext(r)
+			ctx.sessionSecret = secret
+			var userID uint
+			if c, err := r.Cookie(userCookie); err == nil {
+				userID = verifyUser(c.Value, secret)
+			}
+			if userID == 0 {
+				userID = newUserID()
+				SetUser(w, r, userID)
+			}
+			ctx.UserID = userID
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SetUser makes the browser the user with the ID from the next request on.
+// It must run after UserMiddleware.
+func SetUser(w http.ResponseWriter, r *http.Request, userID uint) {
+	id := strconv.FormatUint(uint64(userID), 10)
+	http.SetCookie(w, &http.Cookie{
+		Name:     userCookie,
+		Value:    id + "." + signUser(id, GetAppContext(r).sessionSecret),
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// verifyUser returns the user ID of the cookie value, or zero when it is not
+// signed with the secret.
+func verifyUser(value, secret string) uint {
+	id, signature, signed := strings.Cut(value, ".")
+	if !signed || !hmac.Equal([]byte(signature), []byte(signUser(id, secret))) {
+		return 0
+	}
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint(userID)
+}
+
+func signUser(id, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(userCookie + "=" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newUserID returns a random ID from the anonymous range.
+func newUserID() uint {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return uint(quiz.AnonymousIDs | binary.BigEndian.Uint32(b[:])>>1)
+}
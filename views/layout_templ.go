@@ -102,6 +102,7 @@ func getNavigation() []NavItem {
 	return []NavItem{
 		{Href: "/", Label: "Home", Active: true},
 		{Href: "/quizzes", Label: "Quizzes", Active: false},
+		{Href: "/grading", Label: "Grading", Active: false},
 	}
 }
 
@@ -293,7 +294,7 @@ func Text(text string) templ.Component {
 		var templ_7745c5c3_Var11 string
 		templ_7745c5c3_Var11, templ_7745c5c3_Err = templ.JoinStringErrs(text)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `views/layout.templ`, Line: 124, Col: 6}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `views/layout.templ`, Line: 125, Col: 6}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var11))
 		if templ_7745c5c3_Err != nil {
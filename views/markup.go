@@ -0,0 +1,18 @@
+package views
+
+import (
+	"github.com/a-h/templ"
+	"github.com/mbsof31/go-quiz/internals/markup"
+)
+
+// Markdown renders quiz content written in Markdown with math as sanitised
+// HTML. Style it by placing it inside an element with the markdown class.
+func Markdown(src string) templ.Component {
+	return templ.Raw(markup.Render(src))
+}
+
+// MarkdownInline is Markdown for content shown within a line, such as a
+// question title or a choice label.
+func MarkdownInline(src string) templ.Component {
+	return templ.Raw(markup.RenderInline(src))
+}
@@ -0,0 +1,25 @@
+package views
+
+import (
+	"github.com/mbsof31/go-quiz/internals/media"
+	"github.com/mbsof31/go-quiz/internals/quiz"
+)
+
+// CoverURL returns the cover image URL of the quiz, falling back to the
+// stock image.
+func CoverURL(q *quiz.Quiz) string {
+	if url := media.URL(q.CoverKey); url != "" {
+		return url
+	}
+	return "/public/images/quizzes/1.png"
+}
+
+// QuestionImageURL returns "" when the question has no image.
+func QuestionImageURL(question quiz.Question) string {
+	return media.URL(question.ImageKey)
+}
+
+// ChoiceThumbURL returns "" when the choice has no thumbnail.
+func ChoiceThumbURL(choice quiz.Choice) string {
+	return media.URL(choice.ThumbKey)
+}